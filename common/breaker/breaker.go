@@ -0,0 +1,201 @@
+package breaker
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/go-redis/redis/v8"
+)
+
+//go:embed lua/allow.lua
+var allowScriptSource string
+
+//go:embed lua/record.lua
+var recordScriptSource string
+
+var (
+	allowScript  = redis.NewScript(allowScriptSource)
+	recordScript = redis.NewScript(recordScriptSource)
+)
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// Transition 一次请求结果引起的状态变化
+type Transition int
+
+const (
+	TransitionNone Transition = iota
+	TransitionOpened
+	TransitionClosed
+)
+
+type Config struct {
+	// 窗口内连续失败达到该次数后打开熔断
+	FailureThreshold int
+	WindowSeconds    int64
+	// 打开后经过该时间进入半开状态
+	CooldownSeconds int64
+	// 半开状态下探测请求的间隔
+	ProbeIntervalSeconds int64
+	// 半开状态下连续成功达到该次数后关闭熔断
+	HalfOpenSuccesses int
+}
+
+func (cfg Config) ttl() int64 {
+	return cfg.WindowSeconds + cfg.CooldownSeconds + 3600
+}
+
+// Allow 判断请求是否可以通过，半开状态下会占用一次探测机会
+func Allow(ctx context.Context, key string, cfg Config) bool {
+	if common.RedisEnabled {
+		result, err := allowScript.Run(ctx, common.RDB, []string{key}, cfg.CooldownSeconds, cfg.ProbeIntervalSeconds).Int()
+		if err != nil {
+			// Redis 异常时放行，避免熔断器本身导致不可用
+			common.SysError(fmt.Sprintf("circuit breaker allow failed: %v", err))
+			return true
+		}
+		return result == 1
+	}
+	return memoryAllow(key, cfg)
+}
+
+// Record 记录请求结果，返回引起的状态变化
+func Record(ctx context.Context, key string, success bool, cfg Config) Transition {
+	if common.RedisEnabled {
+		successArg := 0
+		if success {
+			successArg = 1
+		}
+		result, err := recordScript.Run(ctx, common.RDB, []string{key}, successArg, cfg.FailureThreshold,
+			cfg.WindowSeconds, cfg.HalfOpenSuccesses, cfg.ttl()).Int()
+		if err != nil {
+			common.SysError(fmt.Sprintf("circuit breaker record failed: %v", err))
+			return TransitionNone
+		}
+		return Transition(result)
+	}
+	return memoryRecord(key, success, cfg)
+}
+
+// GetState 查询当前状态，不改变状态
+func GetState(ctx context.Context, key string) State {
+	if common.RedisEnabled {
+		state, err := common.RDB.HGet(ctx, key, "state").Int()
+		if err != nil {
+			return StateClosed
+		}
+		return State(state)
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	if r, ok := memoryRecords[key]; ok {
+		return r.state
+	}
+	return StateClosed
+}
+
+type record struct {
+	state       State
+	failures    int
+	successes   int
+	windowStart int64
+	openedAt    int64
+	lastProbe   int64
+}
+
+var (
+	memoryRecords = make(map[string]*record)
+	memoryLock    sync.Mutex
+)
+
+func memoryAllow(key string, cfg Config) bool {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	r, ok := memoryRecords[key]
+	if !ok || r.state == StateClosed {
+		return true
+	}
+	now := time.Now().Unix()
+	if r.state == StateOpen {
+		if now-r.openedAt < cfg.CooldownSeconds {
+			return false
+		}
+		r.state = StateHalfOpen
+		r.successes = 0
+		r.lastProbe = now
+		return true
+	}
+	if now-r.lastProbe < cfg.ProbeIntervalSeconds {
+		return false
+	}
+	r.lastProbe = now
+	return true
+}
+
+func memoryRecord(key string, success bool, cfg Config) Transition {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	r, ok := memoryRecords[key]
+	now := time.Now().Unix()
+	if success {
+		if !ok {
+			return TransitionNone
+		}
+		switch r.state {
+		case StateHalfOpen:
+			r.successes++
+			if r.successes >= cfg.HalfOpenSuccesses {
+				delete(memoryRecords, key)
+				return TransitionClosed
+			}
+		case StateClosed:
+			delete(memoryRecords, key)
+		}
+		return TransitionNone
+	}
+
+	if !ok {
+		r = &record{}
+		memoryRecords[key] = r
+	}
+	switch r.state {
+	case StateHalfOpen:
+		r.state = StateOpen
+		r.openedAt = now
+		return TransitionOpened
+	case StateOpen:
+		return TransitionNone
+	}
+	if now-r.windowStart > cfg.WindowSeconds {
+		r.windowStart = now
+		r.failures = 0
+	}
+	r.failures++
+	if r.failures >= cfg.FailureThreshold {
+		r.state = StateOpen
+		r.openedAt = now
+		return TransitionOpened
+	}
+	return TransitionNone
+}
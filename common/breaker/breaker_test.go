@@ -0,0 +1,84 @@
+package breaker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+func TestMain(m *testing.M) {
+	common.RedisEnabled = false
+	m.Run()
+}
+
+// TestMemoryBreaker 测试内存熔断器的打开、半开探测与恢复
+func TestMemoryBreaker(t *testing.T) {
+	ctx := context.Background()
+	key := "test:memory_breaker"
+	cfg := Config{
+		FailureThreshold:     3,
+		WindowSeconds:        60,
+		CooldownSeconds:      0,
+		ProbeIntervalSeconds: 0,
+		HalfOpenSuccesses:    2,
+	}
+
+	// 成功会重置连续失败计数
+	Record(ctx, key, false, cfg)
+	Record(ctx, key, false, cfg)
+	Record(ctx, key, true, cfg)
+	if got := Record(ctx, key, false, cfg); got != TransitionNone {
+		t.Fatalf("expected no transition after reset, got %v", got)
+	}
+	Record(ctx, key, false, cfg)
+	if got := Record(ctx, key, false, cfg); got != TransitionOpened {
+		t.Fatalf("expected breaker to open, got %v", got)
+	}
+	if state := GetState(ctx, key); state != StateOpen {
+		t.Fatalf("expected open state, got %s", state)
+	}
+
+	// 冷却结束后进入半开状态，探测失败重新打开
+	if !Allow(ctx, key, cfg) {
+		t.Fatal("expected probe to be allowed after cooldown")
+	}
+	if state := GetState(ctx, key); state != StateHalfOpen {
+		t.Fatalf("expected half-open state, got %s", state)
+	}
+	if got := Record(ctx, key, false, cfg); got != TransitionOpened {
+		t.Fatalf("expected failed probe to reopen breaker, got %v", got)
+	}
+
+	// 探测连续成功后关闭
+	Allow(ctx, key, cfg)
+	if got := Record(ctx, key, true, cfg); got != TransitionNone {
+		t.Fatalf("expected breaker to stay half-open, got %v", got)
+	}
+	if got := Record(ctx, key, true, cfg); got != TransitionClosed {
+		t.Fatalf("expected breaker to close, got %v", got)
+	}
+	if state := GetState(ctx, key); state != StateClosed {
+		t.Fatalf("expected closed state, got %s", state)
+	}
+}
+
+// TestMemoryBreakerCooldown 测试冷却期内拒绝请求
+func TestMemoryBreakerCooldown(t *testing.T) {
+	ctx := context.Background()
+	key := "test:memory_breaker_cooldown"
+	cfg := Config{
+		FailureThreshold:     1,
+		WindowSeconds:        60,
+		CooldownSeconds:      3600,
+		ProbeIntervalSeconds: 0,
+		HalfOpenSuccesses:    1,
+	}
+	if !Allow(ctx, key, cfg) {
+		t.Fatal("expected closed breaker to allow")
+	}
+	Record(ctx, key, false, cfg)
+	if Allow(ctx, key, cfg) {
+		t.Fatal("expected open breaker to reject during cooldown")
+	}
+}
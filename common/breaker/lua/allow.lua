@@ -0,0 +1,35 @@
+-- 熔断器放行判断
+-- KEYS[1]: 熔断器唯一标识
+-- ARGV[1]: 熔断冷却时间（秒）
+-- ARGV[2]: 半开状态下探测请求的间隔（秒）
+-- 返回 1 放行，0 拒绝
+
+local key = KEYS[1]
+local cooldown = tonumber(ARGV[1])
+local probe_interval = tonumber(ARGV[2])
+
+local now = tonumber(redis.call('TIME')[1])
+local state = tonumber(redis.call('HGET', key, 'state') or '0')
+
+-- 关闭状态
+if state == 0 then
+    return 1
+end
+
+-- 打开状态，冷却结束后进入半开状态并放行第一个探测请求
+if state == 1 then
+    local opened_at = tonumber(redis.call('HGET', key, 'opened_at') or '0')
+    if now - opened_at < cooldown then
+        return 0
+    end
+    redis.call('HSET', key, 'state', 2, 'successes', 0, 'last_probe', now)
+    return 1
+end
+
+-- 半开状态，按间隔放行探测请求
+local last_probe = tonumber(redis.call('HGET', key, 'last_probe') or '0')
+if now - last_probe < probe_interval then
+    return 0
+end
+redis.call('HSET', key, 'last_probe', now)
+return 1
@@ -0,0 +1,58 @@
+-- 熔断器记录请求结果
+-- KEYS[1]: 熔断器唯一标识
+-- ARGV[1]: 本次请求是否成功 (1/0)
+-- ARGV[2]: 打开熔断所需的连续失败次数
+-- ARGV[3]: 失败计数窗口（秒）
+-- ARGV[4]: 半开状态下恢复所需的成功次数
+-- ARGV[5]: 过期时间（秒）
+-- 返回状态变化：0 无变化，1 打开，2 关闭
+
+local key = KEYS[1]
+local success = tonumber(ARGV[1]) == 1
+local threshold = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+local half_open_successes = tonumber(ARGV[4])
+local ttl = tonumber(ARGV[5])
+
+local now = tonumber(redis.call('TIME')[1])
+local state = tonumber(redis.call('HGET', key, 'state') or '0')
+
+if success then
+    if state == 2 then
+        local successes = redis.call('HINCRBY', key, 'successes', 1)
+        if successes >= half_open_successes then
+            redis.call('DEL', key)
+            return 2
+        end
+        return 0
+    end
+    -- 关闭状态下成功即重置连续失败计数
+    if state == 0 then
+        redis.call('DEL', key)
+    end
+    return 0
+end
+
+-- 半开状态下探测失败，重新打开
+if state == 2 then
+    redis.call('HSET', key, 'state', 1, 'opened_at', now)
+    redis.call('EXPIRE', key, ttl)
+    return 1
+end
+
+if state == 1 then
+    return 0
+end
+
+local window_start = tonumber(redis.call('HGET', key, 'window_start') or '0')
+if now - window_start > window then
+    redis.call('HSET', key, 'window_start', now, 'failures', 0)
+end
+local failures = redis.call('HINCRBY', key, 'failures', 1)
+if failures >= threshold then
+    redis.call('HSET', key, 'state', 1, 'opened_at', now)
+    redis.call('EXPIRE', key, ttl)
+    return 1
+end
+redis.call('EXPIRE', key, ttl)
+return 0
@@ -131,6 +131,8 @@ var CohereSafetySetting string
 
 const (
 	RequestIdKey = "X-Oneapi-Request-Id"
+	// ServedModelKey 响应头中实际提供服务的模型，发生模型降级时与请求的模型不同
+	ServedModelKey = "X-Oneapi-Served-Model"
 )
 
 const (
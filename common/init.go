@@ -135,6 +135,19 @@ func initConstantEnv() {
 	constant.ErrorLogEnabled = GetEnvOrDefaultBool("ERROR_LOG_ENABLED", false)
 	// 任务轮询时查询的最大数量
 	constant.TaskQueryLimit = GetEnvOrDefault("TASK_QUERY_LIMIT", 1000)
+	// Batch 单个输入文件允许的最大请求数
+	constant.BatchMaxRequests = GetEnvOrDefault("BATCH_MAX_REQUESTS", 50000)
+	// Batch 单个任务并发执行的请求数
+	constant.BatchConcurrency = GetEnvOrDefault("BATCH_CONCURRENCY", 4)
+	// Prometheus 指标，/metrics 需携带 METRICS_TOKEN 或来自 METRICS_ALLOWED_IPS（逗号分隔的 IP 或 CIDR），均未配置时仅允许本机访问
+	constant.MetricsEnabled = GetEnvOrDefaultBool("METRICS_ENABLED", false)
+	constant.MetricsToken = GetEnvOrDefaultString("METRICS_TOKEN", "")
+	constant.MetricsAllowedIps = nil
+	for _, ip := range strings.Split(GetEnvOrDefaultString("METRICS_ALLOWED_IPS", ""), ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			constant.MetricsAllowedIps = append(constant.MetricsAllowedIps, ip)
+		}
+	}
 
 	soraPatchStr := GetEnvOrDefaultString("TASK_PRICE_PATCH", "")
 	if soraPatchStr != "" {
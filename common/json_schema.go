@@ -0,0 +1,271 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateJSONSchema 按 JSON Schema 校验已解码的 JSON 值，仅支持结构化输出常用的关键字：
+// type、enum、const、properties、required、additionalProperties、items、minItems、maxItems、
+// minLength、maxLength、pattern、minimum、maximum、anyOf、oneOf、allOf 以及指向 $defs/definitions 的 $ref
+func ValidateJSONSchema(value any, schema map[string]any) error {
+	v := &jsonSchemaValidator{root: schema}
+	return v.validate("$", value, schema, 0)
+}
+
+type jsonSchemaValidator struct {
+	root map[string]any
+}
+
+// 防止循环引用导致无限递归
+const jsonSchemaMaxDepth = 64
+
+func (v *jsonSchemaValidator) validate(path string, value any, schema map[string]any, depth int) error {
+	if depth > jsonSchemaMaxDepth {
+		return fmt.Errorf("%s: schema nesting too deep", path)
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := v.resolveRef(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return v.validate(path, value, resolved, depth+1)
+	}
+
+	if t, ok := schema["type"]; ok {
+		if err := checkJSONSchemaType(path, value, t); err != nil {
+			return err
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		matched := false
+		for _, item := range enum {
+			if reflect.DeepEqual(value, item) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value is not one of the allowed enum values", path)
+		}
+	}
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(value, c) {
+		return fmt.Errorf("%s: value does not match const", path)
+	}
+
+	for _, sub := range jsonSchemaList(schema["allOf"]) {
+		if err := v.validate(path, value, sub, depth+1); err != nil {
+			return err
+		}
+	}
+	for _, key := range []string{"anyOf", "oneOf"} {
+		subs := jsonSchemaList(schema[key])
+		if len(subs) == 0 {
+			continue
+		}
+		var firstErr error
+		matched := false
+		for _, sub := range subs {
+			err := v.validate(path, value, sub, depth+1)
+			if err == nil {
+				matched = true
+				break
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value does not match any schema in %s (%v)", path, key, firstErr)
+		}
+	}
+
+	switch typed := value.(type) {
+	case map[string]any:
+		return v.validateObject(path, typed, schema, depth)
+	case []any:
+		return v.validateArray(path, typed, schema, depth)
+	case string:
+		if minLength, ok := jsonSchemaNumber(schema["minLength"]); ok && float64(utf8.RuneCountInString(typed)) < minLength {
+			return fmt.Errorf("%s: string is shorter than %v", path, minLength)
+		}
+		if maxLength, ok := jsonSchemaNumber(schema["maxLength"]); ok && float64(utf8.RuneCountInString(typed)) > maxLength {
+			return fmt.Errorf("%s: string is longer than %v", path, maxLength)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err == nil && !re.MatchString(typed) {
+				return fmt.Errorf("%s: string does not match pattern %q", path, pattern)
+			}
+		}
+	case float64:
+		if minimum, ok := jsonSchemaNumber(schema["minimum"]); ok && typed < minimum {
+			return fmt.Errorf("%s: number is less than %v", path, minimum)
+		}
+		if maximum, ok := jsonSchemaNumber(schema["maximum"]); ok && typed > maximum {
+			return fmt.Errorf("%s: number is greater than %v", path, maximum)
+		}
+	}
+	return nil
+}
+
+func (v *jsonSchemaValidator) validateObject(path string, value map[string]any, schema map[string]any, depth int) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, item := range required {
+			name, _ := item.(string)
+			if _, exists := value[name]; name != "" && !exists {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	for name, propValue := range value {
+		propPath := path + "." + name
+		if propSchema, ok := properties[name].(map[string]any); ok {
+			if err := v.validate(propPath, propValue, propSchema, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: additional property %q is not allowed", path, name)
+			}
+		case map[string]any:
+			if err := v.validate(propPath, propValue, additional, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *jsonSchemaValidator) validateArray(path string, value []any, schema map[string]any, depth int) error {
+	if minItems, ok := jsonSchemaNumber(schema["minItems"]); ok && float64(len(value)) < minItems {
+		return fmt.Errorf("%s: array has fewer than %v items", path, minItems)
+	}
+	if maxItems, ok := jsonSchemaNumber(schema["maxItems"]); ok && float64(len(value)) > maxItems {
+		return fmt.Errorf("%s: array has more than %v items", path, maxItems)
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range value {
+			if err := v.validate(fmt.Sprintf("%s[%d]", path, i), item, items, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *jsonSchemaValidator) resolveRef(ref string) (map[string]any, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	var current any = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		node, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		current = node[part]
+	}
+	resolved, ok := current.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %q", ref)
+	}
+	return resolved, nil
+}
+
+func checkJSONSchemaType(path string, value any, t any) error {
+	var types []string
+	switch typed := t.(type) {
+	case string:
+		types = []string{typed}
+	case []any:
+		for _, item := range typed {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+	default:
+		return nil
+	}
+	for _, name := range types {
+		if jsonValueIsType(value, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonValueTypeName(value))
+}
+
+func jsonValueIsType(value any, name string) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+func jsonValueTypeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func jsonSchemaList(value any) []map[string]any {
+	list, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	schemas := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		if schema, ok := item.(map[string]any); ok {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas
+}
+
+func jsonSchemaNumber(value any) (float64, bool) {
+	f, ok := value.(float64)
+	return f, ok
+}
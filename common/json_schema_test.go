@@ -0,0 +1,63 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestValidateJSONSchema(t *testing.T) {
+	schema := map[string]any{}
+	if err := Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"age": {"type": "integer", "minimum": 0},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+			"level": {"enum": ["low", "high"]},
+			"address": {"$ref": "#/$defs/address"},
+			"note": {"anyOf": [{"type": "string"}, {"type": "null"}]}
+		},
+		"required": ["name", "age"],
+		"additionalProperties": false,
+		"$defs": {
+			"address": {
+				"type": "object",
+				"properties": {"city": {"type": "string"}},
+				"required": ["city"]
+			}
+		}
+	}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "合法对象", input: `{"name":"a","age":3,"tags":["x"],"level":"low","address":{"city":"c"},"note":null}`},
+		{name: "缺少必填字段", input: `{"name":"a"}`, wantErr: true},
+		{name: "类型不匹配", input: `{"name":"a","age":"3"}`, wantErr: true},
+		{name: "整数类型不接受小数", input: `{"name":"a","age":3.5}`, wantErr: true},
+		{name: "不允许额外字段", input: `{"name":"a","age":3,"extra":1}`, wantErr: true},
+		{name: "数组元素类型", input: `{"name":"a","age":3,"tags":[1]}`, wantErr: true},
+		{name: "数组长度上限", input: `{"name":"a","age":3,"tags":["x","y","z"]}`, wantErr: true},
+		{name: "枚举值", input: `{"name":"a","age":3,"level":"mid"}`, wantErr: true},
+		{name: "引用定义", input: `{"name":"a","age":3,"address":{}}`, wantErr: true},
+		{name: "anyOf", input: `{"name":"a","age":3,"note":1}`, wantErr: true},
+		{name: "最小长度", input: `{"name":"","age":3}`, wantErr: true},
+		{name: "最小值", input: `{"name":"a","age":-1}`, wantErr: true},
+		{name: "非对象", input: `[]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value any
+			if err := Unmarshal([]byte(tt.input), &value); err != nil {
+				t.Fatal(err)
+			}
+			err := ValidateJSONSchema(value, schema)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJSONSchema(%s) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
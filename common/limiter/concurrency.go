@@ -0,0 +1,51 @@
+package limiter
+
+import "sync"
+
+// ConcurrencyLimiter 按 ID 限制同时进行的请求数，仅在当前节点内生效
+type ConcurrencyLimiter struct {
+	mu       sync.Mutex
+	inFlight map[int]int
+}
+
+func NewConcurrencyLimiter() *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{inFlight: make(map[int]int)}
+}
+
+// TryAcquire 在当前并发数小于 limit 时占用一个槽位，limit <= 0 表示不限制
+func (l *ConcurrencyLimiter) TryAcquire(id int, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit > 0 && l.inFlight[id] >= limit {
+		return false
+	}
+	l.inFlight[id]++
+	return true
+}
+
+// Release 释放一个槽位
+func (l *ConcurrencyLimiter) Release(id int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[id] <= 1 {
+		delete(l.inFlight, id)
+	} else {
+		l.inFlight[id]--
+	}
+}
+
+// Saturated 判断并发数是否已达到 limit
+func (l *ConcurrencyLimiter) Saturated(id int, limit int) bool {
+	if limit <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight[id] >= limit
+}
+
+func (l *ConcurrencyLimiter) InFlight(id int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight[id]
+}
@@ -0,0 +1,23 @@
+package limiter
+
+import "testing"
+
+func TestConcurrencyLimiter(t *testing.T) {
+	l := NewConcurrencyLimiter()
+	if !l.TryAcquire(1, 2) || !l.TryAcquire(1, 2) {
+		t.Fatal("should acquire up to the limit")
+	}
+	if l.TryAcquire(1, 2) || !l.Saturated(1, 2) {
+		t.Fatal("should reject once the limit is reached")
+	}
+	if !l.TryAcquire(2, 2) {
+		t.Fatal("limits are tracked per id")
+	}
+	l.Release(1)
+	if l.Saturated(1, 2) || l.InFlight(1) != 1 {
+		t.Fatalf("release should free a slot, in flight = %d", l.InFlight(1))
+	}
+	if !l.TryAcquire(1, 0) || l.Saturated(1, 0) {
+		t.Fatal("limit 0 means unlimited")
+	}
+}
@@ -0,0 +1,44 @@
+-- 按每分钟配额补充的令牌桶，用于 TPM/RPM 限流，支持预留与事后调整
+-- KEYS[1]: 限流器唯一标识
+-- ARGV[1]: 令牌数（调整模式下为增量，负数表示返还）
+-- ARGV[2]: 每分钟配额（桶容量）
+-- ARGV[3]: 模式：1 预留（不足时拒绝），0 调整（允许透支）
+-- 返回: {是否允许, 剩余令牌数}
+
+local key = KEYS[1]
+local requested = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local reserve = tonumber(ARGV[3]) == 1
+
+-- 获取当前时间（毫秒，Redis服务器时间）
+local now = redis.call('TIME')
+local nowMs = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+local rate = capacity / 60000
+
+local bucket = redis.call('HMGET', key, 'tokens', 'last_time')
+local tokens = tonumber(bucket[1])
+local last_time = tonumber(bucket[2])
+
+if not tokens or not last_time then
+    tokens = capacity
+else
+    tokens = math.min(capacity, tokens + (nowMs - last_time) * rate)
+end
+
+local allowed = 1
+if reserve then
+    -- 单次请求超过桶容量时，只要桶已满即放行，随后透支
+    if tokens >= math.min(requested, capacity) then
+        tokens = tokens - requested
+    else
+        allowed = 0
+    end
+else
+    tokens = math.min(capacity, tokens - requested)
+end
+
+redis.call('HMSET', key, 'tokens', tokens, 'last_time', nowMs)
+-- 桶恢复满额后即可过期
+redis.call('PEXPIRE', key, math.ceil((capacity - tokens) / rate) + 60000)
+
+return {allowed, math.floor(tokens)}
@@ -0,0 +1,131 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/go-redis/redis/v8"
+)
+
+//go:embed lua/minute_bucket.lua
+var minuteBucketScriptSource string
+
+var minuteBucketScript = redis.NewScript(minuteBucketScriptSource)
+
+// BucketResult 每分钟配额令牌桶的状态
+type BucketResult struct {
+	Allowed bool
+	// 每分钟配额
+	Limit int64
+	// 剩余令牌数，透支时为负数
+	Remaining int64
+	// 桶恢复满额所需时间
+	ResetAfter time.Duration
+	// 被拒绝时，满足本次请求所需的等待时间
+	RetryAfter time.Duration
+}
+
+func newBucketResult(allowed bool, limit int64, requested int64, remaining int64) BucketResult {
+	msPerToken := 60000 / float64(limit)
+	result := BucketResult{
+		Allowed:    allowed,
+		Limit:      limit,
+		Remaining:  remaining,
+		ResetAfter: time.Duration(math.Ceil(float64(limit-remaining)*msPerToken)) * time.Millisecond,
+	}
+	if !allowed {
+		need := min(requested, limit)
+		result.RetryAfter = time.Duration(math.Ceil(float64(need-remaining)*msPerToken)) * time.Millisecond
+	}
+	return result
+}
+
+// Reserve 从每分钟配额为 limitPerMinute 的令牌桶中预留 requested 个令牌，不足时拒绝
+func Reserve(ctx context.Context, key string, limitPerMinute int64, requested int64) (BucketResult, error) {
+	if common.RedisEnabled {
+		values, err := minuteBucketScript.Run(ctx, common.RDB, []string{key}, requested, limitPerMinute, 1).Int64Slice()
+		if err != nil {
+			return BucketResult{}, fmt.Errorf("reserve rate limit tokens failed: %w", err)
+		}
+		return newBucketResult(values[0] == 1, limitPerMinute, requested, values[1]), nil
+	}
+	allowed, remaining := memoryBuckets.take(key, limitPerMinute, requested, true)
+	return newBucketResult(allowed, limitPerMinute, requested, remaining), nil
+}
+
+// Adjust 按实际用量调整已预留的令牌，delta 为正表示追加扣减（允许透支），为负表示返还
+func Adjust(ctx context.Context, key string, limitPerMinute int64, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	if common.RedisEnabled {
+		if err := minuteBucketScript.Run(ctx, common.RDB, []string{key}, delta, limitPerMinute, 0).Err(); err != nil {
+			return fmt.Errorf("adjust rate limit tokens failed: %w", err)
+		}
+		return nil
+	}
+	memoryBuckets.take(key, limitPerMinute, delta, false)
+	return nil
+}
+
+type minuteBucket struct {
+	tokens   float64
+	lastTime time.Time
+}
+
+// minuteBucketStore 未启用 Redis 时使用的内存令牌桶
+type minuteBucketStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*minuteBucket
+	lastSweep time.Time
+}
+
+var memoryBuckets = &minuteBucketStore{buckets: make(map[string]*minuteBucket)}
+
+func (s *minuteBucketStore) take(key string, limit int64, requested int64, reserve bool) (bool, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+
+	capacity := float64(limit)
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &minuteBucket{tokens: capacity, lastTime: now}
+		s.buckets[key] = bucket
+	} else {
+		elapsed := now.Sub(bucket.lastTime).Minutes()
+		bucket.tokens = math.Min(capacity, bucket.tokens+elapsed*capacity)
+		bucket.lastTime = now
+	}
+
+	allowed := true
+	if reserve {
+		if bucket.tokens >= float64(min(requested, limit)) {
+			bucket.tokens -= float64(requested)
+		} else {
+			allowed = false
+		}
+	} else {
+		bucket.tokens = math.Min(capacity, bucket.tokens-float64(requested))
+	}
+	return allowed, int64(math.Floor(bucket.tokens))
+}
+
+// sweep 定期清理长时间未使用的令牌桶，调用方需持有锁
+func (s *minuteBucketStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.lastTime) > 10*time.Minute {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package limiter
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+func TestMain(m *testing.M) {
+	common.RedisEnabled = false
+	os.Exit(m.Run())
+}
+
+func TestReserveRejectsWhenExhausted(t *testing.T) {
+	ctx := context.Background()
+	key := "test:reserve"
+	result, err := Reserve(ctx, key, 100, 60)
+	if err != nil || !result.Allowed || result.Remaining != 40 {
+		t.Fatalf("first reserve = %+v, %v", result, err)
+	}
+	result, err = Reserve(ctx, key, 100, 60)
+	if err != nil || result.Allowed {
+		t.Fatalf("second reserve should be rejected, got %+v, %v", result, err)
+	}
+	if result.RetryAfter <= 0 || result.Remaining != 40 {
+		t.Fatalf("rejected reserve should keep tokens and report retry, got %+v", result)
+	}
+}
+
+func TestReserveAllowsOversizedRequestOnFullBucket(t *testing.T) {
+	result, _ := Reserve(context.Background(), "test:oversized", 100, 500)
+	if !result.Allowed || result.Remaining != -400 {
+		t.Fatalf("oversized request on full bucket should overdraft, got %+v", result)
+	}
+}
+
+func TestAdjustSettlesActualUsage(t *testing.T) {
+	ctx := context.Background()
+	key := "test:adjust"
+	_, _ = Reserve(ctx, key, 100, 50)
+	// 实际用量多于预留，追加扣减
+	_ = Adjust(ctx, key, 100, 30)
+	result, _ := Reserve(ctx, key, 100, 1)
+	if !result.Allowed || result.Remaining != 19 {
+		t.Fatalf("remaining after adjust = %+v", result)
+	}
+	// 返还不会超过桶容量
+	_ = Adjust(ctx, key, 100, -1000)
+	result, _ = Reserve(ctx, key, 100, 100)
+	if !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("refund should cap at capacity, got %+v", result)
+	}
+}
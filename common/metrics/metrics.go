@@ -0,0 +1,218 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "new_api"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	relayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "relay",
+		Name:      "requests_total",
+		Help:      "Total number of relay requests.",
+	}, []string{"relay_format", "model", "channel_id", "group", "status_code"})
+
+	relayDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "relay",
+		Name:      "request_duration_seconds",
+		Help:      "Relay request duration in seconds.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"relay_format", "model", "channel_id", "group", "status_code"})
+
+	relayFirstToken = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "relay",
+		Name:      "first_token_seconds",
+		Help:      "Time to first token of streaming relay requests in seconds.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20, 30, 60},
+	}, []string{"relay_format", "model", "channel_id", "group"})
+
+	relayTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "relay",
+		Name:      "tokens_total",
+		Help:      "Total number of billed tokens by type.",
+	}, []string{"model", "channel_id", "group", "type"})
+
+	relayOutputThroughput = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "relay",
+		Name:      "output_tokens_per_second",
+		Help:      "Upstream output token throughput per request.",
+		Buckets:   []float64{1, 5, 10, 20, 30, 50, 75, 100, 150, 200, 300, 500},
+	}, []string{"model", "channel_id"})
+
+	quotaConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "billing",
+		Name:      "quota_consumed_total",
+		Help:      "Total quota consumed.",
+	}, []string{"model", "channel_id", "group"})
+
+	preConsumeRefunds = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "billing",
+		Name:      "pre_consume_refunds_total",
+		Help:      "Total number of pre-consumed quota refunds.",
+	})
+
+	preConsumeRefundQuota = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "billing",
+		Name:      "pre_consume_refund_quota_total",
+		Help:      "Total pre-consumed quota refunded.",
+	})
+
+	channelAutoDisabled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "channel",
+		Name:      "auto_disabled_total",
+		Help:      "Total number of channel (or multi-key channel key) auto-disable events.",
+	}, []string{"channel_id"})
+
+	channelMultiKeyKeys = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "channel",
+		Name:      "multi_key_keys",
+		Help:      "Number of keys of multi-key channels by status.",
+	}, []string{"channel_id", "status"})
+
+	taskQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "task",
+		Name:      "queue_depth",
+		Help:      "Number of unfinished tasks waiting in the polling queues.",
+	}, []string{"queue", "platform"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		relayRequests,
+		relayDuration,
+		relayFirstToken,
+		relayTokens,
+		relayOutputThroughput,
+		quotaConsumed,
+		preConsumeRefunds,
+		preConsumeRefundQuota,
+		channelAutoDisabled,
+		channelMultiKeyKeys,
+		taskQueueDepth,
+	)
+}
+
+// Handler 返回 Prometheus 文本格式的指标输出
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RegisterGaugeFunc 注册一个在采集时计算取值的指标
+func RegisterGaugeFunc(subsystem string, name string, help string, fn func() float64) {
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
+
+// ObserveRelayRequest 记录一次转发请求的结果与耗时
+func ObserveRelayRequest(relayFormat string, model string, channelId int, group string, statusCode int, duration time.Duration) {
+	if !constant.MetricsEnabled {
+		return
+	}
+	labels := []string{relayFormat, model, strconv.Itoa(channelId), group, strconv.Itoa(statusCode)}
+	relayRequests.WithLabelValues(labels...).Inc()
+	relayDuration.WithLabelValues(labels...).Observe(duration.Seconds())
+}
+
+// ObserveFirstToken 记录流式请求的首字时间
+func ObserveFirstToken(relayFormat string, model string, channelId int, group string, firstToken time.Duration) {
+	if !constant.MetricsEnabled {
+		return
+	}
+	relayFirstToken.WithLabelValues(relayFormat, model, strconv.Itoa(channelId), group).Observe(firstToken.Seconds())
+}
+
+// ObserveOutputThroughput 记录上游输出速度，duration 为生成输出所用时间
+func ObserveOutputThroughput(model string, channelId int, completionTokens int, duration time.Duration) {
+	if !constant.MetricsEnabled || completionTokens <= 0 || duration <= 0 {
+		return
+	}
+	relayOutputThroughput.WithLabelValues(model, strconv.Itoa(channelId)).Observe(float64(completionTokens) / duration.Seconds())
+}
+
+// RecordConsume 记录一次计费的 Token 数与消耗额度
+func RecordConsume(model string, channelId int, group string, promptTokens int, completionTokens int, quota int) {
+	if !constant.MetricsEnabled {
+		return
+	}
+	channel := strconv.Itoa(channelId)
+	relayTokens.WithLabelValues(model, channel, group, "prompt").Add(float64(promptTokens))
+	relayTokens.WithLabelValues(model, channel, group, "completion").Add(float64(completionTokens))
+	if quota > 0 {
+		quotaConsumed.WithLabelValues(model, channel, group).Add(float64(quota))
+	}
+}
+
+// RecordPreConsumeRefund 记录一次预扣费返还
+func RecordPreConsumeRefund(quota int) {
+	if !constant.MetricsEnabled {
+		return
+	}
+	preConsumeRefunds.Inc()
+	preConsumeRefundQuota.Add(float64(quota))
+}
+
+// RecordChannelAutoDisabled 记录一次渠道自动禁用
+func RecordChannelAutoDisabled(channelId int) {
+	if !constant.MetricsEnabled {
+		return
+	}
+	channelAutoDisabled.WithLabelValues(strconv.Itoa(channelId)).Inc()
+}
+
+// MultiKeyStatus 多 Key 渠道中各状态的 Key 数量
+type MultiKeyStatus struct {
+	ChannelId        int
+	Enabled          int
+	ManuallyDisabled int
+	AutoDisabled     int
+}
+
+// SetChannelMultiKeyStatus 以最新的多 Key 渠道状态覆盖指标
+func SetChannelMultiKeyStatus(statuses []MultiKeyStatus) {
+	channelMultiKeyKeys.Reset()
+	for _, status := range statuses {
+		channel := strconv.Itoa(status.ChannelId)
+		channelMultiKeyKeys.WithLabelValues(channel, "enabled").Set(float64(status.Enabled))
+		channelMultiKeyKeys.WithLabelValues(channel, "manually_disabled").Set(float64(status.ManuallyDisabled))
+		channelMultiKeyKeys.WithLabelValues(channel, "auto_disabled").Set(float64(status.AutoDisabled))
+	}
+}
+
+// SetTaskQueueDepth 以最新一轮轮询的结果覆盖指定队列的深度，depths 为平台到未完成任务数的映射
+func SetTaskQueueDepth(queue string, depths map[string]int) {
+	if !constant.MetricsEnabled {
+		return
+	}
+	taskQueueDepth.DeletePartialMatch(prometheus.Labels{"queue": queue})
+	for platform, depth := range depths {
+		taskQueueDepth.WithLabelValues(queue, platform).Set(float64(depth))
+	}
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	pdfPageWidth  = 595.28 // A4
+	pdfPageHeight = 841.89
+	pdfMargin     = 50.0
+)
+
+// PDFDocument 简单的文本 PDF 生成器，用于导出账单等报表，按行从上到下排版，超出页面时自动分页。
+// 文本使用阅读器内置的 STSong-Light 字体（UniGB-UCS2-H 编码），无需嵌入字体即可显示中文
+type PDFDocument struct {
+	pages   []*bytes.Buffer
+	current *bytes.Buffer
+	y       float64
+}
+
+func NewPDFDocument() *PDFDocument {
+	doc := &PDFDocument{}
+	doc.newPage()
+	return doc
+}
+
+func (doc *PDFDocument) newPage() {
+	doc.current = &bytes.Buffer{}
+	doc.pages = append(doc.pages, doc.current)
+	doc.y = pdfPageHeight - pdfMargin
+}
+
+// pdfHexText 将文本编码为 UCS-2 大端序的十六进制字符串，超出基本平面的字符以 ? 代替
+func pdfHexText(text string) string {
+	buf := bytes.NewBufferString("<")
+	for _, r := range text {
+		if r > 0xFFFF {
+			r = '?'
+		}
+		fmt.Fprintf(buf, "%04X", r)
+	}
+	buf.WriteString(">")
+	return buf.String()
+}
+
+// AddLine 输出一行文本，texts 依次绘制在 columns 指定的横坐标（相对左边距）上，columns 为空时从左边距开始
+func (doc *PDFDocument) AddLine(fontSize float64, columns []float64, texts ...string) {
+	lineHeight := fontSize * 1.6
+	if doc.y-lineHeight < pdfMargin {
+		doc.newPage()
+	}
+	doc.y -= lineHeight
+	for i, text := range texts {
+		if text == "" {
+			continue
+		}
+		x := pdfMargin
+		if i < len(columns) {
+			x += columns[i]
+		}
+		fmt.Fprintf(doc.current, "BT /F1 %.1f Tf %.2f %.2f Td %s Tj ET\n", fontSize, x, doc.y, pdfHexText(text))
+	}
+}
+
+// AddSpace 插入空白，height 为高度
+func (doc *PDFDocument) AddSpace(height float64) {
+	doc.y -= height
+	if doc.y < pdfMargin {
+		doc.newPage()
+	}
+}
+
+// AddRule 绘制一条横跨页面的分隔线
+func (doc *PDFDocument) AddRule() {
+	doc.AddSpace(4)
+	fmt.Fprintf(doc.current, "0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfMargin, doc.y, pdfPageWidth-pdfMargin, doc.y)
+}
+
+// Bytes 生成完整的 PDF 文件内容
+func (doc *PDFDocument) Bytes() []byte {
+	out := &bytes.Buffer{}
+	offsets := make([]int, 0)
+	writeObject := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// 1: Catalog, 2: Pages, 3-5: 字体，之后每页依次为页面对象与内容流
+	const firstPageObject = 6
+	kids := &bytes.Buffer{}
+	for i := range doc.pages {
+		fmt.Fprintf(kids, "%d 0 R ", firstPageObject+i*2)
+	}
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(doc.pages)))
+	writeObject("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [4 0 R] >>")
+	writeObject("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>")
+	writeObject("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	for i, page := range doc.pages {
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, firstPageObject+i*2+1))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xrefOffset := out.Len()
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOffset)
+	return out.Bytes()
+}
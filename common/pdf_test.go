@@ -0,0 +1,58 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestPDFHexText(t *testing.T) {
+	if got := pdfHexText("A账单"); got != "<00418D265355>" {
+		t.Fatalf("unexpected hex text: %s", got)
+	}
+	if got := pdfHexText("😀"); got != "<003F>" {
+		t.Fatalf("characters outside BMP should be replaced: %s", got)
+	}
+}
+
+func TestPDFDocumentBytes(t *testing.T) {
+	doc := NewPDFDocument()
+	doc.AddLine(16, nil, "月度账单")
+	doc.AddRule()
+	for i := 0; i < 100; i++ {
+		doc.AddLine(10, []float64{0, 200}, fmt.Sprintf("gpt-4o-%d", i), "1.00")
+	}
+	data := doc.Bytes()
+
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatal("missing PDF header or trailer")
+	}
+	if len(doc.pages) < 2 {
+		t.Fatalf("expected automatic page break, got %d pages", len(doc.pages))
+	}
+	if !bytes.Contains(data, []byte(fmt.Sprintf("/Count %d", len(doc.pages)))) {
+		t.Fatal("page count mismatch")
+	}
+
+	// xref 中记录的偏移量必须指向对应的对象
+	match := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	if match == nil {
+		t.Fatal("missing startxref")
+	}
+	xrefOffset, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(data[xrefOffset:], []byte("xref\n")) {
+		t.Fatal("startxref does not point to xref table")
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xrefOffset:], -1)
+	if len(entries) != 5+len(doc.pages)*2 {
+		t.Fatalf("unexpected object count %d", len(entries))
+	}
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if !bytes.HasPrefix(data[offset:], []byte(fmt.Sprintf("%d 0 obj", i+1))) {
+			t.Fatalf("xref entry %d points to wrong offset", i+1)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var enabled bool
+
+var tracer = otel.Tracer("github.com/QuantumNous/new-api")
+
+var propagator = propagation.TraceContext{}
+
+// Start 初始化 OTLP Trace 导出，导出地址等参数使用 OpenTelemetry 标准环境变量（如 OTEL_EXPORTER_OTLP_ENDPOINT）
+func Start() error {
+	if !common.GetEnvOrDefaultBool("OTEL_TRACING_ENABLED", false) {
+		return nil
+	}
+	exporter, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		return err
+	}
+	res, err := resource.New(context.Background(),
+		resource.WithFromEnv(),
+		resource.WithAttributes(
+			attribute.String("service.name", common.GetEnvOrDefaultString("OTEL_SERVICE_NAME", "new-api")),
+			attribute.String("service.version", common.Version),
+		),
+	)
+	if err != nil {
+		return err
+	}
+	SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	))
+	common.SysLog("OpenTelemetry tracing enabled")
+	return nil
+}
+
+// SetTracerProvider 使用指定的 TracerProvider 开启追踪，测试中可传入导出到内存的 TracerProvider
+func SetTracerProvider(provider trace.TracerProvider) {
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	enabled = true
+}
+
+func Enabled() bool {
+	return enabled
+}
+
+// Extract 从请求头读取 W3C traceparent
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Inject 将当前 Span 以 W3C traceparent 写入上游请求头
+func Inject(ctx context.Context, header http.Header) {
+	if !enabled {
+		return
+	}
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// StartSpan 在 gin.Context 的请求上下文中开启子 Span，后续 Span 会挂在其下；
+// 返回的 end 函数结束 Span 并恢复父上下文，可重复调用
+func StartSpan(c *gin.Context, name string, attrs ...attribute.KeyValue) (trace.Span, func()) {
+	if !enabled || c.Request == nil {
+		return trace.SpanFromContext(context.Background()), func() {}
+	}
+	parent := c.Request.Context()
+	ctx, span := tracer.Start(parent, name, trace.WithAttributes(attrs...))
+	c.Request = c.Request.WithContext(ctx)
+	ended := false
+	return span, func() {
+		if ended {
+			return
+		}
+		ended = true
+		span.End()
+		c.Request = c.Request.WithContext(parent)
+	}
+}
+
+// RecordError 记录错误并将 Span 标记为失败
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var spanExporter = tracetest.NewInMemoryExporter()
+
+// installTracing 全局 Tracer 只会委托给第一次设置的 TracerProvider，测试共用同一个内存导出器
+var installTracing = sync.OnceFunc(func() {
+	SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter)))
+})
+
+func TestStartSpanRestoresParentOnEnd(t *testing.T) {
+	installTracing()
+	spanExporter.Reset()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	parent, endParent := StartSpan(c, "middleware.distribute")
+	for retry := 0; retry < 2; retry++ {
+		attempt, endAttempt := StartSpan(c, "relay.attempt")
+		header := http.Header{}
+		Inject(c.Request.Context(), header)
+		want := "00-" + attempt.SpanContext().TraceID().String() + "-" + attempt.SpanContext().SpanID().String() + "-01"
+		if header.Get("traceparent") != want {
+			t.Fatalf("traceparent = %q, want %q", header.Get("traceparent"), want)
+		}
+		// 重复调用 end 只结束一次，并恢复到父 Span 的上下文
+		endAttempt()
+		endAttempt()
+		if trace.SpanFromContext(c.Request.Context()).SpanContext().SpanID() != parent.SpanContext().SpanID() {
+			t.Fatalf("context was not restored to the parent span after retry %d", retry)
+		}
+	}
+	endParent()
+
+	spans := spanExporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("exported %d spans, want 3", len(spans))
+	}
+	for _, span := range spans[:2] {
+		if span.Name != "relay.attempt" || span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Fatalf("unexpected span %s with parent %s", span.Name, span.Parent.SpanID())
+		}
+	}
+}
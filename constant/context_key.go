@@ -7,8 +7,11 @@ const (
 	ContextKeyPromptTokens    ContextKey = "prompt_tokens"
 	ContextKeyEstimatedTokens ContextKey = "estimated_tokens"
 
-	ContextKeyOriginalModel    ContextKey = "original_model"
-	ContextKeyRequestStartTime ContextKey = "request_start_time"
+	ContextKeyOriginalModel     ContextKey = "original_model"
+	ContextKeyRequestStartTime  ContextKey = "request_start_time"
+	ContextKeyModelFallbackFrom ContextKey = "model_fallback_from" // 分发阶段因请求的模型不可用而降级时，记录原始请求的模型
+	ContextKeyVirtualModel      ContextKey = "virtual_model"       // 请求的虚拟模型名称
+	ContextKeyVirtualModelArm   ContextKey = "virtual_model_arm"   // 虚拟模型分配到的目标模型
 
 	/* token related keys */
 	ContextKeyTokenUnlimited         ContextKey = "token_unlimited_quota"
@@ -19,6 +22,13 @@ const (
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
+	ContextKeyTokenResponseCache     ContextKey = "token_response_cache"
+	ContextKeyTokenHedgeEnabled      ContextKey = "token_hedge_enabled"
+	ContextKeyTokenTpmLimit          ContextKey = "token_tpm_limit"
+	ContextKeyTokenRpmLimit          ContextKey = "token_rpm_limit"
+	ContextKeyTokenModelFallback     ContextKey = "token_model_fallback"
+	ContextKeyTokenDailyBudget       ContextKey = "token_daily_budget"
+	ContextKeyTokenMonthlyBudget     ContextKey = "token_monthly_budget"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -30,6 +40,7 @@ const (
 	ContextKeyChannelOtherSetting      ContextKey = "channel_other_setting"
 	ContextKeyChannelParamOverride     ContextKey = "param_override"
 	ContextKeyChannelHeaderOverride    ContextKey = "header_override"
+	ContextKeyChannelTransformScript   ContextKey = "channel_transform_script"
 	ContextKeyChannelOrganization      ContextKey = "channel_organization"
 	ContextKeyChannelAutoBan           ContextKey = "auto_ban"
 	ContextKeyChannelModelMapping      ContextKey = "model_mapping"
@@ -52,6 +63,11 @@ const (
 	ContextKeyUsingGroup  ContextKey = "group"
 	ContextKeyUserName    ContextKey = "username"
 
+	/* organization related keys */
+	ContextKeyOrganizationId           ContextKey = "organization_id"
+	ContextKeyOrganizationMemberId     ContextKey = "organization_member_id"
+	ContextKeyOrganizationMonthlyLimit ContextKey = "organization_monthly_limit"
+
 	ContextKeyLocalCountTokens ContextKey = "local_count_tokens"
 
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
@@ -17,6 +17,11 @@ var NotificationLimitDurationMinute int
 var GenerateDefaultToken bool
 var ErrorLogEnabled bool
 var TaskQueryLimit int
+var BatchMaxRequests int
+var BatchConcurrency int
+var MetricsEnabled bool
+var MetricsToken string
+var MetricsAllowedIps []string
 
 // temporary variable for sora patch, will be removed in future
 var TaskPricePatches []string
@@ -0,0 +1,496 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/metrics"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const batchCompletionWindow = "24h"
+
+// batchRateLimitRetryInterval 单行请求被限流时等待后重试的间隔
+const batchRateLimitRetryInterval = 10 * time.Second
+
+// batchEndpoints Batch 支持的端点及其对应的中继格式
+var batchEndpoints = map[string]types.RelayFormat{
+	"/v1/chat/completions": types.RelayFormatOpenAI,
+	"/v1/completions":      types.RelayFormatOpenAI,
+	"/v1/embeddings":       types.RelayFormatEmbedding,
+}
+
+// batchRelayEngine 执行 Batch 请求的内部路由，与对外的中继路由使用同一套鉴权、模型请求限流、选渠道与计费链路
+var batchRelayEngine = sync.OnceValue(func() *gin.Engine {
+	engine := gin.New()
+	engine.Use(middleware.RequestId(), middleware.TokenAuth(), middleware.ModelRequestRateLimit(), middleware.Distribute())
+	for endpoint, relayFormat := range batchEndpoints {
+		engine.POST(endpoint, func(c *gin.Context) {
+			Relay(c, relayFormat)
+		})
+	}
+	return engine
+})
+
+var runningBatches sync.Map
+
+// CreateBatch docs: https://platform.openai.com/docs/api-reference/batch/create
+func CreateBatch(c *gin.Context) {
+	var req dto.BatchCreateRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		openAIResourceError(c, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if _, ok := batchEndpoints[req.Endpoint]; !ok {
+		openAIResourceError(c, http.StatusBadRequest, fmt.Sprintf("unsupported endpoint: %q", req.Endpoint))
+		return
+	}
+	if req.CompletionWindow != batchCompletionWindow {
+		openAIResourceError(c, http.StatusBadRequest, fmt.Sprintf("unsupported completion_window: %q, only %q is supported", req.CompletionWindow, batchCompletionWindow))
+		return
+	}
+	userId := c.GetInt("id")
+	inputFile, err := model.GetUserFile(userId, req.InputFileId, false)
+	if err != nil {
+		openAIResourceDBError(c, err, fmt.Sprintf("No such File object: %s", req.InputFileId))
+		return
+	}
+	if inputFile.Purpose != dto.FilePurposeBatch {
+		openAIResourceError(c, http.StatusBadRequest, fmt.Sprintf("file %s is not a batch input file", req.InputFileId))
+		return
+	}
+
+	now := common.GetTimestamp()
+	batch := &model.Batch{
+		UserId:           userId,
+		TokenId:          c.GetInt("token_id"),
+		ClientIp:         c.ClientIP(),
+		Endpoint:         req.Endpoint,
+		InputFileId:      req.InputFileId,
+		CompletionWindow: req.CompletionWindow,
+		Status:           dto.BatchStatusValidating,
+		CreatedAt:        now,
+		ExpiresAt:        now + int64((24 * time.Hour).Seconds()),
+	}
+	if len(req.Metadata) > 0 {
+		metadata, _ := common.Marshal(req.Metadata)
+		batch.Metadata = string(metadata)
+	}
+	if err = batch.Insert(); err != nil {
+		logger.LogError(c, "failed to create batch: "+err.Error())
+		openAIResourceError(c, http.StatusInternalServerError, "failed to create batch")
+		return
+	}
+	c.JSON(http.StatusOK, batch.ToOpenAIBatch())
+}
+
+func RetrieveBatch(c *gin.Context) {
+	batch, err := model.GetUserBatch(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		openAIResourceDBError(c, err, fmt.Sprintf("No such Batch object: %s", c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, batch.ToOpenAIBatch())
+}
+
+func CancelBatch(c *gin.Context) {
+	batch, err := model.CancelUserBatch(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		openAIResourceDBError(c, err, fmt.Sprintf("No such Batch object: %s", c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, batch.ToOpenAIBatch())
+}
+
+func ListBatches(c *gin.Context) {
+	limit := getListLimit(c, 20, 100)
+	batches, err := model.GetUserBatches(c.GetInt("id"), c.Query("after"), limit+1)
+	if err != nil {
+		openAIResourceDBError(c, err, "cursor batch not found")
+		return
+	}
+	resp := dto.OpenAIBatchList{
+		Object: "list",
+		Data:   make([]*dto.OpenAIBatch, 0, len(batches)),
+	}
+	if len(batches) > limit {
+		resp.HasMore = true
+		batches = batches[:limit]
+	}
+	for _, batch := range batches {
+		resp.Data = append(resp.Data, batch.ToOpenAIBatch())
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstId = resp.Data[0].Id
+		resp.LastId = resp.Data[len(resp.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateBatchBulk 后台轮询未完成的 Batch 并执行
+func UpdateBatchBulk() {
+	for {
+		time.Sleep(time.Duration(10) * time.Second)
+		batches := model.GetAllUnFinishBatches(constant.TaskQueryLimit)
+		metrics.SetTaskQueueDepth("batch", map[string]int{"openai": len(batches)})
+		for _, batch := range batches {
+			if _, running := runningBatches.LoadOrStore(batch.Id, true); running {
+				continue
+			}
+			gopool.Go(func() {
+				defer runningBatches.Delete(batch.Id)
+				processBatch(batch)
+			})
+		}
+	}
+}
+
+type batchInputLine struct {
+	Line  int
+	Input dto.BatchRequestInput
+}
+
+// parseBatchInput 解析并校验输入文件，任何一行不合法都会导致整个 Batch 失败
+func parseBatchInput(content []byte, endpoint string) ([]batchInputLine, []dto.BatchError) {
+	var lines []batchInputLine
+	var errs []dto.BatchError
+	customIds := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), len(content)+1)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var input dto.BatchRequestInput
+		if err := common.Unmarshal(raw, &input); err != nil {
+			errs = append(errs, dto.BatchError{Code: "invalid_json_line", Message: err.Error(), Line: lineNo})
+			continue
+		}
+		if input.CustomId == "" {
+			errs = append(errs, dto.BatchError{Code: "missing_required_parameter", Message: "custom_id is required", Param: "custom_id", Line: lineNo})
+			continue
+		}
+		if customIds[input.CustomId] {
+			errs = append(errs, dto.BatchError{Code: "duplicate_custom_id", Message: fmt.Sprintf("custom_id %q is duplicated", input.CustomId), Param: "custom_id", Line: lineNo})
+			continue
+		}
+		customIds[input.CustomId] = true
+		if input.Method != http.MethodPost {
+			errs = append(errs, dto.BatchError{Code: "invalid_method", Message: "method must be POST", Param: "method", Line: lineNo})
+			continue
+		}
+		if input.Url != endpoint {
+			errs = append(errs, dto.BatchError{Code: "mismatched_endpoint", Message: fmt.Sprintf("url %q does not match the batch endpoint %q", input.Url, endpoint), Param: "url", Line: lineNo})
+			continue
+		}
+		var body map[string]any
+		if err := common.Unmarshal(input.Body, &body); err != nil {
+			errs = append(errs, dto.BatchError{Code: "invalid_request", Message: "body must be a JSON object", Param: "body", Line: lineNo})
+			continue
+		}
+		if stream, _ := body["stream"].(bool); stream {
+			errs = append(errs, dto.BatchError{Code: "invalid_request", Message: "streaming is not supported in batch requests", Param: "body.stream", Line: lineNo})
+			continue
+		}
+		lines = append(lines, batchInputLine{Line: lineNo, Input: input})
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, dto.BatchError{Code: "invalid_file", Message: err.Error()})
+	}
+	if len(lines) == 0 && len(errs) == 0 {
+		errs = append(errs, dto.BatchError{Code: "empty_file", Message: "input file contains no requests"})
+	}
+	if len(lines) > constant.BatchMaxRequests {
+		errs = append(errs, dto.BatchError{Code: "too_many_requests", Message: fmt.Sprintf("input file contains %d requests, exceeds the limit of %d", len(lines), constant.BatchMaxRequests)})
+	}
+	return lines, errs
+}
+
+func failBatch(ctx context.Context, batch *model.Batch, errs []dto.BatchError) {
+	batch.FailedAt = common.GetTimestamp()
+	batch.SetErrors(errs)
+	updated, err := batch.UpdateStatus(batch.Status, map[string]any{
+		"status":    dto.BatchStatusFailed,
+		"failed_at": batch.FailedAt,
+		"errors":    batch.Errors,
+	})
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("batch %s update failed: %s", batch.BatchId, err.Error()))
+	} else if !updated {
+		logger.LogInfo(ctx, fmt.Sprintf("batch %s status changed concurrently, skip failing", batch.BatchId))
+	}
+}
+
+func processBatch(batch *model.Batch) {
+	ctx := context.Background()
+	now := common.GetTimestamp()
+	switch {
+	case batch.Status == dto.BatchStatusCancelling:
+		finalizeBatch(ctx, batch, dto.BatchStatusCancelled)
+		return
+	case batch.Status == dto.BatchStatusFinalizing:
+		finalizeBatch(ctx, batch, dto.BatchStatusCompleted)
+		return
+	case batch.ExpiresAt > 0 && now > batch.ExpiresAt:
+		finalizeBatch(ctx, batch, dto.BatchStatusExpired)
+		return
+	}
+
+	inputFile, err := model.GetUserFile(batch.UserId, batch.InputFileId, true)
+	if err != nil {
+		failBatch(ctx, batch, []dto.BatchError{{Code: "input_file_not_found", Message: fmt.Sprintf("input file %s not found", batch.InputFileId)}})
+		return
+	}
+	lines, errs := parseBatchInput(inputFile.Content, batch.Endpoint)
+	if len(errs) > 0 {
+		failBatch(ctx, batch, errs)
+		return
+	}
+	token, err := model.GetTokenById(batch.TokenId)
+	if err != nil || token.UserId != batch.UserId {
+		failBatch(ctx, batch, []dto.BatchError{{Code: "invalid_token", Message: "the token used to create this batch is no longer available"}})
+		return
+	}
+
+	if batch.Status == dto.BatchStatusValidating {
+		batch.InProgressAt = now
+		batch.TotalCount = len(lines)
+		updated, err := batch.UpdateStatus(dto.BatchStatusValidating, map[string]any{
+			"status":         dto.BatchStatusInProgress,
+			"in_progress_at": batch.InProgressAt,
+			"total_count":    batch.TotalCount,
+		})
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("batch %s update failed: %s", batch.BatchId, err.Error()))
+			return
+		}
+		if !updated {
+			// 已被取消，由下一轮处理
+			return
+		}
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("batch %s started, %d requests", batch.BatchId, len(lines)))
+
+	done, err := model.GetBatchItemLines(batch.BatchId)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("batch %s query items failed: %s", batch.BatchId, err.Error()))
+		return
+	}
+
+	concurrency := constant.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	finalStatus := dto.BatchStatusCompleted
+	for i, line := range lines {
+		if done[line.Line] {
+			continue
+		}
+		if i%10 == 0 {
+			if status, err := model.GetBatchStatus(batch.Id); err == nil && status == dto.BatchStatusCancelling {
+				finalStatus = dto.BatchStatusCancelled
+				break
+			}
+		}
+		if common.GetTimestamp() > batch.ExpiresAt {
+			finalStatus = dto.BatchStatusExpired
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		gopool.Go(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			item := executeBatchLine(batch, token.Key, line)
+			// 被限流时占用并发名额等待后重试，直到 Batch 过期，避免 Batch 绕过限流或整批失败
+			for item.StatusCode == http.StatusTooManyRequests && canRetryBatchLine(batch) {
+				time.Sleep(batchRateLimitRetryInterval)
+				item = executeBatchLine(batch, token.Key, line)
+			}
+			if err := model.InsertBatchItem(item); err != nil {
+				logger.LogError(ctx, fmt.Sprintf("batch %s save line %d failed: %s", batch.BatchId, line.Line, err.Error()))
+				return
+			}
+			if err := model.IncreaseBatchCounts(batch.Id, item.Success); err != nil {
+				logger.LogError(ctx, fmt.Sprintf("batch %s update counts failed: %s", batch.BatchId, err.Error()))
+			}
+		})
+	}
+	wg.Wait()
+
+	if finalStatus == dto.BatchStatusCompleted {
+		// 执行期间被取消时，以取消为准
+		if status, err := model.GetBatchStatus(batch.Id); err == nil && status == dto.BatchStatusCancelling {
+			finalStatus = dto.BatchStatusCancelled
+		}
+	}
+	finalizeBatch(ctx, batch, finalStatus)
+}
+
+// canRetryBatchLine 判断被限流的单行请求能否重试，Batch 即将过期或已被取消时不再重试
+func canRetryBatchLine(batch *model.Batch) bool {
+	if common.GetTimestamp()+int64(batchRateLimitRetryInterval.Seconds()) >= batch.ExpiresAt {
+		return false
+	}
+	status, err := model.GetBatchStatus(batch.Id)
+	return err == nil && status == dto.BatchStatusInProgress
+}
+
+// executeBatchLine 通过内部路由执行单行请求，鉴权、选渠道、重试与计费均与普通请求一致
+func executeBatchLine(batch *model.Batch, tokenKey string, line batchInputLine) *model.BatchItem {
+	item := &model.BatchItem{
+		BatchId:  batch.BatchId,
+		Line:     line.Line,
+		CustomId: line.Input.CustomId,
+	}
+	output := dto.BatchRequestOutput{
+		Id:       "batch_req_" + common.GetRandomString(24),
+		CustomId: line.Input.CustomId,
+	}
+
+	req, err := http.NewRequest(http.MethodPost, line.Input.Url, bytes.NewReader(line.Input.Body))
+	if err != nil {
+		output.Error = &dto.BatchError{Code: "invalid_request", Message: err.Error()}
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer sk-"+tokenKey)
+		if batch.ClientIp != "" {
+			req.RemoteAddr = net.JoinHostPort(batch.ClientIp, "0")
+		}
+		recorder := httptest.NewRecorder()
+		batchRelayEngine().ServeHTTP(recorder, req)
+
+		body := recorder.Body.Bytes()
+		if !json.Valid(body) {
+			body, _ = common.Marshal(string(body))
+		}
+		item.StatusCode = recorder.Code
+		item.Success = recorder.Code == http.StatusOK
+		output.Response = &dto.BatchResponseBody{
+			StatusCode: recorder.Code,
+			RequestId:  recorder.Header().Get(common.RequestIdKey),
+			Body:       body,
+		}
+	}
+	data, _ := common.Marshal(output)
+	item.Output = string(data)
+	return item
+}
+
+// finalizeBatch 汇总执行结果生成输出/错误文件，并将 Batch 置为最终状态
+func finalizeBatch(ctx context.Context, batch *model.Batch, status string) {
+	now := common.GetTimestamp()
+	if batch.Status != dto.BatchStatusFinalizing {
+		batch.FinalizingAt = now
+		updated, err := batch.UpdateStatus(batch.Status, map[string]any{
+			"status":        dto.BatchStatusFinalizing,
+			"finalizing_at": batch.FinalizingAt,
+		})
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("batch %s update failed: %s", batch.BatchId, err.Error()))
+			return
+		}
+		if !updated {
+			// 执行期间被取消，由下一轮按取消收尾
+			return
+		}
+	}
+
+	items, err := model.GetBatchItems(batch.BatchId)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("batch %s query items failed: %s", batch.BatchId, err.Error()))
+		return
+	}
+	var output, errorOutput bytes.Buffer
+	completed, failed := 0, 0
+	for _, item := range items {
+		if item.Success {
+			completed++
+			output.WriteString(item.Output)
+			output.WriteByte('\n')
+		} else {
+			failed++
+			errorOutput.WriteString(item.Output)
+			errorOutput.WriteByte('\n')
+		}
+	}
+	if output.Len() > 0 && batch.OutputFileId == "" {
+		file := &model.File{
+			UserId:   batch.UserId,
+			Filename: batch.BatchId + "_output.jsonl",
+			Purpose:  dto.FilePurposeBatchOutput,
+			Status:   dto.FileStatusProcessed,
+			Content:  output.Bytes(),
+		}
+		if err = file.Insert(); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("batch %s save output file failed: %s", batch.BatchId, err.Error()))
+			return
+		}
+		batch.OutputFileId = file.FileId
+	}
+	if errorOutput.Len() > 0 && batch.ErrorFileId == "" {
+		file := &model.File{
+			UserId:   batch.UserId,
+			Filename: batch.BatchId + "_error.jsonl",
+			Purpose:  dto.FilePurposeBatchOutput,
+			Status:   dto.FileStatusProcessed,
+			Content:  errorOutput.Bytes(),
+		}
+		if err = file.Insert(); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("batch %s save error file failed: %s", batch.BatchId, err.Error()))
+			return
+		}
+		batch.ErrorFileId = file.FileId
+	}
+
+	columns := map[string]any{
+		"status":          status,
+		"completed_count": completed,
+		"failed_count":    failed,
+		"output_file_id":  batch.OutputFileId,
+		"error_file_id":   batch.ErrorFileId,
+	}
+	switch status {
+	case dto.BatchStatusCompleted:
+		columns["completed_at"] = now
+	case dto.BatchStatusCancelled:
+		columns["cancelled_at"] = now
+	case dto.BatchStatusExpired:
+		columns["expired_at"] = now
+	}
+	updated, err := batch.UpdateStatus(dto.BatchStatusFinalizing, columns)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("batch %s update failed: %s", batch.BatchId, err.Error()))
+		return
+	}
+	if !updated {
+		logger.LogInfo(ctx, fmt.Sprintf("batch %s status changed concurrently, skip finalizing", batch.BatchId))
+		return
+	}
+	if err = model.DeleteBatchItems(batch.BatchId); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("batch %s delete items failed: %s", batch.BatchId, err.Error()))
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("batch %s %s, completed %d, failed %d", batch.BatchId, status, completed, failed))
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/breaker"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/model"
@@ -64,6 +65,17 @@ func clearChannelInfo(channel *model.Channel) {
 	}
 }
 
+// fillChannelLiveScore 填充渠道基于实时流量计算的得分与熔断状态
+func fillChannelLiveScore(channel *model.Channel) {
+	score := model.GetChannelLiveScore(channel.Id)
+	if score.Samples > 0 {
+		channel.LiveScore = &score
+	}
+	if state := model.GetChannelCircuitState(channel.Id); state != breaker.StateClosed.String() {
+		channel.CircuitState = state
+	}
+}
+
 func GetAllChannels(c *gin.Context) {
 	pageInfo := common.GetPageQuery(c)
 	channelData := make([]*model.Channel, 0)
@@ -140,6 +152,7 @@ func GetAllChannels(c *gin.Context) {
 
 	for _, datum := range channelData {
 		clearChannelInfo(datum)
+		fillChannelLiveScore(datum)
 	}
 
 	countQuery := model.DB.Model(&model.Channel{})
@@ -452,6 +465,7 @@ func SearchChannels(c *gin.Context) {
 
 	for _, datum := range pagedData {
 		clearChannelInfo(datum)
+		fillChannelLiveScore(datum)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -547,6 +561,13 @@ func validateChannel(channel *model.Channel, isAdd bool) error {
 		return fmt.Errorf("渠道额外设置[channel setting] 格式错误：%s", err.Error())
 	}
 
+	// 转换脚本保存前先编译，避免语法错误的脚本上线
+	if channel.TransformScript != nil && strings.TrimSpace(*channel.TransformScript) != "" {
+		if err := service.ValidateChannelScript(*channel.TransformScript); err != nil {
+			return fmt.Errorf("转换脚本编译失败：%s", err.Error())
+		}
+	}
+
 	// 如果是添加操作，检查 channel 和 key 是否为空
 	if isAdd {
 		if channel == nil || channel.Key == "" {
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TestChannelScriptRequest struct {
+	Script  string          `json:"script"`
+	Stage   string          `json:"stage"`   // request、response 或 chunk
+	Payload json.RawMessage `json:"payload"` // 示例请求体、响应体或流式分片
+	Context map[string]any  `json:"context"` // 传给脚本的上下文，缺省字段由脚本自行处理
+}
+
+// TestChannelScript 以示例内容运行转换脚本，返回转换结果或错误，用于保存前调试
+func TestChannelScript(c *gin.Context) {
+	var req TestChannelScriptRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	switch req.Stage {
+	case service.ChannelScriptStageRequest, service.ChannelScriptStageResponse, service.ChannelScriptStageChunk:
+	default:
+		common.ApiErrorMsg(c, fmt.Sprintf("不支持的执行阶段：%s", req.Stage))
+		return
+	}
+	if strings.TrimSpace(req.Script) == "" {
+		common.ApiErrorMsg(c, "转换脚本不能为空")
+		return
+	}
+	if len(req.Payload) == 0 {
+		common.ApiErrorMsg(c, "示例内容不能为空")
+		return
+	}
+
+	timeout := operation_setting.GetChannelScriptSetting().Timeout()
+	start := time.Now()
+	result := gin.H{}
+	script, err := service.NewChannelScript(req.Script, req.Context, timeout)
+	if err == nil {
+		if !script.Has(req.Stage) {
+			result["skipped"] = true
+		}
+		var output []byte
+		var dropped bool
+		output, dropped, err = script.Run(req.Stage, req.Payload)
+		result["output"] = string(output)
+		result["dropped"] = dropped
+	}
+	if err != nil {
+		result["error"] = err.Error()
+	}
+	result["duration_ms"] = time.Since(start).Milliseconds()
+	common.ApiSuccess(c, result)
+}
@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func openAIResourceError(c *gin.Context, statusCode int, message string) {
+	errType := "invalid_request_error"
+	if statusCode >= http.StatusInternalServerError {
+		errType = "server_error"
+	}
+	c.JSON(statusCode, gin.H{
+		"error": gin.H{
+			"message": common.MessageWithRequestId(message, c.GetString(common.RequestIdKey)),
+			"type":    errType,
+		},
+	})
+}
+
+func openAIResourceDBError(c *gin.Context, err error, notFoundMessage string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		openAIResourceError(c, http.StatusNotFound, notFoundMessage)
+		return
+	}
+	logger.LogError(c, fmt.Sprintf("query database failed: %s", err.Error()))
+	openAIResourceError(c, http.StatusInternalServerError, "database error")
+}
+
+func getListLimit(c *gin.Context, defaultLimit int, maxLimit int) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return defaultLimit
+	}
+	if limit > maxLimit {
+		return maxLimit
+	}
+	return limit
+}
+
+// UploadFile docs: https://platform.openai.com/docs/api-reference/files/create
+func UploadFile(c *gin.Context) {
+	purpose := c.PostForm("purpose")
+	if purpose != dto.FilePurposeBatch {
+		openAIResourceError(c, http.StatusBadRequest, fmt.Sprintf("unsupported purpose: %q, only %q is supported", purpose, dto.FilePurposeBatch))
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		openAIResourceError(c, http.StatusBadRequest, "file is required: "+err.Error())
+		return
+	}
+	maxBytes := int64(constant.MaxRequestBodyMB) << 20
+	if constant.MaxRequestBodyMB > 0 && fileHeader.Size > maxBytes {
+		openAIResourceError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("file exceeds %d MB", constant.MaxRequestBodyMB))
+		return
+	}
+	src, err := fileHeader.Open()
+	if err != nil {
+		openAIResourceError(c, http.StatusBadRequest, "failed to open file: "+err.Error())
+		return
+	}
+	defer src.Close()
+	content, err := io.ReadAll(src)
+	if err != nil {
+		openAIResourceError(c, http.StatusBadRequest, "failed to read file: "+err.Error())
+		return
+	}
+	lines, err := countBatchInputLines(content)
+	if err != nil {
+		openAIResourceError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if lines == 0 {
+		openAIResourceError(c, http.StatusBadRequest, "file contains no requests")
+		return
+	}
+	if lines > constant.BatchMaxRequests {
+		openAIResourceError(c, http.StatusBadRequest, fmt.Sprintf("file contains %d requests, exceeds the limit of %d", lines, constant.BatchMaxRequests))
+		return
+	}
+
+	file := &model.File{
+		UserId:   c.GetInt("id"),
+		Filename: fileHeader.Filename,
+		Purpose:  purpose,
+		Status:   dto.FileStatusProcessed,
+		Content:  content,
+	}
+	if err = file.Insert(); err != nil {
+		logger.LogError(c, "failed to save file: "+err.Error())
+		openAIResourceError(c, http.StatusInternalServerError, "failed to save file")
+		return
+	}
+	c.JSON(http.StatusOK, file.ToOpenAIFile())
+}
+
+// countBatchInputLines 检查输入文件是否为合法的 JSONL，返回非空行数
+func countBatchInputLines(content []byte) (int, error) {
+	count := 0
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), len(content)+1)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var input dto.BatchRequestInput
+		if err := common.Unmarshal(line, &input); err != nil {
+			return 0, fmt.Errorf("line %d is not valid JSON: %s", lineNo, err.Error())
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func ListFiles(c *gin.Context) {
+	limit := getListLimit(c, 100, 10000)
+	files, err := model.GetUserFiles(c.GetInt("id"), c.Query("purpose"), c.Query("after"), limit+1)
+	if err != nil {
+		openAIResourceDBError(c, err, "cursor file not found")
+		return
+	}
+	resp := dto.OpenAIFileList{
+		Object: "list",
+		Data:   make([]*dto.OpenAIFile, 0, len(files)),
+	}
+	if len(files) > limit {
+		resp.HasMore = true
+		files = files[:limit]
+	}
+	for _, file := range files {
+		resp.Data = append(resp.Data, file.ToOpenAIFile())
+	}
+	if len(resp.Data) > 0 {
+		resp.FirstId = resp.Data[0].Id
+		resp.LastId = resp.Data[len(resp.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func RetrieveFile(c *gin.Context) {
+	file, err := model.GetUserFile(c.GetInt("id"), c.Param("id"), false)
+	if err != nil {
+		openAIResourceDBError(c, err, fmt.Sprintf("No such File object: %s", c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, file.ToOpenAIFile())
+}
+
+func RetrieveFileContent(c *gin.Context) {
+	file, err := model.GetUserFile(c.GetInt("id"), c.Param("id"), true)
+	if err != nil {
+		openAIResourceDBError(c, err, fmt.Sprintf("No such File object: %s", c.Param("id")))
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+	c.Data(http.StatusOK, "application/octet-stream", file.Content)
+}
+
+func DeleteFile(c *gin.Context) {
+	fileId := c.Param("id")
+	if err := model.DeleteUserFile(c.GetInt("id"), fileId); err != nil {
+		openAIResourceDBError(c, err, fmt.Sprintf("No such File object: %s", fileId))
+		return
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileDeleted{
+		Id:      fileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
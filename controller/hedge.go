@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// 选择对冲渠道时避开主渠道的最大尝试次数
+const hedgeSelectAttempts = 3
+
+var errHedgeLost = errors.New("hedged request lost the race")
+
+// hedgeWriter 对冲请求中单个尝试的 ResponseWriter，首次写入时争夺胜出权，胜出后才真正写入客户端
+type hedgeWriter struct {
+	gin.ResponseWriter
+	hedge   *relaycommon.HedgeState
+	attempt int
+	header  http.Header
+	status  int
+	won     bool
+}
+
+func newHedgeWriter(w gin.ResponseWriter, hedge *relaycommon.HedgeState, attempt int) *hedgeWriter {
+	return &hedgeWriter{
+		ResponseWriter: w,
+		hedge:          hedge,
+		attempt:        attempt,
+		header:         http.Header{},
+		status:         http.StatusOK,
+	}
+}
+
+// claim 争夺胜出权，胜出时把暂存的响应头写入客户端
+func (w *hedgeWriter) claim() bool {
+	if w.won {
+		return true
+	}
+	if !w.hedge.Claim(w.attempt) {
+		return false
+	}
+	w.won = true
+	for key, values := range w.header {
+		w.ResponseWriter.Header()[key] = values
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	return true
+}
+
+func (w *hedgeWriter) Header() http.Header {
+	if w.won {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *hedgeWriter) WriteHeader(code int) {
+	if w.won {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *hedgeWriter) WriteHeaderNow() {
+	if w.claim() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *hedgeWriter) Write(data []byte) (int, error) {
+	if !w.claim() {
+		return 0, errHedgeLost
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *hedgeWriter) WriteString(s string) (int, error) {
+	if !w.claim() {
+		return 0, errHedgeLost
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *hedgeWriter) Status() int {
+	if w.won {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *hedgeWriter) Size() int {
+	if w.won {
+		return w.ResponseWriter.Size()
+	}
+	return -1
+}
+
+func (w *hedgeWriter) Written() bool {
+	return w.won && w.ResponseWriter.Written()
+}
+
+func (w *hedgeWriter) Flush() {
+	if w.won {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// hedgeAttempt 对冲请求中的一次尝试，使用独立的 gin.Context 与 RelayInfo 副本
+type hedgeAttempt struct {
+	id        int
+	ctx       *gin.Context
+	info      *relaycommon.RelayInfo
+	channel   *model.Channel
+	start     time.Time
+	err       *types.NewAPIError
+	cancelled bool
+}
+
+func newHedgeAttempt(c *gin.Context, info *relaycommon.RelayInfo, hedge *relaycommon.HedgeState, id int, requestBody []byte) *hedgeAttempt {
+	cp := c.Copy()
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	cp.Request = c.Request.WithContext(ctx)
+	cp.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	cp.Writer = newHedgeWriter(c.Writer, hedge, id)
+	hedge.Register(id, cancel)
+
+	attemptInfo := *info
+	attemptInfo.Hedge = hedge
+	attemptInfo.HedgeAttempt = id
+	return &hedgeAttempt{id: id, ctx: cp, info: &attemptInfo}
+}
+
+func (a *hedgeAttempt) run(results chan<- *hedgeAttempt) {
+	a.start = time.Now()
+	gopool.Go(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				a.err = types.NewError(fmt.Errorf("hedged request panic: %v", r), types.ErrorCodeDoRequestFailed)
+			}
+			a.cancelled = a.ctx.Request.Context().Err() != nil
+			results <- a
+		}()
+		a.err = relayHandler(a.ctx, a.info)
+	})
+}
+
+// getHedgeDelay 判断本次请求是否启用对冲，仅 OpenAI 格式的对话与补全请求支持
+func getHedgeDelay(c *gin.Context, info *relaycommon.RelayInfo) (time.Duration, bool) {
+	if info.RelayFormat != types.RelayFormatOpenAI {
+		return 0, false
+	}
+	if info.RelayMode != relayconstant.RelayModeChatCompletions && info.RelayMode != relayconstant.RelayModeCompletions {
+		return 0, false
+	}
+	if !common.GetContextKeyBool(c, constant.ContextKeyTokenHedgeEnabled) {
+		return 0, false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return 0, false
+	}
+	delayMs, ok := operation_setting.GetHedgeDelayMs(info.OriginModelName)
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(delayMs) * time.Millisecond, true
+}
+
+// selectHedgeChannel 为对冲尝试选择一个不同于主渠道的渠道
+func selectHedgeChannel(a *hedgeAttempt, retry int, primaryId int) bool {
+	// 强制重新选择渠道，而不是沿用分发时选中的渠道
+	if a.info.ChannelMeta == nil {
+		a.info.ChannelMeta = &relaycommon.ChannelMeta{}
+	}
+	// 复制的上下文中持有的是主请求的槽位，对冲尝试需要占用自己的槽位
+	service.ClearChannelSlot(a.ctx)
+	retryParam := &service.RetryParam{
+		Ctx:        a.ctx,
+		TokenGroup: a.info.TokenGroup,
+		ModelName:  a.info.OriginModelName,
+		Retry:      common.GetPointer(retry),
+	}
+	for i := 0; i < hedgeSelectAttempts; i++ {
+		channel, err := getChannel(a.ctx, a.info, retryParam)
+		if err != nil {
+			return false
+		}
+		if channel.Id != primaryId {
+			a.channel = channel
+			return true
+		}
+	}
+	service.ReleaseChannelSlot(a.ctx)
+	return false
+}
+
+// relayWithHedge 发起主请求，若在对冲延迟内未返回首字则向另一个渠道发起相同请求，
+// 使用先返回首字的结果并取消另一个请求；只有胜出的尝试计费，落败的尝试仍计入渠道健康统计
+func relayWithHedge(c *gin.Context, relayInfo *relaycommon.RelayInfo, channel *model.Channel, retry int, requestBody []byte, delay time.Duration) (*model.Channel, *types.NewAPIError) {
+	hedge := relaycommon.NewHedgeState()
+	defer hedge.Stop()
+	results := make(chan *hedgeAttempt, 2)
+
+	primary := newHedgeAttempt(c, relayInfo, hedge, 1, requestBody)
+	primary.channel = channel
+	primary.run(results)
+	attempts := []*hedgeAttempt{primary}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	pending := 1
+	select {
+	case <-results:
+		pending--
+	case <-timer.C:
+		if hedge.Winner() != 0 {
+			break
+		}
+		secondary := newHedgeAttempt(c, relayInfo, hedge, 2, requestBody)
+		if !selectHedgeChannel(secondary, retry, channel.Id) {
+			break
+		}
+		logger.LogInfo(c, fmt.Sprintf("渠道 #%d 在 %d 毫秒内未返回首字，对冲请求渠道 #%d", channel.Id, delay.Milliseconds(), secondary.channel.Id))
+		secondary.run(results)
+		attempts = append(attempts, secondary)
+		pending++
+	}
+	for ; pending > 0; pending-- {
+		<-results
+	}
+
+	result := primary
+	if winner := hedge.Winner(); winner != 0 {
+		result = attempts[winner-1]
+	}
+	for _, a := range attempts {
+		if a == result {
+			if !a.info.ResponseCacheHit {
+				recordChannelResult(a.ctx, a.channel.Id, a.info, a.start, a.err)
+			}
+			continue
+		}
+		service.ReleaseChannelSlot(a.ctx)
+		if a.cancelled && hedge.Winner() != 0 {
+			// 被取消的落败请求只计入延迟，不计入失败
+			model.RecordChannelRelayResult(a.channel.Id, true, time.Since(a.start))
+			continue
+		}
+		recordChannelResult(a.ctx, a.channel.Id, a.info, a.start, a.err)
+		if a.err != nil {
+			processChannelError(a.ctx, *types.NewChannelError(a.channel.Id, a.channel.Type, a.channel.Name, a.channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(a.ctx, constant.ContextKeyChannelKey), a.channel.GetAutoBan()), a.err)
+		}
+	}
+
+	// 以结果尝试的上下文继续后续处理（错误日志、重试等）
+	for key, value := range result.ctx.Keys {
+		c.Set(key, value)
+	}
+	*relayInfo = *result.info
+	relayInfo.Hedge = nil
+	relayInfo.HedgeAttempt = 0
+	for _, a := range attempts[1:] {
+		addUsedChannel(c, a.channel.Id)
+	}
+	return result.channel, result.err
+}
@@ -0,0 +1,17 @@
+package controller
+
+import (
+	"github.com/QuantumNous/new-api/common/metrics"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 以 Prometheus 文本格式输出监控指标
+func Metrics(c *gin.Context) {
+	statuses, err := model.GetMultiKeyChannelStatuses()
+	if err == nil {
+		metrics.SetChannelMultiKeyStatus(statuses)
+	}
+	metrics.Handler().ServeHTTP(c.Writer, c.Request)
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/metrics"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
@@ -27,6 +28,7 @@ func UpdateMidjourneyTaskBulk() {
 		time.Sleep(time.Duration(15) * time.Second)
 
 		tasks := model.GetAllUnFinishTasks()
+		metrics.SetTaskQueueDepth("midjourney", map[string]int{"midjourney": len(tasks)})
 		if len(tasks) == 0 {
 			continue
 		}
@@ -177,12 +179,12 @@ func UpdateMidjourneyTaskBulk() {
 					logger.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
 				} else {
 					if shouldReturnQuota {
-						err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
+						err = service.RefundMidjourneyQuota(task, task.Quota)
 						if err != nil {
 							logger.LogError(ctx, "fail to increase user quota: "+err.Error())
 						}
 						logContent := fmt.Sprintf("构图失败 %s，补偿 %s", task.MjId, logger.LogQuota(task.Quota))
-						model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
+						model.RecordRefundLog(task.UserId, task.OrganizationId, task.Quota, logContent)
 					}
 				}
 			}
@@ -133,7 +133,7 @@ func ListModels(c *gin.Context, modelType int) {
 			tokenModelLimit = map[string]bool{}
 		}
 		for allowModel, _ := range tokenModelLimit {
-			if !acceptUnsetRatioModel {
+			if !acceptUnsetRatioModel && !service.IsVirtualModel(allowModel) {
 				_, _, exist := ratio_setting.GetModelRatioOrPrice(allowModel)
 				if !exist {
 					continue
@@ -191,8 +191,11 @@ func ListModels(c *gin.Context, modelType int) {
 				models = model.GetGroupEnabledModels(group)
 			}
 		}
+		// 虚拟模型按目标模型计费，不检查自身的价格配置
+		virtualModels := service.ListVirtualModels(models)
+		models = append(models, virtualModels...)
 		for _, modelName := range models {
-			if !acceptUnsetRatioModel {
+			if !acceptUnsetRatioModel && !common.StringsContains(virtualModels, modelName) {
 				_, _, exist := ratio_setting.GetModelRatioOrPrice(modelName)
 				if !exist {
 					continue
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// modelFallback 记录一次请求在模型降级链上的进度
+type modelFallback struct {
+	remaining []string
+}
+
+// newModelFallback 读取请求模型的降级链，分发阶段已经降级时从当前模型之后继续
+func newModelFallback(c *gin.Context, info *relaycommon.RelayInfo) *modelFallback {
+	if _, ok := c.Get("specific_channel_id"); ok || info.RelayFormat == types.RelayFormatOpenAIRealtime {
+		return &modelFallback{}
+	}
+	requestedModel := info.OriginModelName
+	if from := common.GetContextKeyString(c, constant.ContextKeyModelFallbackFrom); from != "" {
+		requestedModel = from
+		info.ModelFallbackPath = []string{from, info.OriginModelName}
+	}
+	chain := service.GetModelFallbackChain(c, requestedModel)
+	if index := slices.Index(chain, info.OriginModelName); index >= 0 {
+		chain = chain[index+1:]
+	}
+	return &modelFallback{remaining: chain}
+}
+
+// next 当前模型的渠道全部失败或不可用时切换到降级链中的下一个模型，并按新模型重新计算价格。
+// 预扣费额度保持不变，结算时按实际使用的模型补扣或返还差额
+func (f *modelFallback) next(c *gin.Context, info *relaycommon.RelayInfo, err *types.NewAPIError, promptTokens int, meta *types.TokenCountMeta) bool {
+	if !shouldFallbackModel(info, err) {
+		return false
+	}
+	for len(f.remaining) > 0 {
+		fallbackModel := f.remaining[0]
+		f.remaining = f.remaining[1:]
+		previousModel := info.OriginModelName
+		info.OriginModelName = fallbackModel
+		if _, priceErr := helper.ModelPriceHelper(c, info, promptTokens, meta); priceErr != nil {
+			logger.LogWarn(c, fmt.Sprintf("skip fallback model %s: %s", fallbackModel, priceErr.Error()))
+			info.OriginModelName = previousModel
+			continue
+		}
+		if len(info.ModelFallbackPath) == 0 {
+			info.ModelFallbackPath = []string{previousModel}
+		}
+		info.ModelFallbackPath = append(info.ModelFallbackPath, fallbackModel)
+		c.Header(common.ServedModelKey, fallbackModel)
+		logger.LogInfo(c, fmt.Sprintf("model %s unavailable, fallback to %s: %s", previousModel, fallbackModel, err.Error()))
+		return true
+	}
+	return false
+}
+
+// shouldFallbackModel 只有渠道不可用或渠道本身出错时才降级，请求本身的错误（参数错误、额度不足等）不降级
+func shouldFallbackModel(info *relaycommon.RelayInfo, err *types.NewAPIError) bool {
+	if err == nil || info.HasSendResponse() {
+		return false
+	}
+	if err.GetErrorCode() == types.ErrorCodeGetChannelFailed {
+		return true
+	}
+	return isChannelHealthError(err)
+}
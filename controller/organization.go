@@ -0,0 +1,306 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type OrganizationMemberRequest struct {
+	Id           int    `json:"id"`
+	Username     string `json:"username"`
+	Role         string `json:"role"`
+	MonthlyLimit int    `json:"monthly_limit"`
+}
+
+type OrganizationQuotaRequest struct {
+	OrganizationId int `json:"organization_id"`
+	Quota          int `json:"quota"`
+}
+
+type OrganizationStatusRequest struct {
+	Id     int `json:"id"`
+	Status int `json:"status"`
+}
+
+// getOrganizationMember 返回 OrganizationAuth 中间件校验过的当前成员
+func getOrganizationMember(c *gin.Context) *model.OrganizationMember {
+	return c.MustGet("organization_member").(*model.OrganizationMember)
+}
+
+// checkMemberManageable 校验当前成员能否将目标成员设置为指定角色，只有 owner 可以任免管理员
+func checkMemberManageable(operator *model.OrganizationMember, target *model.OrganizationMember, role string) error {
+	if target != nil && target.Role == model.OrganizationRoleOwner {
+		return errors.New("不能修改组织的所有者")
+	}
+	if role == model.OrganizationRoleOwner {
+		return errors.New("不能将成员设置为所有者")
+	}
+	if operator.Role == model.OrganizationRoleOwner {
+		return nil
+	}
+	if role == model.OrganizationRoleAdmin || (target != nil && target.Role == model.OrganizationRoleAdmin) {
+		return errors.New("只有组织所有者可以管理管理员")
+	}
+	return nil
+}
+
+func GetSelfOrganizations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, orgs)
+}
+
+// CreateOrganization 创建组织，创建者成为组织的所有者
+func CreateOrganization(c *gin.Context) {
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org := &model.Organization{
+		Name:    req.Name,
+		OwnerId: c.GetInt("id"),
+	}
+	if err := org.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org.Role = model.OrganizationRoleOwner
+	common.ApiSuccess(c, org)
+}
+
+// GetOrganization 返回组织详情与当前成员本月已使用的组织额度
+func GetOrganization(c *gin.Context) {
+	member := getOrganizationMember(c)
+	org, err := model.GetOrganizationById(member.OrganizationId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org.Role = member.Role
+	monthlyUsed := 0
+	if member.MonthlyLimit > 0 {
+		windowStart := model.BudgetWindowStart(model.BudgetWindowMonth, time.Now())
+		monthlyUsed, err = model.GetBudgetSpend(model.BudgetScopeOrganizationMember, member.Id, model.BudgetWindowMonth, windowStart)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	common.ApiSuccess(c, gin.H{
+		"organization": org,
+		"member":       member,
+		"monthly_used": monthlyUsed,
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org, err := model.GetOrganizationById(getOrganizationMember(c).OrganizationId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := org.UpdateName(req.Name); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	members, err := model.GetOrganizationMembers(getOrganizationMember(c).OrganizationId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, members)
+}
+
+func AddOrganizationMember(c *gin.Context) {
+	var req OrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	operator := getOrganizationMember(c)
+	if err := checkMemberManageable(operator, nil, req.Role); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	member, err := model.AddOrganizationMember(operator.OrganizationId, req.Username, req.Role, req.MonthlyLimit)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	var req OrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	operator := getOrganizationMember(c)
+	member, err := model.GetOrganizationMemberById(operator.OrganizationId, req.Id)
+	if err != nil {
+		common.ApiErrorMsg(c, "成员不存在")
+		return
+	}
+	if member.Role == model.OrganizationRoleOwner && operator.Role == model.OrganizationRoleOwner {
+		// 所有者只能修改自己的每月限额
+		req.Role = model.OrganizationRoleOwner
+	} else if err := checkMemberManageable(operator, member, req.Role); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	member.Role = req.Role
+	member.MonthlyLimit = req.MonthlyLimit
+	if err := member.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+func DeleteOrganizationMember(c *gin.Context) {
+	operator := getOrganizationMember(c)
+	memberId, _ := strconv.Atoi(c.Param("member_id"))
+	member, err := model.GetOrganizationMemberById(operator.OrganizationId, memberId)
+	if err != nil {
+		common.ApiErrorMsg(c, "成员不存在")
+		return
+	}
+	if err := checkMemberManageable(operator, member, member.Role); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := member.Delete(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetOrganizationTokens 返回组织的所有令牌，其他成员创建的令牌不返回密钥
+func GetOrganizationTokens(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	tokens, total, err := model.GetOrganizationTokens(getOrganizationMember(c).OrganizationId, pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	userId := c.GetInt("id")
+	for _, token := range tokens {
+		if token.UserId != userId {
+			token.Clean()
+		}
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(tokens)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func DeleteOrganizationToken(c *gin.Context) {
+	tokenId, _ := strconv.Atoi(c.Param("token_id"))
+	token, err := model.GetTokenById(tokenId)
+	if err != nil || token.OrganizationId != getOrganizationMember(c).OrganizationId {
+		common.ApiErrorMsg(c, "令牌不存在")
+		return
+	}
+	if err := model.DeleteTokenById(token.Id, token.UserId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetOrganizationLogs(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	logType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	username := c.Query("username")
+	tokenName := c.Query("token_name")
+	modelName := c.Query("model_name")
+	logs, total, err := model.GetOrganizationLogs(getOrganizationMember(c).OrganizationId, logType, startTimestamp, endTimestamp, modelName, username, tokenName, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// FundOrganization 成员将个人余额转入组织额度
+func FundOrganization(c *gin.Context) {
+	var req OrganizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.FundOrganization(getOrganizationMember(c).OrganizationId, c.GetInt("id"), req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetAllOrganizations 管理员查看所有组织，可按名称搜索
+func GetAllOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(c.Query("keyword"), pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// TopUpOrganization 管理员为组织充值
+func TopUpOrganization(c *gin.Context) {
+	var req OrganizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.TopUpOrganization(req.OrganizationId, req.Quota, c.GetInt("id")); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func UpdateOrganizationStatus(c *gin.Context) {
+	var req OrganizationStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.UpdateOrganizationStatus(req.Id, req.Status); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
@@ -38,6 +38,21 @@ func GetPricing(c *gin.Context) {
 		}
 	}
 
+	// 只展示适用于用户可用分组的计价规则
+	pricingRules := make([]ratio_setting.PricingRule, 0)
+	for _, rule := range ratio_setting.GetPricingRules() {
+		if len(rule.Groups) == 0 {
+			pricingRules = append(pricingRules, rule)
+			continue
+		}
+		for _, g := range rule.Groups {
+			if _, ok := usableGroup[g]; ok {
+				pricingRules = append(pricingRules, rule)
+				break
+			}
+		}
+	}
+
 	c.JSON(200, gin.H{
 		"success":            true,
 		"data":               pricing,
@@ -46,6 +61,7 @@ func GetPricing(c *gin.Context) {
 		"usable_group":       usableGroup,
 		"supported_endpoint": model.GetSupportedEndpointMap(),
 		"auto_groups":        service.GetUserAutoGroup(group),
+		"pricing_rules":      pricingRules,
 	})
 }
 
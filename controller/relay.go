@@ -8,8 +8,11 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/metrics"
+	"github.com/QuantumNous/new-api/common/tracing"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
@@ -21,9 +24,11 @@ import (
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/bytedance/gopkg/util/gopool"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -103,7 +108,9 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}()
 
+	_, endValidateSpan := tracing.StartSpan(c, "relay.validate_request", attribute.String("relay.format", string(relayFormat)))
 	request, err := helper.GetAndValidateRequest(c, relayFormat)
+	endValidateSpan()
 	if err != nil {
 		// Map "request body too large" to 413 so clients can handle it correctly
 		if common.IsRequestBodyTooLargeError(err) || errors.Is(err, common.ErrRequestBodyTooLarge) {
@@ -120,11 +127,24 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	defer func() {
+		statusCode := c.Writer.Status()
+		if newAPIError != nil {
+			statusCode = newAPIError.StatusCode
+		}
+		channelId := c.GetInt("channel_id")
+		metrics.ObserveRelayRequest(string(relayFormat), relayInfo.OriginModelName, channelId, relayInfo.UsingGroup, statusCode, time.Since(relayInfo.StartTime))
+		if relayInfo.IsStream && relayInfo.HasSendResponse() {
+			metrics.ObserveFirstToken(string(relayFormat), relayInfo.OriginModelName, channelId, relayInfo.UsingGroup, relayInfo.FirstResponseTime.Sub(relayInfo.StartTime))
+		}
+	}()
+
 	needSensitiveCheck := setting.ShouldCheckPromptSensitive()
 	needCountToken := constant.CountToken
-	// Avoid building huge CombineText (strings.Join) when token counting and sensitive check are both disabled.
+	_, needModeration := operation_setting.GetModerationPolicy(relayInfo.UsingGroup)
+	// Avoid building huge CombineText (strings.Join) when token counting, sensitive check and moderation are all disabled.
 	var meta *types.TokenCountMeta
-	if needSensitiveCheck || needCountToken {
+	if needSensitiveCheck || needCountToken || needModeration {
 		meta = request.GetTokenCountMeta()
 	} else {
 		meta = fastTokenCountMetaForPricing(request)
@@ -139,6 +159,15 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}
 
+	redacted, moderationErr := service.ModeratePrompt(c, relayInfo, request, meta)
+	if moderationErr != nil {
+		newAPIError = moderationErr
+		return
+	}
+	if redacted {
+		meta = request.GetTokenCountMeta()
+	}
+
 	tokens, err := service.EstimateRequestToken(c, meta, relayInfo)
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeCountTokenFailed)
@@ -147,6 +176,18 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 	relayInfo.SetEstimatePromptTokens(tokens)
 
+	newAPIError = service.ReserveTokenRateLimit(c, relayInfo, tokens)
+	if newAPIError != nil {
+		return
+	}
+
+	defer func() {
+		// 请求失败时返还预留的 TPM 配额
+		if newAPIError != nil {
+			service.ReleaseTokenRateLimit(c)
+		}
+	}()
+
 	priceData, err := helper.ModelPriceHelper(c, relayInfo, tokens, meta)
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeModelPriceError)
@@ -169,6 +210,8 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		if newAPIError != nil && relayInfo.FinalPreConsumedQuota != 0 {
 			service.ReturnPreConsumedQuota(c, relayInfo)
 		}
+		// 释放未结算的预算预留，例如信任额度未预扣费或实际消费为 0 的请求
+		service.ReleaseSpendBudget(relayInfo)
 	}()
 
 	retryParam := &service.RetryParam{
@@ -178,47 +221,84 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		Retry:      common.GetPointer(0),
 	}
 
-	for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
-		channel, channelErr := getChannel(c, relayInfo, retryParam)
-		if channelErr != nil {
-			logger.LogError(c, channelErr.Error())
-			newAPIError = channelErr
-			break
-		}
+	fallback := newModelFallback(c, relayInfo)
+	if relayFormat != types.RelayFormatOpenAIRealtime {
+		c.Header(common.ServedModelKey, relayInfo.OriginModelName)
+	}
+
+	for {
+		for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
+			channel, channelErr := getChannel(c, relayInfo, retryParam)
+			if channelErr != nil {
+				logger.LogError(c, channelErr.Error())
+				newAPIError = channelErr
+				break
+			}
 
-		addUsedChannel(c, channel.Id)
-		requestBody, bodyErr := common.GetRequestBody(c)
-		if bodyErr != nil {
-			// Ensure consistent 413 for oversized bodies even when error occurs later (e.g., retry path)
-			if common.IsRequestBodyTooLargeError(bodyErr) || errors.Is(bodyErr, common.ErrRequestBodyTooLarge) {
-				newAPIError = types.NewErrorWithStatusCode(bodyErr, types.ErrorCodeReadRequestBodyFailed, http.StatusRequestEntityTooLarge, types.ErrOptionWithSkipRetry())
+			addUsedChannel(c, channel.Id)
+			requestBody, bodyErr := common.GetRequestBody(c)
+			if bodyErr != nil {
+				// Ensure consistent 413 for oversized bodies even when error occurs later (e.g., retry path)
+				if common.IsRequestBodyTooLargeError(bodyErr) || errors.Is(bodyErr, common.ErrRequestBodyTooLarge) {
+					newAPIError = types.NewErrorWithStatusCode(bodyErr, types.ErrorCodeReadRequestBodyFailed, http.StatusRequestEntityTooLarge, types.ErrOptionWithSkipRetry())
+				} else {
+					newAPIError = types.NewErrorWithStatusCode(bodyErr, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+				}
+				break
+			}
+			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+
+			attemptSpan, endAttemptSpan := tracing.StartSpan(c, "relay.attempt",
+				attribute.Int("relay.retry", retryParam.GetRetry()),
+				attribute.Int("channel.id", channel.Id),
+				attribute.Int("channel.type", channel.Type),
+			)
+			if hedgeDelay, ok := getHedgeDelay(c, relayInfo); ok {
+				channel, newAPIError = relayWithHedge(c, relayInfo, channel, retryParam.GetRetry(), requestBody, hedgeDelay)
 			} else {
-				newAPIError = types.NewErrorWithStatusCode(bodyErr, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+				attemptStart := time.Now()
+				switch relayFormat {
+				case types.RelayFormatOpenAIRealtime:
+					newAPIError = relay.WssHelper(c, relayInfo)
+				case types.RelayFormatClaude:
+					newAPIError = relay.ClaudeHelper(c, relayInfo)
+				case types.RelayFormatGemini:
+					newAPIError = geminiRelayHandler(c, relayInfo)
+				default:
+					newAPIError = relayHandler(c, relayInfo)
+				}
+
+				if relayFormat != types.RelayFormatOpenAIRealtime && !relayInfo.ResponseCacheHit {
+					recordChannelResult(c, channel.Id, relayInfo, attemptStart, newAPIError)
+				}
 			}
-			break
-		}
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+			attemptSpan.SetAttributes(attribute.String("gen_ai.request.model", relayInfo.UpstreamModelName))
+			if newAPIError != nil {
+				tracing.RecordError(attemptSpan, newAPIError)
+			}
+			endAttemptSpan()
 
-		switch relayFormat {
-		case types.RelayFormatOpenAIRealtime:
-			newAPIError = relay.WssHelper(c, relayInfo)
-		case types.RelayFormatClaude:
-			newAPIError = relay.ClaudeHelper(c, relayInfo)
-		case types.RelayFormatGemini:
-			newAPIError = geminiRelayHandler(c, relayInfo)
-		default:
-			newAPIError = relayHandler(c, relayInfo)
-		}
+			if newAPIError == nil {
+				return
+			}
 
-		if newAPIError == nil {
-			return
-		}
+			processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
 
-		processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+			if !shouldRetry(c, newAPIError, common.RetryTimes-retryParam.GetRetry()) {
+				break
+			}
+		}
 
-		if !shouldRetry(c, newAPIError, common.RetryTimes-retryParam.GetRetry()) {
+		// 当前模型的渠道全部失败或不可用时，按降级链改用下一个模型重新选择渠道
+		if !fallback.next(c, relayInfo, newAPIError, tokens, meta) {
 			break
 		}
+		retryParam = &service.RetryParam{
+			Ctx:        c,
+			TokenGroup: relayInfo.TokenGroup,
+			ModelName:  relayInfo.OriginModelName,
+			Retry:      common.GetPointer(0),
+		}
 	}
 
 	useChannel := c.GetStringSlice("use_channel")
@@ -241,6 +321,31 @@ func addUsedChannel(c *gin.Context, channelId int) {
 	c.Set("use_channel", useChannel)
 }
 
+// recordChannelResult 记录本次尝试的结果，用于自适应渠道选择与渠道熔断，请求自身的错误（如参数错误）不计入渠道失败
+func recordChannelResult(c *gin.Context, channelId int, info *relaycommon.RelayInfo, attemptStart time.Time, err *types.NewAPIError) {
+	isMultiKey := common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey)
+	keyIndex := common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	if err == nil {
+		latency := time.Since(attemptStart)
+		if info.HasSendResponse() && info.FirstResponseTime.After(attemptStart) {
+			latency = info.FirstResponseTime.Sub(attemptStart)
+		}
+		model.RecordChannelRelayResult(channelId, true, latency)
+		model.RecordChannelCircuitResult(channelId, isMultiKey, keyIndex, true)
+		return
+	}
+	if isChannelHealthError(err) {
+		model.RecordChannelRelayResult(channelId, false, time.Since(attemptStart))
+		model.RecordChannelCircuitResult(channelId, isMultiKey, keyIndex, false)
+	}
+}
+
+// isChannelHealthError 判断错误是否由渠道本身引起（上游故障、限流、鉴权失败等）
+func isChannelHealthError(err *types.NewAPIError) bool {
+	return types.IsChannelError(err) || err.StatusCode == http.StatusTooManyRequests || err.StatusCode == http.StatusUnauthorized ||
+		err.StatusCode == http.StatusForbidden || err.StatusCode >= http.StatusInternalServerError
+}
+
 func fastTokenCountMetaForPricing(request dto.Request) *types.TokenCountMeta {
 	if request == nil {
 		return &types.TokenCountMeta{}
@@ -286,6 +391,9 @@ func getChannel(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service
 
 	info.PriceData.GroupRatioInfo = helper.HandleGroupRatio(c, info)
 
+	if errors.Is(err, model.ErrChannelsSaturated) {
+		return nil, types.NewErrorWithStatusCode(fmt.Errorf("分组 %s 下模型 %s 的渠道繁忙，请稍后重试: %s", selectGroup, info.OriginModelName, err.Error()), types.ErrorCodeGetChannelFailed, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry())
+	}
 	if err != nil {
 		return nil, types.NewError(fmt.Errorf("获取分组 %s 下模型 %s 的可用渠道失败（retry）: %s", selectGroup, info.OriginModelName, err.Error()), types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
 	}
@@ -397,6 +505,25 @@ func RelayMidjourney(c *gin.Context) {
 
 	var mjErr *dto.MidjourneyResponse
 	switch relayInfo.RelayMode {
+	case relayconstant.RelayModeMidjourneyNotify, relayconstant.RelayModeMidjourneyTaskFetch,
+		relayconstant.RelayModeMidjourneyTaskFetchByCondition, relayconstant.RelayModeMidjourneyTaskImageSeed:
+	default:
+		// 提交绘图任务同样受 RPM/TPM 限制，任务不返回 Token 用量，按单次请求计入
+		if rateLimitErr := service.ReserveTokenRateLimit(c, relayInfo, 0); rateLimitErr != nil {
+			c.JSON(rateLimitErr.StatusCode, gin.H{
+				"description": rateLimitErr.Error(),
+				"type":        "new_api_error",
+				"code":        4,
+			})
+			return
+		}
+		defer func() {
+			if mjErr != nil {
+				service.ReleaseTokenRateLimit(c)
+			}
+		}()
+	}
+	switch relayInfo.RelayMode {
 	case relayconstant.RelayModeMidjourneyNotify:
 		mjErr = relay.RelayMidjourneyNotify(c)
 	case relayconstant.RelayModeMidjourneyTaskFetch, relayconstant.RelayModeMidjourneyTaskFetchByCondition:
@@ -458,6 +585,13 @@ func RelayTask(c *gin.Context) {
 	if err != nil {
 		return
 	}
+	if !isTaskFetchMode(relayInfo.RelayMode) {
+		// 提交任务同样受 RPM/TPM 限制，任务不返回 Token 用量，按单次请求计入
+		if rateLimitErr := service.ReserveTokenRateLimit(c, relayInfo, 0); rateLimitErr != nil {
+			c.JSON(rateLimitErr.StatusCode, service.TaskErrorWrapperLocal(rateLimitErr.Err, string(rateLimitErr.GetErrorCode()), rateLimitErr.StatusCode))
+			return
+		}
+	}
 	taskErr := taskRelayHandler(c, relayInfo)
 	if taskErr == nil {
 		retryTimes = 0
@@ -500,6 +634,7 @@ func RelayTask(c *gin.Context) {
 		logger.LogInfo(c, retryLogStr)
 	}
 	if taskErr != nil {
+		service.ReleaseTokenRateLimit(c)
 		if taskErr.StatusCode == http.StatusTooManyRequests {
 			taskErr.Message = "当前分组上游负载已饱和，请稍后再试"
 		}
@@ -507,15 +642,19 @@ func RelayTask(c *gin.Context) {
 	}
 }
 
-func taskRelayHandler(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dto.TaskError {
-	var err *dto.TaskError
-	switch relayInfo.RelayMode {
+func isTaskFetchMode(relayMode int) bool {
+	switch relayMode {
 	case relayconstant.RelayModeSunoFetch, relayconstant.RelayModeSunoFetchByID, relayconstant.RelayModeVideoFetchByID:
-		err = relay.RelayTaskFetch(c, relayInfo.RelayMode)
-	default:
-		err = relay.RelayTaskSubmit(c, relayInfo)
+		return true
 	}
-	return err
+	return false
+}
+
+func taskRelayHandler(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dto.TaskError {
+	if isTaskFetchMode(relayInfo.RelayMode) {
+		return relay.RelayTaskFetch(c, relayInfo.RelayMode)
+	}
+	return relay.RelayTaskSubmit(c, relayInfo)
 }
 
 func shouldRetryTaskRelay(c *gin.Context, channelId int, taskErr *dto.TaskError, retryTimes int) bool {
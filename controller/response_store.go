@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetrieveResponse 获取网关保存的 Responses 响应，仅能访问当前令牌创建的响应
+// docs: https://platform.openai.com/docs/api-reference/responses/get
+func RetrieveResponse(c *gin.Context) {
+	stored, err := model.GetTokenStoredResponse(c.GetInt("token_id"), c.Param("id"))
+	if err != nil {
+		openAIResourceDBError(c, err, fmt.Sprintf("Response with id '%s' not found.", c.Param("id")))
+		return
+	}
+	c.Data(http.StatusOK, "application/json", stored.Response)
+}
+
+// DeleteResponse 删除网关保存的 Responses 响应
+// docs: https://platform.openai.com/docs/api-reference/responses/delete
+func DeleteResponse(c *gin.Context) {
+	responseId := c.Param("id")
+	if err := model.DeleteTokenStoredResponse(c.GetInt("token_id"), responseId); err != nil {
+		openAIResourceDBError(c, err, fmt.Sprintf("Response with id '%s' not found.", responseId))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      responseId,
+		"object":  "response",
+		"deleted": true,
+	})
+}
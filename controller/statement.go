@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StatementRequest struct {
+	Scope   string `json:"scope"`
+	ScopeId int    `json:"scope_id"`
+	Period  string `json:"period"`
+}
+
+type StatementLockRequest struct {
+	Period string `json:"period"`
+}
+
+// writeStatementFile 按 format 参数返回 CSV 或 PDF 格式的账单文件，默认为 CSV
+func writeStatementFile(c *gin.Context, statement *model.Statement) {
+	format := c.DefaultQuery("format", service.StatementFormatCSV)
+	data, contentType, err := service.RenderStatement(statement, format)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", service.StatementFileName(statement, format)))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// GetSelfStatements 返回当前用户已生成的账单
+func GetSelfStatements(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	statements, total, err := model.GetStatements(model.StatementScopeUser, c.GetInt("id"), "", pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetSelfStatement(c *gin.Context) {
+	statement, err := model.GetStatement(model.StatementScopeUser, c.GetInt("id"), c.Param("period"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"statement": statement,
+		"detail":    statement.GetDetail(),
+	})
+}
+
+func DownloadSelfStatement(c *gin.Context) {
+	statement, err := model.GetStatement(model.StatementScopeUser, c.GetInt("id"), c.Param("period"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	writeStatementFile(c, statement)
+}
+
+// GetOrganizationStatement 返回组织的账单，仅组织管理员与财务成员可查看
+func GetOrganizationStatement(c *gin.Context) {
+	statement, err := model.GetStatement(model.StatementScopeOrganization, getOrganizationMember(c).OrganizationId, c.Param("period"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"statement": statement,
+		"detail":    statement.GetDetail(),
+	})
+}
+
+func DownloadOrganizationStatement(c *gin.Context) {
+	statement, err := model.GetStatement(model.StatementScopeOrganization, getOrganizationMember(c).OrganizationId, c.Param("period"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	writeStatementFile(c, statement)
+}
+
+// GetAllStatements 管理员查询已生成的账单，可按对象与账期筛选
+func GetAllStatements(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	scopeId, _ := strconv.Atoi(c.Query("scope_id"))
+	statements, total, err := model.GetStatements(c.Query("scope"), scopeId, c.Query("period"), pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// RegenerateStatement 管理员重新生成指定用户或组织某一账期的账单，账期锁定后不能重新生成
+func RegenerateStatement(c *gin.Context) {
+	var req StatementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	switch req.Scope {
+	case model.StatementScopeUser:
+		if _, err := model.GetUserById(req.ScopeId, false); err != nil {
+			common.ApiErrorMsg(c, "用户不存在")
+			return
+		}
+	case model.StatementScopeOrganization:
+		if _, err := model.GetOrganizationById(req.ScopeId); err != nil {
+			common.ApiErrorMsg(c, "组织不存在")
+			return
+		}
+	default:
+		common.ApiErrorMsg(c, "无效的账单对象")
+		return
+	}
+	statement, err := model.GenerateStatement(req.Scope, req.ScopeId, req.Period)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, statement)
+}
+
+func DownloadStatement(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	statement, err := model.GetStatementById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	writeStatementFile(c, statement)
+}
+
+func GetStatementPeriodLocks(c *gin.Context) {
+	locks, err := model.GetStatementPeriodLocks()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, locks)
+}
+
+// LockStatementPeriod 锁定账期，为账期内有记录的用户与组织生成最终账单，锁定后不再重新生成
+func LockStatementPeriod(c *gin.Context) {
+	var req StatementLockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.LockStatementPeriod(req.Period, c.GetInt("id")); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func UnlockStatementPeriod(c *gin.Context) {
+	if err := model.UnlockStatementPeriod(c.Param("period")); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/Calcium-Ion/go-epay/epay"
+	"github.com/gin-gonic/gin"
+	"github.com/thanhpk/randstr"
+)
+
+type SubscriptionPayRequest struct {
+	PlanId        int    `json:"plan_id"`
+	PaymentMethod string `json:"payment_method"`
+}
+
+func GetAllSubscriptionPlans(c *gin.Context) {
+	plans, err := model.GetSubscriptionPlans(false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plans)
+}
+
+func AddSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := plan.Validate(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	plan.Id = 0
+	if err := plan.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plan)
+}
+
+func UpdateSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if plan.Id == 0 {
+		common.ApiErrorMsg(c, "缺少套餐 ID")
+		return
+	}
+	if err := plan.Validate(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := plan.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plan)
+}
+
+func DeleteSubscriptionPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.DeleteSubscriptionPlanById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetAllUserSubscriptions 管理员查看订阅记录，可按 user_id 过滤
+func GetAllUserSubscriptions(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	pageInfo := common.GetPageQuery(c)
+	subscriptions, total, err := model.GetUserSubscriptions(userId, pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(subscriptions)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetSubscriptionPlans 返回用户可订阅的套餐及当前生效的订阅
+func GetSubscriptionPlans(c *gin.Context) {
+	if !operation_setting.GetSubscriptionSetting().Enabled {
+		common.ApiSuccess(c, gin.H{"enabled": false})
+		return
+	}
+	plans, err := model.GetSubscriptionPlans(true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	subscription, err := model.GetActiveUserSubscription(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"enabled":       true,
+		"plans":         plans,
+		"subscription":  subscription,
+		"enable_epay":   GetEpayClient() != nil,
+		"enable_stripe": setting.StripeApiSecret != "" && setting.StripeWebhookSecret != "",
+		"enable_creem":  setting.CreemApiKey != "",
+	})
+}
+
+func GetSelfSubscriptions(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	subscriptions, total, err := model.GetUserSubscriptions(c.GetInt("id"), pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(subscriptions)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// checkSubscribable 校验用户能否购买该套餐，已有其他套餐生效时需要等待到期后再订阅
+func checkSubscribable(userId int, planId int) (*model.SubscriptionPlan, error) {
+	if !operation_setting.GetSubscriptionSetting().Enabled {
+		return nil, errors.New("订阅功能未开启")
+	}
+	plan, err := model.GetSubscriptionPlanById(planId)
+	if err != nil || !plan.Enabled {
+		return nil, errors.New("套餐不存在")
+	}
+	subscription, err := model.GetActiveUserSubscription(userId)
+	if err != nil {
+		return nil, err
+	}
+	if subscription != nil && subscription.PlanId != plan.Id {
+		return nil, fmt.Errorf("当前已订阅套餐 %s，请在到期后再订阅其他套餐", subscription.PlanName)
+	}
+	return plan, nil
+}
+
+// RequestSubscriptionPay 创建订阅套餐订单，根据支付方式拉起易支付、Stripe 或 Creem 支付
+func RequestSubscriptionPay(c *gin.Context) {
+	var req SubscriptionPayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": "参数错误"})
+		return
+	}
+	id := c.GetInt("id")
+	plan, err := checkSubscribable(id, req.PlanId)
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": "获取用户信息失败"})
+		return
+	}
+
+	topUp := &model.TopUp{
+		UserId:        id,
+		Money:         plan.Price,
+		PaymentMethod: req.PaymentMethod,
+		CreateTime:    time.Now().Unix(),
+		Status:        common.TopUpStatusPending,
+		PlanId:        plan.Id,
+	}
+	var data gin.H
+	switch req.PaymentMethod {
+	case PaymentMethodStripe:
+		if plan.StripePriceId == "" {
+			c.JSON(200, gin.H{"message": "error", "data": "该套餐不支持 Stripe 支付"})
+			return
+		}
+		reference := fmt.Sprintf("new-api-sub-%d-%d-%s", id, time.Now().UnixMilli(), randstr.String(4))
+		topUp.TradeNo = "ref_" + common.Sha1([]byte(reference))
+		payLink, err := genStripeLink(topUp.TradeNo, user.StripeCustomer, user.Email, plan.StripePriceId, 1)
+		if err != nil {
+			log.Println("获取Stripe Checkout支付链接失败", err)
+			c.JSON(200, gin.H{"message": "error", "data": "拉起支付失败"})
+			return
+		}
+		data = gin.H{"pay_link": payLink}
+	case PaymentMethodCreem:
+		if plan.CreemProductId == "" {
+			c.JSON(200, gin.H{"message": "error", "data": "该套餐不支持 Creem 支付"})
+			return
+		}
+		reference := fmt.Sprintf("creem-api-sub-%d-%d-%s", id, time.Now().UnixMilli(), randstr.String(4))
+		topUp.TradeNo = "ref_" + common.Sha1([]byte(reference))
+		product := &CreemProduct{ProductId: plan.CreemProductId, Name: plan.Name, Price: plan.Price}
+		checkoutUrl, err := genCreemLink(topUp.TradeNo, product, user.Email, user.Username)
+		if err != nil {
+			log.Printf("获取Creem支付链接失败: %v", err)
+			c.JSON(200, gin.H{"message": "error", "data": "拉起支付失败"})
+			return
+		}
+		data = gin.H{"checkout_url": checkoutUrl, "order_id": topUp.TradeNo}
+	default:
+		if !operation_setting.ContainsPayMethod(req.PaymentMethod) {
+			c.JSON(200, gin.H{"message": "error", "data": "支付方式不存在"})
+			return
+		}
+		if plan.Price < 0.01 {
+			c.JSON(200, gin.H{"message": "error", "data": "充值金额过低"})
+			return
+		}
+		client := GetEpayClient()
+		if client == nil {
+			c.JSON(200, gin.H{"message": "error", "data": "当前管理员未配置支付信息"})
+			return
+		}
+		returnUrl, _ := url.Parse(system_setting.ServerAddress + "/console/topup")
+		notifyUrl, _ := url.Parse(service.GetCallbackAddress() + "/api/user/epay/notify")
+		topUp.TradeNo = fmt.Sprintf("SUB%dNO%s%d", id, common.GetRandomString(6), time.Now().Unix())
+		uri, params, err := client.Purchase(&epay.PurchaseArgs{
+			Type:           req.PaymentMethod,
+			ServiceTradeNo: topUp.TradeNo,
+			Name:           fmt.Sprintf("SUB%d", plan.Id),
+			Money:          strconv.FormatFloat(plan.Price, 'f', 2, 64),
+			Device:         epay.PC,
+			NotifyUrl:      notifyUrl,
+			ReturnUrl:      returnUrl,
+		})
+		if err != nil {
+			c.JSON(200, gin.H{"message": "error", "data": "拉起支付失败"})
+			return
+		}
+		if err := topUp.Insert(); err != nil {
+			c.JSON(200, gin.H{"message": "error", "data": "创建订单失败"})
+			return
+		}
+		c.JSON(200, gin.H{"message": "success", "data": params, "url": uri})
+		return
+	}
+	if err := topUp.Insert(); err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": "创建订单失败"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "success", "data": data})
+}
@@ -12,11 +12,13 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/metrics"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/relay"
+	"github.com/QuantumNous/new-api/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/samber/lo"
@@ -34,6 +36,11 @@ func UpdateTaskBulk() {
 		for _, t := range allTasks {
 			platformTask[t.Platform] = append(platformTask[t.Platform], t)
 		}
+		queueDepths := make(map[string]int)
+		for platform, tasks := range platformTask {
+			queueDepths[string(platform)] = len(tasks)
+		}
+		metrics.SetTaskQueueDepth("task", queueDepths)
 		for platform, tasks := range platformTask {
 			if len(tasks) == 0 {
 				continue
@@ -165,12 +172,12 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 			} else {
 				quota := task.Quota
 				if quota != 0 {
-					err = model.IncreaseUserQuota(task.UserId, quota, false)
+					err = service.RefundTaskQuota(task, quota)
 					if err != nil {
 						logger.LogError(ctx, "fail to increase user quota: "+err.Error())
 					}
 					logContent := fmt.Sprintf("异步任务执行失败 %s，补偿 %s", task.TaskID, logger.LogQuota(quota))
-					model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
+					model.RecordRefundLog(task.UserId, task.OrganizationId, quota, logContent)
 				}
 			}
 		}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -119,6 +120,15 @@ func GetTokenUsage(c *gin.Context) {
 		expiredAt = 0
 	}
 
+	budget, err := service.GetTokenBudgetUsage(token)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    true,
 		"message": "ok",
@@ -132,6 +142,7 @@ func GetTokenUsage(c *gin.Context) {
 			"model_limits":         token.GetModelLimitsMap(),
 			"model_limits_enabled": token.ModelLimitsEnabled,
 			"expires_at":           expiredAt,
+			"budget":               budget,
 		},
 	})
 }
@@ -150,6 +161,27 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if token.TpmLimit < 0 || token.RpmLimit < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "TPM/RPM 限制不能为负数",
+		})
+		return
+	}
+	if token.DailyBudget < 0 || token.MonthlyBudget < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "消费预算不能为负数",
+		})
+		return
+	}
+	if err := service.ValidateModelFallbackChains(token.ModelFallback); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	// 非无限额度时，检查额度值是否超出有效范围
 	if !token.UnlimitedQuota {
 		if token.RemainQuota < 0 {
@@ -168,6 +200,13 @@ func AddToken(c *gin.Context) {
 			return
 		}
 	}
+	if token.OrganizationId != 0 {
+		// 组织令牌消费组织额度，创建者需要是可使用组织额度的成员
+		if _, err := model.GetActiveOrganizationMember(token.OrganizationId, c.GetInt("id")); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
 	key, err := common.GenerateKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -191,6 +230,14 @@ func AddToken(c *gin.Context) {
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
+		ResponseCache:      token.ResponseCache,
+		HedgeEnabled:       token.HedgeEnabled,
+		TpmLimit:           token.TpmLimit,
+		RpmLimit:           token.RpmLimit,
+		ModelFallback:      token.ModelFallback,
+		DailyBudget:        token.DailyBudget,
+		MonthlyBudget:      token.MonthlyBudget,
+		OrganizationId:     token.OrganizationId,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -235,6 +282,27 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if token.TpmLimit < 0 || token.RpmLimit < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "TPM/RPM 限制不能为负数",
+		})
+		return
+	}
+	if token.DailyBudget < 0 || token.MonthlyBudget < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "消费预算不能为负数",
+		})
+		return
+	}
+	if err := service.ValidateModelFallbackChains(token.ModelFallback); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	if !token.UnlimitedQuota {
 		if token.RemainQuota < 0 {
 			c.JSON(http.StatusOK, gin.H{
@@ -286,6 +354,13 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.ResponseCache = token.ResponseCache
+		cleanToken.HedgeEnabled = token.HedgeEnabled
+		cleanToken.TpmLimit = token.TpmLimit
+		cleanToken.RpmLimit = token.RpmLimit
+		cleanToken.ModelFallback = token.ModelFallback
+		cleanToken.DailyBudget = token.DailyBudget
+		cleanToken.MonthlyBudget = token.MonthlyBudget
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -531,9 +531,11 @@ func generateDefaultSidebarConfig(userRole int) string {
 
 	// 个人中心区域 - 所有用户都可以访问
 	defaultConfig["personal"] = map[string]interface{}{
-		"enabled":  true,
-		"topup":    true,
-		"personal": true,
+		"enabled":      true,
+		"topup":        true,
+		"organization": true,
+		"statement":    true,
+		"personal":     true,
 	}
 
 	// 管理员区域 - 根据角色决定
@@ -1112,6 +1114,8 @@ type UpdateUserSettingRequest struct {
 	GotifyPriority             int     `json:"gotify_priority,omitempty"`
 	AcceptUnsetModelRatioModel bool    `json:"accept_unset_model_ratio_model"`
 	RecordIpLog                bool    `json:"record_ip_log"`
+	DailyBudget                int     `json:"daily_budget"`
+	MonthlyBudget              int     `json:"monthly_budget"`
 }
 
 func UpdateUserSetting(c *gin.Context) {
@@ -1142,6 +1146,15 @@ func UpdateUserSetting(c *gin.Context) {
 		return
 	}
 
+	// 验证消费预算
+	if req.DailyBudget < 0 || req.MonthlyBudget < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "消费预算不能为负数",
+		})
+		return
+	}
+
 	// 如果是webhook类型,验证webhook地址
 	if req.QuotaWarningType == dto.NotifyTypeWebhook {
 		if req.WebhookUrl == "" {
@@ -1247,6 +1260,8 @@ func UpdateUserSetting(c *gin.Context) {
 		QuotaWarningThreshold: req.QuotaWarningThreshold,
 		AcceptUnsetRatioModel: req.AcceptUnsetModelRatioModel,
 		RecordIpLog:           req.RecordIpLog,
+		DailyBudget:           req.DailyBudget,
+		MonthlyBudget:         req.MonthlyBudget,
 	}
 
 	// 如果是webhook类型,添加webhook相关设置
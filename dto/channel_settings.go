@@ -7,6 +7,9 @@ type ChannelSettings struct {
 	PassThroughBodyEnabled bool   `json:"pass_through_body_enabled,omitempty"`
 	SystemPrompt           string `json:"system_prompt,omitempty"`
 	SystemPromptOverride   bool   `json:"system_prompt_override,omitempty"`
+	// 上游不支持 json_schema 时由网关注入格式说明并校验输出，校验失败时修复或重新请求
+	StructuredOutputEmulation  bool `json:"structured_output_emulation,omitempty"`
+	StructuredOutputMaxRetries int  `json:"structured_output_max_retries,omitempty"` // 校验失败后的最大重新请求次数，0 表示使用默认值
 }
 
 type VertexKeyType string
@@ -301,6 +301,12 @@ func (p *GeminiPart) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// GeminiCountTokensRequest 为 models/*:countTokens 的请求体，contents 与 generateContentRequest 二选一
+type GeminiCountTokensRequest struct {
+	Contents               []GeminiChatContent `json:"contents,omitempty"`
+	GenerateContentRequest *GeminiChatRequest  `json:"generateContentRequest,omitempty"`
+}
+
 type GeminiChatContent struct {
 	Role  string       `json:"role,omitempty"`
 	Parts []GeminiPart `json:"parts"`
@@ -372,6 +378,8 @@ type GeminiUsageMetadata struct {
 	TotalTokenCount      int                         `json:"totalTokenCount"`
 	ThoughtsTokenCount   int                         `json:"thoughtsTokenCount"`
 	PromptTokensDetails  []GeminiPromptTokensDetails `json:"promptTokensDetails"`
+	// 命中上下文缓存的输入用量，已包含在 PromptTokenCount 中
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"`
 }
 
 type GeminiPromptTokensDetails struct {
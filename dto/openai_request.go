@@ -237,6 +237,29 @@ func (r *GeneralOpenAIRequest) GetSystemRoleName() string {
 	return "system"
 }
 
+// AppendSystemInstruction 将网关生成的说明拼接到系统提示词之后，请求中没有系统提示词时新增一条
+func (r *GeneralOpenAIRequest) AppendSystemInstruction(instruction string) {
+	systemRole := r.GetSystemRoleName()
+	for i, message := range r.Messages {
+		if message.Role != systemRole {
+			continue
+		}
+		if message.IsStringContent() {
+			r.Messages[i].SetStringContent(message.StringContent() + "\n\n" + instruction)
+		} else {
+			r.Messages[i].Content = append(message.ParseContent(), MediaContent{
+				Type: ContentTypeText,
+				Text: instruction,
+			})
+		}
+		return
+	}
+	r.Messages = append([]Message{{
+		Role:    systemRole,
+		Content: instruction,
+	}}, r.Messages...)
+}
+
 const CustomType = "custom"
 
 type ToolCallRequest struct {
@@ -331,6 +331,7 @@ func (o *OpenAIResponsesResponse) GetSize() string {
 
 type IncompleteDetails struct {
 	Reasoning string `json:"reasoning"`
+	Reason    string `json:"reason,omitempty"`
 }
 
 type ResponsesOutput struct {
@@ -341,6 +342,12 @@ type ResponsesOutput struct {
 	Content []ResponsesOutputContent `json:"content"`
 	Quality string                   `json:"quality"`
 	Size    string                   `json:"size"`
+	// function_call
+	CallId    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	// reasoning
+	Summary []ResponsesOutputContent `json:"summary,omitempty"`
 }
 
 type ResponsesOutputContent struct {
@@ -365,10 +372,18 @@ const (
 
 // ResponsesStreamResponse 用于处理 /v1/responses 流式响应
 type ResponsesStreamResponse struct {
-	Type     string                   `json:"type"`
-	Response *OpenAIResponsesResponse `json:"response,omitempty"`
-	Delta    string                   `json:"delta,omitempty"`
-	Item     *ResponsesOutput         `json:"item,omitempty"`
+	Type           string                   `json:"type"`
+	SequenceNumber int                      `json:"sequence_number,omitempty"`
+	Response       *OpenAIResponsesResponse `json:"response,omitempty"`
+	Delta          string                   `json:"delta,omitempty"`
+	Item           *ResponsesOutput         `json:"item,omitempty"`
+	OutputIndex    *int                     `json:"output_index,omitempty"`
+	ContentIndex   *int                     `json:"content_index,omitempty"`
+	SummaryIndex   *int                     `json:"summary_index,omitempty"`
+	ItemID         string                   `json:"item_id,omitempty"`
+	Part           *ResponsesOutputContent  `json:"part,omitempty"`
+	Text           *string                  `json:"text,omitempty"`
+	Arguments      string                   `json:"arguments,omitempty"`
 }
 
 // GetOpenAIError 从动态错误类型中提取OpenAIError结构
@@ -13,6 +13,8 @@ type UserSetting struct {
 	AcceptUnsetRatioModel bool    `json:"accept_unset_model_ratio_model,omitempty"` // AcceptUnsetRatioModel 是否接受未设置价格的模型
 	RecordIpLog           bool    `json:"record_ip_log,omitempty"`                  // 是否记录请求和错误日志IP
 	SidebarModules        string  `json:"sidebar_modules,omitempty"`                // SidebarModules 左侧边栏模块配置
+	DailyBudget           int     `json:"daily_budget,omitempty"`                   // DailyBudget 每日消费预算，0 表示不限制
+	MonthlyBudget         int     `json:"monthly_budget,omitempty"`                 // MonthlyBudget 每月消费预算，0 表示不限制
 }
 
 var (
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/tracing"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func validUserInfo(username string, role int) bool {
@@ -174,12 +176,41 @@ func RootAuth() func(c *gin.Context) {
 	}
 }
 
+// OrganizationAuth 校验当前用户是路由参数 id 对应组织的成员，并拥有指定角色之一，roles 为空时所有成员均可访问。
+// 需要在 UserAuth 之后使用，成员信息保存在 organization_member 中
+func OrganizationAuth(roles ...string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		organizationId, _ := strconv.Atoi(c.Param("id"))
+		member, err := model.GetOrganizationMember(organizationId, c.GetInt("id"))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+		if len(roles) > 0 && !member.HasRole(roles...) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权进行此操作，组织角色权限不足",
+			})
+			c.Abort()
+			return
+		}
+		c.Set("organization_member", member)
+		c.Next()
+	}
+}
+
 func WssAuth(c *gin.Context) {
 
 }
 
 func TokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		span, endSpan := tracing.StartSpan(c, "middleware.token_auth")
+		defer endSpan()
 		// 先检测是否为ws
 		if c.Request.Header.Get("Sec-WebSocket-Protocol") != "" {
 			// Sec-WebSocket-Protocol: realtime, openai-insecure-api-key.sk-xxx, openai-beta.realtime-v1
@@ -290,10 +321,28 @@ func TokenAuth() func(c *gin.Context) {
 		}
 		common.SetContextKey(c, constant.ContextKeyUsingGroup, userGroup)
 
+		if token.OrganizationId != 0 {
+			// 组织令牌：校验创建者仍是组织成员，请求消费组织的共享额度
+			member, err := model.GetActiveOrganizationMember(token.OrganizationId, token.UserId)
+			if err != nil {
+				abortWithOpenAiMessage(c, http.StatusForbidden, err.Error())
+				return
+			}
+			common.SetContextKey(c, constant.ContextKeyOrganizationId, token.OrganizationId)
+			common.SetContextKey(c, constant.ContextKeyOrganizationMemberId, member.Id)
+			common.SetContextKey(c, constant.ContextKeyOrganizationMonthlyLimit, member.MonthlyLimit)
+		}
+
 		err = SetupContextForToken(c, token, parts...)
 		if err != nil {
 			return
 		}
+		span.SetAttributes(
+			attribute.Int("user.id", token.UserId),
+			attribute.Int("token.id", token.Id),
+			attribute.String("token.group", userGroup),
+		)
+		endSpan()
 		c.Next()
 	}
 }
@@ -318,6 +367,13 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	common.SetContextKey(c, constant.ContextKeyTokenGroup, token.Group)
 	common.SetContextKey(c, constant.ContextKeyTokenCrossGroupRetry, token.CrossGroupRetry)
+	common.SetContextKey(c, constant.ContextKeyTokenResponseCache, token.ResponseCache)
+	common.SetContextKey(c, constant.ContextKeyTokenHedgeEnabled, token.HedgeEnabled)
+	common.SetContextKey(c, constant.ContextKeyTokenTpmLimit, token.TpmLimit)
+	common.SetContextKey(c, constant.ContextKeyTokenRpmLimit, token.RpmLimit)
+	common.SetContextKey(c, constant.ContextKeyTokenModelFallback, token.GetModelFallback())
+	common.SetContextKey(c, constant.ContextKeyTokenDailyBudget, token.DailyBudget)
+	common.SetContextKey(c, constant.ContextKeyTokenMonthlyBudget, token.MonthlyBudget)
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
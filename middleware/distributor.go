@@ -10,8 +10,10 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/tracing"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/service"
@@ -19,6 +21,7 @@ import (
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type ModelRequest struct {
@@ -28,6 +31,10 @@ type ModelRequest struct {
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		span, endSpan := tracing.StartSpan(c, "middleware.distribute")
+		defer endSpan()
+		// 请求结束后释放渠道并发槽位
+		defer service.ReleaseChannelSlot(c)
 		var channel *model.Channel
 		channelId, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId)
 		modelRequest, shouldSelectChannel, err := getModelRequest(c)
@@ -97,12 +104,34 @@ func Distribute() func(c *gin.Context) {
 						common.SetContextKey(c, constant.ContextKeyUsingGroup, usingGroup)
 					}
 				}
+				// 虚拟模型按权重分流到目标模型，之后按目标模型选择渠道与计费
+				if target, ok := service.ResolveVirtualModel(c, modelRequest.Model); ok {
+					if target == "" {
+						abortWithOpenAiMessage(c, http.StatusForbidden, "虚拟模型 "+modelRequest.Model+" 没有当前令牌可访问的目标模型")
+						return
+					}
+					common.SetContextKey(c, constant.ContextKeyVirtualModel, modelRequest.Model)
+					common.SetContextKey(c, constant.ContextKeyVirtualModelArm, target)
+					modelRequest.Model = target
+				}
 				channel, selectGroup, err = service.CacheGetRandomSatisfiedChannel(&service.RetryParam{
 					Ctx:        c,
 					ModelName:  modelRequest.Model,
 					TokenGroup: usingGroup,
 					Retry:      common.GetPointer(0),
 				})
+				// 请求的模型没有可用渠道时，按降级链改用其他模型
+				if err != nil || channel == nil {
+					if fallbackModel, fallbackChannel := selectModelFallbackChannel(c, modelRequest.Model, usingGroup); fallbackChannel != nil {
+						logger.LogInfo(c, fmt.Sprintf("model %s has no available channel, fallback to %s", modelRequest.Model, fallbackModel))
+						common.SetContextKey(c, constant.ContextKeyModelFallbackFrom, modelRequest.Model)
+						modelRequest.Model, channel, err = fallbackModel, fallbackChannel, nil
+					}
+				}
+				if errors.Is(err, model.ErrChannelsSaturated) {
+					abortWithOpenAiMessage(c, http.StatusTooManyRequests, fmt.Sprintf("分组 %s 下模型 %s 的渠道繁忙，请稍后重试: %s", usingGroup, modelRequest.Model, err.Error()), string(types.ErrorCodeGetChannelFailed))
+					return
+				}
 				if err != nil {
 					showGroup := usingGroup
 					if usingGroup == "auto" {
@@ -124,11 +153,39 @@ func Distribute() func(c *gin.Context) {
 			}
 		}
 		common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
-		SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+		if newAPIError := SetupContextForSelectedChannel(c, channel, modelRequest.Model); newAPIError != nil && channel != nil {
+			// 例如多 Key 渠道的 Key 全部被熔断
+			abortWithOpenAiMessage(c, http.StatusServiceUnavailable, fmt.Sprintf("渠道 #%d 暂不可用：%s", channel.Id, newAPIError.Error()), string(newAPIError.GetErrorCode()))
+			return
+		}
+		if channel != nil {
+			span.SetAttributes(
+				attribute.Int("channel.id", channel.Id),
+				attribute.Int("channel.type", channel.Type),
+				attribute.String("gen_ai.request.model", modelRequest.Model),
+			)
+		}
+		// 转发的每次尝试挂在选渠道的 Span 下，Span 在请求结束时结束
 		c.Next()
 	}
 }
 
+// selectModelFallbackChannel 依次为降级链中的模型选择渠道，返回第一个有可用渠道的模型
+func selectModelFallbackChannel(c *gin.Context, modelName string, usingGroup string) (string, *model.Channel) {
+	for _, fallbackModel := range service.GetModelFallbackChain(c, modelName) {
+		channel, _, err := service.CacheGetRandomSatisfiedChannel(&service.RetryParam{
+			Ctx:        c,
+			ModelName:  fallbackModel,
+			TokenGroup: usingGroup,
+			Retry:      common.GetPointer(0),
+		})
+		if err == nil && channel != nil {
+			return fallbackModel, channel
+		}
+	}
+	return "", nil
+}
+
 // getModelFromRequest 从请求中读取模型信息
 // 根据 Content-Type 自动处理：
 // - application/json
@@ -319,6 +376,7 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	common.SetContextKey(c, constant.ContextKeyChannelOtherSetting, channel.GetOtherSettings())
 	common.SetContextKey(c, constant.ContextKeyChannelParamOverride, channel.GetParamOverride())
 	common.SetContextKey(c, constant.ContextKeyChannelHeaderOverride, channel.GetHeaderOverride())
+	common.SetContextKey(c, constant.ContextKeyChannelTransformScript, channel.GetTransformScript())
 	if nil != channel.OpenAIOrganization && *channel.OpenAIOrganization != "" {
 		common.SetContextKey(c, constant.ContextKeyChannelOrganization, *channel.OpenAIOrganization)
 	}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+var metricsLoopbackIps = []string{"127.0.0.1", "::1"}
+
+// MetricsAuth 校验 /metrics 的访问权限：携带正确的 Bearer Token 或来源 IP 在白名单中
+func MetricsAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !constant.MetricsEnabled {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if constant.MetricsToken != "" {
+			token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(constant.MetricsToken)) == 1 {
+				c.Next()
+				return
+			}
+		}
+		allowedIps := constant.MetricsAllowedIps
+		if len(allowedIps) == 0 && constant.MetricsToken == "" {
+			allowedIps = metricsLoopbackIps
+		}
+		ip := net.ParseIP(c.ClientIP())
+		if ip != nil && common.IsIpInCIDRList(ip, allowedIps) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+}
@@ -0,0 +1,262 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+
+	"gorm.io/gorm"
+)
+
+// Batch OpenAI Batch API 任务，逐行通过网关自身的中继链路执行
+type Batch struct {
+	Id               int    `json:"id"`
+	BatchId          string `json:"batch_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId           int    `json:"user_id" gorm:"index"`
+	TokenId          int    `json:"token_id" gorm:"index"`
+	ClientIp         string `json:"-" gorm:"type:varchar(64);default:''"` // 创建时的客户端 IP，用于执行时通过令牌 IP 限制
+	Endpoint         string `json:"endpoint" gorm:"type:varchar(64)"`
+	InputFileId      string `json:"input_file_id" gorm:"type:varchar(64)"`
+	OutputFileId     string `json:"output_file_id" gorm:"type:varchar(64);default:''"`
+	ErrorFileId      string `json:"error_file_id" gorm:"type:varchar(64);default:''"`
+	CompletionWindow string `json:"completion_window" gorm:"type:varchar(16)"`
+	Status           string `json:"status" gorm:"type:varchar(20);index"`
+	Errors           string `json:"errors" gorm:"type:text"`
+	Metadata         string `json:"metadata" gorm:"type:text"`
+	TotalCount       int    `json:"total_count" gorm:"default:0"`
+	CompletedCount   int    `json:"completed_count" gorm:"default:0"`
+	FailedCount      int    `json:"failed_count" gorm:"default:0"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint;index"`
+	InProgressAt     int64  `json:"in_progress_at" gorm:"bigint;default:0"`
+	ExpiresAt        int64  `json:"expires_at" gorm:"bigint;default:0"`
+	FinalizingAt     int64  `json:"finalizing_at" gorm:"bigint;default:0"`
+	CompletedAt      int64  `json:"completed_at" gorm:"bigint;default:0"`
+	FailedAt         int64  `json:"failed_at" gorm:"bigint;default:0"`
+	ExpiredAt        int64  `json:"expired_at" gorm:"bigint;default:0"`
+	CancellingAt     int64  `json:"cancelling_at" gorm:"bigint;default:0"`
+	CancelledAt      int64  `json:"cancelled_at" gorm:"bigint;default:0"`
+}
+
+// BatchItem 单行请求的执行结果，Batch 结束时汇总为输出/错误文件后删除
+type BatchItem struct {
+	Id         int    `json:"id"`
+	BatchId    string `json:"batch_id" gorm:"type:varchar(64);uniqueIndex:idx_batch_item_line,priority:1"`
+	Line       int    `json:"line" gorm:"uniqueIndex:idx_batch_item_line,priority:2"`
+	CustomId   string `json:"custom_id" gorm:"type:varchar(255)"`
+	StatusCode int    `json:"status_code"`
+	Success    bool   `json:"success"`
+	Output     string `json:"output" gorm:"type:text"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint"`
+}
+
+func NewBatchId() string {
+	return "batch_" + common.GetRandomString(24)
+}
+
+func optionalTime(t int64) *int64 {
+	if t == 0 {
+		return nil
+	}
+	return &t
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func (b *Batch) SetErrors(errs []dto.BatchError) {
+	if len(errs) == 0 {
+		b.Errors = ""
+		return
+	}
+	data, _ := common.Marshal(dto.BatchErrors{Object: "list", Data: errs})
+	b.Errors = string(data)
+}
+
+func (b *Batch) ToOpenAIBatch() *dto.OpenAIBatch {
+	batch := &dto.OpenAIBatch{
+		Id:               b.BatchId,
+		Object:           "batch",
+		Endpoint:         b.Endpoint,
+		InputFileId:      b.InputFileId,
+		CompletionWindow: b.CompletionWindow,
+		Status:           b.Status,
+		OutputFileId:     optionalString(b.OutputFileId),
+		ErrorFileId:      optionalString(b.ErrorFileId),
+		CreatedAt:        b.CreatedAt,
+		InProgressAt:     optionalTime(b.InProgressAt),
+		ExpiresAt:        optionalTime(b.ExpiresAt),
+		FinalizingAt:     optionalTime(b.FinalizingAt),
+		CompletedAt:      optionalTime(b.CompletedAt),
+		FailedAt:         optionalTime(b.FailedAt),
+		ExpiredAt:        optionalTime(b.ExpiredAt),
+		CancellingAt:     optionalTime(b.CancellingAt),
+		CancelledAt:      optionalTime(b.CancelledAt),
+		RequestCounts: dto.BatchRequestCounts{
+			Total:     b.TotalCount,
+			Completed: b.CompletedCount,
+			Failed:    b.FailedCount,
+		},
+	}
+	if b.Errors != "" {
+		var errs dto.BatchErrors
+		if err := common.UnmarshalJsonStr(b.Errors, &errs); err == nil {
+			batch.Errors = &errs
+		}
+	}
+	if b.Metadata != "" {
+		_ = common.UnmarshalJsonStr(b.Metadata, &batch.Metadata)
+	}
+	return batch
+}
+
+func (b *Batch) IsFinished() bool {
+	switch b.Status {
+	case dto.BatchStatusCompleted, dto.BatchStatusFailed, dto.BatchStatusExpired, dto.BatchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (b *Batch) Insert() error {
+	if b.BatchId == "" {
+		b.BatchId = NewBatchId()
+	}
+	if b.CreatedAt == 0 {
+		b.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(b).Error
+}
+
+// UpdateStatus 仅当 Batch 仍处于 expected 状态时更新 columns（需包含 status），返回是否更新成功。
+// 后台任务持有的 Batch 可能已过期，按状态条件更新可避免覆盖并发的取消操作
+func (b *Batch) UpdateStatus(expected string, columns map[string]any) (bool, error) {
+	result := DB.Model(&Batch{}).Where("id = ? AND status = ?", b.Id, expected).Updates(columns)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	if status, ok := columns["status"].(string); ok {
+		b.Status = status
+	}
+	return true, nil
+}
+
+func GetUserBatch(userId int, batchId string) (*Batch, error) {
+	if batchId == "" {
+		return nil, errors.New("batch id 为空！")
+	}
+	var batch Batch
+	err := DB.Where("batch_id = ? AND user_id = ?", batchId, userId).First(&batch).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func GetBatchByBatchId(batchId string) (*Batch, error) {
+	var batch Batch
+	err := DB.Where("batch_id = ?", batchId).First(&batch).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// GetUserBatches 按创建时间倒序列出用户的 Batch，after 为上一页最后一个 batch id
+func GetUserBatches(userId int, after string, limit int) (batches []*Batch, err error) {
+	query := DB.Where("user_id = ?", userId)
+	if after != "" {
+		var cursor Batch
+		if err = DB.Select("id").Where("batch_id = ? AND user_id = ?", after, userId).First(&cursor).Error; err != nil {
+			return nil, err
+		}
+		query = query.Where("id < ?", cursor.Id)
+	}
+	err = query.Order("id desc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+func GetAllUnFinishBatches(limit int) []*Batch {
+	var batches []*Batch
+	err := DB.Where("status IN ?", []string{dto.BatchStatusValidating, dto.BatchStatusInProgress, dto.BatchStatusFinalizing, dto.BatchStatusCancelling}).
+		Order("id asc").Limit(limit).Find(&batches).Error
+	if err != nil {
+		return nil
+	}
+	return batches
+}
+
+// CancelUserBatch 将校验中或执行中的 Batch 置为 cancelling，由后台任务完成收尾，
+// 已进入 finalizing 的 Batch 不再取消
+func CancelUserBatch(userId int, batchId string) (*Batch, error) {
+	batch, err := GetUserBatch(userId, batchId)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status != dto.BatchStatusValidating && batch.Status != dto.BatchStatusInProgress {
+		return batch, nil
+	}
+	cancellingAt := common.GetTimestamp()
+	result := DB.Model(&Batch{}).Where("id = ? AND status IN ?", batch.Id,
+		[]string{dto.BatchStatusValidating, dto.BatchStatusInProgress}).
+		Updates(map[string]any{"status": dto.BatchStatusCancelling, "cancelling_at": cancellingAt})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// 状态已被后台任务更新，返回最新状态
+		return GetUserBatch(userId, batchId)
+	}
+	batch.Status = dto.BatchStatusCancelling
+	batch.CancellingAt = cancellingAt
+	return batch, nil
+}
+
+func GetBatchStatus(id int) (string, error) {
+	var batch Batch
+	err := DB.Select("status").Where("id = ?", id).First(&batch).Error
+	return batch.Status, err
+}
+
+func InsertBatchItem(item *BatchItem) error {
+	item.CreatedAt = common.GetTimestamp()
+	return DB.Create(item).Error
+}
+
+// IncreaseBatchCounts 累加 Batch 的完成/失败计数
+func IncreaseBatchCounts(id int, success bool) error {
+	column := "failed_count"
+	if success {
+		column = "completed_count"
+	}
+	return DB.Model(&Batch{}).Where("id = ?", id).UpdateColumn(column, gorm.Expr(column+" + ?", 1)).Error
+}
+
+func GetBatchItemLines(batchId string) (map[int]bool, error) {
+	var lines []int
+	err := DB.Model(&BatchItem{}).Where("batch_id = ?", batchId).Pluck("line", &lines).Error
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int]bool, len(lines))
+	for _, line := range lines {
+		done[line] = true
+	}
+	return done, nil
+}
+
+func GetBatchItems(batchId string) (items []*BatchItem, err error) {
+	err = DB.Where("batch_id = ?", batchId).Order("line asc").Find(&items).Error
+	return items, err
+}
+
+func DeleteBatchItems(batchId string) error {
+	return DB.Where("batch_id = ?", batchId).Delete(&BatchItem{}).Error
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+
+	"gorm.io/gorm"
+)
+
+func TestBatchUpdateStatusKeepsConcurrentCancel(t *testing.T) {
+	setupTestDB(t)
+	batch := &Batch{UserId: 1, Status: dto.BatchStatusInProgress}
+	if err := batch.Insert(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 后台任务持有的 batch 仍为 in_progress，此时用户取消
+	cancelled, err := CancelUserBatch(1, batch.BatchId)
+	if err != nil || cancelled.Status != dto.BatchStatusCancelling {
+		t.Fatalf("cancel failed: %v %+v", err, cancelled)
+	}
+
+	updated, err := batch.UpdateStatus(dto.BatchStatusInProgress, map[string]any{"status": dto.BatchStatusFinalizing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated {
+		t.Fatal("stale status update should not overwrite cancelling")
+	}
+	status, _ := GetBatchStatus(batch.Id)
+	if status != dto.BatchStatusCancelling {
+		t.Fatalf("expected cancelling, got %s", status)
+	}
+
+	updated, err = batch.UpdateStatus(dto.BatchStatusCancelling, map[string]any{"status": dto.BatchStatusFinalizing})
+	if err != nil || !updated || batch.Status != dto.BatchStatusFinalizing {
+		t.Fatalf("expected transition to finalizing: %v %v %s", updated, err, batch.Status)
+	}
+}
+
+func TestCancelUserBatchSkipsFinalizing(t *testing.T) {
+	setupTestDB(t)
+	batch := &Batch{UserId: 1, Status: dto.BatchStatusFinalizing}
+	if err := batch.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := CancelUserBatch(1, batch.BatchId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != dto.BatchStatusFinalizing {
+		t.Fatalf("finalizing batch should not be cancelled, got %s", result.Status)
+	}
+}
+
+func TestDeleteUserFileNotFound(t *testing.T) {
+	setupTestDB(t)
+	file := &File{UserId: 1, Filename: "input.jsonl", Purpose: dto.FilePurposeBatch, Content: []byte("{}")}
+	if err := file.Insert(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他用户的文件与不存在的文件返回 ErrRecordNotFound，由接口返回 404
+	if err := DeleteUserFile(2, file.FileId); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected record not found, got %v", err)
+	}
+	if err := DeleteUserFile(1, file.FileId); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteUserFile(1, file.FileId); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected record not found after deleting, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	BudgetScopeUser  = "user"
+	BudgetScopeToken = "token"
+	// 组织成员每月可使用的组织额度，ScopeId 为成员记录 ID
+	BudgetScopeOrganizationMember = "org_member"
+)
+
+const (
+	BudgetWindowDay   = "day"
+	BudgetWindowMonth = "month"
+)
+
+// BudgetSpend 令牌、用户或组织成员在一个预算窗口内的消费额度，每个日/月窗口一条记录，
+// 只统计设置了对应预算的令牌、用户与成员
+type BudgetSpend struct {
+	Id          int    `json:"id"`
+	Scope       string `json:"scope" gorm:"type:varchar(16);uniqueIndex:idx_budget_spend_window,priority:1"`
+	ScopeId     int    `json:"scope_id" gorm:"uniqueIndex:idx_budget_spend_window,priority:2"`
+	WindowType  string `json:"window_type" gorm:"type:varchar(16);uniqueIndex:idx_budget_spend_window,priority:3"`
+	WindowStart int64  `json:"window_start" gorm:"bigint;uniqueIndex:idx_budget_spend_window,priority:4"`
+	Quota       int    `json:"quota"`
+	AlertLevel  int    `json:"alert_level"` // 本窗口已发送的最高预警百分比
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// BudgetWindowStart 返回 at 所在预算窗口的开始时间，窗口按服务器时区的自然日与自然月划分
+func BudgetWindowStart(windowType string, at time.Time) int64 {
+	at = at.In(time.Local)
+	if windowType == BudgetWindowMonth {
+		return time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.Local).Unix()
+	}
+	return time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.Local).Unix()
+}
+
+// BudgetWindowEnd 返回预算窗口的结束时间，即下一个窗口的开始时间
+func BudgetWindowEnd(windowType string, windowStart int64) int64 {
+	start := time.Unix(windowStart, 0).In(time.Local)
+	if windowType == BudgetWindowMonth {
+		return start.AddDate(0, 1, 0).Unix()
+	}
+	return start.AddDate(0, 0, 1).Unix()
+}
+
+func budgetSpendQuery(db *gorm.DB, scope string, scopeId int, windowType string, windowStart int64) *gorm.DB {
+	return db.Model(&BudgetSpend{}).
+		Where("scope = ? AND scope_id = ? AND window_type = ? AND window_start = ?", scope, scopeId, windowType, windowStart)
+}
+
+// GetBudgetSpend 返回预算窗口内已消费的额度，没有记录时返回 0
+func GetBudgetSpend(scope string, scopeId int, windowType string, windowStart int64) (int, error) {
+	var spends []BudgetSpend
+	err := budgetSpendQuery(DB, scope, scopeId, windowType, windowStart).Limit(1).Find(&spends).Error
+	if err != nil || len(spends) == 0 {
+		return 0, err
+	}
+	return spends[0].Quota, nil
+}
+
+// AddBudgetSpend 累加预算窗口内的消费额度，quota 为负数时表示退款，返回累加后的额度
+func AddBudgetSpend(scope string, scopeId int, windowType string, windowStart int64, quota int) (int, error) {
+	if quota == 0 {
+		return GetBudgetSpend(scope, scopeId, windowType, windowStart)
+	}
+	now := common.GetTimestamp()
+	spend := &BudgetSpend{
+		Scope:       scope,
+		ScopeId:     scopeId,
+		WindowType:  windowType,
+		WindowStart: windowStart,
+		Quota:       quota,
+		UpdatedTime: now,
+	}
+	err := DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "scope"}, {Name: "scope_id"}, {Name: "window_type"}, {Name: "window_start"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"quota":        gorm.Expr("budget_spends.quota + ?", quota),
+			"updated_time": now,
+		}),
+	}).Create(spend).Error
+	if err != nil {
+		return 0, err
+	}
+	return GetBudgetSpend(scope, scopeId, windowType, windowStart)
+}
+
+// ReserveBudgetSpend 在预算窗口内预留额度，预留后不超过预算时才计入，返回是否预留成功与窗口内已消费的额度。
+// 以累加后的额度作为更新条件，多个请求并发预留时不会超出预算
+func ReserveBudgetSpend(scope string, scopeId int, windowType string, windowStart int64, quota int, limit int) (bool, int, error) {
+	if quota < 0 {
+		return false, 0, errors.New("invalid reserve quota")
+	}
+	// 先确保窗口记录存在，再以条件更新预留额度
+	err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&BudgetSpend{
+		Scope:       scope,
+		ScopeId:     scopeId,
+		WindowType:  windowType,
+		WindowStart: windowStart,
+		UpdatedTime: common.GetTimestamp(),
+	}).Error
+	if err != nil {
+		return false, 0, err
+	}
+	result := budgetSpendQuery(DB, scope, scopeId, windowType, windowStart).
+		Where("quota < ? AND quota + ? <= ?", limit, quota, limit).
+		Updates(map[string]interface{}{
+			"quota":        gorm.Expr("quota + ?", quota),
+			"updated_time": common.GetTimestamp(),
+		})
+	if result.Error != nil {
+		return false, 0, result.Error
+	}
+	spent, err := GetBudgetSpend(scope, scopeId, windowType, windowStart)
+	if err != nil {
+		return false, 0, err
+	}
+	if result.RowsAffected == 0 {
+		return false, spent, nil
+	}
+	return true, spent, nil
+}
+
+// AdjustBudgetSpend 调整预算窗口内已有记录的消费额度，quota 为负数时表示退款。
+// 窗口没有记录时说明当时未设置预算，不做处理
+func AdjustBudgetSpend(scope string, scopeId int, windowType string, windowStart int64, quota int) error {
+	if quota == 0 {
+		return nil
+	}
+	return budgetSpendQuery(DB, scope, scopeId, windowType, windowStart).
+		Updates(map[string]interface{}{
+			"quota":        gorm.Expr("quota + ?", quota),
+			"updated_time": common.GetTimestamp(),
+		}).Error
+}
+
+// MarkBudgetAlert 记录预算窗口已发送的预警百分比，只有首次达到该百分比时返回 true，
+// 多个节点同时消费时也只会发送一次预警
+func MarkBudgetAlert(scope string, scopeId int, windowType string, windowStart int64, level int) (bool, error) {
+	if level <= 0 {
+		return false, errors.New("invalid alert level")
+	}
+	result := budgetSpendQuery(DB, scope, scopeId, windowType, windowStart).
+		Where("alert_level < ?", level).
+		Update("alert_level", level)
+	return result.RowsAffected > 0, result.Error
+}
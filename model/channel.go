@@ -10,6 +10,7 @@ import (
 	"sync"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/metrics"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/types"
@@ -42,11 +43,13 @@ type Channel struct {
 	StatusCodeMapping *string `json:"status_code_mapping" gorm:"type:varchar(1024);default:''"`
 	Priority          *int64  `json:"priority" gorm:"bigint;default:0"`
 	AutoBan           *int    `json:"auto_ban" gorm:"default:1"`
+	MaxInFlight       *int    `json:"max_in_flight" gorm:"default:0"` // 网关允许的最大并发请求数，0 表示不限制
 	OtherInfo         string  `json:"other_info"`
 	Tag               *string `json:"tag" gorm:"index"`
 	Setting           *string `json:"setting" gorm:"type:text"` // 渠道额外设置
 	ParamOverride     *string `json:"param_override" gorm:"type:text"`
 	HeaderOverride    *string `json:"header_override" gorm:"type:text"`
+	TransformScript   *string `json:"transform_script" gorm:"type:text"` // 请求/响应转换脚本（JavaScript）
 	Remark            *string `json:"remark" gorm:"type:varchar(255)" validate:"max=255"`
 	// add after v0.8.5
 	ChannelInfo ChannelInfo `json:"channel_info" gorm:"type:json"`
@@ -55,6 +58,10 @@ type Channel struct {
 
 	// cache info
 	Keys []string `json:"-" gorm:"-"`
+	// 实时表现统计，仅在管理端渠道列表中返回
+	LiveScore *ChannelLiveScore `json:"live_score,omitempty" gorm:"-"`
+	// 熔断状态，仅在管理端渠道列表中返回
+	CircuitState string `json:"circuit_state,omitempty" gorm:"-"`
 }
 
 type ChannelInfo struct {
@@ -147,9 +154,14 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 
 	switch channel.ChannelInfo.MultiKeyMode {
 	case constant.MultiKeyModeRandom:
-		// Randomly pick one enabled key
-		selectedIdx := enabledIdx[rand.Intn(len(enabledIdx))]
-		return keys[selectedIdx], selectedIdx, nil
+		// Randomly pick one enabled key whose circuit breaker allows the request
+		for _, i := range rand.Perm(len(enabledIdx)) {
+			selectedIdx := enabledIdx[i]
+			if AllowChannelKeyCircuit(channel.Id, selectedIdx) {
+				return keys[selectedIdx], selectedIdx, nil
+			}
+		}
+		return "", 0, types.NewError(errors.New("all enabled keys are circuit open"), types.ErrorCodeChannelNoAvailableKey)
 	case constant.MultiKeyModePolling:
 		// Use channel-specific lock to ensure thread-safe polling
 
@@ -175,14 +187,14 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		}
 		for i := 0; i < len(keys); i++ {
 			idx := (start + i) % len(keys)
-			if getStatus(idx) == common.ChannelStatusEnabled {
+			if getStatus(idx) == common.ChannelStatusEnabled && AllowChannelKeyCircuit(channel.Id, idx) {
 				// update polling index for next call (point to the next position)
 				channel.ChannelInfo.MultiKeyPollingIndex = (idx + 1) % len(keys)
 				return keys[idx], idx, nil
 			}
 		}
-		// Fallback – should not happen, but return first enabled key
-		return keys[enabledIdx[0]], enabledIdx[0], nil
+		// every enabled key is circuit open
+		return "", 0, types.NewError(errors.New("all enabled keys are circuit open"), types.ErrorCodeChannelNoAvailableKey)
 	default:
 		// Unknown mode, default to first enabled key (or original key string)
 		return keys[enabledIdx[0]], enabledIdx[0], nil
@@ -420,6 +432,13 @@ func (channel *Channel) GetWeight() int {
 	return int(*channel.Weight)
 }
 
+func (channel *Channel) GetMaxInFlight() int {
+	if channel.MaxInFlight == nil {
+		return 0
+	}
+	return *channel.MaxInFlight
+}
+
 func (channel *Channel) GetBaseURL() string {
 	if channel.BaseURL == nil {
 		return ""
@@ -918,6 +937,13 @@ func (channel *Channel) GetHeaderOverride() map[string]interface{} {
 	return headerOverride
 }
 
+func (channel *Channel) GetTransformScript() string {
+	if channel.TransformScript == nil {
+		return ""
+	}
+	return *channel.TransformScript
+}
+
 func GetChannelsByIds(ids []int) ([]*Channel, error) {
 	var channels []*Channel
 	err := DB.Where("id in (?)", ids).Find(&channels).Error
@@ -1006,3 +1032,31 @@ func CountChannelsGroupByType() (map[int64]int64, error) {
 	}
 	return counts, nil
 }
+
+// GetMultiKeyChannelStatuses 统计所有多 Key 渠道中各状态的 Key 数量
+func GetMultiKeyChannelStatuses() ([]metrics.MultiKeyStatus, error) {
+	var channels []*Channel
+	if err := DB.Select("id", "channel_info").Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	statuses := make([]metrics.MultiKeyStatus, 0)
+	for _, channel := range channels {
+		info := channel.ChannelInfo
+		if !info.IsMultiKey {
+			continue
+		}
+		status := metrics.MultiKeyStatus{ChannelId: channel.Id}
+		for i := 0; i < info.MultiKeySize; i++ {
+			switch info.MultiKeyStatusList[i] {
+			case common.ChannelStatusManuallyDisabled:
+				status.ManuallyDisabled++
+			case common.ChannelStatusAutoDisabled:
+				status.AutoDisabled++
+			default:
+				status.Enabled++
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
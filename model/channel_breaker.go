@@ -0,0 +1,86 @@
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/breaker"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+func channelBreakerConfig() breaker.Config {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	return breaker.Config{
+		FailureThreshold:     max(setting.FailureThreshold, 1),
+		WindowSeconds:        int64(setting.WindowSeconds),
+		CooldownSeconds:      int64(setting.CooldownSeconds),
+		ProbeIntervalSeconds: int64(setting.ProbeIntervalSeconds),
+		HalfOpenSuccesses:    max(setting.HalfOpenSuccesses, 1),
+	}
+}
+
+func channelBreakerKey(channelId int) string {
+	return fmt.Sprintf("circuit_breaker:channel:%d", channelId)
+}
+
+func channelKeyBreakerKey(channelId int, keyIndex int) string {
+	return fmt.Sprintf("circuit_breaker:channel:%d:key:%d", channelId, keyIndex)
+}
+
+func isChannelBreakerEnabled() bool {
+	return operation_setting.GetCircuitBreakerSetting().Enabled
+}
+
+func isChannelKeyBreakerEnabled() bool {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	return setting.Enabled && setting.MultiKeyEnabled
+}
+
+// AllowChannelCircuit 判断渠道熔断器是否放行，半开状态下会占用一次探测机会
+func AllowChannelCircuit(channelId int) bool {
+	if !isChannelBreakerEnabled() {
+		return true
+	}
+	return breaker.Allow(context.Background(), channelBreakerKey(channelId), channelBreakerConfig())
+}
+
+// AllowChannelKeyCircuit 判断多 Key 渠道中单个 Key 的熔断器是否放行
+func AllowChannelKeyCircuit(channelId int, keyIndex int) bool {
+	if !isChannelKeyBreakerEnabled() {
+		return true
+	}
+	return breaker.Allow(context.Background(), channelKeyBreakerKey(channelId, keyIndex), channelBreakerConfig())
+}
+
+// RecordChannelCircuitResult 记录一次转发结果，更新渠道（及多 Key 渠道中所用 Key）的熔断状态
+func RecordChannelCircuitResult(channelId int, isMultiKey bool, keyIndex int, success bool) {
+	if !isChannelBreakerEnabled() {
+		return
+	}
+	ctx := context.Background()
+	cfg := channelBreakerConfig()
+	switch breaker.Record(ctx, channelBreakerKey(channelId), success, cfg) {
+	case breaker.TransitionOpened:
+		common.SysLog(fmt.Sprintf("渠道 #%d 熔断已打开，%d 秒后开始探测", channelId, cfg.CooldownSeconds))
+	case breaker.TransitionClosed:
+		common.SysLog(fmt.Sprintf("渠道 #%d 探测成功，熔断已关闭", channelId))
+	}
+	if !isMultiKey || !isChannelKeyBreakerEnabled() {
+		return
+	}
+	switch breaker.Record(ctx, channelKeyBreakerKey(channelId, keyIndex), success, cfg) {
+	case breaker.TransitionOpened:
+		common.SysLog(fmt.Sprintf("渠道 #%d 的 Key #%d 熔断已打开，%d 秒后开始探测", channelId, keyIndex, cfg.CooldownSeconds))
+	case breaker.TransitionClosed:
+		common.SysLog(fmt.Sprintf("渠道 #%d 的 Key #%d 探测成功，熔断已关闭", channelId, keyIndex))
+	}
+}
+
+// GetChannelCircuitState 查询渠道熔断状态，不占用探测机会
+func GetChannelCircuitState(channelId int) string {
+	if !isChannelBreakerEnabled() {
+		return breaker.StateClosed.String()
+	}
+	return breaker.GetState(context.Background(), channelBreakerKey(channelId)).String()
+}
@@ -1,8 +1,8 @@
 package model
 
 import (
-	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"sort"
 	"strings"
@@ -11,6 +11,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 )
 
@@ -115,20 +116,27 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		return nil, nil
 	}
 
-	if len(channels) == 1 {
-		if channel, ok := channelsIDM[channels[0]]; ok {
-			return channel, nil
+	candidates := make([]*Channel, 0, len(channels))
+	for _, channelId := range channels {
+		channel, ok := channelsIDM[channelId]
+		if !ok {
+			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
 		}
-		return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channels[0])
+		candidates = append(candidates, channel)
 	}
+	return selectSatisfiedChannel(group, candidates, retry)
+}
 
+// selectSatisfiedChannel 从候选渠道中按优先级与权重选择渠道，内存缓存与数据库两条选择路径共用。
+// 跳过已达最大并发数的渠道，按权重（自适应模式下结合实时评分）选择后经熔断器放行；
+// 目标优先级的渠道均不可用时继续尝试更低的优先级。存在渠道但均已饱和时返回 ErrChannelsSaturated
+func selectSatisfiedChannel(group string, candidates []*Channel, retry int) (*Channel, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
 	uniquePriorities := make(map[int]bool)
-	for _, channelId := range channels {
-		if channel, ok := channelsIDM[channelId]; ok {
-			uniquePriorities[int(channel.GetPriority())] = true
-		} else {
-			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
-		}
+	for _, channel := range candidates {
+		uniquePriorities[int(channel.GetPriority())] = true
 	}
 	var sortedUniquePriorities []int
 	for priority := range uniquePriorities {
@@ -136,27 +144,47 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	}
 	sort.Sort(sort.Reverse(sort.IntSlice(sortedUniquePriorities)))
 
-	if retry >= len(uniquePriorities) {
-		retry = len(uniquePriorities) - 1
+	if retry >= len(sortedUniquePriorities) {
+		retry = len(sortedUniquePriorities) - 1
 	}
-	targetPriority := int64(sortedUniquePriorities[retry])
 
-	// get the priority for the given retry number
-	var sumWeight = 0
-	var targetChannels []*Channel
-	for _, channelId := range channels {
-		if channel, ok := channelsIDM[channelId]; ok {
-			if channel.GetPriority() == targetPriority {
-				sumWeight += channel.GetWeight()
-				targetChannels = append(targetChannels, channel)
+	// when every channel of the target priority is circuit open or saturated, fall through to lower priorities
+	saturated := false
+	for priorityIdx := retry; priorityIdx < len(sortedUniquePriorities); priorityIdx++ {
+		targetPriority := int64(sortedUniquePriorities[priorityIdx])
+
+		// saturated channels are skipped before they are tried
+		var targetChannels []*Channel
+		for _, channel := range candidates {
+			if channel.GetPriority() != targetPriority {
+				continue
 			}
-		} else {
-			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
+			if IsChannelSaturated(channel) {
+				saturated = true
+				continue
+			}
+			targetChannels = append(targetChannels, channel)
+		}
+
+		for len(targetChannels) > 0 {
+			idx := selectChannelByWeight(group, targetChannels)
+			if AllowChannelCircuit(targetChannels[idx].Id) {
+				return targetChannels[idx], nil
+			}
+			targetChannels = append(targetChannels[:idx:idx], targetChannels[idx+1:]...)
 		}
 	}
+	if saturated {
+		return nil, ErrChannelsSaturated
+	}
+	return nil, nil
+}
 
-	if len(targetChannels) == 0 {
-		return nil, errors.New(fmt.Sprintf("no channel found, group: %s, model: %s, priority: %d", group, model, targetPriority))
+// selectChannelByWeight picks a channel index from channels of the same priority based on weight
+func selectChannelByWeight(group string, targetChannels []*Channel) int {
+	var sumWeight = 0
+	for _, channel := range targetChannels {
+		sumWeight += channel.GetWeight()
 	}
 
 	// smoothing factor and adjustment
@@ -164,30 +192,43 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	smoothingAdjustment := 0
 
 	if sumWeight == 0 {
-		// when all channels have weight 0, set sumWeight to the number of channels and set smoothing adjustment to 100
+		// when all channels have weight 0, set smoothing adjustment to 100
 		// each channel's effective weight = 100
-		sumWeight = len(targetChannels) * 100
 		smoothingAdjustment = 100
 	} else if sumWeight/len(targetChannels) < 10 {
 		// when the average weight is less than 10, set smoothing factor to 100
 		smoothingFactor = 100
 	}
 
-	// Calculate the total weight of all channels up to endIdx
-	totalWeight := sumWeight * smoothingFactor
+	// adaptive mode scales each channel's weight by its live score (success rate & first response time)
+	adaptive := operation_setting.IsAdaptiveChannelSelectEnabled(group)
+
+	// Calculate the effective weight of each channel and the total weight
+	totalWeight := 0
+	effectiveWeights := make([]int, len(targetChannels))
+	for i, channel := range targetChannels {
+		weight := channel.GetWeight()*smoothingFactor + smoothingAdjustment
+		if adaptive && weight > 0 {
+			weight = int(math.Ceil(float64(weight) * GetChannelLiveScore(channel.Id).Score))
+		}
+		effectiveWeights[i] = weight
+		totalWeight += weight
+	}
+	if totalWeight <= 0 {
+		return rand.Intn(len(targetChannels))
+	}
 
 	// Generate a random value in the range [0, totalWeight)
 	randomWeight := rand.Intn(totalWeight)
 
 	// Find a channel based on its weight
-	for _, channel := range targetChannels {
-		randomWeight -= channel.GetWeight()*smoothingFactor + smoothingAdjustment
+	for i := range targetChannels {
+		randomWeight -= effectiveWeights[i]
 		if randomWeight < 0 {
-			return channel, nil
+			return i
 		}
 	}
-	// return null if no channel is not found
-	return nil, errors.New("channel not found")
+	return len(targetChannels) - 1
 }
 
 func CacheGetChannel(id int) (*Channel, error) {
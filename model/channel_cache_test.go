@@ -0,0 +1,151 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// createTestChannels 创建同一优先级的测试渠道，渠道 ID 由调用方指定，避免不同测试共享熔断与统计状态
+func createTestChannels(t *testing.T, ids ...int) map[int]*Channel {
+	t.Helper()
+	channels := make(map[int]*Channel, len(ids))
+	for _, id := range ids {
+		weight := uint(100)
+		priority := int64(0)
+		channel := &Channel{
+			Id:       id,
+			Type:     1,
+			Key:      "sk-test",
+			Name:     "test",
+			Status:   common.ChannelStatusEnabled,
+			Models:   "gpt-4o",
+			Group:    "default",
+			Weight:   &weight,
+			Priority: &priority,
+		}
+		if err := channel.Insert(); err != nil {
+			t.Fatal(err)
+		}
+		channels[id] = channel
+	}
+	return channels
+}
+
+// forEachChannelCacheMode 分别在启用与未启用内存缓存时运行测试，两条选择路径的行为应一致
+func forEachChannelCacheMode(t *testing.T, ids []int, fn func(t *testing.T, channels map[int]*Channel)) {
+	for _, cacheEnabled := range []bool{false, true} {
+		name := "db"
+		if cacheEnabled {
+			name = "memory_cache"
+		}
+		t.Run(name, func(t *testing.T) {
+			setupTestDB(t)
+			channels := createTestChannels(t, ids...)
+			original := common.MemoryCacheEnabled
+			common.MemoryCacheEnabled = cacheEnabled
+			t.Cleanup(func() { common.MemoryCacheEnabled = original })
+			if cacheEnabled {
+				InitChannelCache()
+			}
+			fn(t, channels)
+		})
+	}
+}
+
+func TestChannelSelectionSkipsOpenCircuit(t *testing.T) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	original := *setting
+	setting.Enabled = true
+	setting.FailureThreshold = 1
+	setting.CooldownSeconds = 600
+	t.Cleanup(func() { *setting = original })
+
+	ids := []int{3101, 3102}
+	forEachChannelCacheMode(t, ids, func(t *testing.T, channels map[int]*Channel) {
+		RecordChannelCircuitResult(ids[0], false, 0, false)
+		for i := 0; i < 50; i++ {
+			channel, err := GetRandomSatisfiedChannel("default", "gpt-4o", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if channel == nil || channel.Id != ids[1] {
+				t.Fatalf("expected channel %d, got %+v", ids[1], channel)
+			}
+			// 数据库路径只用部分字段选择，返回的仍是完整的渠道
+			if channel.Key != "sk-test" || channel.Models != "gpt-4o" {
+				t.Fatalf("expected the full channel row, got %+v", channel)
+			}
+		}
+	})
+}
+
+func TestChannelSelectionSkipsSaturatedChannel(t *testing.T) {
+	ids := []int{3201, 3202}
+	forEachChannelCacheMode(t, ids, func(t *testing.T, channels map[int]*Channel) {
+		maxInFlight := 1
+		for _, id := range ids {
+			if err := DB.Model(&Channel{}).Where("id = ?", id).Update("max_in_flight", maxInFlight).Error; err != nil {
+				t.Fatal(err)
+			}
+			channels[id].MaxInFlight = &maxInFlight
+		}
+		if common.MemoryCacheEnabled {
+			InitChannelCache()
+		}
+
+		if !TryAcquireChannelSlot(channels[ids[0]]) {
+			t.Fatal("acquire slot failed")
+		}
+		defer ReleaseChannelSlot(ids[0])
+		for i := 0; i < 20; i++ {
+			channel, err := GetRandomSatisfiedChannel("default", "gpt-4o", 0)
+			if err != nil || channel == nil || channel.Id != ids[1] {
+				t.Fatalf("expected channel %d, got %+v %v", ids[1], channel, err)
+			}
+		}
+
+		if !TryAcquireChannelSlot(channels[ids[1]]) {
+			t.Fatal("acquire slot failed")
+		}
+		defer ReleaseChannelSlot(ids[1])
+		if _, err := GetRandomSatisfiedChannel("default", "gpt-4o", 0); !errors.Is(err, ErrChannelsSaturated) {
+			t.Fatalf("expected ErrChannelsSaturated, got %v", err)
+		}
+	})
+}
+
+func TestChannelSelectionUsesAdaptiveWeights(t *testing.T) {
+	setting := operation_setting.GetChannelSelectSetting()
+	original := *setting
+	setting.AdaptiveEnabled = true
+	setting.AdaptiveGroups = nil
+	setting.MinSamples = 10
+	setting.MinWeightPercent = 1
+	t.Cleanup(func() { *setting = original })
+
+	ids := []int{3301, 3302}
+	for i := 0; i < 20; i++ {
+		RecordChannelRelayResult(ids[0], false, 0)
+		RecordChannelRelayResult(ids[1], true, 100*time.Millisecond)
+	}
+	forEachChannelCacheMode(t, ids, func(t *testing.T, channels map[int]*Channel) {
+		picked := 0
+		for i := 0; i < 300; i++ {
+			channel, err := GetRandomSatisfiedChannel("default", "gpt-4o", 0)
+			if err != nil || channel == nil {
+				t.Fatalf("unexpected result %+v %v", channel, err)
+			}
+			if channel.Id == ids[0] {
+				picked++
+			}
+		}
+		// 失败渠道的有效权重降为 1%，期望约 3 次
+		if picked > 30 {
+			t.Fatalf("failing channel picked %d times out of 300", picked)
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common/limiter"
+)
+
+// ErrChannelsSaturated 存在可用渠道，但均已达到最大并发数
+var ErrChannelsSaturated = errors.New("all available channels are at max in-flight requests")
+
+var channelConcurrency = limiter.NewConcurrencyLimiter()
+
+// IsChannelSaturated 判断渠道在当前节点的并发请求数是否已达到上限
+func IsChannelSaturated(channel *Channel) bool {
+	return channelConcurrency.Saturated(channel.Id, channel.GetMaxInFlight())
+}
+
+// TryAcquireChannelSlot 占用渠道的一个并发槽位，已达上限时返回 false
+func TryAcquireChannelSlot(channel *Channel) bool {
+	return channelConcurrency.TryAcquire(channel.Id, channel.GetMaxInFlight())
+}
+
+func ReleaseChannelSlot(channelId int) {
+	channelConcurrency.Release(channelId)
+}
+
+// GetChannelInFlight 获取渠道在当前节点正在进行的请求数
+func GetChannelInFlight(channelId int) int {
+	return channelConcurrency.InFlight(channelId)
+}
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// 统计窗口被切分为固定数量的时间桶，窗口滑动时过期的桶会被复用
+const channelStatBucketCount = 60
+
+type channelStatBucket struct {
+	slot       int64
+	success    int
+	failure    int
+	latencySum int64 // 成功请求的首字时间之和（毫秒）
+}
+
+type channelLiveStat struct {
+	mu      sync.Mutex
+	width   int64
+	buckets [channelStatBucketCount]channelStatBucket
+}
+
+// ChannelLiveScore 渠道在统计窗口内的实时表现，Score 为作用于权重的系数（0~1）
+type ChannelLiveScore struct {
+	Samples      int     `json:"samples"`
+	SuccessRate  float64 `json:"success_rate"`
+	AvgLatencyMs int64   `json:"avg_latency_ms"`
+	Score        float64 `json:"score"`
+}
+
+// 仅保存在本节点内存中，多节点部署时各节点独立统计
+var channelLiveStats sync.Map // channel id -> *channelLiveStat
+
+func channelStatBucketWidth() int64 {
+	width := int64(operation_setting.GetChannelSelectSetting().WindowSeconds) / channelStatBucketCount
+	if width < 1 {
+		width = 1
+	}
+	return width
+}
+
+// RecordChannelRelayResult 记录一次真实转发的结果，latency 为本次尝试的首字时间
+func RecordChannelRelayResult(channelId int, success bool, latency time.Duration) {
+	value, _ := channelLiveStats.LoadOrStore(channelId, &channelLiveStat{})
+	stat := value.(*channelLiveStat)
+
+	width := channelStatBucketWidth()
+	slot := time.Now().Unix() / width
+
+	stat.mu.Lock()
+	defer stat.mu.Unlock()
+	if stat.width != width {
+		// 统计窗口配置变更，丢弃旧数据
+		stat.width = width
+		stat.buckets = [channelStatBucketCount]channelStatBucket{}
+	}
+	bucket := &stat.buckets[slot%channelStatBucketCount]
+	if bucket.slot != slot {
+		*bucket = channelStatBucket{slot: slot}
+	}
+	if success {
+		bucket.success++
+		bucket.latencySum += latency.Milliseconds()
+	} else {
+		bucket.failure++
+	}
+}
+
+// GetChannelLiveScore 计算渠道的实时得分，样本不足时得分为 1（不调整权重）
+func GetChannelLiveScore(channelId int) ChannelLiveScore {
+	selectSetting := operation_setting.GetChannelSelectSetting()
+	score := ChannelLiveScore{SuccessRate: 1, Score: 1}
+	value, ok := channelLiveStats.Load(channelId)
+	if !ok {
+		return score
+	}
+	stat := value.(*channelLiveStat)
+
+	width := channelStatBucketWidth()
+	minSlot := time.Now().Unix()/width - channelStatBucketCount + 1
+	success, failure := 0, 0
+	var latencySum int64
+	stat.mu.Lock()
+	if stat.width == width {
+		for _, bucket := range stat.buckets {
+			if bucket.slot < minSlot {
+				continue
+			}
+			success += bucket.success
+			failure += bucket.failure
+			latencySum += bucket.latencySum
+		}
+	}
+	stat.mu.Unlock()
+
+	score.Samples = success + failure
+	if score.Samples == 0 {
+		return score
+	}
+	score.SuccessRate = float64(success) / float64(score.Samples)
+	if success > 0 {
+		score.AvgLatencyMs = latencySum / int64(success)
+	}
+	if score.Samples < selectSetting.MinSamples {
+		return score
+	}
+
+	// 失败率按平方惩罚，延迟超过目标值时按比例降权
+	factor := score.SuccessRate * score.SuccessRate
+	if selectSetting.TargetLatencyMs > 0 && score.AvgLatencyMs > int64(selectSetting.TargetLatencyMs) {
+		factor *= float64(selectSetting.TargetLatencyMs) / float64(score.AvgLatencyMs)
+	}
+	minScore := float64(selectSetting.MinWeightPercent) / 100
+	if factor < minScore {
+		factor = minScore
+	}
+	if factor > 1 {
+		factor = 1
+	}
+	score.Score = factor
+	return score
+}
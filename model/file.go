@@ -0,0 +1,98 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+
+	"gorm.io/gorm"
+)
+
+// File 用户上传的文件（目前用于 Batch 输入，以及 Batch 生成的输出/错误文件）
+type File struct {
+	Id        int    `json:"id"`
+	FileId    string `json:"file_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	Filename  string `json:"filename" gorm:"type:varchar(255)"`
+	Purpose   string `json:"purpose" gorm:"type:varchar(32);index"`
+	Bytes     int64  `json:"bytes" gorm:"bigint"`
+	Status    string `json:"status" gorm:"type:varchar(20)"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt int64  `json:"expires_at" gorm:"bigint;default:0"`
+	// 文件内容，列表查询时不加载
+	Content []byte `json:"-"`
+}
+
+func NewFileId() string {
+	return "file-" + common.GetRandomString(24)
+}
+
+func (f *File) ToOpenAIFile() *dto.OpenAIFile {
+	return &dto.OpenAIFile{
+		Id:        f.FileId,
+		Object:    "file",
+		Bytes:     f.Bytes,
+		CreatedAt: f.CreatedAt,
+		ExpiresAt: f.ExpiresAt,
+		Filename:  f.Filename,
+		Purpose:   f.Purpose,
+		Status:    f.Status,
+	}
+}
+
+func (f *File) Insert() error {
+	if f.FileId == "" {
+		f.FileId = NewFileId()
+	}
+	if f.CreatedAt == 0 {
+		f.CreatedAt = common.GetTimestamp()
+	}
+	f.Bytes = int64(len(f.Content))
+	return DB.Create(f).Error
+}
+
+// GetUserFile 获取用户的文件，withContent 为 false 时不加载文件内容
+func GetUserFile(userId int, fileId string, withContent bool) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id 为空！")
+	}
+	var file File
+	query := DB.Where("file_id = ? AND user_id = ?", fileId, userId)
+	if !withContent {
+		query = query.Omit("content")
+	}
+	err := query.First(&file).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// GetUserFiles 按创建时间倒序列出用户文件，after 为上一页最后一个 file id
+func GetUserFiles(userId int, purpose string, after string, limit int) (files []*File, err error) {
+	query := DB.Omit("content").Where("user_id = ?", userId)
+	if purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+	if after != "" {
+		var cursor File
+		if err = DB.Select("id").Where("file_id = ? AND user_id = ?", after, userId).First(&cursor).Error; err != nil {
+			return nil, err
+		}
+		query = query.Where("id < ?", cursor.Id)
+	}
+	err = query.Order("id desc").Limit(limit).Find(&files).Error
+	return files, err
+}
+
+func DeleteUserFile(userId int, fileId string) error {
+	result := DB.Where("file_id = ? AND user_id = ?", fileId, userId).Delete(&File{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/metrics"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/types"
 
@@ -37,6 +39,7 @@ type Log struct {
 	Group            string `json:"group" gorm:"index"`
 	Ip               string `json:"ip" gorm:"index;default:''"`
 	Other            string `json:"other"`
+	OrganizationId   int    `json:"organization_id" gorm:"default:0;index"` // 使用组织令牌时的组织 ID
 }
 
 // don't use iota, avoid change log type value
@@ -48,6 +51,10 @@ const (
 	LogTypeSystem  = 4
 	LogTypeError   = 5
 	LogTypeRefund  = 6
+	// 命中响应缓存的消费记录
+	LogTypeCacheHit = 7
+	// 内容审核命中记录
+	LogTypeModeration = 8
 )
 
 func formatUserLogs(logs []*Log) {
@@ -96,6 +103,49 @@ func RecordLog(userId int, logType int, content string) {
 	}
 }
 
+// RecordOrganizationLog 记录组织的充值等日志，组织成员可在组织日志中查看，quota 为入账额度，用于生成账单
+func RecordOrganizationLog(organizationId int, userId int, logType int, quota int, content string) {
+	recordOrganizationLog(organizationId, userId, logType, quota, content, nil)
+}
+
+func recordOrganizationLog(organizationId int, userId int, logType int, quota int, content string, other map[string]interface{}) {
+	username, _ := GetUsernameById(userId, false)
+	log := &Log{
+		UserId:         userId,
+		Username:       username,
+		CreatedAt:      common.GetTimestamp(),
+		Type:           logType,
+		Content:        content,
+		Quota:          quota,
+		OrganizationId: organizationId,
+	}
+	if other != nil {
+		log.Other = common.MapToJsonStr(other)
+	}
+	err := LOG_DB.Create(log).Error
+	if err != nil {
+		common.SysLog("failed to record log: " + err.Error())
+	}
+}
+
+// RecordRefundLog 记录异步任务失败等情况下退还的额度，organizationId 不为 0 时额度退还到组织
+func RecordRefundLog(userId int, organizationId int, quota int, content string) {
+	username, _ := GetUsernameById(userId, false)
+	log := &Log{
+		UserId:         userId,
+		Username:       username,
+		CreatedAt:      common.GetTimestamp(),
+		Type:           LogTypeRefund,
+		Content:        content,
+		Quota:          quota,
+		OrganizationId: organizationId,
+	}
+	err := LOG_DB.Create(log).Error
+	if err != nil {
+		common.SysLog("failed to record log: " + err.Error())
+	}
+}
+
 func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string, tokenName string, content string, tokenId int, useTimeSeconds int,
 	isStream bool, group string, other map[string]interface{}) {
 	logger.LogInfo(c, fmt.Sprintf("record error log: userId=%d, channelId=%d, modelName=%s, tokenName=%s, content=%s", userId, channelId, modelName, tokenName, content))
@@ -138,6 +188,42 @@ func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string,
 	}
 }
 
+// RecordModerationLog 记录一次内容审核命中，other 中包含命中的类别、来源与处理方式
+func RecordModerationLog(c *gin.Context, userId int, channelId int, modelName string, tokenName string, content string, tokenId int,
+	isStream bool, group string, other map[string]interface{}) {
+	logger.LogInfo(c, fmt.Sprintf("record moderation log: userId=%d, modelName=%s, content=%s", userId, modelName, content))
+	needRecordIp := false
+	if settingMap, err := GetUserSetting(userId, false); err == nil {
+		if settingMap.RecordIpLog {
+			needRecordIp = true
+		}
+	}
+	log := &Log{
+		UserId:    userId,
+		Username:  c.GetString("username"),
+		CreatedAt: common.GetTimestamp(),
+		Type:      LogTypeModeration,
+		Content:   content,
+		TokenName: tokenName,
+		ModelName: modelName,
+		ChannelId: channelId,
+		TokenId:   tokenId,
+		IsStream:  isStream,
+		Group:     group,
+		Ip: func() string {
+			if needRecordIp {
+				return c.ClientIP()
+			}
+			return ""
+		}(),
+		Other: common.MapToJsonStr(other),
+	}
+	err := LOG_DB.Create(log).Error
+	if err != nil {
+		logger.LogError(c, "failed to record log: "+err.Error())
+	}
+}
+
 type RecordConsumeLogParams struct {
 	ChannelId        int                    `json:"channel_id"`
 	PromptTokens     int                    `json:"prompt_tokens"`
@@ -151,9 +237,12 @@ type RecordConsumeLogParams struct {
 	IsStream         bool                   `json:"is_stream"`
 	Group            string                 `json:"group"`
 	Other            map[string]interface{} `json:"other"`
+	// 日志类型，默认为 LogTypeConsume
+	LogType int `json:"log_type,omitempty"`
 }
 
 func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams) {
+	metrics.RecordConsume(params.ModelName, params.ChannelId, params.Group, params.PromptTokens, params.CompletionTokens, params.Quota)
 	if !common.LogConsumeEnabled {
 		return
 	}
@@ -167,11 +256,15 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 			needRecordIp = true
 		}
 	}
+	logType := params.LogType
+	if logType == LogTypeUnknown {
+		logType = LogTypeConsume
+	}
 	log := &Log{
 		UserId:           userId,
 		Username:         username,
 		CreatedAt:        common.GetTimestamp(),
-		Type:             LogTypeConsume,
+		Type:             logType,
 		Content:          params.Content,
 		PromptTokens:     params.PromptTokens,
 		CompletionTokens: params.CompletionTokens,
@@ -183,6 +276,7 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		UseTime:          params.UseTimeSeconds,
 		IsStream:         params.IsStream,
 		Group:            params.Group,
+		OrganizationId:   common.GetContextKeyInt(c, constant.ContextKeyOrganizationId),
 		Ip: func() string {
 			if needRecordIp {
 				return c.ClientIP()
@@ -303,6 +397,40 @@ func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int
 	return logs, total, err
 }
 
+// GetOrganizationLogs 查询组织令牌产生的日志，可按成员用户名过滤
+func GetOrganizationLogs(organizationId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int) (logs []*Log, total int64, err error) {
+	tx := LOG_DB.Where("logs.organization_id = ?", organizationId)
+	if logType != LogTypeUnknown {
+		tx = tx.Where("logs.type = ?", logType)
+	}
+	if modelName != "" {
+		tx = tx.Where("logs.model_name like ?", modelName)
+	}
+	if username != "" {
+		tx = tx.Where("logs.username = ?", username)
+	}
+	if tokenName != "" {
+		tx = tx.Where("logs.token_name = ?", tokenName)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", endTimestamp)
+	}
+	err = tx.Model(&Log{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("logs.id desc").Limit(num).Offset(startIdx).Find(&logs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	formatUserLogs(logs)
+	return logs, total, err
+}
+
 func SearchAllLogs(keyword string) (logs []*Log, err error) {
 	err = LOG_DB.Where("type = ? or content LIKE ?", keyword, keyword+"%").Order("id desc").Limit(common.MaxRecentItems).Find(&logs).Error
 	return logs, err
@@ -353,8 +481,8 @@ func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelNa
 		rpmTpmQuery = rpmTpmQuery.Where(logGroupCol+" = ?", group)
 	}
 
-	tx = tx.Where("type = ?", LogTypeConsume)
-	rpmTpmQuery = rpmTpmQuery.Where("type = ?", LogTypeConsume)
+	tx = tx.Where("type IN ?", []int{LogTypeConsume, LogTypeCacheHit})
+	rpmTpmQuery = rpmTpmQuery.Where("type IN ?", []int{LogTypeConsume, LogTypeCacheHit})
 
 	// 只统计最近60秒的rpm和tpm
 	rpmTpmQuery = rpmTpmQuery.Where("created_at >= ?", time.Now().Add(-60*time.Second).Unix())
@@ -383,7 +511,8 @@ func SumUsedToken(logType int, startTimestamp int64, endTimestamp int64, modelNa
 	if modelName != "" {
 		tx = tx.Where("model_name = ?", modelName)
 	}
-	tx.Where("type = ?", LogTypeConsume).Scan(&token)
+	// 命中响应缓存的请求与 SumUsedQuota 一致计入用量
+	tx.Where("type IN ?", []int{LogTypeConsume, LogTypeCacheHit}).Scan(&token)
 	return token
 }
 
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestSumUsedTokenCountsCacheHits(t *testing.T) {
+	setupTestDB(t)
+	logs := []*Log{
+		{Username: "sum_tokens", Type: LogTypeConsume, CreatedAt: 100, PromptTokens: 10, CompletionTokens: 5, Quota: 30},
+		{Username: "sum_tokens", Type: LogTypeCacheHit, CreatedAt: 100, PromptTokens: 10, CompletionTokens: 5, Quota: 3},
+		{Username: "sum_tokens", Type: LogTypeRefund, CreatedAt: 100, PromptTokens: 100, Quota: 10},
+	}
+	if err := LOG_DB.Create(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 令牌用量与额度用量统计相同类型的日志
+	if tokens := SumUsedToken(LogTypeUnknown, 0, 0, "", "sum_tokens", ""); tokens != 30 {
+		t.Fatalf("tokens = %d", tokens)
+	}
+	if stat := SumUsedQuota(LogTypeUnknown, 0, 0, "", "sum_tokens", "", 0, ""); stat.Quota != 33 {
+		t.Fatalf("quota = %d", stat.Quota)
+	}
+}
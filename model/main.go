@@ -268,6 +268,17 @@ func migrateDB() error {
 		&TwoFA{},
 		&TwoFABackupCode{},
 		&Checkin{},
+		&File{},
+		&Batch{},
+		&BatchItem{},
+		&StoredResponse{},
+		&SubscriptionPlan{},
+		&UserSubscription{},
+		&BudgetSpend{},
+		&Organization{},
+		&OrganizationMember{},
+		&Statement{},
+		&StatementPeriodLock{},
 	)
 	if err != nil {
 		return err
@@ -302,6 +313,17 @@ func migrateDBFast() error {
 		{&TwoFA{}, "TwoFA"},
 		{&TwoFABackupCode{}, "TwoFABackupCode"},
 		{&Checkin{}, "Checkin"},
+		{&File{}, "File"},
+		{&Batch{}, "Batch"},
+		{&BatchItem{}, "BatchItem"},
+		{&StoredResponse{}, "StoredResponse"},
+		{&SubscriptionPlan{}, "SubscriptionPlan"},
+		{&UserSubscription{}, "UserSubscription"},
+		{&BudgetSpend{}, "BudgetSpend"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
+		{&Statement{}, "Statement"},
+		{&StatementPeriodLock{}, "StatementPeriodLock"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB 为每个测试创建独立的内存 SQLite 数据库并完成迁移
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := "file:" + strings.ReplaceAll(t.Name(), "/", "_") + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	DB, LOG_DB = db, db
+	common.UsingSQLite = true
+	common.UsingMySQL = false
+	common.UsingPostgreSQL = false
+	common.RedisEnabled = false
+	initCol()
+	if err := migrateDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// createTestUser 创建测试用户，AffCode 与 AccessToken 需唯一
+func createTestUser(t *testing.T, username string, quota int) *User {
+	t.Helper()
+	accessToken := "access-" + username
+	user := &User{
+		Username:    username,
+		Password:    "password",
+		DisplayName: username,
+		Role:        common.RoleCommonUser,
+		Status:      common.UserStatusEnabled,
+		Quota:       quota,
+		Group:       "default",
+		AffCode:     "aff-" + username,
+		AccessToken: &accessToken,
+	}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
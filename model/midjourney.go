@@ -1,28 +1,36 @@
 package model
 
 type Midjourney struct {
-	Id          int    `json:"id"`
-	Code        int    `json:"code"`
-	UserId      int    `json:"user_id" gorm:"index"`
-	Action      string `json:"action" gorm:"type:varchar(40);index"`
-	MjId        string `json:"mj_id" gorm:"index"`
-	Prompt      string `json:"prompt"`
-	PromptEn    string `json:"prompt_en"`
-	Description string `json:"description"`
-	State       string `json:"state"`
-	SubmitTime  int64  `json:"submit_time" gorm:"index"`
-	StartTime   int64  `json:"start_time" gorm:"index"`
-	FinishTime  int64  `json:"finish_time" gorm:"index"`
-	ImageUrl    string `json:"image_url"`
-	VideoUrl    string `json:"video_url"`
-	VideoUrls   string `json:"video_urls"`
-	Status      string `json:"status" gorm:"type:varchar(20);index"`
-	Progress    string `json:"progress" gorm:"type:varchar(30);index"`
-	FailReason  string `json:"fail_reason"`
-	ChannelId   int    `json:"channel_id"`
-	Quota       int    `json:"quota"`
-	Buttons     string `json:"buttons"`
-	Properties  string `json:"properties"`
+	Id             int    `json:"id"`
+	Code           int    `json:"code"`
+	UserId         int    `json:"user_id" gorm:"index"`
+	OrganizationId int    `json:"organization_id" gorm:"default:0"` // 使用组织令牌提交时的组织 ID，退款返还到组织额度
+	Action         string `json:"action" gorm:"type:varchar(40);index"`
+	MjId           string `json:"mj_id" gorm:"index"`
+	Prompt         string `json:"prompt"`
+	PromptEn       string `json:"prompt_en"`
+	Description    string `json:"description"`
+	State          string `json:"state"`
+	SubmitTime     int64  `json:"submit_time" gorm:"index"`
+	StartTime      int64  `json:"start_time" gorm:"index"`
+	FinishTime     int64  `json:"finish_time" gorm:"index"`
+	ImageUrl       string `json:"image_url"`
+	VideoUrl       string `json:"video_url"`
+	VideoUrls      string `json:"video_urls"`
+	Status         string `json:"status" gorm:"type:varchar(20);index"`
+	Progress       string `json:"progress" gorm:"type:varchar(30);index"`
+	FailReason     string `json:"fail_reason"`
+	ChannelId      int    `json:"channel_id"`
+	Quota          int    `json:"quota"`
+	Buttons        string `json:"buttons"`
+	Properties     string `json:"properties"`
+	// 提交时从订阅套餐中扣除的部分，失败退款时按原路返还到套餐
+	SubscriptionId          int   `json:"subscription_id" gorm:"default:0"`
+	SubscriptionPeriodStart int64 `json:"subscription_period_start" gorm:"default:0"`
+	SubscriptionQuota       int   `json:"subscription_quota" gorm:"default:0"`
+	// 提交时使用的令牌与组织成员，退款时从对应的消费预算中扣除
+	TokenId              int `json:"token_id" gorm:"default:0"`
+	OrganizationMemberId int `json:"organization_member_id" gorm:"default:0"`
 }
 
 // TaskQueryParams 用于包含所有搜索条件的结构体，可以根据需求添加更多字段
@@ -157,6 +165,18 @@ func (midjourney *Midjourney) Update() error {
 	return err
 }
 
+// UpdateSubscriptionCharge 记录任务从订阅套餐中扣除的额度
+func (midjourney *Midjourney) UpdateSubscriptionCharge(subscriptionId int, periodStart int64, quota int) error {
+	midjourney.SubscriptionId = subscriptionId
+	midjourney.SubscriptionPeriodStart = periodStart
+	midjourney.SubscriptionQuota = quota
+	return DB.Model(midjourney).Updates(map[string]interface{}{
+		"subscription_id":           subscriptionId,
+		"subscription_period_start": periodStart,
+		"subscription_quota":        quota,
+	}).Error
+}
+
 func MjBulkUpdate(mjIds []string, params map[string]any) error {
 	return DB.Model(&Midjourney{}).
 		Where("mj_id in (?)", mjIds).
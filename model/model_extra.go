@@ -29,3 +29,13 @@ func GetModelQuotaTypes(modelName string) []int {
 	}
 	return []int{quota}
 }
+
+// IsModelToolCallEmulationEnabled 模型元数据是否开启了函数调用模拟
+func IsModelToolCallEmulationEnabled(modelName string) bool {
+	GetPricing()
+
+	modelEnableGroupsLock.RLock()
+	enabled := modelToolCallEmulation[modelName]
+	modelEnableGroupsLock.RUnlock()
+	return enabled
+}
@@ -38,6 +38,8 @@ type Model struct {
 	EnableGroups  []string       `json:"enable_groups,omitempty" gorm:"-"`
 	QuotaTypes    []int          `json:"quota_types,omitempty" gorm:"-"`
 	NameRule      int            `json:"name_rule" gorm:"default:0"`
+	// 模型不支持原生函数调用时，由网关将工具定义写入提示词并从输出中解析工具调用
+	ToolCallEmulation bool `json:"tool_call_emulation" gorm:"default:false"`
 
 	MatchedModels []string `json:"matched_models,omitempty" gorm:"-"`
 	MatchedCount  int      `json:"matched_count,omitempty" gorm:"-"`
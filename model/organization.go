@@ -0,0 +1,389 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+// 组织成员角色
+const (
+	OrganizationRoleOwner   = "owner"   // 创建者，拥有全部权限
+	OrganizationRoleAdmin   = "admin"   // 管理成员、令牌并查看日志
+	OrganizationRoleMember  = "member"  // 使用组织额度创建令牌
+	OrganizationRoleBilling = "billing" // 查看日志并为组织充值，不能创建令牌
+)
+
+const (
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+)
+
+// organizationLogWalletTransfer 组织充值日志 other 中的字段，表示额度由成员从个人钱包转入
+const organizationLogWalletTransfer = "wallet_transfer"
+
+// Organization 组织，成员使用组织令牌时消费组织的共享额度，不扣除个人钱包
+type Organization struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64)"`
+	OwnerId     int    `json:"owner_id" gorm:"index"`
+	Quota       int    `json:"quota" gorm:"default:0"`      // 共享额度余额
+	UsedQuota   int    `json:"used_quota" gorm:"default:0"` // 累计已使用的额度
+	Status      int    `json:"status" gorm:"default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+	Role        string `json:"role,omitempty" gorm:"-"` // 当前用户在组织中的角色，仅用于返回给前端
+}
+
+// OrganizationMember 组织成员，每个用户在同一组织中只有一条记录
+type OrganizationMember struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"uniqueIndex:idx_organization_member,priority:1"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex:idx_organization_member,priority:2;index"`
+	Username       string `json:"username" gorm:"->;-:migration"`
+	Role           string `json:"role" gorm:"type:varchar(16)"`
+	MonthlyLimit   int    `json:"monthly_limit" gorm:"default:0"` // 每月可使用的组织额度，0 表示不限制
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+}
+
+func IsValidOrganizationRole(role string) bool {
+	switch role {
+	case OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember, OrganizationRoleBilling:
+		return true
+	}
+	return false
+}
+
+// HasRole 判断成员是否拥有指定角色之一，owner 拥有所有角色的权限
+func (member *OrganizationMember) HasRole(roles ...string) bool {
+	if member.Role == OrganizationRoleOwner {
+		return true
+	}
+	for _, role := range roles {
+		if member.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// CanUseQuota 判断成员能否创建和使用组织令牌
+func (member *OrganizationMember) CanUseQuota() bool {
+	return member.Role != OrganizationRoleBilling
+}
+
+// Insert 创建组织，并将创建者添加为 owner
+func (org *Organization) Insert() error {
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		return errors.New("组织名称不能为空")
+	}
+	now := common.GetTimestamp()
+	org.Id = 0
+	org.Quota = 0
+	org.UsedQuota = 0
+	org.Status = OrganizationStatusEnabled
+	org.CreatedTime = now
+	org.UpdatedTime = now
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: org.Id,
+			UserId:         org.OwnerId,
+			Role:           OrganizationRoleOwner,
+			CreatedTime:    now,
+		}).Error
+	})
+}
+
+// UpdateName 修改组织名称
+func (org *Organization) UpdateName(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("组织名称不能为空")
+	}
+	org.Name = name
+	org.UpdatedTime = common.GetTimestamp()
+	return DB.Model(org).Select("name", "updated_time").Updates(org).Error
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	if id == 0 {
+		return nil, errors.New("组织不存在")
+	}
+	org := &Organization{}
+	err := DB.First(org, "id = ?", id).Error
+	return org, err
+}
+
+func GetOrganizationQuota(id int) (quota int, err error) {
+	err = DB.Model(&Organization{}).Where("id = ?", id).Select("quota").Find(&quota).Error
+	return quota, err
+}
+
+// GetAllOrganizations 管理员查看所有组织，可按名称搜索
+func GetAllOrganizations(keyword string, pageInfo *common.PageInfo) (orgs []*Organization, total int64, err error) {
+	tx := DB.Model(&Organization{})
+	if keyword != "" {
+		tx = tx.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&orgs).Error
+	return orgs, total, err
+}
+
+// GetUserOrganizations 返回用户加入的组织，并填充用户在组织中的角色
+func GetUserOrganizations(userId int) ([]*Organization, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("user_id = ?", userId).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	orgs := make([]*Organization, 0, len(members))
+	if len(members) == 0 {
+		return orgs, nil
+	}
+	roles := make(map[int]string, len(members))
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		roles[member.OrganizationId] = member.Role
+		ids = append(ids, member.OrganizationId)
+	}
+	if err := DB.Where("id IN ?", ids).Order("id asc").Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	for _, org := range orgs {
+		org.Role = roles[org.Id]
+	}
+	return orgs, nil
+}
+
+func UpdateOrganizationStatus(id int, status int) error {
+	if status != OrganizationStatusEnabled && status != OrganizationStatusDisabled {
+		return errors.New("无效的组织状态")
+	}
+	return DB.Model(&Organization{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       status,
+		"updated_time": common.GetTimestamp(),
+	}).Error
+}
+
+func GetOrganizationMember(organizationId int, userId int) (*OrganizationMember, error) {
+	member := &OrganizationMember{}
+	err := DB.Where("organization_id = ? AND user_id = ?", organizationId, userId).First(member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("不是该组织的成员")
+	}
+	return member, err
+}
+
+// GetActiveOrganizationMember 校验组织已启用且用户仍是可使用组织额度的成员，用于组织令牌鉴权
+func GetActiveOrganizationMember(organizationId int, userId int) (*OrganizationMember, error) {
+	org, err := GetOrganizationById(organizationId)
+	if err != nil {
+		return nil, errors.New("组织不存在")
+	}
+	if org.Status != OrganizationStatusEnabled {
+		return nil, errors.New("组织已被禁用")
+	}
+	member, err := GetOrganizationMember(organizationId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !member.CanUseQuota() {
+		return nil, errors.New("当前角色不能使用组织额度")
+	}
+	return member, nil
+}
+
+func GetOrganizationMembers(organizationId int) (members []*OrganizationMember, err error) {
+	err = DB.Table("organization_members").
+		Select("organization_members.*, users.username").
+		Joins("left join users on users.id = organization_members.user_id").
+		Where("organization_members.organization_id = ?", organizationId).
+		Order("organization_members.id asc").
+		Find(&members).Error
+	return members, err
+}
+
+func GetOrganizationMemberById(organizationId int, id int) (*OrganizationMember, error) {
+	member := &OrganizationMember{}
+	err := DB.Where("organization_id = ? AND id = ?", organizationId, id).First(member).Error
+	return member, err
+}
+
+// AddOrganizationMember 按用户名添加成员，组织只能有一个 owner
+func AddOrganizationMember(organizationId int, username string, role string, monthlyLimit int) (*OrganizationMember, error) {
+	if !IsValidOrganizationRole(role) || role == OrganizationRoleOwner {
+		return nil, errors.New("无效的成员角色")
+	}
+	if monthlyLimit < 0 {
+		return nil, errors.New("成员每月限额不能为负数")
+	}
+	user := &User{}
+	if err := DB.Where("username = ?", strings.TrimSpace(username)).First(user).Error; err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	if _, err := GetOrganizationMember(organizationId, user.Id); err == nil {
+		return nil, errors.New("该用户已是组织成员")
+	}
+	member := &OrganizationMember{
+		OrganizationId: organizationId,
+		UserId:         user.Id,
+		Username:       user.Username,
+		Role:           role,
+		MonthlyLimit:   monthlyLimit,
+		CreatedTime:    common.GetTimestamp(),
+	}
+	err := DB.Omit("username").Create(member).Error
+	return member, err
+}
+
+// Update 修改成员角色与每月限额，owner 的角色不能修改
+func (member *OrganizationMember) Update() error {
+	if !IsValidOrganizationRole(member.Role) {
+		return errors.New("无效的成员角色")
+	}
+	if member.MonthlyLimit < 0 {
+		return errors.New("成员每月限额不能为负数")
+	}
+	return DB.Model(&OrganizationMember{}).Where("id = ?", member.Id).Updates(map[string]interface{}{
+		"role":          member.Role,
+		"monthly_limit": member.MonthlyLimit,
+	}).Error
+}
+
+// Delete 移除成员，成员创建的组织令牌随之失效
+func (member *OrganizationMember) Delete() error {
+	if member.Role == OrganizationRoleOwner {
+		return errors.New("不能移除组织的所有者")
+	}
+	return DB.Delete(&OrganizationMember{}, "id = ?", member.Id).Error
+}
+
+func GetOrganizationTokens(organizationId int, pageInfo *common.PageInfo) (tokens []*Token, total int64, err error) {
+	tx := DB.Model(&Token{}).Where("organization_id = ?", organizationId)
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&tokens).Error
+	return tokens, total, err
+}
+
+// DecreaseOrganizationQuota 扣除组织额度并累计已使用额度，组织额度不足时返回错误，用于预扣费
+func DecreaseOrganizationQuota(id int, quota int) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	result := DB.Model(&Organization{}).Where("id = ? AND quota >= ?", id, quota).Updates(map[string]interface{}{
+		"quota":      gorm.Expr("quota - ?", quota),
+		"used_quota": gorm.Expr("used_quota + ?", quota),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("组织额度不足")
+	}
+	return nil
+}
+
+// ChargeOrganizationQuota 结算已产生的消费，与钱包扣费一致，组织额度允许变为负数
+func ChargeOrganizationQuota(id int, quota int) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return DB.Model(&Organization{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"quota":      gorm.Expr("quota - ?", quota),
+		"used_quota": gorm.Expr("used_quota + ?", quota),
+	}).Error
+}
+
+// IncreaseOrganizationQuota 返还组织额度，用于退还预扣费与失败任务
+func IncreaseOrganizationQuota(id int, quota int) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return DB.Model(&Organization{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"quota":      gorm.Expr("quota + ?", quota),
+		"used_quota": gorm.Expr("used_quota - ?", quota),
+	}).Error
+}
+
+// IncreasePayerQuota 返还额度给付款方，organizationId 不为 0 时返还到组织额度，否则返还到用户钱包
+func IncreasePayerQuota(userId int, organizationId int, quota int) error {
+	if organizationId != 0 {
+		return IncreaseOrganizationQuota(organizationId, quota)
+	}
+	return IncreaseUserQuota(userId, quota, false)
+}
+
+// DecreasePayerQuota 从付款方扣除已产生的消费，organizationId 不为 0 时扣除组织额度，否则扣除用户钱包
+func DecreasePayerQuota(userId int, organizationId int, quota int) error {
+	if organizationId != 0 {
+		return ChargeOrganizationQuota(organizationId, quota)
+	}
+	return DecreaseUserQuota(userId, quota)
+}
+
+// TopUpOrganization 管理员为组织充值，并记录到组织的充值日志
+func TopUpOrganization(id int, quota int, operatorId int) error {
+	if quota <= 0 {
+		return errors.New("充值额度必须大于 0")
+	}
+	org, err := GetOrganizationById(id)
+	if err != nil {
+		return errors.New("组织不存在")
+	}
+	err = DB.Model(&Organization{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", quota)).Error
+	if err != nil {
+		return err
+	}
+	RecordOrganizationLog(org.Id, org.OwnerId, LogTypeTopup, quota,
+		fmt.Sprintf("管理员 %d 为组织 %s 充值 %s", operatorId, org.Name, logger.LogQuota(quota)))
+	return nil
+}
+
+// FundOrganization 成员将个人钱包余额转入组织额度
+func FundOrganization(id int, userId int, quota int) error {
+	if quota <= 0 {
+		return errors.New("转入额度必须大于 0")
+	}
+	org, err := GetOrganizationById(id)
+	if err != nil {
+		return errors.New("组织不存在")
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证并发转入时余额不会变为负数
+		result := tx.Model(&User{}).Where("id = ? AND quota >= ?", userId, quota).Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("余额不足")
+		}
+		return tx.Model(&Organization{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", quota)).Error
+	})
+	if err != nil {
+		return err
+	}
+	gopool.Go(func() {
+		if err := cacheDecrUserQuota(userId, int64(quota)); err != nil {
+			common.SysLog("failed to decrease user quota: " + err.Error())
+		}
+	})
+	// 标记为钱包转入，用户账单据此统计转出到组织的额度
+	recordOrganizationLog(org.Id, userId, LogTypeTopup, quota,
+		fmt.Sprintf("从个人余额转入组织 %s %s", org.Name, logger.LogQuota(quota)),
+		map[string]interface{}{organizationLogWalletTransfer: true})
+	return nil
+}
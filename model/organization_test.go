@@ -0,0 +1,158 @@
+package model
+
+import (
+	"sync"
+	"testing"
+)
+
+func createTestOrganization(t *testing.T, owner *User, quota int) *Organization {
+	t.Helper()
+	org := &Organization{Name: "org-" + owner.Username, OwnerId: owner.Id}
+	if err := org.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	if quota > 0 {
+		if err := DB.Model(org).Update("quota", quota).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return org
+}
+
+func assertOrganizationQuota(t *testing.T, id int, quota int, usedQuota int) {
+	t.Helper()
+	org, err := GetOrganizationById(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if org.Quota != quota || org.UsedQuota != usedQuota {
+		t.Fatalf("quota = %d, used = %d, want quota = %d, used = %d", org.Quota, org.UsedQuota, quota, usedQuota)
+	}
+}
+
+func TestOrganizationInsertAddsOwner(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "org_owner", 0)
+	org := createTestOrganization(t, owner, 0)
+
+	member, err := GetActiveOrganizationMember(org.Id, owner.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member.Role != OrganizationRoleOwner || !member.HasRole(OrganizationRoleAdmin) || !member.CanUseQuota() {
+		t.Fatalf("unexpected owner member: %+v", member)
+	}
+
+	createTestUser(t, "org_billing", 0)
+	billing, err := AddOrganizationMember(org.Id, "org_billing", OrganizationRoleBilling, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if billing.CanUseQuota() || billing.HasRole(OrganizationRoleAdmin) {
+		t.Fatalf("billing member should not use quota or manage members: %+v", billing)
+	}
+	if _, err := AddOrganizationMember(org.Id, "org_billing", OrganizationRoleMember, 0); err == nil {
+		t.Fatal("expected adding an existing member to fail")
+	}
+	if _, err := AddOrganizationMember(org.Id, "org_owner", OrganizationRoleOwner, 0); err == nil {
+		t.Fatal("expected adding a second owner to fail")
+	}
+}
+
+func TestPayerQuotaUsesOrganizationPool(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "org_payer", 1000)
+	org := createTestOrganization(t, owner, 500)
+
+	// 组织令牌的消费与退款只影响组织额度
+	if err := DecreasePayerQuota(owner.Id, org.Id, 200); err != nil {
+		t.Fatal(err)
+	}
+	assertOrganizationQuota(t, org.Id, 300, 200)
+	if err := IncreasePayerQuota(owner.Id, org.Id, 50); err != nil {
+		t.Fatal(err)
+	}
+	assertOrganizationQuota(t, org.Id, 350, 150)
+	// 预扣费不能超出组织额度，结算时允许组织额度变为负数
+	if err := DecreaseOrganizationQuota(org.Id, 400); err == nil {
+		t.Fatal("expected decreasing more than the organization quota to fail")
+	}
+	assertOrganizationQuota(t, org.Id, 350, 150)
+	if err := DecreasePayerQuota(owner.Id, org.Id, 400); err != nil {
+		t.Fatal(err)
+	}
+	assertOrganizationQuota(t, org.Id, -50, 550)
+	if err := IncreasePayerQuota(owner.Id, org.Id, 400); err != nil {
+		t.Fatal(err)
+	}
+	if quota, err := GetUserQuota(owner.Id, true); err != nil || quota != 1000 {
+		t.Fatalf("wallet = %d, err = %v", quota, err)
+	}
+
+	// 个人令牌扣除钱包
+	if err := DecreasePayerQuota(owner.Id, 0, 100); err != nil {
+		t.Fatal(err)
+	}
+	if quota, err := GetUserQuota(owner.Id, true); err != nil || quota != 900 {
+		t.Fatalf("wallet = %d, err = %v", quota, err)
+	}
+	assertOrganizationQuota(t, org.Id, 350, 150)
+}
+
+func TestFundOrganization(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "org_fund", 1000)
+	org := createTestOrganization(t, owner, 0)
+
+	if err := FundOrganization(org.Id, owner.Id, 1500); err == nil {
+		t.Fatal("expected funding more than the wallet balance to fail")
+	}
+	if err := FundOrganization(org.Id, owner.Id, 400); err != nil {
+		t.Fatal(err)
+	}
+	assertOrganizationQuota(t, org.Id, 400, 0)
+	if quota, err := GetUserQuota(owner.Id, true); err != nil || quota != 600 {
+		t.Fatalf("wallet = %d, err = %v", quota, err)
+	}
+	var logs []*Log
+	if err := LOG_DB.Where("organization_id = ? AND type = ?", org.Id, LogTypeTopup).Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Quota != 400 || logs[0].UserId != owner.Id {
+		t.Fatalf("unexpected organization logs: %+v", logs)
+	}
+}
+
+func TestFundOrganizationConcurrent(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "org_fund_concurrent", 1000)
+	org := createTestOrganization(t, owner, 0)
+
+	// 并发转入时余额不能变为负数，组织入账额度与钱包扣除额度一致
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	funded := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := FundOrganization(org.Id, owner.Id, 300); err == nil {
+				mu.Lock()
+				funded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if funded == 0 || funded > 3 {
+		t.Fatalf("funded %d times", funded)
+	}
+	wallet, err := GetUserQuota(owner.Id, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet != 1000-funded*300 {
+		t.Fatalf("wallet = %d after funding %d times", wallet, funded)
+	}
+	assertOrganizationQuota(t, org.Id, funded*300, 0)
+}
@@ -47,6 +47,9 @@ var (
 	modelEnableGroups     = make(map[string][]string)
 	modelQuotaTypeMap     = make(map[string]int)
 	modelEnableGroupsLock = sync.RWMutex{}
+
+	// 缓存映射：模型名 -> 是否模拟函数调用，与启用分组共用锁
+	modelToolCallEmulation = make(map[string]bool)
 )
 
 var (
@@ -302,6 +305,12 @@ func updatePricing() {
 		modelEnableGroups[p.ModelName] = p.EnableGroup
 		modelQuotaTypeMap[p.ModelName] = p.QuotaType
 	}
+	modelToolCallEmulation = make(map[string]bool)
+	for modelName, meta := range metaMap {
+		if meta.Status == 1 && meta.ToolCallEmulation {
+			modelToolCallEmulation[modelName] = true
+		}
+	}
 	modelEnableGroupsLock.Unlock()
 
 	lastGetPricingTime = time.Now()
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// StoredResponse 网关保存的 Responses 响应，按令牌隔离，用于解析 previous_response_id
+type StoredResponse struct {
+	Id                 int    `json:"id"`
+	ResponseId         string `json:"response_id" gorm:"type:varchar(128);uniqueIndex"`
+	UserId             int    `json:"user_id" gorm:"index"`
+	TokenId            int    `json:"token_id" gorm:"index"`
+	Model              string `json:"model" gorm:"type:varchar(255)"`
+	PreviousResponseId string `json:"previous_response_id" gorm:"type:varchar(128);default:''"`
+	Bytes              int64  `json:"bytes" gorm:"bigint"`
+	CreatedAt          int64  `json:"created_at" gorm:"bigint"`
+	ExpiresAt          int64  `json:"expires_at" gorm:"bigint;index"`
+	// 展开后的完整输入条目
+	Input []byte `json:"-"`
+	// 完整的 Responses 响应
+	Response []byte `json:"-"`
+}
+
+// Insert 保存响应，同一 response id 重复保存时覆盖；maxPerToken 大于 0 时删除该令牌超出数量的最早记录。
+// 覆盖与裁剪在同一事务中完成，避免并发保存时丢失记录或超出上限
+func (r *StoredResponse) Insert(maxPerToken int) error {
+	if r.CreatedAt == 0 {
+		r.CreatedAt = common.GetTimestamp()
+	}
+	r.Bytes = int64(len(r.Input) + len(r.Response))
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("response_id = ?", r.ResponseId).Delete(&StoredResponse{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(r).Error; err != nil {
+			return err
+		}
+		if maxPerToken <= 0 {
+			return nil
+		}
+		var ids []int
+		err := tx.Model(&StoredResponse{}).Where("token_id = ?", r.TokenId).
+			Order("id desc").Offset(maxPerToken).Limit(1000).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&StoredResponse{}).Error
+	})
+}
+
+// GetTokenStoredResponse 获取令牌保存的未过期响应
+func GetTokenStoredResponse(tokenId int, responseId string) (*StoredResponse, error) {
+	if responseId == "" {
+		return nil, errors.New("response id 为空！")
+	}
+	var stored StoredResponse
+	err := DB.Where("response_id = ? AND token_id = ? AND expires_at > ?", responseId, tokenId, common.GetTimestamp()).
+		First(&stored).Error
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func DeleteTokenStoredResponse(tokenId int, responseId string) error {
+	result := DB.Where("response_id = ? AND token_id = ?", responseId, tokenId).Delete(&StoredResponse{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteExpiredStoredResponses 删除已过期的响应，返回删除数量
+func DeleteExpiredStoredResponses() (int64, error) {
+	result := DB.Where("expires_at <= ?", common.GetTimestamp()).Delete(&StoredResponse{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+func newTestStoredResponse(responseId string, tokenId int) *StoredResponse {
+	return &StoredResponse{
+		ResponseId: responseId,
+		UserId:     1,
+		TokenId:    tokenId,
+		Model:      "gpt-4o",
+		ExpiresAt:  common.GetTimestamp() + 3600,
+		Response:   []byte(`{"id":"` + responseId + `"}`),
+	}
+}
+
+func TestStoredResponseInsertOverwritesAndTrims(t *testing.T) {
+	setupTestDB(t)
+	for i := 0; i < 4; i++ {
+		if err := newTestStoredResponse(fmt.Sprintf("resp_%d", i), 7).Insert(3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 重复保存同一 response id 时覆盖旧记录
+	overwrite := newTestStoredResponse("resp_3", 7)
+	overwrite.Model = "gpt-4o-mini"
+	if err := overwrite.Insert(3); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored []StoredResponse
+	DB.Where("token_id = ?", 7).Order("id").Find(&stored)
+	if len(stored) != 3 {
+		t.Fatalf("expected 3 responses after trimming, got %d", len(stored))
+	}
+	if stored[0].ResponseId != "resp_1" || stored[2].ResponseId != "resp_3" || stored[2].Model != "gpt-4o-mini" {
+		t.Fatalf("unexpected stored responses: %+v", stored)
+	}
+}
+
+func TestStoredResponseInsertRollsBackOnFailure(t *testing.T) {
+	setupTestDB(t)
+	first := newTestStoredResponse("resp_a", 7)
+	if err := first.Insert(0); err != nil {
+		t.Fatal(err)
+	}
+	other := newTestStoredResponse("resp_b", 7)
+	if err := other.Insert(0); err != nil {
+		t.Fatal(err)
+	}
+	// 主键冲突导致写入失败时，对 resp_a 的覆盖删除必须回滚
+	conflict := newTestStoredResponse("resp_a", 7)
+	conflict.Id = other.Id
+	if err := conflict.Insert(0); err == nil {
+		t.Fatal("expected insert to fail on primary key conflict")
+	}
+	if _, err := GetTokenStoredResponse(7, "resp_a"); err != nil {
+		t.Fatalf("original response should survive a failed overwrite: %v", err)
+	}
+}
+
+func TestDeleteTokenStoredResponseNotFound(t *testing.T) {
+	setupTestDB(t)
+	if err := newTestStoredResponse("resp_x", 7).Insert(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteTokenStoredResponse(8, "resp_x"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("deleting another token's response should be not found, got %v", err)
+	}
+	if err := DeleteTokenStoredResponse(7, "resp_x"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,499 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/samber/lo"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	StatementScopeUser         = "user"
+	StatementScopeOrganization = "organization"
+)
+
+// Statement 用户或组织的月度账单，按账期汇总消费日志，并与充值、退款记录对账。
+// 账单只在管理员生成或锁定账期时保存，锁定账期时生成的账单即为该账期的最终账单
+type Statement struct {
+	Id            int     `json:"id"`
+	Scope         string  `json:"scope" gorm:"type:varchar(16);uniqueIndex:idx_statement_period,priority:1"`
+	ScopeId       int     `json:"scope_id" gorm:"uniqueIndex:idx_statement_period,priority:2"`
+	Period        string  `json:"period" gorm:"type:varchar(7);uniqueIndex:idx_statement_period,priority:3;index"` // 账期，格式为 YYYY-MM
+	PeriodStart   int64   `json:"period_start" gorm:"bigint"`
+	PeriodEnd     int64   `json:"period_end" gorm:"bigint"`
+	RequestCount  int     `json:"request_count"`
+	ConsumeQuota  int     `json:"consume_quota"`           // 消费额度
+	RefundQuota   int     `json:"refund_quota"`            // 退款额度
+	TopUpQuota    int     `json:"top_up_quota"`            // 充值入账额度
+	TopUpMoney    float64 `json:"top_up_money"`            // 在线充值的支付金额
+	TransferQuota int     `json:"transfer_quota"`          // 从钱包转入组织的额度，仅用户账单
+	Detail        string  `json:"detail" gorm:"type:text"` // StatementDetail 的 JSON
+	GeneratedTime int64   `json:"generated_time" gorm:"bigint"`
+	Locked        bool    `json:"locked" gorm:"-"`
+}
+
+// StatementPeriodLock 已锁定的账期，锁定后该账期的账单不再变化
+type StatementPeriodLock struct {
+	Id         int    `json:"id"`
+	Period     string `json:"period" gorm:"type:varchar(7);uniqueIndex"`
+	LockedBy   int    `json:"locked_by"`
+	LockedTime int64  `json:"locked_time" gorm:"bigint"`
+}
+
+// StatementItem 账单中按模型、令牌或日期汇总的一行
+type StatementItem struct {
+	Name             string `json:"name"`
+	Requests         int    `json:"requests"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	Quota            int    `json:"quota"`
+}
+
+// StatementTopUp 账期内的一笔充值、兑换码兑换或转入组织的额度
+type StatementTopUp struct {
+	Time          int64   `json:"time"`
+	TradeNo       string  `json:"trade_no,omitempty"`
+	PaymentMethod string  `json:"payment_method,omitempty"`
+	Money         float64 `json:"money"`
+	Quota         int     `json:"quota"`
+	Content       string  `json:"content,omitempty"`
+}
+
+type StatementDetail struct {
+	ByModel []StatementItem  `json:"by_model"`
+	ByToken []StatementItem  `json:"by_token"`
+	ByDay   []StatementItem  `json:"by_day"`
+	TopUps  []StatementTopUp `json:"top_ups"`
+	// 从钱包转入组织的记录，仅用户账单
+	Transfers []StatementTopUp `json:"transfers"`
+}
+
+// NetQuota 账期内额度的净变化，即充值与退款之和减去消费与转入组织的额度
+func (statement *Statement) NetQuota() int {
+	return statement.TopUpQuota + statement.RefundQuota - statement.ConsumeQuota - statement.TransferQuota
+}
+
+func (statement *Statement) GetDetail() StatementDetail {
+	detail := StatementDetail{}
+	if statement.Detail != "" {
+		if err := common.UnmarshalJsonStr(statement.Detail, &detail); err != nil {
+			common.SysError("failed to unmarshal statement detail: " + err.Error())
+		}
+	}
+	return detail
+}
+
+// ParseStatementPeriod 解析 YYYY-MM 格式的账期，返回按服务器时区划分的开始与结束时间
+func ParseStatementPeriod(period string) (int64, int64, error) {
+	start, err := time.ParseInLocation("2006-01", period, time.Local)
+	if err != nil {
+		return 0, 0, errors.New("账期格式应为 YYYY-MM")
+	}
+	return start.Unix(), start.AddDate(0, 1, 0).Unix(), nil
+}
+
+func IsStatementPeriodLocked(period string) (bool, error) {
+	var count int64
+	err := DB.Model(&StatementPeriodLock{}).Where("period = ?", period).Count(&count).Error
+	return count > 0, err
+}
+
+func GetStatementPeriodLocks() (locks []*StatementPeriodLock, err error) {
+	err = DB.Order("period desc").Find(&locks).Error
+	return locks, err
+}
+
+// LockStatementPeriod 锁定账期，只能锁定已结束的账期。锁定前为账期内有记录的用户与组织重新生成账单，
+// 锁定后账单不再变化，日志清理也不影响已锁定的账单
+func LockStatementPeriod(period string, operatorId int) error {
+	start, end, err := ParseStatementPeriod(period)
+	if err != nil {
+		return err
+	}
+	if end > common.GetTimestamp() {
+		return errors.New("只能锁定已结束的账期")
+	}
+	if locked, err := IsStatementPeriodLocked(period); err != nil || locked {
+		if locked {
+			return errors.New("账期已锁定")
+		}
+		return err
+	}
+	userIds, organizationIds, err := statementPeriodScopes(period, start, end)
+	if err != nil {
+		return err
+	}
+	for _, userId := range userIds {
+		if _, err := saveStatement(StatementScopeUser, userId, period); err != nil {
+			return fmt.Errorf("生成用户 %d 的账单失败: %w", userId, err)
+		}
+	}
+	for _, organizationId := range organizationIds {
+		if _, err := saveStatement(StatementScopeOrganization, organizationId, period); err != nil {
+			return fmt.Errorf("生成组织 %d 的账单失败: %w", organizationId, err)
+		}
+	}
+	return DB.Create(&StatementPeriodLock{
+		Period:     period,
+		LockedBy:   operatorId,
+		LockedTime: common.GetTimestamp(),
+	}).Error
+}
+
+// statementPeriodScopes 返回账期内有消费、退款、充值、兑换或转入记录，以及已生成过账单的用户与组织
+func statementPeriodScopes(period string, start int64, end int64) (userIds []int, organizationIds []int, err error) {
+	users := make(map[int]bool)
+	organizations := make(map[int]bool)
+	collect := func(target map[int]bool, tx *gorm.DB, column string) error {
+		var ids []int
+		if err := tx.Distinct(column).Pluck(column, &ids).Error; err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if id != 0 {
+				target[id] = true
+			}
+		}
+		return nil
+	}
+	logTypes := []int{LogTypeConsume, LogTypeCacheHit, LogTypeRefund, LogTypeTopup}
+	logQuery := func() *gorm.DB {
+		return LOG_DB.Model(&Log{}).Where("created_at >= ? AND created_at < ? AND type IN ?", start, end, logTypes)
+	}
+	queries := []struct {
+		target map[int]bool
+		tx     *gorm.DB
+		column string
+	}{
+		{users, logQuery().Where("organization_id = 0"), "user_id"},
+		// 成员从钱包转入组织的记录在组织日志中，同时影响成员的用户账单
+		{users, logQuery().Where("organization_id <> 0 AND type = ?", LogTypeTopup), "user_id"},
+		{organizations, logQuery().Where("organization_id <> 0"), "organization_id"},
+		{users, DB.Model(&TopUp{}).Where("status = ?", common.TopUpStatusSuccess).
+			Where("(complete_time >= ? AND complete_time < ?) OR (complete_time = 0 AND create_time >= ? AND create_time < ?)", start, end, start, end), "user_id"},
+		{users, DB.Unscoped().Model(&Redemption{}).Where("status = ? AND redeemed_time >= ? AND redeemed_time < ?",
+			common.RedemptionCodeStatusUsed, start, end), "used_user_id"},
+		{users, DB.Model(&Statement{}).Where("period = ? AND scope = ?", period, StatementScopeUser), "scope_id"},
+		{organizations, DB.Model(&Statement{}).Where("period = ? AND scope = ?", period, StatementScopeOrganization), "scope_id"},
+	}
+	for _, query := range queries {
+		if err := collect(query.target, query.tx, query.column); err != nil {
+			return nil, nil, err
+		}
+	}
+	userIds = lo.Keys(users)
+	organizationIds = lo.Keys(organizations)
+	sort.Ints(userIds)
+	sort.Ints(organizationIds)
+	return userIds, organizationIds, nil
+}
+
+func UnlockStatementPeriod(period string) error {
+	return DB.Where("period = ?", period).Delete(&StatementPeriodLock{}).Error
+}
+
+// statementLogQuery 返回账期内属于该账单的日志查询。用户账单不包含组织令牌产生的日志，由组织账单统计
+func statementLogQuery(scope string, scopeId int, start int64, end int64) *gorm.DB {
+	tx := LOG_DB.Model(&Log{}).Where("created_at >= ? AND created_at < ?", start, end)
+	if scope == StatementScopeOrganization {
+		return tx.Where("organization_id = ?", scopeId)
+	}
+	return tx.Where("user_id = ? AND organization_id = 0", scopeId)
+}
+
+func statementConsumeItems(scope string, scopeId int, start int64, end int64, nameExpr string) (items []StatementItem, err error) {
+	err = statementLogQuery(scope, scopeId, start, end).
+		Where("type IN ?", []int{LogTypeConsume, LogTypeCacheHit}).
+		Select(nameExpr + " AS name, COUNT(*) AS requests, SUM(prompt_tokens) AS prompt_tokens, " +
+			"SUM(completion_tokens) AS completion_tokens, SUM(quota) AS quota").
+		Group("name").
+		Order("SUM(quota) desc").
+		Scan(&items).Error
+	return items, err
+}
+
+// statementTopUpQuota 返回在线充值订单入账的额度。订单完成时记录了入账额度的直接使用，
+// 更早的订单按各支付回调的入账方式重新计算；订阅套餐订单的额度发放到套餐，不计入钱包
+func statementTopUpQuota(topUp *TopUp) int {
+	if topUp.PlanId != 0 {
+		return 0
+	}
+	if topUp.Quota > 0 {
+		return topUp.Quota
+	}
+	dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
+	switch topUp.PaymentMethod {
+	case "stripe":
+		return int(decimal.NewFromFloat(topUp.Money).Mul(dQuotaPerUnit).IntPart())
+	case "creem":
+		return int(topUp.Amount)
+	default:
+		return int(decimal.NewFromInt(topUp.Amount).Mul(dQuotaPerUnit).IntPart())
+	}
+}
+
+func statementTopUps(scope string, scopeId int, start int64, end int64) ([]StatementTopUp, error) {
+	result := make([]StatementTopUp, 0)
+	if scope == StatementScopeOrganization {
+		// 组织的充值记录在组织日志中，包括管理员充值与成员转入
+		var logs []*Log
+		err := statementLogQuery(scope, scopeId, start, end).Where("type = ?", LogTypeTopup).Order("id asc").Find(&logs).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			result = append(result, StatementTopUp{Time: log.CreatedAt, Quota: log.Quota, Content: log.Content})
+		}
+		return result, nil
+	}
+	// 早期易支付订单未记录完成时间，按创建时间统计
+	var topUps []*TopUp
+	err := DB.Where("user_id = ? AND status = ?", scopeId, common.TopUpStatusSuccess).
+		Where("(complete_time >= ? AND complete_time < ?) OR (complete_time = 0 AND create_time >= ? AND create_time < ?)", start, end, start, end).
+		Find(&topUps).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, topUp := range topUps {
+		completeTime := topUp.CompleteTime
+		if completeTime == 0 {
+			completeTime = topUp.CreateTime
+		}
+		result = append(result, StatementTopUp{
+			Time:          completeTime,
+			TradeNo:       topUp.TradeNo,
+			PaymentMethod: topUp.PaymentMethod,
+			Money:         topUp.Money,
+			Quota:         statementTopUpQuota(topUp),
+		})
+	}
+	// 兑换码兑换的额度，兑换码被删除后仍计入
+	var redemptions []*Redemption
+	err = DB.Unscoped().Where("used_user_id = ? AND status = ? AND redeemed_time >= ? AND redeemed_time < ?",
+		scopeId, common.RedemptionCodeStatusUsed, start, end).Find(&redemptions).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, redemption := range redemptions {
+		result = append(result, StatementTopUp{
+			Time:    redemption.RedeemedTime,
+			Quota:   redemption.Quota,
+			Content: fmt.Sprintf("兑换码 #%d", redemption.Id),
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time < result[j].Time
+	})
+	return result, nil
+}
+
+// statementTransfers 返回用户账期内从钱包转入组织的记录，转入记录保存在组织日志中
+func statementTransfers(userId int, start int64, end int64) ([]StatementTopUp, error) {
+	var logs []*Log
+	err := LOG_DB.Where("user_id = ? AND organization_id <> 0 AND type = ? AND created_at >= ? AND created_at < ?",
+		userId, LogTypeTopup, start, end).Order("id asc").Find(&logs).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make([]StatementTopUp, 0)
+	for _, log := range logs {
+		other, err := common.StrToMap(log.Other)
+		if err != nil || other[organizationLogWalletTransfer] != true {
+			continue
+		}
+		result = append(result, StatementTopUp{Time: log.CreatedAt, Quota: log.Quota, Content: log.Content})
+	}
+	return result, nil
+}
+
+// buildStatement 从日志与充值记录汇总账单
+func buildStatement(scope string, scopeId int, period string) (*Statement, error) {
+	start, end, err := ParseStatementPeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	if start > common.GetTimestamp() {
+		return nil, errors.New("账期尚未开始")
+	}
+	statement := &Statement{
+		Scope:         scope,
+		ScopeId:       scopeId,
+		Period:        period,
+		PeriodStart:   start,
+		PeriodEnd:     end,
+		GeneratedTime: common.GetTimestamp(),
+	}
+	detail := StatementDetail{}
+	if detail.ByModel, err = statementConsumeItems(scope, scopeId, start, end, "model_name"); err != nil {
+		return nil, err
+	}
+	if detail.ByToken, err = statementConsumeItems(scope, scopeId, start, end, "token_name"); err != nil {
+		return nil, err
+	}
+	// 按服务器时区的自然日分组，取整运算在各数据库中一致
+	_, offset := time.Unix(start, 0).In(time.Local).Zone()
+	dayExpr := fmt.Sprintf("(created_at - (created_at + %d) %% 86400)", offset)
+	if detail.ByDay, err = statementConsumeItems(scope, scopeId, start, end, dayExpr); err != nil {
+		return nil, err
+	}
+	for i := range detail.ByDay {
+		dayStart, _ := strconv.ParseInt(detail.ByDay[i].Name, 10, 64)
+		detail.ByDay[i].Name = time.Unix(dayStart, 0).In(time.Local).Format("2006-01-02")
+	}
+	sort.Slice(detail.ByDay, func(i, j int) bool {
+		return detail.ByDay[i].Name < detail.ByDay[j].Name
+	})
+	for _, item := range detail.ByModel {
+		statement.RequestCount += item.Requests
+		statement.ConsumeQuota += item.Quota
+	}
+
+	var refund struct{ Quota int }
+	err = statementLogQuery(scope, scopeId, start, end).Where("type = ?", LogTypeRefund).
+		Select("COALESCE(SUM(quota), 0) AS quota").Scan(&refund).Error
+	if err != nil {
+		return nil, err
+	}
+	statement.RefundQuota = refund.Quota
+
+	if detail.TopUps, err = statementTopUps(scope, scopeId, start, end); err != nil {
+		return nil, err
+	}
+	money := decimal.Zero
+	for _, topUp := range detail.TopUps {
+		statement.TopUpQuota += topUp.Quota
+		money = money.Add(decimal.NewFromFloat(topUp.Money))
+	}
+	statement.TopUpMoney = money.InexactFloat64()
+
+	if scope == StatementScopeUser {
+		if detail.Transfers, err = statementTransfers(scopeId, start, end); err != nil {
+			return nil, err
+		}
+		for _, transfer := range detail.Transfers {
+			statement.TransferQuota += transfer.Quota
+		}
+	}
+
+	detailJson, err := json.Marshal(detail)
+	if err != nil {
+		return nil, err
+	}
+	statement.Detail = string(detailJson)
+	return statement, nil
+}
+
+func findStatement(scope string, scopeId int, period string) (*Statement, error) {
+	var statements []*Statement
+	err := DB.Where("scope = ? AND scope_id = ? AND period = ?", scope, scopeId, period).Limit(1).Find(&statements).Error
+	if err != nil || len(statements) == 0 {
+		return nil, err
+	}
+	return statements[0], nil
+}
+
+// saveStatement 生成账单并保存，覆盖该账期已有的账单
+func saveStatement(scope string, scopeId int, period string) (*Statement, error) {
+	existing, err := findStatement(scope, scopeId, period)
+	if err != nil {
+		return nil, err
+	}
+	statement, err := buildStatement(scope, scopeId, period)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		statement.Id = existing.Id
+	}
+	if err := DB.Save(statement).Error; err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+// GenerateStatement 重新生成账单并保存，账期已锁定时返回错误
+func GenerateStatement(scope string, scopeId int, period string) (*Statement, error) {
+	locked, err := IsStatementPeriodLocked(period)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		return nil, errors.New("账期已锁定，不能重新生成账单")
+	}
+	return saveStatement(scope, scopeId, period)
+}
+
+// GetStatement 返回账单，只读取不保存。账期已锁定或账期结束后生成的账单直接返回，
+// 账期已锁定但没有账单时说明该账期没有记录，其余情况按当前记录实时汇总
+func GetStatement(scope string, scopeId int, period string) (*Statement, error) {
+	if _, _, err := ParseStatementPeriod(period); err != nil {
+		return nil, err
+	}
+	locked, err := IsStatementPeriodLocked(period)
+	if err != nil {
+		return nil, err
+	}
+	statement, err := findStatement(scope, scopeId, period)
+	if err != nil {
+		return nil, err
+	}
+	if statement != nil && (locked || statement.GeneratedTime >= statement.PeriodEnd) {
+		statement.Locked = locked
+		return statement, nil
+	}
+	if locked {
+		return nil, errors.New("账期已锁定，该账期没有账单")
+	}
+	return buildStatement(scope, scopeId, period)
+}
+
+func GetStatementById(id int) (*Statement, error) {
+	statement := &Statement{}
+	if err := DB.First(statement, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	locked, err := IsStatementPeriodLocked(statement.Period)
+	statement.Locked = locked
+	return statement, err
+}
+
+// GetStatements 查询已生成的账单，列表不返回明细
+func GetStatements(scope string, scopeId int, period string, pageInfo *common.PageInfo) (statements []*Statement, total int64, err error) {
+	tx := DB.Model(&Statement{})
+	if scope != "" {
+		tx = tx.Where("scope = ?", scope)
+	}
+	if scopeId != 0 {
+		tx = tx.Where("scope_id = ?", scopeId)
+	}
+	if period != "" {
+		tx = tx.Where("period = ?", period)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Omit("detail").Order("period desc, id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&statements).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	locks, err := GetStatementPeriodLocks()
+	if err != nil {
+		return nil, 0, err
+	}
+	lockedPeriods := make(map[string]bool, len(locks))
+	for _, lock := range locks {
+		lockedPeriods[lock.Period] = true
+	}
+	for _, statement := range statements {
+		statement.Locked = lockedPeriods[statement.Period]
+	}
+	return statements, total, nil
+}
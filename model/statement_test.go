@@ -0,0 +1,164 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+func createStatementLog(t *testing.T, log *Log) {
+	t.Helper()
+	if err := LOG_DB.Create(log).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func countStatements(t *testing.T) int64 {
+	t.Helper()
+	var count int64
+	if err := DB.Model(&Statement{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestStatementReconcilesWallet(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "statement_reconcile", 0)
+	org := createTestOrganization(t, createTestUser(t, "statement_org_owner", 0), 0)
+	now := common.GetTimestamp()
+	period := time.Unix(now, 0).Format("2006-01")
+
+	// 在线充值按订单记录的入账额度统计，不受之后修改单位额度的影响
+	topUp := &TopUp{
+		UserId:        user.Id,
+		Amount:        10,
+		Money:         10,
+		Quota:         5000,
+		TradeNo:       "statement_reconcile",
+		PaymentMethod: "epay",
+		CreateTime:    now,
+		CompleteTime:  now,
+		Status:        common.TopUpStatusSuccess,
+	}
+	if err := DB.Create(topUp).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := IncreaseUserQuota(user.Id, topUp.Quota, true); err != nil {
+		t.Fatal(err)
+	}
+	originalQuotaPerUnit := common.QuotaPerUnit
+	common.QuotaPerUnit = originalQuotaPerUnit * 2
+	t.Cleanup(func() {
+		common.QuotaPerUnit = originalQuotaPerUnit
+	})
+
+	// 兑换码删除后仍计入兑换的额度
+	redemption := &Redemption{
+		Key:          "statement_reconcile",
+		Status:       common.RedemptionCodeStatusUsed,
+		Quota:        700,
+		RedeemedTime: now,
+		UsedUserId:   user.Id,
+	}
+	if err := DB.Create(redemption).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Delete(redemption).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := IncreaseUserQuota(user.Id, redemption.Quota, true); err != nil {
+		t.Fatal(err)
+	}
+
+	createStatementLog(t, &Log{UserId: user.Id, CreatedAt: now, Type: LogTypeConsume, ModelName: "gpt-4o", Quota: 1200})
+	createStatementLog(t, &Log{UserId: user.Id, CreatedAt: now, Type: LogTypeRefund, Quota: 200})
+	if err := DecreaseUserQuota(user.Id, 1000); err != nil {
+		t.Fatal(err)
+	}
+	// 管理员为组织充值不计入用户账单，从钱包转入组织的额度计入
+	RecordOrganizationLog(org.Id, user.Id, LogTypeTopup, 900, "管理员充值")
+	if err := FundOrganization(org.Id, user.Id, 1500); err != nil {
+		t.Fatal(err)
+	}
+
+	statement, err := GetStatement(StatementScopeUser, user.Id, period)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.TopUpQuota != 5700 || statement.TopUpMoney != 10 || statement.TransferQuota != 1500 {
+		t.Fatalf("top up = %d, money = %v, transfer = %d", statement.TopUpQuota, statement.TopUpMoney, statement.TransferQuota)
+	}
+	wallet, err := GetUserQuota(user.Id, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.NetQuota() != wallet {
+		t.Fatalf("net quota = %d, wallet = %d", statement.NetQuota(), wallet)
+	}
+	detail := statement.GetDetail()
+	if len(detail.TopUps) != 2 || len(detail.Transfers) != 1 {
+		t.Fatalf("unexpected detail: %+v", detail)
+	}
+	// 查看本期账单不保存
+	if count := countStatements(t); count != 0 {
+		t.Fatalf("statements saved = %d", count)
+	}
+}
+
+func TestLockStatementPeriodFreezesStatements(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "statement_lock", 0)
+	idle := createTestUser(t, "statement_idle", 0)
+	org := createTestOrganization(t, user, 0)
+	currentStart, _, err := ParseStatementPeriod(time.Now().Format("2006-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastMonth := currentStart - 24*60*60
+	period := time.Unix(lastMonth, 0).Format("2006-01")
+
+	createStatementLog(t, &Log{UserId: user.Id, CreatedAt: lastMonth, Type: LogTypeConsume, ModelName: "gpt-4o", Quota: 300})
+	createStatementLog(t, &Log{UserId: user.Id, OrganizationId: org.Id, CreatedAt: lastMonth, Type: LogTypeConsume, ModelName: "gpt-4o", Quota: 100})
+	if _, err := GetStatement(StatementScopeUser, user.Id, period); err != nil {
+		t.Fatal(err)
+	}
+	if count := countStatements(t); count != 0 {
+		t.Fatalf("statements saved before locking = %d", count)
+	}
+
+	// 锁定时为账期内有记录的用户与组织生成账单
+	if err := LockStatementPeriod(period, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	if count := countStatements(t); count != 2 {
+		t.Fatalf("statements saved when locking = %d", count)
+	}
+	if _, err := GenerateStatement(StatementScopeUser, user.Id, period); err == nil {
+		t.Fatal("expected regenerating a locked period to fail")
+	}
+
+	// 锁定后新增的日志不影响账单
+	createStatementLog(t, &Log{UserId: user.Id, CreatedAt: lastMonth, Type: LogTypeConsume, ModelName: "gpt-4o", Quota: 500})
+	statement, err := GetStatement(StatementScopeUser, user.Id, period)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statement.Locked || statement.ConsumeQuota != 300 {
+		t.Fatalf("locked = %v, consume = %d", statement.Locked, statement.ConsumeQuota)
+	}
+	orgStatement, err := GetStatement(StatementScopeOrganization, org.Id, period)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orgStatement.ConsumeQuota != 100 {
+		t.Fatalf("organization consume = %d", orgStatement.ConsumeQuota)
+	}
+	if _, err := GetStatement(StatementScopeUser, idle.Id, period); err == nil {
+		t.Fatal("expected a locked period without a statement to fail")
+	}
+	if count := countStatements(t); count != 2 {
+		t.Fatalf("statements after reading = %d", count)
+	}
+}
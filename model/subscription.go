@@ -0,0 +1,436 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+
+	"gorm.io/gorm"
+)
+
+// 订阅套餐的额度结转方式
+const (
+	SubscriptionRolloverNone  = "none"  // 每个周期重置为套餐额度，未用完的额度作废
+	SubscriptionRolloverCarry = "carry" // 未用完的额度结转到下个周期
+)
+
+const (
+	SubscriptionStatusActive  = "active"
+	SubscriptionStatusExpired = "expired"
+)
+
+const secondsPerDay = 24 * 60 * 60
+
+// SubscriptionPlan 预付费订阅套餐，每个周期发放一次套餐额度
+type SubscriptionPlan struct {
+	Id             int     `json:"id"`
+	Name           string  `json:"name" gorm:"type:varchar(64)"`
+	Description    string  `json:"description" gorm:"type:text"`
+	Price          float64 `json:"price"`                                                  // 易支付的支付金额，同时用于展示
+	PeriodDays     int     `json:"period_days" gorm:"default:30"`                          // 每个额度周期的天数
+	Periods        int     `json:"periods" gorm:"default:1"`                               // 一次购买包含的周期数
+	Quota          int     `json:"quota"`                                                  // 每个周期发放的额度
+	UpgradeGroup   string  `json:"upgrade_group" gorm:"type:varchar(64);default:''"`       // 订阅期间用户所在的分组，为空时不调整
+	AllowedGroups  string  `json:"allowed_groups" gorm:"type:varchar(255);default:''"`     // 可使用套餐额度的分组，逗号分隔，为空表示所有分组
+	RolloverPolicy string  `json:"rollover_policy" gorm:"type:varchar(16);default:'none'"` // 周期结束时剩余额度的处理方式
+	RolloverCap    int     `json:"rollover_cap"`                                           // 结转后的额度上限，0 表示不限制
+	StripePriceId  string  `json:"stripe_price_id" gorm:"type:varchar(128);default:''"`
+	CreemProductId string  `json:"creem_product_id" gorm:"type:varchar(128);default:''"`
+	Enabled        bool    `json:"enabled"`
+	CreatedTime    int64   `json:"created_time" gorm:"bigint"`
+	UpdatedTime    int64   `json:"updated_time" gorm:"bigint"`
+}
+
+// UserSubscription 用户的订阅记录，套餐额度与钱包余额分开记录，消费时优先使用套餐额度。
+// 额度相关的套餐配置在订阅时保存快照，修改套餐不影响已有订阅
+type UserSubscription struct {
+	Id             int    `json:"id"`
+	UserId         int    `json:"user_id" gorm:"index"`
+	PlanId         int    `json:"plan_id" gorm:"index"`
+	PlanName       string `json:"plan_name" gorm:"type:varchar(64)"`
+	Status         string `json:"status" gorm:"type:varchar(16);index"`
+	Quota          int    `json:"quota"`        // 当前周期剩余的套餐额度
+	UsedQuota      int    `json:"used_quota"`   // 当前周期已使用的套餐额度
+	PeriodQuota    int    `json:"period_quota"` // 每个周期发放的额度
+	PeriodDays     int    `json:"period_days"`
+	AllowedGroups  string `json:"allowed_groups" gorm:"type:varchar(255);default:''"`
+	RolloverPolicy string `json:"rollover_policy" gorm:"type:varchar(16)"`
+	RolloverCap    int    `json:"rollover_cap"`
+	UpgradeGroup   string `json:"upgrade_group" gorm:"type:varchar(64);default:''"`
+	PreviousGroup  string `json:"previous_group" gorm:"type:varchar(64);default:''"` // 订阅前的分组，到期后恢复
+	StartTime      int64  `json:"start_time" gorm:"bigint"`
+	EndTime        int64  `json:"end_time" gorm:"bigint;index"`
+	PeriodStart    int64  `json:"period_start" gorm:"bigint"`
+	PeriodEnd      int64  `json:"period_end" gorm:"bigint;index"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime    int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// Validate 校验套餐配置
+func (plan *SubscriptionPlan) Validate() error {
+	plan.Name = strings.TrimSpace(plan.Name)
+	if plan.Name == "" {
+		return errors.New("套餐名称不能为空")
+	}
+	if plan.Price < 0 {
+		return errors.New("套餐价格不能为负数")
+	}
+	if plan.PeriodDays <= 0 {
+		return errors.New("额度周期天数必须大于 0")
+	}
+	if plan.Periods <= 0 {
+		return errors.New("套餐包含的周期数必须大于 0")
+	}
+	if plan.Quota < 0 || plan.RolloverCap < 0 {
+		return errors.New("套餐额度不能为负数")
+	}
+	switch plan.RolloverPolicy {
+	case "":
+		plan.RolloverPolicy = SubscriptionRolloverNone
+	case SubscriptionRolloverNone, SubscriptionRolloverCarry:
+	default:
+		return fmt.Errorf("未知的额度结转方式：%s", plan.RolloverPolicy)
+	}
+	groups := make([]string, 0)
+	for _, group := range strings.Split(plan.AllowedGroups, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	plan.AllowedGroups = strings.Join(groups, ",")
+	plan.UpgradeGroup = strings.TrimSpace(plan.UpgradeGroup)
+	return nil
+}
+
+func (plan *SubscriptionPlan) Insert() error {
+	now := common.GetTimestamp()
+	plan.CreatedTime = now
+	plan.UpdatedTime = now
+	return DB.Create(plan).Error
+}
+
+func (plan *SubscriptionPlan) Update() error {
+	plan.UpdatedTime = common.GetTimestamp()
+	return DB.Model(plan).Select("name", "description", "price", "period_days", "periods", "quota", "upgrade_group",
+		"allowed_groups", "rollover_policy", "rollover_cap", "stripe_price_id", "creem_product_id", "enabled", "updated_time").
+		Updates(plan).Error
+}
+
+func DeleteSubscriptionPlanById(id int) error {
+	return DB.Delete(&SubscriptionPlan{}, "id = ?", id).Error
+}
+
+func GetSubscriptionPlanById(id int) (*SubscriptionPlan, error) {
+	plan := &SubscriptionPlan{}
+	err := DB.Where("id = ?", id).First(plan).Error
+	return plan, err
+}
+
+// GetSubscriptionPlans 返回套餐列表，enabledOnly 为 true 时只返回可订阅的套餐
+func GetSubscriptionPlans(enabledOnly bool) ([]*SubscriptionPlan, error) {
+	var plans []*SubscriptionPlan
+	query := DB.Order("id asc")
+	if enabledOnly {
+		query = query.Where("enabled = ?", true)
+	}
+	err := query.Find(&plans).Error
+	return plans, err
+}
+
+// AllowsGroup 返回该分组的请求能否使用套餐额度
+func (subscription *UserSubscription) AllowsGroup(group string) bool {
+	if subscription.AllowedGroups == "" {
+		return true
+	}
+	return common.StringsContains(strings.Split(subscription.AllowedGroups, ","), group)
+}
+
+// GetActiveUserSubscription 返回用户生效中的订阅，没有时返回 nil
+func GetActiveUserSubscription(userId int) (*UserSubscription, error) {
+	var subscriptions []*UserSubscription
+	err := DB.Where("user_id = ? AND status = ? AND end_time > ?", userId, SubscriptionStatusActive, common.GetTimestamp()).
+		Order("id desc").Limit(1).Find(&subscriptions).Error
+	if err != nil || len(subscriptions) == 0 {
+		return nil, err
+	}
+	return subscriptions[0], nil
+}
+
+// GetUserSubscriptions 分页返回用户的订阅记录，userId 为 0 时返回全部用户的记录
+func GetUserSubscriptions(userId int, pageInfo *common.PageInfo) (subscriptions []*UserSubscription, total int64, err error) {
+	query := DB.Model(&UserSubscription{})
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&subscriptions).Error
+	return subscriptions, total, err
+}
+
+// ConsumeSubscriptionQuota 从套餐额度中扣除至多 quota，返回实际扣除的额度。
+// 订阅已进入下一个周期时不扣除，由调用方改为扣除钱包余额
+func ConsumeSubscriptionQuota(id int, periodStart int64, quota int) (int, error) {
+	if quota <= 0 {
+		return 0, nil
+	}
+	subscription := &UserSubscription{}
+	err := DB.Where("id = ? AND status = ? AND period_start = ?", id, SubscriptionStatusActive, periodStart).First(subscription).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	consumed := min(quota, subscription.Quota)
+	if consumed <= 0 {
+		return 0, nil
+	}
+	// 以剩余额度作为条件，避免并发扣除时额度变为负数
+	result := DB.Model(&UserSubscription{}).
+		Where("id = ? AND period_start = ? AND quota >= ?", id, periodStart, consumed).
+		Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota - ?", consumed),
+			"used_quota": gorm.Expr("used_quota + ?", consumed),
+		})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, nil
+	}
+	return consumed, nil
+}
+
+// RefundSubscriptionQuota 返还套餐额度，订阅已进入下一个周期时不返还并返回 false
+func RefundSubscriptionQuota(id int, periodStart int64, quota int) (bool, error) {
+	if quota <= 0 {
+		return true, nil
+	}
+	result := DB.Model(&UserSubscription{}).
+		Where("id = ? AND status = ? AND period_start = ?", id, SubscriptionStatusActive, periodStart).
+		Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota + ?", quota),
+			"used_quota": gorm.Expr("used_quota - ?", quota),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// startNextPeriod 进入下一个额度周期，按结转方式计算新周期的额度
+func (subscription *UserSubscription) startNextPeriod() {
+	remaining := subscription.Quota
+	subscription.PeriodStart = subscription.PeriodEnd
+	subscription.PeriodEnd = min(subscription.PeriodStart+int64(subscription.PeriodDays)*secondsPerDay, subscription.EndTime)
+	subscription.Quota = subscription.PeriodQuota
+	if subscription.RolloverPolicy == SubscriptionRolloverCarry && remaining > 0 {
+		subscription.Quota += remaining
+		if subscription.RolloverCap > 0 && subscription.Quota > subscription.RolloverCap {
+			subscription.Quota = max(subscription.RolloverCap, subscription.PeriodQuota)
+		}
+	}
+	subscription.UsedQuota = 0
+}
+
+// CompleteSubscriptionTopUp 完成订阅套餐的支付订单并开通或续期订阅，重复回调时直接返回
+func CompleteSubscriptionTopUp(tradeNo string) error {
+	if tradeNo == "" {
+		return errors.New("未提供支付单号")
+	}
+	refCol := "`trade_no`"
+	if common.UsingPostgreSQL {
+		refCol = `"trade_no"`
+	}
+
+	topUp := &TopUp{}
+	var subscription *UserSubscription
+	var groupChanged bool
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Set("gorm:query_option", "FOR UPDATE").Where(refCol+" = ?", tradeNo).First(topUp).Error
+		if err != nil {
+			return errors.New("订阅订单不存在")
+		}
+		if topUp.Status == common.TopUpStatusSuccess {
+			return nil
+		}
+		if topUp.Status != common.TopUpStatusPending {
+			return errors.New("订阅订单状态错误")
+		}
+		plan := &SubscriptionPlan{}
+		if err = tx.Where("id = ?", topUp.PlanId).First(plan).Error; err != nil {
+			return errors.New("订阅套餐不存在")
+		}
+
+		topUp.CompleteTime = common.GetTimestamp()
+		topUp.Status = common.TopUpStatusSuccess
+		if err = tx.Save(topUp).Error; err != nil {
+			return err
+		}
+		subscription, groupChanged, err = activateSubscription(tx, topUp.UserId, plan)
+		return err
+	})
+	if err != nil {
+		return errors.New("开通订阅失败，" + err.Error())
+	}
+	if subscription == nil {
+		return nil
+	}
+	if groupChanged {
+		if err := invalidateUserCache(topUp.UserId); err != nil {
+			common.SysLog("failed to invalidate user cache: " + err.Error())
+		}
+	}
+	RecordLog(topUp.UserId, LogTypeTopup, fmt.Sprintf("订阅套餐 %s 成功，支付金额：%.2f，有效期至 %s，每周期额度：%v",
+		subscription.PlanName, topUp.Money, time.Unix(subscription.EndTime, 0).Format("2006-01-02 15:04:05"), logger.FormatQuota(subscription.PeriodQuota)))
+	return nil
+}
+
+// activateSubscription 开通订阅，已订阅同一套餐时顺延有效期，订阅了其他套餐时以新套餐替换
+func activateSubscription(tx *gorm.DB, userId int, plan *SubscriptionPlan) (*UserSubscription, bool, error) {
+	now := common.GetTimestamp()
+	duration := int64(plan.PeriodDays*plan.Periods) * secondsPerDay
+
+	var current UserSubscription
+	err := tx.Where("user_id = ? AND status = ? AND end_time > ?", userId, SubscriptionStatusActive, now).Order("id desc").First(&current).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+	hasCurrent := err == nil
+	if hasCurrent && current.PlanId == plan.Id {
+		current.EndTime += duration
+		current.UpdatedTime = now
+		return &current, false, tx.Save(&current).Error
+	}
+
+	var userGroup string
+	if err = tx.Model(&User{}).Where("id = ?", userId).Select(commonGroupCol).Find(&userGroup).Error; err != nil {
+		return nil, false, err
+	}
+	previousGroup := userGroup
+	if hasCurrent {
+		// 替换原订阅，恢复分组时仍以最初订阅前的分组为准
+		if current.UpgradeGroup != "" && current.PreviousGroup != "" {
+			previousGroup = current.PreviousGroup
+		}
+		current.Status = SubscriptionStatusExpired
+		current.EndTime = now
+		current.UpdatedTime = now
+		if err = tx.Save(&current).Error; err != nil {
+			return nil, false, err
+		}
+	}
+
+	subscription := &UserSubscription{
+		UserId:         userId,
+		PlanId:         plan.Id,
+		PlanName:       plan.Name,
+		Status:         SubscriptionStatusActive,
+		Quota:          plan.Quota,
+		PeriodQuota:    plan.Quota,
+		PeriodDays:     plan.PeriodDays,
+		AllowedGroups:  plan.AllowedGroups,
+		RolloverPolicy: plan.RolloverPolicy,
+		RolloverCap:    plan.RolloverCap,
+		UpgradeGroup:   plan.UpgradeGroup,
+		StartTime:      now,
+		EndTime:        now + duration,
+		PeriodStart:    now,
+		PeriodEnd:      min(now+int64(plan.PeriodDays)*secondsPerDay, now+duration),
+		CreatedTime:    now,
+		UpdatedTime:    now,
+	}
+	targetGroup := previousGroup
+	if plan.UpgradeGroup != "" {
+		subscription.PreviousGroup = previousGroup
+		targetGroup = plan.UpgradeGroup
+	}
+	if err = tx.Create(subscription).Error; err != nil {
+		return nil, false, err
+	}
+	if targetGroup == userGroup {
+		return subscription, false, nil
+	}
+	err = tx.Model(&User{}).Where("id = ?", userId).Update("group", targetGroup).Error
+	return subscription, true, err
+}
+
+// RenewSubscriptionPeriods 为到达周期结束时间的订阅发放下一周期的额度，返回处理的订阅数
+func RenewSubscriptionPeriods() (int, error) {
+	now := common.GetTimestamp()
+	var ids []int
+	err := DB.Model(&UserSubscription{}).
+		Where("status = ? AND period_end <= ? AND end_time > ?", SubscriptionStatusActive, now, now).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+	renewed := 0
+	for _, id := range ids {
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			subscription := &UserSubscription{}
+			if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id).First(subscription).Error; err != nil {
+				return err
+			}
+			if subscription.Status != SubscriptionStatusActive || subscription.PeriodEnd > now || subscription.EndTime <= now {
+				return nil
+			}
+			// 服务停机期间错过的周期不补发，直接进入当前周期
+			for subscription.PeriodEnd <= now && subscription.PeriodEnd < subscription.EndTime {
+				subscription.startNextPeriod()
+			}
+			subscription.UpdatedTime = now
+			return tx.Save(subscription).Error
+		})
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to renew subscription %d: %s", id, err.Error()))
+			continue
+		}
+		renewed++
+	}
+	return renewed, nil
+}
+
+// ExpireSubscriptions 将到期的订阅标记为过期，并把订阅期间升级的用户分组恢复为订阅前的分组，返回处理的订阅数
+func ExpireSubscriptions() (int, error) {
+	now := common.GetTimestamp()
+	var subscriptions []*UserSubscription
+	err := DB.Where("status = ? AND end_time <= ?", SubscriptionStatusActive, now).Find(&subscriptions).Error
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, subscription := range subscriptions {
+		var groupChanged bool
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&UserSubscription{}).Where("id = ? AND status = ?", subscription.Id, SubscriptionStatusActive).
+				Updates(map[string]interface{}{"status": SubscriptionStatusExpired, "quota": 0, "updated_time": now})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			if subscription.UpgradeGroup == "" {
+				return nil
+			}
+			// 只在用户仍处于套餐分组时恢复，管理员期间手动调整过的分组保持不变
+			result = tx.Model(&User{}).Where("id = ? AND "+commonGroupCol+" = ?", subscription.UserId, subscription.UpgradeGroup).
+				Update("group", subscription.PreviousGroup)
+			groupChanged = result.RowsAffected > 0
+			return result.Error
+		})
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to expire subscription %d: %s", subscription.Id, err.Error()))
+			continue
+		}
+		if groupChanged {
+			if err := invalidateUserCache(subscription.UserId); err != nil {
+				common.SysLog("failed to invalidate user cache: " + err.Error())
+			}
+		}
+		RecordLog(subscription.UserId, LogTypeSystem, fmt.Sprintf("订阅套餐 %s 已到期", subscription.PlanName))
+		expired++
+	}
+	return expired, nil
+}
@@ -40,26 +40,34 @@ const (
 )
 
 type Task struct {
-	ID         int64                 `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
-	CreatedAt  int64                 `json:"created_at" gorm:"index"`
-	UpdatedAt  int64                 `json:"updated_at"`
-	TaskID     string                `json:"task_id" gorm:"type:varchar(191);index"` // 第三方id，不一定有/ song id\ Task id
-	Platform   constant.TaskPlatform `json:"platform" gorm:"type:varchar(30);index"` // 平台
-	UserId     int                   `json:"user_id" gorm:"index"`
-	Group      string                `json:"group" gorm:"type:varchar(50)"` // 修正计费用
-	ChannelId  int                   `json:"channel_id" gorm:"index"`
-	Quota      int                   `json:"quota"`
-	Action     string                `json:"action" gorm:"type:varchar(40);index"` // 任务类型, song, lyrics, description-mode
-	Status     TaskStatus            `json:"status" gorm:"type:varchar(20);index"` // 任务状态
-	FailReason string                `json:"fail_reason"`
-	SubmitTime int64                 `json:"submit_time" gorm:"index"`
-	StartTime  int64                 `json:"start_time" gorm:"index"`
-	FinishTime int64                 `json:"finish_time" gorm:"index"`
-	Progress   string                `json:"progress" gorm:"type:varchar(20);index"`
-	Properties Properties            `json:"properties" gorm:"type:json"`
+	ID             int64                 `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	CreatedAt      int64                 `json:"created_at" gorm:"index"`
+	UpdatedAt      int64                 `json:"updated_at"`
+	TaskID         string                `json:"task_id" gorm:"type:varchar(191);index"` // 第三方id，不一定有/ song id\ Task id
+	Platform       constant.TaskPlatform `json:"platform" gorm:"type:varchar(30);index"` // 平台
+	UserId         int                   `json:"user_id" gorm:"index"`
+	OrganizationId int                   `json:"organization_id" gorm:"default:0"` // 使用组织令牌提交时的组织 ID，退款返还到组织额度
+	Group          string                `json:"group" gorm:"type:varchar(50)"`    // 修正计费用
+	ChannelId      int                   `json:"channel_id" gorm:"index"`
+	Quota          int                   `json:"quota"`
+	Action         string                `json:"action" gorm:"type:varchar(40);index"` // 任务类型, song, lyrics, description-mode
+	Status         TaskStatus            `json:"status" gorm:"type:varchar(20);index"` // 任务状态
+	FailReason     string                `json:"fail_reason"`
+	SubmitTime     int64                 `json:"submit_time" gorm:"index"`
+	StartTime      int64                 `json:"start_time" gorm:"index"`
+	FinishTime     int64                 `json:"finish_time" gorm:"index"`
+	Progress       string                `json:"progress" gorm:"type:varchar(20);index"`
+	Properties     Properties            `json:"properties" gorm:"type:json"`
 	// 禁止返回给用户，内部可能包含key等隐私信息
 	PrivateData TaskPrivateData `json:"-" gorm:"column:private_data;type:json"`
 	Data        json.RawMessage `json:"data" gorm:"type:json"`
+	// 提交时从订阅套餐中扣除的部分，失败退款时按原路返还到套餐
+	SubscriptionId          int   `json:"subscription_id" gorm:"default:0"`
+	SubscriptionPeriodStart int64 `json:"subscription_period_start" gorm:"default:0"`
+	SubscriptionQuota       int   `json:"subscription_quota" gorm:"default:0"`
+	// 提交时使用的令牌与组织成员，退款时从对应的消费预算中扣除
+	TokenId              int `json:"token_id" gorm:"default:0"`
+	OrganizationMemberId int `json:"organization_member_id" gorm:"default:0"`
 }
 
 func (t *Task) SetData(data any) {
@@ -142,15 +150,18 @@ func InitTask(platform constant.TaskPlatform, relayInfo *commonRelay.RelayInfo)
 	}
 
 	t := &Task{
-		UserId:      relayInfo.UserId,
-		Group:       relayInfo.UsingGroup,
-		SubmitTime:  time.Now().Unix(),
-		Status:      TaskStatusNotStart,
-		Progress:    "0%",
-		ChannelId:   relayInfo.ChannelId,
-		Platform:    platform,
-		Properties:  properties,
-		PrivateData: privateData,
+		UserId:               relayInfo.UserId,
+		OrganizationId:       relayInfo.TokenOrganization.Id,
+		Group:                relayInfo.UsingGroup,
+		SubmitTime:           time.Now().Unix(),
+		Status:               TaskStatusNotStart,
+		Progress:             "0%",
+		ChannelId:            relayInfo.ChannelId,
+		Platform:             platform,
+		Properties:           properties,
+		PrivateData:          privateData,
+		TokenId:              relayInfo.TokenId,
+		OrganizationMemberId: relayInfo.TokenOrganization.MemberId,
 	}
 	return t
 }
@@ -306,6 +317,18 @@ func (Task *Task) Update() error {
 	return err
 }
 
+// UpdateSubscriptionCharge 记录任务从订阅套餐中扣除的额度
+func (Task *Task) UpdateSubscriptionCharge(subscriptionId int, periodStart int64, quota int) error {
+	Task.SubscriptionId = subscriptionId
+	Task.SubscriptionPeriodStart = periodStart
+	Task.SubscriptionQuota = quota
+	return DB.Model(Task).Updates(map[string]interface{}{
+		"subscription_id":           subscriptionId,
+		"subscription_period_start": periodStart,
+		"subscription_quota":        quota,
+	}).Error
+}
+
 func TaskBulkUpdate(TaskIds []string, params map[string]any) error {
 	if len(TaskIds) == 0 {
 		return nil
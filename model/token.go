@@ -26,7 +26,15 @@ type Token struct {
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
-	CrossGroupRetry    bool           `json:"cross_group_retry"` // 跨分组重试，仅auto分组有效
+	CrossGroupRetry    bool           `json:"cross_group_retry"`                      // 跨分组重试，仅auto分组有效
+	ResponseCache      bool           `json:"response_cache"`                         // 启用响应缓存
+	HedgeEnabled       bool           `json:"hedge_enabled"`                          // 启用对冲请求
+	TpmLimit           int            `json:"tpm_limit" gorm:"default:0"`             // 每分钟 Token 数限制，0 表示不限制
+	RpmLimit           int            `json:"rpm_limit" gorm:"default:0"`             // 每分钟请求数限制，0 表示不限制
+	ModelFallback      string         `json:"model_fallback" gorm:"type:text"`        // 模型降级链，JSON 格式，覆盖全局配置中同名模型的降级链
+	DailyBudget        int            `json:"daily_budget" gorm:"default:0"`          // 每日消费预算，0 表示不限制
+	MonthlyBudget      int            `json:"monthly_budget" gorm:"default:0"`        // 每月消费预算，0 表示不限制
+	OrganizationId     int            `json:"organization_id" gorm:"default:0;index"` // 所属组织，0 表示个人令牌
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -190,7 +198,7 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "response_cache", "hedge_enabled", "tpm_limit", "rpm_limit", "model_fallback", "daily_budget", "monthly_budget").Updates(token).Error
 	return err
 }
 
@@ -244,6 +252,19 @@ func (token *Token) GetModelLimitsMap() map[string]bool {
 	return limitsMap
 }
 
+// GetModelFallback 解析令牌配置的模型降级链，格式错误时视为未配置
+func (token *Token) GetModelFallback() map[string][]string {
+	if strings.TrimSpace(token.ModelFallback) == "" {
+		return nil
+	}
+	var chains map[string][]string
+	if err := common.UnmarshalJsonStr(token.ModelFallback, &chains); err != nil {
+		common.SysLog(fmt.Sprintf("failed to unmarshal model fallback: token_id=%d, error=%v", token.Id, err))
+		return nil
+	}
+	return chains
+}
+
 func DisableModelLimits(tokenId int) error {
 	token, err := GetTokenById(tokenId)
 	if err != nil {
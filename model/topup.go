@@ -21,6 +21,8 @@ type TopUp struct {
 	CreateTime    int64   `json:"create_time"`
 	CompleteTime  int64   `json:"complete_time"`
 	Status        string  `json:"status"`
+	PlanId        int     `json:"plan_id" gorm:"default:0"` // 订阅套餐订单对应的套餐，充值订单为 0
+	Quota         int     `json:"quota" gorm:"default:0"`   // 订单完成时入账的额度，用于生成账单
 }
 
 func (topUp *TopUp) Insert() error {
@@ -55,10 +57,19 @@ func GetTopUpByTradeNo(tradeNo string) *TopUp {
 	return topUp
 }
 
+// isSubscriptionTopUp 判断订单是否为订阅套餐订单，订阅订单开通套餐而不增加余额
+func isSubscriptionTopUp(tradeNo string) bool {
+	topUp := GetTopUpByTradeNo(tradeNo)
+	return topUp != nil && topUp.PlanId > 0
+}
+
 func Recharge(referenceId string, customerId string) (err error) {
 	if referenceId == "" {
 		return errors.New("未提供支付单号")
 	}
+	if isSubscriptionTopUp(referenceId) {
+		return CompleteSubscriptionTopUp(referenceId)
+	}
 
 	var quota float64
 	topUp := &TopUp{}
@@ -78,14 +89,15 @@ func Recharge(referenceId string, customerId string) (err error) {
 			return errors.New("充值订单状态错误")
 		}
 
+		quota = topUp.Money * common.QuotaPerUnit
 		topUp.CompleteTime = common.GetTimestamp()
 		topUp.Status = common.TopUpStatusSuccess
+		topUp.Quota = int(quota)
 		err = tx.Save(topUp).Error
 		if err != nil {
 			return err
 		}
 
-		quota = topUp.Money * common.QuotaPerUnit
 		err = tx.Model(&User{}).Where("id = ?", topUp.UserId).Updates(map[string]interface{}{"stripe_customer": customerId, "quota": gorm.Expr("quota + ?", quota)}).Error
 		if err != nil {
 			return err
@@ -239,6 +251,9 @@ func ManualCompleteTopUp(tradeNo string) error {
 	if tradeNo == "" {
 		return errors.New("未提供订单号")
 	}
+	if isSubscriptionTopUp(tradeNo) {
+		return CompleteSubscriptionTopUp(tradeNo)
+	}
 
 	refCol := "`trade_no`"
 	if common.UsingPostgreSQL {
@@ -283,6 +298,7 @@ func ManualCompleteTopUp(tradeNo string) error {
 		// 标记完成
 		topUp.CompleteTime = common.GetTimestamp()
 		topUp.Status = common.TopUpStatusSuccess
+		topUp.Quota = quotaToAdd
 		if err := tx.Save(topUp).Error; err != nil {
 			return err
 		}
@@ -309,6 +325,9 @@ func RechargeCreem(referenceId string, customerEmail string, customerName string
 	if referenceId == "" {
 		return errors.New("未提供支付单号")
 	}
+	if isSubscriptionTopUp(referenceId) {
+		return CompleteSubscriptionTopUp(referenceId)
+	}
 
 	var quota int64
 	topUp := &TopUp{}
@@ -328,16 +347,16 @@ func RechargeCreem(referenceId string, customerEmail string, customerName string
 			return errors.New("充值订单状态错误")
 		}
 
+		// Creem 直接使用 Amount 作为充值额度（整数）
+		quota = topUp.Amount
 		topUp.CompleteTime = common.GetTimestamp()
 		topUp.Status = common.TopUpStatusSuccess
+		topUp.Quota = int(quota)
 		err = tx.Save(topUp).Error
 		if err != nil {
 			return err
 		}
 
-		// Creem 直接使用 Amount 作为充值额度（整数）
-		quota = topUp.Amount
-
 		// 构建更新字段，优先使用邮箱，如果邮箱为空则使用用户名
 		updateFields := map[string]interface{}{
 			"quota": gorm.Expr("quota + ?", quota),
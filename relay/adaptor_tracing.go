@@ -0,0 +1,112 @@
+package relay
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common/tracing"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracingAdaptor 为适配器的请求转换、上游请求与响应处理添加 Span
+type tracingAdaptor struct {
+	channel.Adaptor
+}
+
+func traceConvert[T any](c *gin.Context, info *relaycommon.RelayInfo, convert func() (T, error)) (T, error) {
+	attrs := []attribute.KeyValue{}
+	if info != nil {
+		attrs = append(attrs, attribute.String("gen_ai.request.model", info.UpstreamModelName))
+	}
+	span, endSpan := tracing.StartSpan(c, "adaptor.convert_request", attrs...)
+	defer endSpan()
+	result, err := convert()
+	tracing.RecordError(span, err)
+	return result, err
+}
+
+func (a *tracingAdaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (any, error) {
+	return traceConvert(c, info, func() (any, error) {
+		return a.Adaptor.ConvertOpenAIRequest(c, info, request)
+	})
+}
+
+func (a *tracingAdaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
+	return traceConvert(c, nil, func() (any, error) {
+		return a.Adaptor.ConvertRerankRequest(c, relayMode, request)
+	})
+}
+
+func (a *tracingAdaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.EmbeddingRequest) (any, error) {
+	return traceConvert(c, info, func() (any, error) {
+		return a.Adaptor.ConvertEmbeddingRequest(c, info, request)
+	})
+}
+
+func (a *tracingAdaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
+	return traceConvert(c, info, func() (io.Reader, error) {
+		return a.Adaptor.ConvertAudioRequest(c, info, request)
+	})
+}
+
+func (a *tracingAdaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	return traceConvert(c, info, func() (any, error) {
+		return a.Adaptor.ConvertImageRequest(c, info, request)
+	})
+}
+
+func (a *tracingAdaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
+	return traceConvert(c, info, func() (any, error) {
+		return a.Adaptor.ConvertOpenAIResponsesRequest(c, info, request)
+	})
+}
+
+func (a *tracingAdaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error) {
+	return traceConvert(c, info, func() (any, error) {
+		return a.Adaptor.ConvertClaudeRequest(c, info, request)
+	})
+}
+
+func (a *tracingAdaptor) ConvertGeminiRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiChatRequest) (any, error) {
+	return traceConvert(c, info, func() (any, error) {
+		return a.Adaptor.ConvertGeminiRequest(c, info, request)
+	})
+}
+
+func (a *tracingAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
+	span, endSpan := tracing.StartSpan(c, "adaptor.do_request",
+		attribute.Int("channel.id", info.ChannelId),
+		attribute.Int("channel.type", info.ChannelType),
+		attribute.String("gen_ai.request.model", info.UpstreamModelName),
+		attribute.Bool("relay.stream", info.IsStream),
+	)
+	defer endSpan()
+	resp, err := a.Adaptor.DoRequest(c, info, requestBody)
+	tracing.RecordError(span, err)
+	if httpResp, ok := resp.(*http.Response); ok && httpResp != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", httpResp.StatusCode))
+	}
+	return resp, err
+}
+
+func (a *tracingAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (any, *types.NewAPIError) {
+	span, endSpan := tracing.StartSpan(c, "adaptor.do_response")
+	defer endSpan()
+	usage, err := a.Adaptor.DoResponse(c, resp, info)
+	if err != nil {
+		tracing.RecordError(span, err)
+	}
+	if u, ok := usage.(*dto.Usage); ok && u != nil {
+		span.SetAttributes(
+			attribute.Int("gen_ai.usage.input_tokens", u.PromptTokens),
+			attribute.Int("gen_ai.usage.output_tokens", u.CompletionTokens),
+		)
+	}
+	return usage, err
+}
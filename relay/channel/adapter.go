@@ -1,6 +1,7 @@
 package channel
 
 import (
+	"errors"
 	"io"
 	"net/http"
 
@@ -12,6 +13,18 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// ErrResponsesViaChatCompletions 由仅支持对话接口的适配器在 ConvertOpenAIResponsesRequest 中返回，
+// relay 层会将 Responses 请求转换为 Chat Completions 请求发送，并把响应转换回 Responses 格式
+var ErrResponsesViaChatCompletions = errors.New("responses request must be relayed via chat completions")
+
+// ErrClaudeViaChatCompletions 由不支持 Claude Messages 的适配器在 ConvertClaudeRequest 中返回，
+// relay 层会将 Claude 请求转换为 Chat Completions 请求发送，并把响应转换回 Claude 格式
+var ErrClaudeViaChatCompletions = errors.New("claude request must be relayed via chat completions")
+
+// ErrGeminiViaChatCompletions 由不支持 Gemini 原生接口的适配器在 ConvertGeminiRequest 中返回，
+// relay 层会将 Gemini 请求转换为 Chat Completions 请求发送，并把响应转换回 Gemini 格式
+var ErrGeminiViaChatCompletions = errors.New("gemini request must be relayed via chat completions")
+
 type Adaptor interface {
 	// Init IsStream bool
 	Init(info *relaycommon.RelayInfo)
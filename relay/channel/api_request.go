@@ -11,6 +11,7 @@ import (
 	"time"
 
 	common2 "github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/tracing"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/constant"
@@ -63,9 +64,19 @@ func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if err != nil {
 		return nil, fmt.Errorf("get request url failed: %w", err)
 	}
+	return DoApiRequestWithURL(a, c, info, fullRequestURL, requestBody)
+}
+
+// DoApiRequestWithURL 使用适配器的请求头设置向指定地址发送请求，用于适配器请求地址之外的辅助接口
+func DoApiRequestWithURL(a Adaptor, c *gin.Context, info *common.RelayInfo, fullRequestURL string, requestBody io.Reader) (*http.Response, error) {
 	if common2.DebugEnabled {
 		println("fullRequestURL:", fullRequestURL)
 	}
+	// 渠道配置了转换脚本时改写请求体，并在响应返回后改写响应体或流式分片
+	scriptSession := service.NewChannelScriptSession(c, info)
+	if scriptSession != nil {
+		requestBody = scriptSession.TransformRequest(requestBody)
+	}
 	req, err := http.NewRequest(c.Request.Method, fullRequestURL, requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("new request failed: %w", err)
@@ -86,9 +97,13 @@ func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 	if err != nil {
 		return nil, fmt.Errorf("do request failed: %w", err)
 	}
+	if scriptSession != nil {
+		scriptSession.TransformResponse(resp)
+	}
 	return resp, nil
 }
 
+// DoFormRequest 发送 multipart 表单请求，渠道转换脚本不处理表单请求体
 func DoFormRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*http.Response, error) {
 	fullRequestURL, err := a.GetRequestURL(info)
 	if err != nil {
@@ -122,6 +137,7 @@ func DoFormRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBod
 	return resp, nil
 }
 
+// DoWssRequest 建立上游 WebSocket 连接，Realtime 消息不经过渠道转换脚本
 func DoWssRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*websocket.Conn, error) {
 	fullRequestURL, err := a.GetRequestURL(info)
 	if err != nil {
@@ -269,7 +285,8 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		helper.SetEventStreamHeaders(c)
 		// 处理流式请求的 ping 保活
 		generalSettings := operation_setting.GetGeneralSetting()
-		if generalSettings.PingIntervalEnabled && !info.DisablePing {
+		// 对冲请求以首次写入判定胜负，不能发送 ping
+		if generalSettings.PingIntervalEnabled && !info.DisablePing && info.Hedge == nil {
 			pingInterval := time.Duration(generalSettings.PingIntervalSeconds) * time.Second
 			stopPinger = startPingKeepAlive(c, pingInterval)
 			// 使用defer确保在任何情况下都能停止ping goroutine
@@ -284,6 +301,12 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		}
 	}
 
+	if info.Hedge != nil {
+		// 对冲请求落败时需要中断上游请求
+		req = req.WithContext(c.Request.Context())
+	}
+	tracing.Inject(c.Request.Context(), req.Header)
+
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.LogError(c, "do request failed: "+err.Error())
@@ -34,11 +34,13 @@ type Adaptor struct {
 }
 
 func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dto.GeminiChatRequest) (any, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	return nil, channel.ErrGeminiViaChatCompletions
 }
 
 func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error) {
+	if isNovaModel(info.UpstreamModelName) {
+		return nil, channel.ErrClaudeViaChatCompletions
+	}
 	for i, message := range request.Messages {
 		updated := false
 		if !message.IsStringContent() {
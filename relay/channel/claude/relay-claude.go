@@ -36,6 +36,8 @@ func stopReasonClaude2OpenAI(reason string) string {
 		return "length"
 	case "tool_use":
 		return "tool_calls"
+	case "refusal":
+		return "content_filter"
 	default:
 		return reason
 	}
@@ -712,11 +714,18 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 	}
 	var err *types.NewAPIError
 	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
-		err = HandleStreamResponseData(c, info, claudeInfo, data, requestMode)
-		if err != nil {
-			return false
+		events, blocked := []string{data}, false
+		if info.Moderator != nil && requestMode == RequestModeMessage {
+			events, blocked = moderateClaudeStreamData(info, data)
+		}
+		for _, event := range events {
+			err = HandleStreamResponseData(c, info, claudeInfo, event, requestMode)
+			if err != nil {
+				return false
+			}
 		}
-		return true
+		// 内容审核要求中止时，以 stop_reason 为 refusal 的结束事件收尾
+		return !blocked
 	})
 	if err != nil {
 		return nil, err
@@ -726,6 +735,62 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 	return claudeInfo.Usage, nil
 }
 
+func marshalClaudeStreamEvent(event *dto.ClaudeResponse) string {
+	data, err := common.Marshal(event)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// moderateClaudeStreamData 审核 Claude 流式事件中的文本增量，返回需要依次处理的事件：
+// 暂存的文本在内容块结束前补发；需要中止时依次结束内容块并发送 stop_reason 为 refusal 的结束事件
+func moderateClaudeStreamData(info *relaycommon.RelayInfo, data string) ([]string, bool) {
+	var event dto.ClaudeResponse
+	if err := common.UnmarshalJsonStr(data, &event); err != nil {
+		return []string{data}, false
+	}
+	switch event.Type {
+	case "content_block_delta":
+		if event.Delta == nil || event.Delta.Text == nil || *event.Delta.Text == "" {
+			return []string{data}, false
+		}
+		moderated, stop := info.Moderator.Moderate(*event.Delta.Text)
+		if stop {
+			stopReason := "refusal"
+			blockStop := &dto.ClaudeResponse{Type: "content_block_stop"}
+			blockStop.SetIndex(event.GetIndex())
+			messageDelta := &dto.ClaudeResponse{
+				Type:  "message_delta",
+				Delta: &dto.ClaudeMediaMessage{StopReason: &stopReason},
+				Usage: &dto.ClaudeUsage{},
+			}
+			return []string{
+				marshalClaudeStreamEvent(blockStop),
+				marshalClaudeStreamEvent(messageDelta),
+				marshalClaudeStreamEvent(&dto.ClaudeResponse{Type: "message_stop"}),
+			}, true
+		}
+		if moderated == "" {
+			return nil, false
+		}
+		event.Delta.SetText(moderated)
+		return []string{marshalClaudeStreamEvent(&event)}, false
+	case "content_block_stop":
+		pending := info.Moderator.Flush()
+		if pending == "" {
+			return []string{data}, false
+		}
+		flush := &dto.ClaudeResponse{
+			Type:  "content_block_delta",
+			Delta: &dto.ClaudeMediaMessage{Type: "text_delta", Text: &pending},
+		}
+		flush.SetIndex(event.GetIndex())
+		return []string{marshalClaudeStreamEvent(flush), data}, false
+	}
+	return []string{data}, false
+}
+
 func HandleClaudeResponseData(c *gin.Context, info *relaycommon.RelayInfo, claudeInfo *ClaudeResponseInfo, httpResp *http.Response, data []byte, requestMode int) *types.NewAPIError {
 	var claudeResponse dto.ClaudeResponse
 	err := common.Unmarshal(data, &claudeResponse)
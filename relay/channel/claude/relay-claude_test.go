@@ -0,0 +1,67 @@
+package claude
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+)
+
+// holdingModerator 暂存全部文本，遇到 blocked 时要求中止
+type holdingModerator struct {
+	pending string
+}
+
+func (m *holdingModerator) Moderate(delta string) (string, bool) {
+	if strings.Contains(m.pending+delta, "blocked") {
+		m.pending = ""
+		return "", true
+	}
+	m.pending += delta
+	return "", false
+}
+
+func (m *holdingModerator) Flush() string {
+	pending := m.pending
+	m.pending = ""
+	return pending
+}
+
+func TestModerateClaudeStreamDataFlushesBeforeBlockStop(t *testing.T) {
+	info := &relaycommon.RelayInfo{Moderator: &holdingModerator{}}
+	events, blocked := moderateClaudeStreamData(info, `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hello"}}`)
+	if blocked || len(events) != 0 {
+		t.Fatalf("held delta should not be emitted, got %v", events)
+	}
+	events, blocked = moderateClaudeStreamData(info, `{"type":"content_block_stop","index":0}`)
+	if blocked || len(events) != 2 {
+		t.Fatalf("expected flushed delta and block stop, got %v", events)
+	}
+	var flushed dto.ClaudeResponse
+	if err := common.UnmarshalJsonStr(events[0], &flushed); err != nil {
+		t.Fatal(err)
+	}
+	if flushed.Type != "content_block_delta" || flushed.Delta.Text == nil || *flushed.Delta.Text != "hello" {
+		t.Fatalf("unexpected flushed event %s", events[0])
+	}
+}
+
+func TestModerateClaudeStreamDataBlock(t *testing.T) {
+	info := &relaycommon.RelayInfo{Moderator: &holdingModerator{}}
+	events, blocked := moderateClaudeStreamData(info, `{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"blocked"}}`)
+	if !blocked || len(events) != 3 {
+		t.Fatalf("expected block with closing events, got %v, %v", events, blocked)
+	}
+	var messageDelta dto.ClaudeResponse
+	if err := common.UnmarshalJsonStr(events[1], &messageDelta); err != nil {
+		t.Fatal(err)
+	}
+	if messageDelta.Type != "message_delta" || *messageDelta.Delta.StopReason != "refusal" || messageDelta.Usage == nil {
+		t.Fatalf("unexpected stop event %s", events[1])
+	}
+	if stopReasonClaude2OpenAI("refusal") != "content_filter" {
+		t.Fatal("refusal should map to content_filter")
+	}
+}
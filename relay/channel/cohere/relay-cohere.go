@@ -32,8 +32,16 @@ func requestOpenAI2Cohere(textRequest dto.GeneralOpenAIRequest) *CohereRequest {
 	if cohereReq.MaxTokens == 0 {
 		cohereReq.MaxTokens = 4000
 	}
-	for _, msg := range textRequest.Messages {
-		if msg.Role == "user" {
+	// 最后一条用户消息作为本轮输入，没有用户消息时取最后一条消息，其余消息按顺序放入历史
+	messageIndex := len(textRequest.Messages) - 1
+	for i := len(textRequest.Messages) - 1; i >= 0; i-- {
+		if textRequest.Messages[i].Role == "user" {
+			messageIndex = i
+			break
+		}
+	}
+	for i, msg := range textRequest.Messages {
+		if i == messageIndex {
 			cohereReq.Message = msg.StringContent()
 		} else {
 			var role string
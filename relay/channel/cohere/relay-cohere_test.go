@@ -0,0 +1,33 @@
+package cohere
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+func TestRequestOpenAI2CohereMessage(t *testing.T) {
+	request := dto.GeneralOpenAIRequest{
+		Model: "command-r-plus",
+		Messages: []dto.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", Content: "hello"},
+		},
+	}
+	// 最后一条不是用户消息时，以最后一条用户消息作为本轮输入
+	cohereRequest := requestOpenAI2Cohere(request)
+	if cohereRequest.Message != "hi" {
+		t.Fatalf("message = %q", cohereRequest.Message)
+	}
+	if len(cohereRequest.ChatHistory) != 2 || cohereRequest.ChatHistory[1].Role != "CHATBOT" {
+		t.Fatalf("chat history = %+v", cohereRequest.ChatHistory)
+	}
+
+	// 没有用户消息时取最后一条消息
+	request.Messages = []dto.Message{{Role: "system", Content: "say something"}}
+	cohereRequest = requestOpenAI2Cohere(request)
+	if cohereRequest.Message != "say something" || len(cohereRequest.ChatHistory) != 0 {
+		t.Fatalf("unexpected request %+v", cohereRequest)
+	}
+}
@@ -355,6 +355,7 @@ func CovertOpenAI2Gemini(c *gin.Context, textRequest dto.GeneralOpenAIRequest, i
 			geminiTools = append(geminiTools, dto.GeminiChatTool{
 				FunctionDeclarations: functions,
 			})
+			geminiRequest.ToolConfig = toolChoiceOpenAI2Gemini(textRequest.ToolChoice)
 		}
 		geminiRequest.SetTools(geminiTools)
 	}
@@ -644,6 +645,35 @@ func hasFunctionCallContent(call *dto.FunctionCall) bool {
 	}
 }
 
+// toolChoiceOpenAI2Gemini 将 OpenAI 的 tool_choice 转换为 Gemini 的 functionCallingConfig，未指定时返回 nil
+func toolChoiceOpenAI2Gemini(toolChoice any) *dto.ToolConfig {
+	var config dto.FunctionCallingConfig
+	switch choice := toolChoice.(type) {
+	case string:
+		switch choice {
+		case "auto":
+			config.Mode = "AUTO"
+		case "required":
+			config.Mode = "ANY"
+		case "none":
+			config.Mode = "NONE"
+		default:
+			return nil
+		}
+	case map[string]any:
+		function, _ := choice["function"].(map[string]any)
+		name := common.Interface2String(function["name"])
+		if name == "" {
+			return nil
+		}
+		config.Mode = "ANY"
+		config.AllowedFunctionNames = []string{name}
+	default:
+		return nil
+	}
+	return &dto.ToolConfig{FunctionCallingConfig: &config}
+}
+
 // Helper function to get a list of supported MIME types for error messages
 func getSupportedMimeTypesList() []string {
 	keys := make([]string, 0, len(geminiSupportedMimeTypes))
@@ -1081,6 +1111,11 @@ func geminiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 			return false
 		}
 
+		blocked := false
+		if info.Moderator != nil {
+			data, blocked = moderateGeminiStreamData(info, &geminiResponse, data)
+		}
+
 		// 统计图片数量
 		for _, candidate := range geminiResponse.Candidates {
 			for _, part := range candidate.Content.Parts {
@@ -1108,7 +1143,8 @@ func geminiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 			}
 		}
 
-		return callback(data, &geminiResponse)
+		// 内容审核要求中止时，以 finishReason 为 SAFETY 的分片收尾
+		return callback(data, &geminiResponse) && !blocked
 	})
 
 	if imageCount != 0 {
@@ -1134,6 +1170,65 @@ func geminiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 	return usage, nil
 }
 
+// moderateGeminiStreamData 审核 Gemini 流式分片中的文本，暂存的文本在带 finishReason 的分片中补发；
+// 需要中止时清空该候选的文本并把 finishReason 改写为 SAFETY
+func moderateGeminiStreamData(info *relaycommon.RelayInfo, geminiResponse *dto.GeminiChatResponse, data string) (string, bool) {
+	changed, blocked := false, false
+	for i := range geminiResponse.Candidates {
+		candidate := &geminiResponse.Candidates[i]
+		parts := make([]dto.GeminiPart, 0, len(candidate.Content.Parts))
+		stopped := false
+		for _, part := range candidate.Content.Parts {
+			if part.Text == "" || part.Thought {
+				parts = append(parts, part)
+				continue
+			}
+			if stopped {
+				changed = true
+				continue
+			}
+			moderated, stop := info.Moderator.Moderate(part.Text)
+			if stop {
+				stopped, changed = true, true
+				continue
+			}
+			if moderated != part.Text {
+				changed = true
+			}
+			if moderated == "" {
+				// 文本全部暂存，待后续分片审核后补发
+				continue
+			}
+			part.Text = moderated
+			parts = append(parts, part)
+		}
+		if stopped {
+			finishReason := "SAFETY"
+			candidate.FinishReason = &finishReason
+			blocked = true
+		} else if candidate.FinishReason != nil {
+			if pending := info.Moderator.Flush(); pending != "" {
+				// 并入最后一个文本片段，避免转换为 OpenAI 格式时在片段之间插入换行
+				if last := len(parts) - 1; last >= 0 && parts[last].Text != "" && !parts[last].Thought {
+					parts[last].Text += pending
+				} else {
+					parts = append(parts, dto.GeminiPart{Text: pending})
+				}
+				changed = true
+			}
+		}
+		candidate.Content.Parts = parts
+	}
+	if !changed {
+		return data, blocked
+	}
+	moderatedData, err := common.Marshal(geminiResponse)
+	if err != nil {
+		return data, blocked
+	}
+	return string(moderatedData), blocked
+}
+
 func GeminiChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
 	id := helper.GetResponseID(c)
 	createAt := common.GetTimestamp()
@@ -0,0 +1,65 @@
+package gemini
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+)
+
+// holdingModerator 暂存全部文本，遇到 blocked 时要求中止
+type holdingModerator struct {
+	pending string
+}
+
+func (m *holdingModerator) Moderate(delta string) (string, bool) {
+	if strings.Contains(m.pending+delta, "blocked") {
+		m.pending = ""
+		return "", true
+	}
+	m.pending += delta
+	return "", false
+}
+
+func (m *holdingModerator) Flush() string {
+	pending := m.pending
+	m.pending = ""
+	return pending
+}
+
+func moderateGeminiChunk(t *testing.T, info *relaycommon.RelayInfo, data string) (*dto.GeminiChatResponse, string, bool) {
+	t.Helper()
+	var response dto.GeminiChatResponse
+	if err := common.UnmarshalJsonStr(data, &response); err != nil {
+		t.Fatal(err)
+	}
+	moderated, blocked := moderateGeminiStreamData(info, &response, data)
+	return &response, moderated, blocked
+}
+
+func TestModerateGeminiStreamDataFlushesOnFinish(t *testing.T) {
+	info := &relaycommon.RelayInfo{Moderator: &holdingModerator{}}
+	response, _, blocked := moderateGeminiChunk(t, info, `{"candidates":[{"content":{"role":"model","parts":[{"text":"hel"}]},"index":0}]}`)
+	if blocked || len(response.Candidates[0].Content.Parts) != 0 {
+		t.Fatalf("held text should be removed from the chunk, got %+v", response.Candidates[0].Content.Parts)
+	}
+	response, data, _ := moderateGeminiChunk(t, info, `{"candidates":[{"content":{"role":"model","parts":[{"text":"lo"}]},"finishReason":"STOP","index":0}]}`)
+	parts := response.Candidates[0].Content.Parts
+	if len(parts) != 1 || parts[0].Text != "hello" {
+		t.Fatalf("held text should be flushed into the final chunk, got %+v", parts)
+	}
+	if !strings.Contains(data, `"text":"hello"`) {
+		t.Fatalf("rewritten chunk missing flushed text: %s", data)
+	}
+}
+
+func TestModerateGeminiStreamDataBlock(t *testing.T) {
+	info := &relaycommon.RelayInfo{Moderator: &holdingModerator{}}
+	response, _, blocked := moderateGeminiChunk(t, info, `{"candidates":[{"content":{"role":"model","parts":[{"text":"blocked"}]},"index":0}]}`)
+	candidate := response.Candidates[0]
+	if !blocked || candidate.FinishReason == nil || *candidate.FinishReason != "SAFETY" || len(candidate.Content.Parts) != 0 {
+		t.Fatalf("expected SAFETY finish without text, got %+v", candidate)
+	}
+}
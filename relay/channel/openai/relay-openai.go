@@ -133,6 +133,10 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 				common.SysLog("error handling stream format: " + err.Error())
 			}
 		}
+		blocked := false
+		if len(data) > 0 && info.Moderator != nil {
+			data, blocked = moderateStreamData(info, data)
+		}
 		if len(data) > 0 {
 			// 对音频模型，保存倒数第二个stream data
 			if isAudioModel && lastStreamData != "" {
@@ -142,7 +146,8 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 			lastStreamData = data
 			streamItems = append(streamItems, data)
 		}
-		return true
+		// 内容审核要求中止时，以 content_filter 结束分片收尾
+		return !blocked
 	})
 
 	// 对音频模型，从倒数第二个stream data中提取usage信息
@@ -193,6 +198,47 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 	return usage, nil
 }
 
+// moderateStreamData 审核流式分片中的补全文本，暂存的文本在带 finish_reason 的分片中补发；
+// 需要中止时把分片改写为 finish_reason 为 content_filter 的结束分片
+func moderateStreamData(info *relaycommon.RelayInfo, data string) (string, bool) {
+	var chunk dto.ChatCompletionsStreamResponse
+	if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+		return data, false
+	}
+	changed, blocked := false, false
+	for i := range chunk.Choices {
+		delta := &chunk.Choices[i].Delta
+		content := delta.GetContentString()
+		moderated := content
+		if content != "" {
+			var stop bool
+			moderated, stop = info.Moderator.Moderate(content)
+			if stop {
+				finishReason := constant.FinishReasonContentFilter
+				delta.SetContentString("")
+				chunk.Choices[i].FinishReason = &finishReason
+				changed, blocked = true, true
+				continue
+			}
+		}
+		if chunk.Choices[i].FinishReason != nil {
+			moderated += info.Moderator.Flush()
+		}
+		if moderated != content {
+			delta.SetContentString(moderated)
+			changed = true
+		}
+	}
+	if !changed {
+		return data, false
+	}
+	moderatedData, err := common.Marshal(chunk)
+	if err != nil {
+		return "", blocked
+	}
+	return string(moderatedData), blocked
+}
+
 func OpenaiHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
 	defer service.CloseResponseBodyGracefully(resp)
 
@@ -279,13 +325,6 @@ func OpenaiHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Respo
 			return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
 		}
 		responseBody = claudeRespStr
-	case types.RelayFormatGemini:
-		geminiResp := service.ResponseOpenAI2Gemini(&simpleResponse, info)
-		geminiRespStr, err := common.Marshal(geminiResp)
-		if err != nil {
-			return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
-		}
-		responseBody = geminiRespStr
 	}
 
 	service.IOCopyBytesGracefully(c, resp, responseBody)
@@ -1,6 +1,7 @@
 package openai
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -40,6 +41,10 @@ func OaiResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 		c.Set("image_generation_call_size", responsesResponse.GetSize())
 	}
 
+	if info != nil && info.ResponsesStore != nil && info.ResponsesStore.Save {
+		info.ResponsesStore.Response = responseBody
+	}
+
 	// 写入新的 response body
 	service.IOCopyBytesGracefully(c, resp, responseBody)
 
@@ -87,6 +92,7 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 			sendResponsesStreamData(c, streamResponse, data)
 			switch streamResponse.Type {
 			case "response.completed":
+				captureStoredResponse(info, data)
 				if streamResponse.Response != nil {
 					if streamResponse.Response.Usage != nil {
 						if streamResponse.Response.Usage.InputTokens != 0 {
@@ -108,6 +114,8 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 						c.Set("image_generation_call_size", streamResponse.Response.GetSize())
 					}
 				}
+			case "response.incomplete":
+				captureStoredResponse(info, data)
 			case "response.output_text.delta":
 				// 处理输出文本
 				responseTextBuilder.WriteString(streamResponse.Delta)
@@ -148,3 +156,16 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 
 	return usage, nil
 }
+
+// captureStoredResponse 记录流式结束事件中的完整响应，供网关保存会话状态
+func captureStoredResponse(info *relaycommon.RelayInfo, data string) {
+	if info == nil || info.ResponsesStore == nil || !info.ResponsesStore.Save {
+		return
+	}
+	var event struct {
+		Response json.RawMessage `json:"response"`
+	}
+	if err := common.UnmarshalJsonStr(data, &event); err == nil && len(event.Response) > 0 {
+		info.ResponsesStore.Response = event.Response
+	}
+}
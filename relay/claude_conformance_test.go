@@ -0,0 +1,224 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+var updateConformanceFixtures = flag.Bool("update-conformance-fixtures", false, "rewrite expected files under testdata/*_conformance")
+
+// generatedToolCallId 匹配上游未返回 id 时适配器生成的随机工具调用 id
+var generatedToolCallId = regexp.MustCompile(`call_[0-9a-f]{32}`)
+
+// claudeConformanceCase 描述 testdata/claude_conformance 下的一组录制数据：
+// request.json 为 /v1/messages 请求，upstream_request.json 为期望发往上游的请求体，
+// upstream_response.txt 为录制的上游响应，response.json 为期望返回给客户端的 Claude 响应（流式为事件数组）
+type claudeConformanceCase struct {
+	ApiType             int                      `json:"api_type"`
+	ChannelType         int                      `json:"channel_type"`
+	UpstreamModel       string                   `json:"upstream_model"`
+	UpstreamContentType string                   `json:"upstream_content_type"`
+	ApiKey              string                   `json:"api_key"`
+	Region              string                   `json:"region"`
+	OtherSettings       dto.ChannelOtherSettings `json:"other_settings"`
+}
+
+// conformanceTransport 代替上游返回录制的响应，并记录实际发出的请求体
+type conformanceTransport struct {
+	contentType string
+	body        []byte
+	requestBody []byte
+}
+
+func (t *conformanceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		t.requestBody = body
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{t.contentType}},
+		Body:       io.NopCloser(bytes.NewReader(t.body)),
+		Request:    req,
+	}, nil
+}
+
+func TestClaudeViaChatConformance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 与 common 中环境变量的默认值保持一致
+	constant.StreamingTimeout = 300
+	constant.GeminiVisionMaxImageNum = 16
+	if service.GetHttpClient() == nil {
+		service.InitHttpClient()
+	}
+	dirs, err := filepath.Glob(filepath.Join("testdata", "claude_conformance", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) == 0 {
+		t.Fatal("no claude conformance fixtures found")
+	}
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			runClaudeConformanceCase(t, dir)
+		})
+	}
+}
+
+// runClaudeConformanceCase 与 ClaudeHelper 一样经适配器的 ConvertClaudeRequest 入口转发，
+// 上游请求由 conformanceTransport 截获，覆盖请求转换、stream_options 注入与响应转换的完整链路
+func runClaudeConformanceCase(t *testing.T, dir string) {
+	var tc claudeConformanceCase
+	readFixtureJSON(t, filepath.Join(dir, "case.json"), &tc)
+	var request dto.ClaudeRequest
+	readFixtureJSON(t, filepath.Join(dir, "request.json"), &request)
+	body, err := os.ReadFile(filepath.Join(dir, "upstream_response.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &conformanceTransport{contentType: tc.UpstreamContentType, body: body}
+	client := service.GetHttpClient()
+	originTransport := client.Transport
+	client.Transport = transport
+	defer func() {
+		client.Transport = originTransport
+	}()
+
+	apiKey := tc.ApiKey
+	if apiKey == "" {
+		apiKey = "sk-conformance"
+	}
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	c.Set(common.RequestIdKey, "conformance")
+	common.SetContextKey(c, constant.ContextKeyOriginalModel, request.Model)
+	common.SetContextKey(c, constant.ContextKeyChannelType, tc.ChannelType)
+	common.SetContextKey(c, constant.ContextKeyChannelBaseUrl, "https://upstream.example.com")
+	common.SetContextKey(c, constant.ContextKeyChannelKey, apiKey)
+	common.SetContextKey(c, constant.ContextKeyChannelOtherSetting, tc.OtherSettings)
+	if tc.Region != "" {
+		c.Set("region", tc.Region)
+	}
+	info := relaycommon.GenRelayInfoClaude(c, &request)
+	info.InitChannelMeta(c)
+	if info.ApiType != tc.ApiType {
+		t.Fatalf("channel type %d maps to api type %d, want %d", tc.ChannelType, info.ApiType, tc.ApiType)
+	}
+	// 模型映射后的上游模型
+	info.UpstreamModelName = tc.UpstreamModel
+	request.Model = tc.UpstreamModel
+
+	adaptor := GetAdaptor(tc.ApiType)
+	if adaptor == nil {
+		t.Fatalf("no adaptor for api type %d", tc.ApiType)
+	}
+	adaptor.Init(info)
+	if _, err := adaptor.ConvertClaudeRequest(c, info, &request); !errors.Is(err, channel.ErrClaudeViaChatCompletions) {
+		t.Fatalf("adaptor should relay claude requests via chat completions, got %v", err)
+	}
+	if _, apiErr := claudeViaChatCompletions(c, info, adaptor, &request); apiErr != nil {
+		t.Fatalf("relay claude request: %v", apiErr)
+	}
+	if info.RelayFormat != types.RelayFormatClaude {
+		t.Fatalf("relay format should be restored, got %s", info.RelayFormat)
+	}
+	compareFixtureJSON(t, filepath.Join(dir, "upstream_request.json"), transport.requestBody)
+
+	output := recorder.Body.Bytes()
+	if request.Stream {
+		output = claudeEventsJSON(t, output)
+	}
+	compareFixtureJSON(t, filepath.Join(dir, "response.json"), output)
+}
+
+// claudeEventsJSON 将 SSE 输出整理为事件数组，并校验 event 行与 data 中的 type 一致
+func claudeEventsJSON(t *testing.T, output []byte) []byte {
+	events := make([]json.RawMessage, 0)
+	for _, block := range strings.Split(string(output), "\n\n") {
+		var eventType, data string
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				eventType = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		if data == "" {
+			continue
+		}
+		var event struct {
+			Type string `json:"type"`
+		}
+		if err := common.UnmarshalJsonStr(data, &event); err != nil {
+			t.Fatalf("invalid event data %q: %v", data, err)
+		}
+		if event.Type != eventType {
+			t.Fatalf("event line %q does not match data type %q", eventType, event.Type)
+		}
+		events = append(events, json.RawMessage(data))
+	}
+	result, err := json.Marshal(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func readFixtureJSON(t *testing.T, path string, v any) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("parse %s: %v", path, err)
+	}
+}
+
+func compareFixtureJSON(t *testing.T, path string, actual []byte) {
+	actual = generatedToolCallId.ReplaceAll(actual, []byte("call_generated"))
+	if *updateConformanceFixtures {
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, actual, "", "  "); err != nil {
+			t.Fatal(err)
+		}
+		pretty.WriteByte('\n')
+		if err := os.WriteFile(path, pretty.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	var expectedValue, actualValue any
+	readFixtureJSON(t, path, &expectedValue)
+	if err := json.Unmarshal(actual, &actualValue); err != nil {
+		t.Fatalf("parse actual output: %v\n%s", err, actual)
+	}
+	if !reflect.DeepEqual(expectedValue, actualValue) {
+		t.Fatalf("%s mismatch\nactual: %s", path, actual)
+	}
+}
@@ -2,6 +2,7 @@ package relay
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
@@ -44,6 +46,7 @@ func ClaudeHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
 	}
 	adaptor.Init(info)
+	initToolCallEmulation(info, len(request.GetTools()) > 0)
 
 	if request.MaxTokens == 0 {
 		request.MaxTokens = uint(model_setting.GetClaudeSettings().GetDefaultMaxTokens(request.Model))
@@ -106,7 +109,20 @@ func ClaudeHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 		}
 		requestBody = bytes.NewBuffer(body)
 	} else {
-		convertedRequest, err := adaptor.ConvertClaudeRequest(c, info, request)
+		// 模拟函数调用时需要经 Chat Completions 处理模型输出
+		var convertedRequest any
+		err := channel.ErrClaudeViaChatCompletions
+		if !info.ToolCallEmulation {
+			convertedRequest, err = adaptor.ConvertClaudeRequest(c, info, request)
+		}
+		if errors.Is(err, channel.ErrClaudeViaChatCompletions) {
+			usage, newAPIError := claudeViaChatCompletions(c, info, adaptor, request)
+			if newAPIError != nil {
+				return newAPIError
+			}
+			service.PostClaudeConsumeQuota(c, info, usage)
+			return nil
+		}
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
 		}
@@ -0,0 +1,184 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// claudeChatWriter 将适配器写出的 Chat Completions 响应转换为 Claude Messages 格式：
+// 流式响应逐个转换 data 分片为 Claude 事件，非流式响应先缓存，结束后整体转换
+type claudeChatWriter struct {
+	gin.ResponseWriter
+	c         *gin.Context
+	info      *relaycommon.RelayInfo
+	id        string
+	stream    bool
+	converter *service.ClaudeStreamConverter
+	buf       bytes.Buffer
+	status    int
+}
+
+func newClaudeChatWriter(c *gin.Context, info *relaycommon.RelayInfo) *claudeChatWriter {
+	id := fmt.Sprintf("msg_%s", c.GetString(common.RequestIdKey))
+	return &claudeChatWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		info:           info,
+		id:             id,
+		stream:         info.IsStream,
+		converter:      service.NewClaudeStreamConverter(id, info.GetEstimatePromptTokens()),
+		status:         http.StatusOK,
+	}
+}
+
+func (w *claudeChatWriter) WriteHeader(code int) {
+	if w.stream {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *claudeChatWriter) WriteHeaderNow() {
+	if w.stream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *claudeChatWriter) Write(data []byte) (int, error) {
+	w.buf.Write(data)
+	if w.stream {
+		err := translateChatStream(w.c, &w.buf, w.ResponseWriter, func(chunk *dto.ChatCompletionsStreamResponse) error {
+			return w.writeEvents(w.converter.Convert(chunk))
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *claudeChatWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *claudeChatWriter) Status() int {
+	if w.stream {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *claudeChatWriter) Flush() {
+	if w.stream {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *claudeChatWriter) writeEvents(events []*dto.ClaudeResponse) error {
+	for _, event := range events {
+		data, err := common.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := w.ResponseWriter.WriteString(fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish 在适配器处理完响应后输出最终结果：流式响应补发 message_delta 与 message_stop，非流式响应整体转换后写出
+func (w *claudeChatWriter) finish(usage *dto.Usage) {
+	if w.stream {
+		helper.SetEventStreamHeaders(w.c)
+		if err := w.writeEvents(w.converter.Finish(usage)); err != nil {
+			logger.LogError(w.c, fmt.Sprintf("failed to write claude stream events: %s", err.Error()))
+		}
+		w.ResponseWriter.Flush()
+		return
+	}
+	body := w.buf.Bytes()
+	var chatResponse dto.OpenAITextResponse
+	if err := common.Unmarshal(body, &chatResponse); err == nil {
+		// 适配器可能在响应体之外修正用量，以最终用量为准
+		chatResponse.Usage = *usage
+		claudeResponse := service.ResponseOpenAI2Claude(&chatResponse, w.info)
+		claudeResponse.Id = w.id
+		if converted, err := common.Marshal(claudeResponse); err == nil {
+			body = converted
+		} else {
+			logger.LogError(w.c, fmt.Sprintf("failed to marshal claude response: %s", err.Error()))
+		}
+	} else {
+		logger.LogError(w.c, fmt.Sprintf("failed to parse chat completions response: %s", err.Error()))
+	}
+	header := w.ResponseWriter.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/json")
+	w.ResponseWriter.WriteHeader(w.status)
+	if _, err := w.ResponseWriter.Write(body); err != nil {
+		logger.LogError(w.c, fmt.Sprintf("failed to write claude response: %s", err.Error()))
+	}
+	w.ResponseWriter.Flush()
+}
+
+// claudeViaChatCompletions 为不支持 Claude Messages 的渠道转发 /v1/messages 请求：
+// 请求转换为 Chat Completions 后交由适配器按 OpenAI 格式处理，响应再转换回 Claude 格式
+func claudeViaChatCompletions(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, request *dto.ClaudeRequest) (*dto.Usage, *types.NewAPIError) {
+	openAIRequest, err := service.ClaudeToOpenAIRequest(*request, info)
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	// message_delta 需要最终用量
+	if openAIRequest.Stream && info.SupportStreamOptions {
+		openAIRequest.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+
+	// 适配器按对话请求处理，结束后恢复，以免影响 Claude 的计费与日志
+	relayMode, relayFormat, originRequest := info.RelayMode, info.RelayFormat, info.Request
+	defer func() {
+		info.RelayMode, info.RelayFormat, info.Request = relayMode, relayFormat, originRequest
+	}()
+	httpResp, newAPIError := doChatCompletionsRequest(c, info, adaptor, openAIRequest)
+	if newAPIError != nil {
+		return nil, newAPIError
+	}
+	return claudeChatResponse(c, info, adaptor, httpResp)
+}
+
+// claudeChatResponse 由适配器按 OpenAI 格式处理上游响应，并转换为 Claude 格式写出，返回 Claude 计费口径的用量
+func claudeChatResponse(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, httpResp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	writer := newClaudeChatWriter(c, info)
+	c.Writer = writer
+	usage, newAPIError := doChatResponse(c, info, adaptor, httpResp)
+	c.Writer = writer.ResponseWriter
+	if newAPIError != nil {
+		service.ResetStatusCode(newAPIError, c.GetString("status_code_mapping"))
+		return nil, newAPIError
+	}
+	openAIUsage, _ := usage.(*dto.Usage)
+	if openAIUsage == nil {
+		openAIUsage = &dto.Usage{}
+	}
+	writer.finish(openAIUsage)
+
+	// Claude 计费的输入用量不包含缓存部分，避免缓存命中被重复计费
+	claudeUsage := *openAIUsage
+	claudeUsage.PromptTokens = max(claudeUsage.PromptTokens-claudeUsage.PromptTokensDetails.CachedTokens-claudeUsage.PromptTokensDetails.CachedCreationTokens, 0)
+	return &claudeUsage, nil
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"context"
+	"sync"
+)
+
+// HedgeState 对冲请求中各个尝试共享的状态，第一个向客户端输出内容的尝试胜出，其余尝试被取消且不计费
+type HedgeState struct {
+	mu      sync.Mutex
+	winner  int
+	cancels map[int]context.CancelFunc
+}
+
+func NewHedgeState() *HedgeState {
+	return &HedgeState{cancels: make(map[int]context.CancelFunc)}
+}
+
+// Register 登记一个尝试及其取消函数
+func (h *HedgeState) Register(attempt int, cancel context.CancelFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cancels[attempt] = cancel
+}
+
+// Claim 尝试成为胜出者，成功时取消其余尝试；已胜出的尝试再次调用同样返回 true
+func (h *HedgeState) Claim(attempt int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.winner != 0 {
+		return h.winner == attempt
+	}
+	h.winner = attempt
+	for other, cancel := range h.cancels {
+		if other != attempt {
+			cancel()
+		}
+	}
+	return true
+}
+
+// Stop 取消所有尝试的上下文，对冲结束后调用
+func (h *HedgeState) Stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, cancel := range h.cancels {
+		cancel()
+	}
+}
+
+// Winner 返回胜出的尝试，0 表示尚未决出
+func (h *HedgeState) Winner() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.winner
+}
+
+// ClaimHedge 对冲请求中声明本次尝试胜出，未启用对冲时总是返回 true
+func (info *RelayInfo) ClaimHedge() bool {
+	if info.Hedge == nil {
+		return true
+	}
+	return info.Hedge.Claim(info.HedgeAttempt)
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHedgeStateClaim(t *testing.T) {
+	hedge := NewHedgeState()
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	hedge.Register(1, cancel1)
+	hedge.Register(2, cancel2)
+
+	if !hedge.Claim(2) {
+		t.Fatal("expected first claim to win")
+	}
+	if hedge.Claim(1) {
+		t.Fatal("expected second attempt to lose")
+	}
+	if !hedge.Claim(2) {
+		t.Fatal("expected winner to keep winning")
+	}
+	if ctx1.Err() == nil {
+		t.Fatal("expected loser context to be cancelled")
+	}
+	if ctx2.Err() != nil {
+		t.Fatal("expected winner context to stay alive")
+	}
+	if hedge.Winner() != 2 {
+		t.Fatalf("expected winner 2, got %d", hedge.Winner())
+	}
+}
+
+func TestRelayInfoClaimHedgeWithoutHedge(t *testing.T) {
+	info := &RelayInfo{}
+	if !info.ClaimHedge() {
+		t.Fatal("expected claim to succeed when hedging is disabled")
+	}
+}
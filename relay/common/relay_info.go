@@ -54,6 +54,45 @@ type ResponsesUsageInfo struct {
 	BuiltInTools map[string]*BuildInToolInfo
 }
 
+// StructuredOutputInfo 网关模拟结构化输出时的请求与校验情况
+type StructuredOutputInfo struct {
+	Attempts int  // 请求上游的次数，重试次数为 Attempts-1
+	Repaired bool // 输出经修复（去除代码块、截取 JSON）后通过校验
+	Valid    bool // 最终输出是否通过校验
+}
+
+// SubscriptionCharge 本次请求可使用的订阅套餐及已扣除的额度，消费时先扣套餐额度，返还时先返还钱包余额
+type SubscriptionCharge struct {
+	SubscriptionId int   // 订阅记录 ID，没有可用订阅时为 0
+	PeriodStart    int64 // 加载时所在周期的开始时间，周期切换后不再扣除或返还套餐额度
+	Available      int   // 加载时剩余的套餐额度
+	Charged        int   // 已从套餐额度扣除的额度
+	WalletCharged  int   // 已从钱包余额扣除的额度
+}
+
+// SpendBudget 令牌或用户的日/月消费预算，0 表示不限制
+type SpendBudget struct {
+	Daily   int
+	Monthly int
+}
+
+// OrganizationInfo 组织令牌所属的组织与成员，使用组织令牌时消费组织的共享额度
+type OrganizationInfo struct {
+	Id           int // 组织 ID，个人令牌为 0
+	MemberId     int // 成员记录 ID
+	MonthlyLimit int // 成员每月可使用的组织额度，0 表示不限制
+}
+
+// ResponsesStoreInfo 网关保存 Responses 会话状态所需的信息
+type ResponsesStoreInfo struct {
+	Store              bool            // 请求是否要求保存（store 未显式设为 false）
+	Save               bool            // 本次响应是否由网关保存
+	Expanded           bool            // 是否已由网关展开 previous_response_id 的历史输入
+	PreviousResponseId string          // 展开前的 previous_response_id
+	Input              json.RawMessage // 展开后的完整输入条目
+	Response           json.RawMessage // 完整的 Responses 响应
+}
+
 type ChannelMeta struct {
 	ChannelType          int
 	ChannelId            int
@@ -67,6 +106,7 @@ type ChannelMeta struct {
 	ChannelCreateTime    int64
 	ParamOverride        map[string]interface{}
 	HeadersOverride      map[string]interface{}
+	TransformScript      string // 渠道的请求/响应转换脚本
 	ChannelSetting       dto.ChannelSettings
 	ChannelOtherSettings dto.ChannelOtherSettings
 	UpstreamModelName    string
@@ -87,6 +127,8 @@ type RelayInfo struct {
 	UsingGroup        string // 使用的分组，当auto跨分组重试时，会变动
 	UserGroup         string // 用户所在分组
 	TokenUnlimited    bool
+	TokenBudget       SpendBudget      // 令牌的消费预算
+	TokenOrganization OrganizationInfo // 组织令牌所属的组织
 	StartTime         time.Time
 	FirstResponseTime time.Time
 	isFirstResponse   bool
@@ -94,6 +136,7 @@ type RelayInfo struct {
 	IsStream               bool
 	IsGeminiBatchEmbedding bool
 	IsPlayground           bool
+	ResponseCacheHit       bool // 命中响应缓存，未请求上游
 	UsePrice               bool
 	RelayMode              int
 	OriginModelName        string
@@ -113,8 +156,18 @@ type RelayInfo struct {
 	UserQuota              int
 	RelayFormat            types.RelayFormat
 	SendResponseCount      int
-	FinalPreConsumedQuota  int  // 最终预消耗的配额
-	IsClaudeBetaQuery      bool // /v1/messages?beta=true
+	FinalPreConsumedQuota  int                   // 最终预消耗的配额
+	IsClaudeBetaQuery      bool                  // /v1/messages?beta=true
+	Hedge                  *HedgeState           // 对冲请求共享状态，未启用对冲时为 nil
+	HedgeAttempt           int                   // 对冲请求中的尝试序号，从 1 开始
+	Moderator              StreamModerator       // 流式补全内容审核，未启用时为 nil
+	ResponsesStore         *ResponsesStoreInfo   // 网关保存的 Responses 会话状态，未启用时为 nil
+	StructuredOutput       *StructuredOutputInfo // 网关模拟结构化输出的执行情况，未启用时为 nil
+	ToolCallEmulation      bool                  // 模型不支持原生函数调用，由网关以提示词模拟
+	ChannelScriptError     string                // 渠道转换脚本最近一次执行失败的原因，失败时沿用原始内容
+	ModelFallbackPath      []string              // 发生模型降级时依次使用的模型，首个为请求的模型，未降级时为空
+	Subscription           *SubscriptionCharge   // 本次请求的订阅套餐扣费情况，未加载时为 nil，没有可用订阅时 SubscriptionId 为 0
+	BudgetReserved         int                   // 已计入消费预算但尚未实际扣费的预留额度，结算或请求结束时释放
 
 	PriceData types.PriceData
 
@@ -147,6 +200,7 @@ func (info *RelayInfo) InitChannelMeta(c *gin.Context) {
 		ChannelCreateTime:    c.GetInt64("channel_create_time"),
 		ParamOverride:        paramOverride,
 		HeadersOverride:      headerOverride,
+		TransformScript:      common.GetContextKeyString(c, constant.ContextKeyChannelTransformScript),
 		UpstreamModelName:    common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
 		IsModelMapped:        false,
 		SupportStreamOptions: false,
@@ -409,6 +463,15 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		TokenKey:       common.GetContextKeyString(c, constant.ContextKeyTokenKey),
 		TokenUnlimited: common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
 		TokenGroup:     tokenGroup,
+		TokenBudget: SpendBudget{
+			Daily:   common.GetContextKeyInt(c, constant.ContextKeyTokenDailyBudget),
+			Monthly: common.GetContextKeyInt(c, constant.ContextKeyTokenMonthlyBudget),
+		},
+		TokenOrganization: OrganizationInfo{
+			Id:           common.GetContextKeyInt(c, constant.ContextKeyOrganizationId),
+			MemberId:     common.GetContextKeyInt(c, constant.ContextKeyOrganizationMemberId),
+			MonthlyLimit: common.GetContextKeyInt(c, constant.ContextKeyOrganizationMonthlyLimit),
+		},
 
 		isFirstResponse: true,
 		RelayMode:       relayconstant.Path2RelayMode(c.Request.URL.Path),
@@ -679,3 +742,11 @@ func RemoveGeminiDisabledFields(jsonData []byte) ([]byte, error) {
 	}
 	return jsonDataAfter, nil
 }
+
+// StreamModerator 审核流式补全内容
+type StreamModerator interface {
+	// Moderate 审核新增的补全文本，返回可以下发的文本以及是否需要中止生成；末尾未审核完的文本会暂存
+	Moderate(delta string) (string, bool)
+	// Flush 返回暂存的文本，补全结束时调用
+	Flush() string
+}
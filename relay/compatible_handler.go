@@ -2,6 +2,7 @@ package relay
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -67,15 +68,41 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 	}
 
 	info.ShouldIncludeUsage = includeUsage
+	initToolCallEmulation(info, len(request.Tools) > 0)
 
 	adaptor := GetAdaptor(info.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
 	}
 	adaptor.Init(info)
+
+	passThroughBody := model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled
+	if !passThroughBody {
+		schema, err := structuredOutputEmulationSchema(info, request)
+		if err != nil {
+			return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+		}
+		if schema != nil {
+			usage, newApiErr := structuredOutputViaEmulation(c, info, adaptor, request, schema)
+			if newApiErr != nil {
+				return newApiErr
+			}
+			// 对冲请求中只有胜出的尝试计费
+			if !info.ClaimHedge() {
+				return types.NewError(errors.New("hedged request lost the race"), types.ErrorCodeHedgeCancelled, types.ErrOptionWithSkipRetry())
+			}
+			postConsumeQuota(c, info, usage)
+			return nil
+		}
+	}
+
 	var requestBody io.Reader
+	var cache *responseCache
+	defer func() {
+		cache.restore(c)
+	}()
 
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled {
+	if passThroughBody {
 		body, err := common.GetRequestBody(c)
 		if err != nil {
 			return types.NewErrorWithStatusCode(err, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
@@ -85,6 +112,9 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		}
 		requestBody = bytes.NewBuffer(body)
 	} else {
+		if info.ToolCallEmulation {
+			service.EmulateToolCallRequest(request)
+		}
 		convertedRequest, err := adaptor.ConvertOpenAIRequest(c, info, request)
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
@@ -153,6 +183,11 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 
 		logger.LogDebug(c, fmt.Sprintf("text request body: %s", string(jsonData)))
 
+		var hit bool
+		if cache, hit = lookupResponseCache(c, info, jsonData); hit {
+			return nil
+		}
+
 		requestBody = bytes.NewBuffer(jsonData)
 	}
 
@@ -175,13 +210,20 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		}
 	}
 
-	usage, newApiErr := adaptor.DoResponse(c, httpResp, info)
+	usage, newApiErr := doChatResponse(c, info, adaptor, httpResp)
 	if newApiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newApiErr, statusCodeMappingStr)
 		return newApiErr
 	}
 
+	// 对冲请求中只有胜出的尝试计费
+	if !info.ClaimHedge() {
+		return types.NewError(errors.New("hedged request lost the race"), types.ErrorCodeHedgeCancelled, types.ErrOptionWithSkipRetry())
+	}
+
+	cache.save(c, info, usage.(*dto.Usage))
+
 	var containAudioTokens = usage.(*dto.Usage).CompletionTokenDetails.AudioTokens > 0 || usage.(*dto.Usage).PromptTokensDetails.AudioTokens > 0
 	var containsAudioRatios = ratio_setting.ContainsAudioRatio(info.OriginModelName) || ratio_setting.ContainsAudioCompletionRatio(info.OriginModelName)
 
@@ -202,6 +244,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		}
 		extraContent = append(extraContent, "上游无计费信息")
 	}
+	defer service.BeginQuotaSettle(ctx, relayInfo, usage)()
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
 	cacheTokens := usage.PromptTokensDetails.CachedTokens
@@ -211,6 +254,8 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	cachedCreationTokens := usage.PromptTokensDetails.CachedCreationTokens
 
 	modelName := relayInfo.OriginModelName
+	// Anthropic 渠道的 input_tokens 不含缓存部分
+	service.SettlePricingRule(relayInfo, service.PricingInputTokens(usage, relayInfo.ChannelType == constant.ChannelTypeAnthropic))
 
 	tokenName := ctx.GetString("token_name")
 	completionRatio := relayInfo.PriceData.CompletionRatio
@@ -382,11 +427,18 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		logger.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, modelName, relayInfo.FinalPreConsumedQuota))
 	} else {
-		if !ratio.IsZero() && quota == 0 {
+		if relayInfo.ResponseCacheHit {
+			// 命中响应缓存按配置的倍率计费，且不计入渠道用量
+			cacheBillingRatio := operation_setting.GetResponseCacheSetting().BillingRatio
+			quota = int(decimal.NewFromInt(int64(quota)).Mul(decimal.NewFromFloat(cacheBillingRatio)).Round(0).IntPart())
+			extraContent = append(extraContent, fmt.Sprintf("命中响应缓存，计费倍率 %.2f", cacheBillingRatio))
+		} else if !ratio.IsZero() && quota == 0 {
 			quota = 1
 		}
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
-		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		if !relayInfo.ResponseCacheHit {
+			model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		}
 	}
 
 	quotaDelta := quota - relayInfo.FinalPreConsumedQuota
@@ -423,6 +475,9 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		logModel = "gpt-4o-gizmo-*"
 		extraContent = append(extraContent, fmt.Sprintf("模型 %s", modelName))
 	}
+	if relayInfo.StructuredOutput != nil && relayInfo.StructuredOutput.Attempts > 1 {
+		extraContent = append(extraContent, fmt.Sprintf("结构化输出校验重试 %d 次", relayInfo.StructuredOutput.Attempts-1))
+	}
 	logContent := strings.Join(extraContent, ", ")
 	other := service.GenerateTextOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio, cacheTokens, cacheRatio, modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
 	if imageTokens != 0 {
@@ -467,6 +522,18 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		other["image_generation_call"] = true
 		other["image_generation_call_price"] = imageGenerationCallPrice
 	}
+	if relayInfo.StructuredOutput != nil {
+		other["structured_output_emulation"] = true
+		other["structured_output_attempts"] = relayInfo.StructuredOutput.Attempts
+		other["structured_output_valid"] = relayInfo.StructuredOutput.Valid
+		other["structured_output_repaired"] = relayInfo.StructuredOutput.Repaired
+	}
+	logType := model.LogTypeConsume
+	if relayInfo.ResponseCacheHit {
+		logType = model.LogTypeCacheHit
+		other["response_cache_hit"] = true
+		other["response_cache_billing_ratio"] = operation_setting.GetResponseCacheSetting().BillingRatio
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     promptTokens,
@@ -480,5 +547,6 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		IsStream:         relayInfo.IsStream,
 		Group:            relayInfo.UsingGroup,
 		Other:            other,
+		LogType:          logType,
 	})
 }
@@ -0,0 +1,113 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/sjson"
+)
+
+// CountTokensHelper 处理 token 计数请求：开启转发且所选渠道原生支持对应格式的计数接口时转发给上游，
+// 否则（或上游失败时）在本地估算输入 token 数。计数请求不预扣费也不计费
+func CountTokensHelper(c *gin.Context, info *relaycommon.RelayInfo, request dto.Request) *types.NewAPIError {
+	if operation_setting.GetCountTokensSetting().ForwardUpstreamEnabled {
+		forwarded, err := forwardCountTokens(c, info, request)
+		if forwarded {
+			return nil
+		}
+		if err != nil {
+			logger.LogWarn(c, fmt.Sprintf("forward count tokens failed, fallback to local counting: %s", err.Error()))
+		}
+	}
+
+	tokens, err := service.CountRequestToken(c, request.GetTokenCountMeta(), info)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeCountTokenFailed, types.ErrOptionWithSkipRetry())
+	}
+	switch info.RelayFormat {
+	case types.RelayFormatClaude:
+		c.JSON(http.StatusOK, gin.H{"input_tokens": tokens})
+	case types.RelayFormatGemini:
+		c.JSON(http.StatusOK, gin.H{"totalTokens": tokens})
+	default:
+		c.JSON(http.StatusOK, gin.H{"object": "response.input_tokens", "input_tokens": tokens})
+	}
+	return nil
+}
+
+// countTokensUpstreamURL 返回渠道原生计数接口的地址，渠道不支持当前格式的计数接口时返回空字符串
+func countTokensUpstreamURL(adaptor channel.Adaptor, info *relaycommon.RelayInfo) (string, error) {
+	switch {
+	case info.RelayFormat == types.RelayFormatClaude && info.ChannelType == constant.ChannelTypeAnthropic:
+		return fmt.Sprintf("%s/v1/messages/count_tokens", info.ChannelBaseUrl), nil
+	case info.RelayFormat == types.RelayFormatGemini && info.ChannelType == constant.ChannelTypeGemini:
+		// 复用适配器的地址拼接（API 版本、思考后缀处理），仅替换接口动作
+		requestURL, err := adaptor.GetRequestURL(info)
+		if err != nil || !strings.Contains(requestURL, ":generateContent") {
+			return "", err
+		}
+		return strings.Replace(requestURL, ":generateContent", ":countTokens", 1), nil
+	case info.RelayFormat == types.RelayFormatOpenAIResponses && info.ChannelType == constant.ChannelTypeOpenAI:
+		return relaycommon.GetFullRequestURL(info.ChannelBaseUrl, "/v1/responses/input_tokens", info.ChannelType), nil
+	default:
+		return "", nil
+	}
+}
+
+// forwardCountTokens 将计数请求转发给上游，上游成功响应并已写回客户端时返回 true
+func forwardCountTokens(c *gin.Context, info *relaycommon.RelayInfo, request dto.Request) (bool, error) {
+	info.InitChannelMeta(c)
+	if err := helper.ModelMappedHelper(c, info, request); err != nil {
+		return false, err
+	}
+	adaptor := GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return false, fmt.Errorf("invalid api type: %d", info.ApiType)
+	}
+	adaptor.Init(info)
+	fullRequestURL, err := countTokensUpstreamURL(adaptor, info)
+	if err != nil || fullRequestURL == "" {
+		return false, err
+	}
+
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return false, err
+	}
+	// Gemini 的模型名在路径中，其余格式替换请求体中的模型名
+	if info.RelayFormat != types.RelayFormatGemini {
+		body, err = sjson.SetBytes(body, "model", info.UpstreamModelName)
+		if err != nil {
+			return false, err
+		}
+	}
+	resp, err := channel.DoApiRequestWithURL(adaptor, c, info, fullRequestURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("upstream status code %d: %s", resp.StatusCode, string(responseBody))
+	}
+	service.IOCopyBytesGracefully(c, resp, responseBody)
+	return true, nil
+}
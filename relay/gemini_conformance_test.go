@@ -0,0 +1,131 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// geminiConformanceCase 描述 testdata/gemini_conformance 下的一组录制数据，文件约定与 claude_conformance 相同，
+// 由于 Gemini 通过路径区分流式请求，stream 字段标记是否为 streamGenerateContent
+type geminiConformanceCase struct {
+	claudeConformanceCase
+	Stream bool `json:"stream"`
+}
+
+func TestGeminiViaChatConformance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 与 common 中环境变量的默认值保持一致
+	constant.StreamingTimeout = 300
+	dirs, err := filepath.Glob(filepath.Join("testdata", "gemini_conformance", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) == 0 {
+		t.Fatal("no gemini conformance fixtures found")
+	}
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			runGeminiConformanceCase(t, dir)
+		})
+	}
+}
+
+func runGeminiConformanceCase(t *testing.T, dir string) {
+	var tc geminiConformanceCase
+	readFixtureJSON(t, filepath.Join(dir, "case.json"), &tc)
+	var request dto.GeminiChatRequest
+	readFixtureJSON(t, filepath.Join(dir, "request.json"), &request)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1beta/models/"+tc.UpstreamModel+":generateContent", nil)
+	info := &relaycommon.RelayInfo{
+		IsStream:    tc.Stream,
+		RelayMode:   relayconstant.RelayModeChatCompletions,
+		RelayFormat: types.RelayFormatOpenAI,
+		StartTime:   time.Now(),
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ApiType:           tc.ApiType,
+			ChannelType:       tc.ChannelType,
+			UpstreamModelName: tc.UpstreamModel,
+		},
+	}
+
+	adaptor := GetAdaptor(tc.ApiType)
+	if adaptor == nil {
+		t.Fatalf("no adaptor for api type %d", tc.ApiType)
+	}
+	adaptor.Init(info)
+
+	openAIRequest, err := service.GeminiToOpenAIRequest(&request, info)
+	if err != nil {
+		t.Fatalf("convert gemini request: %v", err)
+	}
+	info.Request = openAIRequest
+	upstreamRequest, err := adaptor.ConvertOpenAIRequest(c, info, openAIRequest)
+	if err != nil {
+		t.Fatalf("convert upstream request: %v", err)
+	}
+	upstreamRequestJSON, err := common.Marshal(upstreamRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareFixtureJSON(t, filepath.Join(dir, "upstream_request.json"), upstreamRequestJSON)
+
+	body, err := os.ReadFile(filepath.Join(dir, "upstream_response.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{tc.UpstreamContentType}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+	if _, apiErr := geminiChatResponse(c, info, adaptor, resp); apiErr != nil {
+		t.Fatalf("handle upstream response: %v", apiErr)
+	}
+
+	output := recorder.Body.Bytes()
+	if tc.Stream {
+		output = geminiChunksJSON(t, output)
+	}
+	compareFixtureJSON(t, filepath.Join(dir, "response.json"), output)
+}
+
+// geminiChunksJSON 将 SSE 输出整理为分片数组
+func geminiChunksJSON(t *testing.T, output []byte) []byte {
+	chunks := make([]json.RawMessage, 0)
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := strings.TrimPrefix(line, "data: ")
+		if !json.Valid([]byte(data)) {
+			t.Fatalf("invalid chunk data %q", data)
+		}
+		chunks = append(chunks, json.RawMessage(data))
+	}
+	result, err := json.Marshal(chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
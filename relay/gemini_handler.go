@@ -2,6 +2,7 @@ package relay
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
 	"github.com/QuantumNous/new-api/relay/channel/gemini"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/helper"
@@ -95,6 +97,7 @@ func GeminiHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 	}
 
 	adaptor.Init(info)
+	initToolCallEmulation(info, len(request.GetTools()) > 0)
 
 	if info.ChannelSetting.SystemPrompt != "" {
 		if request.SystemInstructions == nil {
@@ -144,8 +147,20 @@ func GeminiHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 		}
 		requestBody = bytes.NewReader(body)
 	} else {
-		// 使用 ConvertGeminiRequest 转换请求格式
-		convertedRequest, err := adaptor.ConvertGeminiRequest(c, info, request)
+		// 使用 ConvertGeminiRequest 转换请求格式，模拟函数调用时需要经 Chat Completions 处理模型输出
+		var convertedRequest any
+		err := channel.ErrGeminiViaChatCompletions
+		if !info.ToolCallEmulation {
+			convertedRequest, err = adaptor.ConvertGeminiRequest(c, info, request)
+		}
+		if errors.Is(err, channel.ErrGeminiViaChatCompletions) {
+			usage, newAPIError := geminiViaChatCompletions(c, info, adaptor, request)
+			if newAPIError != nil {
+				return newAPIError
+			}
+			postConsumeQuota(c, info, usage)
+			return nil
+		}
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
 		}
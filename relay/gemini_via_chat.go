@@ -0,0 +1,175 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// geminiChatWriter 将适配器写出的 Chat Completions 响应转换为 Gemini generateContent 格式：
+// 流式响应逐个转换 data 分片，非流式响应先缓存，结束后整体转换
+type geminiChatWriter struct {
+	gin.ResponseWriter
+	c         *gin.Context
+	info      *relaycommon.RelayInfo
+	stream    bool
+	converter *service.GeminiStreamConverter
+	buf       bytes.Buffer
+	status    int
+}
+
+func newGeminiChatWriter(c *gin.Context, info *relaycommon.RelayInfo) *geminiChatWriter {
+	return &geminiChatWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		info:           info,
+		stream:         info.IsStream,
+		converter:      service.NewGeminiStreamConverter(info.GetEstimatePromptTokens()),
+		status:         http.StatusOK,
+	}
+}
+
+func (w *geminiChatWriter) WriteHeader(code int) {
+	if w.stream {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *geminiChatWriter) WriteHeaderNow() {
+	if w.stream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *geminiChatWriter) Write(data []byte) (int, error) {
+	w.buf.Write(data)
+	if w.stream {
+		err := translateChatStream(w.c, &w.buf, w.ResponseWriter, func(chunk *dto.ChatCompletionsStreamResponse) error {
+			return w.writeResponse(w.converter.Convert(chunk))
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *geminiChatWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *geminiChatWriter) Status() int {
+	if w.stream {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *geminiChatWriter) Flush() {
+	if w.stream {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *geminiChatWriter) writeResponse(response *dto.GeminiChatResponse) error {
+	if response == nil {
+		return nil
+	}
+	data, err := common.Marshal(response)
+	if err != nil {
+		return err
+	}
+	_, err = w.ResponseWriter.WriteString(fmt.Sprintf("data: %s\n\n", data))
+	return err
+}
+
+// finish 在适配器处理完响应后输出最终结果：流式响应补发带 finishReason 与用量的结束分片，非流式响应整体转换后写出
+func (w *geminiChatWriter) finish(usage *dto.Usage) {
+	if w.stream {
+		helper.SetEventStreamHeaders(w.c)
+		if err := w.writeResponse(w.converter.Finish(usage)); err != nil {
+			logger.LogError(w.c, fmt.Sprintf("failed to write gemini stream response: %s", err.Error()))
+		}
+		w.ResponseWriter.Flush()
+		return
+	}
+	body := w.buf.Bytes()
+	var chatResponse dto.OpenAITextResponse
+	if err := common.Unmarshal(body, &chatResponse); err == nil {
+		// 适配器可能在响应体之外修正用量，以最终用量为准
+		chatResponse.Usage = *usage
+		geminiResponse := service.ResponseOpenAI2Gemini(&chatResponse, w.info)
+		if converted, err := common.Marshal(geminiResponse); err == nil {
+			body = converted
+		} else {
+			logger.LogError(w.c, fmt.Sprintf("failed to marshal gemini response: %s", err.Error()))
+		}
+	} else {
+		logger.LogError(w.c, fmt.Sprintf("failed to parse chat completions response: %s", err.Error()))
+	}
+	header := w.ResponseWriter.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/json")
+	w.ResponseWriter.WriteHeader(w.status)
+	if _, err := w.ResponseWriter.Write(body); err != nil {
+		logger.LogError(w.c, fmt.Sprintf("failed to write gemini response: %s", err.Error()))
+	}
+	w.ResponseWriter.Flush()
+}
+
+// geminiViaChatCompletions 为不支持 Gemini 原生接口的渠道转发 generateContent 请求：
+// 请求转换为 Chat Completions 后交由适配器按 OpenAI 格式处理，响应再转换回 Gemini 格式
+func geminiViaChatCompletions(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, request *dto.GeminiChatRequest) (*dto.Usage, *types.NewAPIError) {
+	openAIRequest, err := service.GeminiToOpenAIRequest(request, info)
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	// 结束分片中的 usageMetadata 需要最终用量
+	if openAIRequest.Stream && info.SupportStreamOptions {
+		openAIRequest.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+
+	// 适配器按对话请求处理，结束后恢复，以免影响 Gemini 的计费与日志
+	relayMode, relayFormat, originRequest := info.RelayMode, info.RelayFormat, info.Request
+	defer func() {
+		info.RelayMode, info.RelayFormat, info.Request = relayMode, relayFormat, originRequest
+	}()
+	httpResp, newAPIError := doChatCompletionsRequest(c, info, adaptor, openAIRequest)
+	if newAPIError != nil {
+		return nil, newAPIError
+	}
+	return geminiChatResponse(c, info, adaptor, httpResp)
+}
+
+// geminiChatResponse 由适配器按 OpenAI 格式处理上游响应，并转换为 Gemini 格式写出
+func geminiChatResponse(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, httpResp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	writer := newGeminiChatWriter(c, info)
+	c.Writer = writer
+	usage, newAPIError := doChatResponse(c, info, adaptor, httpResp)
+	c.Writer = writer.ResponseWriter
+	if newAPIError != nil {
+		service.ResetStatusCode(newAPIError, c.GetString("status_code_mapping"))
+		return nil, newAPIError
+	}
+	openAIUsage, _ := usage.(*dto.Usage)
+	if openAIUsage == nil {
+		openAIUsage = &dto.Usage{}
+	}
+	writer.finish(openAIUsage)
+	return openAIUsage, nil
+}
@@ -115,6 +115,12 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		}
 	}
 
+	// 计价规则先按预估的提示词 tokens 匹配，结算时再按实际用量重新匹配
+	pricingRule := ratio_setting.MatchPricingRule(info.OriginModelName, info.UsingGroup, promptTokens, info.StartTime)
+	if pricingRule != nil {
+		preConsumedQuota = int(float64(preConsumedQuota) * pricingRule.InputMultiplier)
+	}
+
 	priceData := types.PriceData{
 		FreeModel:            freeModel,
 		ModelPrice:           modelPrice,
@@ -131,6 +137,7 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		CacheCreation1hRatio: cacheCreationRatio1h,
 		QuotaToPreConsume:    preConsumedQuota,
 	}
+	priceData.ApplyPricingRule(pricingRule)
 
 	if common.DebugEnabled {
 		println(fmt.Sprintf("model_price_helper result: %s", priceData.ToSetting()))
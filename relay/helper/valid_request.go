@@ -308,6 +308,37 @@ func GetAndValidateGeminiRequest(c *gin.Context) (*dto.GeminiChatRequest, error)
 	return request, nil
 }
 
+// GetAndValidateGeminiCountTokensRequest 解析 :countTokens 请求，统一转换为 generateContent 请求
+func GetAndValidateGeminiCountTokensRequest(c *gin.Context) (*dto.GeminiChatRequest, error) {
+	countRequest := &dto.GeminiCountTokensRequest{}
+	err := common.UnmarshalBodyReusable(c, countRequest)
+	if err != nil {
+		return nil, err
+	}
+	request := countRequest.GenerateContentRequest
+	if request == nil {
+		request = &dto.GeminiChatRequest{Contents: countRequest.Contents}
+	}
+	if len(request.Contents) == 0 {
+		return nil, errors.New("contents is required")
+	}
+	return request, nil
+}
+
+// GetAndValidateCountTokensRequest 按接口格式解析 token 计数请求，请求体与对应的生成接口一致
+func GetAndValidateCountTokensRequest(c *gin.Context, format types.RelayFormat) (dto.Request, error) {
+	switch format {
+	case types.RelayFormatClaude:
+		return GetAndValidateClaudeRequest(c)
+	case types.RelayFormatGemini:
+		return GetAndValidateGeminiCountTokensRequest(c)
+	case types.RelayFormatOpenAIResponses:
+		return GetAndValidateResponsesRequest(c)
+	default:
+		return nil, fmt.Errorf("unsupported count tokens format: %s", format)
+	}
+}
+
 func GetAndValidateGeminiEmbeddingRequest(c *gin.Context) (*dto.GeminiEmbeddingRequest, error) {
 	request := &dto.GeminiEmbeddingRequest{}
 	err := common.UnmarshalBodyReusable(c, request)
@@ -188,7 +188,7 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 
 	priceData := helper.ModelPriceHelperPerCall(c, info)
 
-	userQuota, err := model.GetUserQuota(info.UserId, false)
+	userQuota, err := service.GetUserAvailableQuota(info)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -202,6 +202,14 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 			Description: "quota_not_enough",
 		}
 	}
+	if apiErr := service.ReserveSpendBudget(info, priceData.Quota); apiErr != nil {
+		return &dto.MidjourneyResponse{
+			Code:        4,
+			Description: apiErr.Error(),
+		}
+	}
+	// 提交失败时释放预留的预算，提交成功时已在扣费时结算
+	defer service.ReleaseSpendBudget(info)
 	requestURL := getMjRequestPath(c.Request.URL.String())
 	baseURL := c.GetString("base_url")
 	fullRequestURL := fmt.Sprintf("%s%s", baseURL, requestURL)
@@ -209,12 +217,19 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 	if err != nil {
 		return &mjResp.Response
 	}
+	var midjourneyTask *model.Midjourney
 	defer func() {
 		if mjResp.StatusCode == 200 && mjResp.Response.Code == 1 {
 			err := service.PostConsumeQuota(info, priceData.Quota, 0, true)
 			if err != nil {
 				common.SysLog("error consuming token remain quota: " + err.Error())
 			}
+			// 记录从套餐扣除的额度，任务失败时返还到套餐
+			if charge := info.Subscription; midjourneyTask != nil && midjourneyTask.Id != 0 && charge != nil && charge.Charged > 0 {
+				if err := midjourneyTask.UpdateSubscriptionCharge(charge.SubscriptionId, charge.PeriodStart, charge.Charged); err != nil {
+					common.SysLog("error recording midjourney subscription charge: " + err.Error())
+				}
+			}
 
 			tokenName := c.GetString("token_name")
 			logContent := fmt.Sprintf("模型固定价格 %.2f，分组倍率 %.2f，操作 %s", priceData.ModelPrice, priceData.GroupRatioInfo.GroupRatio, constant.MjActionSwapFace)
@@ -234,24 +249,27 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 		}
 	}()
 	midjResponse := &mjResp.Response
-	midjourneyTask := &model.Midjourney{
-		UserId:      info.UserId,
-		Code:        midjResponse.Code,
-		Action:      constant.MjActionSwapFace,
-		MjId:        midjResponse.Result,
-		Prompt:      "InsightFace",
-		PromptEn:    "",
-		Description: midjResponse.Description,
-		State:       "",
-		SubmitTime:  info.StartTime.UnixNano() / int64(time.Millisecond),
-		StartTime:   time.Now().UnixNano() / int64(time.Millisecond),
-		FinishTime:  0,
-		ImageUrl:    "",
-		Status:      "",
-		Progress:    "0%",
-		FailReason:  "",
-		ChannelId:   c.GetInt("channel_id"),
-		Quota:       priceData.Quota,
+	midjourneyTask = &model.Midjourney{
+		UserId:               info.UserId,
+		OrganizationId:       info.TokenOrganization.Id,
+		Code:                 midjResponse.Code,
+		Action:               constant.MjActionSwapFace,
+		MjId:                 midjResponse.Result,
+		Prompt:               "InsightFace",
+		PromptEn:             "",
+		Description:          midjResponse.Description,
+		State:                "",
+		SubmitTime:           info.StartTime.UnixNano() / int64(time.Millisecond),
+		StartTime:            time.Now().UnixNano() / int64(time.Millisecond),
+		FinishTime:           0,
+		ImageUrl:             "",
+		Status:               "",
+		Progress:             "0%",
+		FailReason:           "",
+		ChannelId:            c.GetInt("channel_id"),
+		Quota:                priceData.Quota,
+		TokenId:              info.TokenId,
+		OrganizationMemberId: info.TokenOrganization.MemberId,
 	}
 	err = midjourneyTask.Insert()
 	if err != nil {
@@ -489,7 +507,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 
 	priceData := helper.ModelPriceHelperPerCall(c, relayInfo)
 
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := service.GetUserAvailableQuota(relayInfo)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -503,6 +521,16 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 			Description: "quota_not_enough",
 		}
 	}
+	if consumeQuota {
+		if apiErr := service.ReserveSpendBudget(relayInfo, priceData.Quota); apiErr != nil {
+			return &dto.MidjourneyResponse{
+				Code:        4,
+				Description: apiErr.Error(),
+			}
+		}
+		// 提交失败时释放预留的预算，提交成功时已在扣费时结算
+		defer service.ReleaseSpendBudget(relayInfo)
+	}
 
 	midjResponseWithStatus, responseBody, err := service.DoMidjourneyHttpRequest(c, time.Second*60, fullRequestURL)
 	if err != nil {
@@ -510,12 +538,19 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 	}
 	midjResponse := &midjResponseWithStatus.Response
 
+	var midjourneyTask *model.Midjourney
 	defer func() {
 		if consumeQuota && midjResponseWithStatus.StatusCode == 200 {
 			err := service.PostConsumeQuota(relayInfo, priceData.Quota, 0, true)
 			if err != nil {
 				common.SysLog("error consuming token remain quota: " + err.Error())
 			}
+			// 记录从套餐扣除的额度，任务失败时返还到套餐
+			if charge := relayInfo.Subscription; midjourneyTask != nil && midjourneyTask.Id != 0 && charge != nil && charge.Charged > 0 {
+				if err := midjourneyTask.UpdateSubscriptionCharge(charge.SubscriptionId, charge.PeriodStart, charge.Charged); err != nil {
+					common.SysLog("error recording midjourney subscription charge: " + err.Error())
+				}
+			}
 			tokenName := c.GetString("token_name")
 			logContent := fmt.Sprintf("模型固定价格 %.2f，分组倍率 %.2f，操作 %s，ID %s", priceData.ModelPrice, priceData.GroupRatioInfo.GroupRatio, midjRequest.Action, midjResponse.Result)
 			other := service.GenerateMjOtherInfo(relayInfo, priceData)
@@ -541,24 +576,27 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 	// 23-队列已满，请稍后再试 {"code":23,"description":"队列已满，请稍后尝试","result":"14001929738841620","properties":{"discordInstanceId":"1118138338562560102"}}
 	// 24-prompt包含敏感词 {"code":24,"description":"可能包含敏感词","properties":{"promptEn":"nude body","bannedWord":"nude"}}
 	// other: 提交错误，description为错误描述
-	midjourneyTask := &model.Midjourney{
-		UserId:      relayInfo.UserId,
-		Code:        midjResponse.Code,
-		Action:      midjRequest.Action,
-		MjId:        midjResponse.Result,
-		Prompt:      midjRequest.Prompt,
-		PromptEn:    "",
-		Description: midjResponse.Description,
-		State:       "",
-		SubmitTime:  time.Now().UnixNano() / int64(time.Millisecond),
-		StartTime:   0,
-		FinishTime:  0,
-		ImageUrl:    "",
-		Status:      "",
-		Progress:    "0%",
-		FailReason:  "",
-		ChannelId:   c.GetInt("channel_id"),
-		Quota:       priceData.Quota,
+	midjourneyTask = &model.Midjourney{
+		UserId:               relayInfo.UserId,
+		OrganizationId:       relayInfo.TokenOrganization.Id,
+		Code:                 midjResponse.Code,
+		Action:               midjRequest.Action,
+		MjId:                 midjResponse.Result,
+		Prompt:               midjRequest.Prompt,
+		PromptEn:             "",
+		Description:          midjResponse.Description,
+		State:                "",
+		SubmitTime:           time.Now().UnixNano() / int64(time.Millisecond),
+		StartTime:            0,
+		FinishTime:           0,
+		ImageUrl:             "",
+		Status:               "",
+		Progress:             "0%",
+		FailReason:           "",
+		ChannelId:            c.GetInt("channel_id"),
+		Quota:                priceData.Quota,
+		TokenId:              relayInfo.TokenId,
+		OrganizationMemberId: relayInfo.TokenOrganization.MemberId,
 	}
 	if midjResponse.Code == 3 {
 		//无实例账号自动禁用渠道（No available account instance）
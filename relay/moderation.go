@@ -0,0 +1,67 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	service.RegisterModerationRequestBuilder(buildModerationRequest)
+}
+
+// buildModerationRequest 通过渠道适配器构造内容审核分类器的 /v1/moderations 请求地址与请求头，
+// 与转发请求一样处理 Azure 等渠道的地址格式与鉴权方式
+func buildModerationRequest(c *gin.Context, channel *model.Channel, key string, modelName string) (string, http.Header, error) {
+	apiType, ok := common.ChannelType2APIType(channel.Type)
+	if !ok {
+		return "", nil, fmt.Errorf("channel type %d does not support moderation", channel.Type)
+	}
+	adaptor := GetAdaptor(apiType)
+	if adaptor == nil {
+		return "", nil, fmt.Errorf("invalid api type: %d", apiType)
+	}
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" && channel.Type >= 0 && channel.Type < len(constant.ChannelBaseURLs) {
+		baseURL = constant.ChannelBaseURLs[channel.Type]
+	}
+	info := &relaycommon.RelayInfo{
+		RelayMode:       relayconstant.RelayModeModerations,
+		RelayFormat:     types.RelayFormatOpenAI,
+		RequestURLPath:  "/v1/moderations",
+		OriginModelName: modelName,
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelType:          channel.Type,
+			ChannelId:            channel.Id,
+			ChannelBaseUrl:       baseURL,
+			ApiType:              apiType,
+			ApiKey:               key,
+			ChannelCreateTime:    channel.CreatedTime,
+			ChannelSetting:       channel.GetSetting(),
+			ChannelOtherSettings: channel.GetOtherSettings(),
+			UpstreamModelName:    modelName,
+		},
+	}
+	if channel.Type == constant.ChannelTypeAzure {
+		info.ApiVersion = channel.Other
+	}
+	adaptor.Init(info)
+	requestURL, err := adaptor.GetRequestURL(info)
+	if err != nil {
+		return "", nil, err
+	}
+	header := http.Header{}
+	if err := adaptor.SetupRequestHeader(c, &header, info); err != nil {
+		return "", nil, err
+	}
+	return requestURL, header, nil
+}
@@ -50,6 +50,14 @@ import (
 )
 
 func GetAdaptor(apiType int) channel.Adaptor {
+	adaptor := getAdaptor(apiType)
+	if adaptor == nil {
+		return nil
+	}
+	return &tracingAdaptor{Adaptor: adaptor}
+}
+
+func getAdaptor(apiType int) channel.Adaptor {
 	switch apiType {
 	case constant.APITypeAli:
 		return &ali.Adaptor{}
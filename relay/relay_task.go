@@ -19,6 +19,7 @@ import (
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
 )
@@ -178,7 +179,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 		}
 	}
 	println(fmt.Sprintf("model: %s, model_price: %.4f, group: %s, group_ratio: %.4f, final_ratio: %.4f", modelName, modelPrice, info.UsingGroup, groupRatio, ratio))
-	userQuota, err := model.GetUserQuota(info.UserId, false)
+	userQuota, err := service.GetUserAvailableQuota(info)
 	if err != nil {
 		taskErr = service.TaskErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 		return
@@ -188,6 +189,12 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 		taskErr = service.TaskErrorWrapperLocal(errors.New("user quota is not enough"), "quota_not_enough", http.StatusForbidden)
 		return
 	}
+	if apiErr := service.ReserveSpendBudget(info, quota); apiErr != nil {
+		taskErr = service.TaskErrorWrapperLocal(apiErr.Err, string(types.ErrorCodeSpendBudgetExceeded), http.StatusTooManyRequests)
+		return
+	}
+	// 任务提交失败时释放预留的预算，提交成功时已在扣费时结算
+	defer service.ReleaseSpendBudget(info)
 
 	// build body
 	requestBody, err := adaptor.BuildRequestBody(c, info)
@@ -208,6 +215,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 		return
 	}
 
+	var task *model.Task
 	defer func() {
 		// release quota
 		if info.ConsumeQuota && taskErr == nil {
@@ -216,6 +224,12 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 			if err != nil {
 				common.SysLog("error consuming token remain quota: " + err.Error())
 			}
+			// 记录从套餐扣除的额度，任务失败时返还到套餐
+			if charge := info.Subscription; task != nil && charge != nil && charge.Charged > 0 {
+				if err := task.UpdateSubscriptionCharge(charge.SubscriptionId, charge.PeriodStart, charge.Charged); err != nil {
+					common.SysLog("error recording task subscription charge: " + err.Error())
+				}
+			}
 			if quota != 0 {
 				tokenName := c.GetString("token_name")
 				//gRatio := groupRatio
@@ -270,7 +284,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 	}
 	info.ConsumeQuota = true
 	// insert task
-	task := model.InitTask(platform, info)
+	task = model.InitTask(platform, info)
 	task.TaskID = taskID
 	task.Quota = quota
 	task.Data = taskData
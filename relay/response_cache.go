@@ -0,0 +1,174 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseCacheRecorder 透传写入客户端的同时记录响应内容，用于写入响应缓存
+type responseCacheRecorder struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	maxSize  int
+	overflow bool
+}
+
+func (w *responseCacheRecorder) record(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.buf.Len()+len(data) > w.maxSize {
+		w.overflow = true
+		w.buf.Reset()
+		return
+	}
+	w.buf.Write(data)
+}
+
+func (w *responseCacheRecorder) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseCacheRecorder) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// responseCache 单次尝试中的响应缓存上下文
+type responseCache struct {
+	key      string
+	recorder *responseCacheRecorder
+	original gin.ResponseWriter
+}
+
+// isResponseCacheable 仅缓存结果确定的请求：Embedding 以及 temperature 为 0 的对话/补全
+func isResponseCacheable(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	tokenEnabled := common.GetContextKeyBool(c, constant.ContextKeyTokenResponseCache)
+	if !operation_setting.IsResponseCacheEnabled(info.UsingGroup, tokenEnabled) {
+		return false
+	}
+	switch request := info.Request.(type) {
+	case *dto.EmbeddingRequest:
+		return info.RelayMode == relayconstant.RelayModeEmbeddings
+	case *dto.GeneralOpenAIRequest:
+		if info.RelayFormat != types.RelayFormatOpenAI {
+			return false
+		}
+		if info.RelayMode != relayconstant.RelayModeChatCompletions && info.RelayMode != relayconstant.RelayModeCompletions {
+			return false
+		}
+		return request.Temperature != nil && *request.Temperature == 0
+	}
+	return false
+}
+
+// lookupResponseCache 查询响应缓存，命中时直接回放并按缓存倍率计费；未命中时开始记录本次响应
+func lookupResponseCache(c *gin.Context, info *relaycommon.RelayInfo, requestBody []byte) (*responseCache, bool) {
+	if !isResponseCacheable(c, info) {
+		return nil, false
+	}
+	key, err := service.GetResponseCacheKey(info.UserId, info.UsingGroup, info.UpstreamModelName, requestBody)
+	if err != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to build response cache key: %s", err.Error()))
+		return nil, false
+	}
+	if entry, ok := service.GetResponseCache(key); ok {
+		// 对冲请求中另一个尝试已胜出时不再回放和计费
+		if !info.ClaimHedge() {
+			return nil, true
+		}
+		logger.LogInfo(c, fmt.Sprintf("response cache hit: %s", key))
+		info.ResponseCacheHit = true
+		replayResponseCache(c, info, entry)
+		usage := entry.Usage
+		postConsumeQuota(c, info, &usage)
+		return nil, true
+	}
+
+	recorder := &responseCacheRecorder{
+		ResponseWriter: c.Writer,
+		maxSize:        operation_setting.GetResponseCacheSetting().MaxEntrySizeKB * 1024,
+	}
+	cache := &responseCache{key: key, recorder: recorder, original: c.Writer}
+	c.Writer = recorder
+	return cache, false
+}
+
+func replayResponseCache(c *gin.Context, info *relaycommon.RelayInfo, entry *service.ResponseCacheEntry) {
+	info.IsStream = entry.IsStream
+	if !entry.IsStream {
+		c.Data(http.StatusOK, entry.ContentType, entry.Body)
+		return
+	}
+	helper.SetEventStreamHeaders(c)
+	for _, chunk := range entry.Chunks {
+		info.SetFirstResponseTime()
+		if err := helper.StringData(c, chunk); err != nil {
+			logger.LogError(c, "failed to replay response cache: "+err.Error())
+			return
+		}
+	}
+	helper.Done(c)
+}
+
+// restore 恢复原始的 ResponseWriter，需在本次尝试结束时调用
+func (rc *responseCache) restore(c *gin.Context) {
+	if rc == nil {
+		return
+	}
+	c.Writer = rc.original
+}
+
+// save 上游请求成功后写入响应缓存
+func (rc *responseCache) save(c *gin.Context, info *relaycommon.RelayInfo, usage *dto.Usage) {
+	if rc == nil || usage == nil || usage.TotalTokens == 0 {
+		return
+	}
+	recorder := rc.recorder
+	if recorder.overflow || recorder.buf.Len() == 0 || recorder.Status() != http.StatusOK {
+		return
+	}
+	entry := &service.ResponseCacheEntry{
+		IsStream: info.IsStream,
+		Usage:    *usage,
+	}
+	if info.IsStream {
+		scanner := bufio.NewScanner(bytes.NewReader(recorder.buf.Bytes()))
+		scanner.Buffer(make([]byte, 64*1024), recorder.buf.Len()+1)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "" || data == "[DONE]" {
+				continue
+			}
+			entry.Chunks = append(entry.Chunks, data)
+		}
+		if len(entry.Chunks) == 0 {
+			return
+		}
+	} else {
+		entry.ContentType = recorder.Header().Get("Content-Type")
+		entry.Body = bytes.Clone(recorder.buf.Bytes())
+	}
+	service.SetResponseCache(rc.key, entry)
+}
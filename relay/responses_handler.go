@@ -2,6 +2,7 @@ package relay
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
@@ -36,11 +38,18 @@ func ResponsesHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
 	}
 
+	// 解析网关保存的 previous_response_id，需在模型映射后、转换请求前完成
+	if newAPIError = service.PrepareResponsesStore(c, info, request); newAPIError != nil {
+		return newAPIError
+	}
+
 	adaptor := GetAdaptor(info.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
 	}
 	adaptor.Init(info)
+	// 仅在转为 Chat Completions 转发时生效
+	initToolCallEmulation(info, len(request.Tools) > 0)
 	var requestBody io.Reader
 	if model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled {
 		body, err := common.GetRequestBody(c)
@@ -50,6 +59,23 @@ func ResponsesHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		requestBody = bytes.NewBuffer(body)
 	} else {
 		convertedRequest, err := adaptor.ConvertOpenAIResponsesRequest(c, info, *request)
+		if errors.Is(err, channel.ErrResponsesViaChatCompletions) {
+			// 无状态上游无法解析未由网关保存的 previous_response_id
+			if request.PreviousResponseID != "" {
+				return types.NewErrorWithStatusCode(fmt.Errorf("previous response with id '%s' not found", request.PreviousResponseID),
+					types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+			}
+			if info.ResponsesStore != nil {
+				info.ResponsesStore.Save = info.ResponsesStore.Store
+			}
+			usage, newAPIError := responsesViaChatCompletions(c, info, adaptor, request)
+			if newAPIError != nil {
+				return newAPIError
+			}
+			postConsumeQuota(c, info, usage)
+			service.SaveResponsesStore(c, info)
+			return nil
+		}
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
 		}
@@ -78,6 +104,11 @@ func ResponsesHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		requestBody = bytes.NewBuffer(jsonData)
 	}
 
+	// 已由网关展开历史或渠道禁用了 store 透传时，由网关保存本次响应
+	if store := info.ResponsesStore; store != nil {
+		store.Save = store.Store && (store.Expanded || info.ChannelOtherSettings.DisableStore)
+	}
+
 	var httpResp *http.Response
 	resp, err := adaptor.DoRequest(c, info, requestBody)
 	if err != nil {
@@ -109,5 +140,6 @@ func ResponsesHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 	} else {
 		postConsumeQuota(c, info, usage.(*dto.Usage))
 	}
+	service.SaveResponsesStore(c, info)
 	return nil
 }
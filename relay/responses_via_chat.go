@@ -0,0 +1,275 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responsesChatWriter 将适配器写出的 Chat Completions 响应转换为 Responses API 格式：
+// 流式响应逐个转换 data 分片为 response.* 事件，非流式响应先缓存，结束后整体转换
+type responsesChatWriter struct {
+	gin.ResponseWriter
+	c         *gin.Context
+	request   *dto.OpenAIResponsesRequest
+	id        string
+	stream    bool
+	converter *service.ResponsesStreamConverter
+	buf       bytes.Buffer
+	status    int
+}
+
+func newResponsesChatWriter(c *gin.Context, request *dto.OpenAIResponsesRequest, stream bool) *responsesChatWriter {
+	id := fmt.Sprintf("resp_%s", c.GetString(common.RequestIdKey))
+	return &responsesChatWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		request:        request,
+		id:             id,
+		stream:         stream,
+		converter:      service.NewResponsesStreamConverter(request, id),
+		status:         http.StatusOK,
+	}
+}
+
+func (w *responsesChatWriter) WriteHeader(code int) {
+	if w.stream {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *responsesChatWriter) WriteHeaderNow() {
+	if w.stream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *responsesChatWriter) Write(data []byte) (int, error) {
+	w.buf.Write(data)
+	if w.stream {
+		if err := w.translateEvents(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *responsesChatWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *responsesChatWriter) Status() int {
+	if w.stream {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *responsesChatWriter) Flush() {
+	if w.stream {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// translateEvents 处理缓冲区中完整的 SSE 事件
+func (w *responsesChatWriter) translateEvents() error {
+	return translateChatStream(w.c, &w.buf, w.ResponseWriter, func(chunk *dto.ChatCompletionsStreamResponse) error {
+		return w.writeEvents(w.converter.Convert(chunk))
+	})
+}
+
+// translateChatStream 逐个处理缓冲区中完整的 SSE 事件：保活注释原样透传，data 分片解析后交给 convert
+func translateChatStream(c *gin.Context, buf *bytes.Buffer, writer gin.ResponseWriter, convert func(chunk *dto.ChatCompletionsStreamResponse) error) error {
+	for {
+		data := buf.Bytes()
+		idx := bytes.Index(data, []byte("\n\n"))
+		if idx < 0 {
+			return nil
+		}
+		block := string(data[:idx])
+		buf.Next(idx + 2)
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, ":"):
+				if _, err := writer.WriteString(line + "\n\n"); err != nil {
+					return err
+				}
+			case strings.HasPrefix(line, "data:"):
+				payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+				if payload == "" || payload == "[DONE]" {
+					continue
+				}
+				var chunk dto.ChatCompletionsStreamResponse
+				if err := common.UnmarshalJsonStr(payload, &chunk); err != nil {
+					logger.LogError(c, fmt.Sprintf("failed to parse chat completions chunk: %s", err.Error()))
+					continue
+				}
+				if err := convert(&chunk); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (w *responsesChatWriter) writeEvents(events []dto.ResponsesStreamResponse) error {
+	for _, event := range events {
+		data, err := common.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := w.ResponseWriter.WriteString(fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish 在适配器处理完响应后输出最终结果：流式响应补发结束事件，非流式响应整体转换后写出；
+// 返回转换后的完整响应，无法转换时返回 nil
+func (w *responsesChatWriter) finish(usage *dto.Usage) *dto.OpenAIResponsesResponse {
+	if w.stream {
+		helper.SetEventStreamHeaders(w.c)
+		if err := w.writeEvents(w.converter.Finish(usage)); err != nil {
+			logger.LogError(w.c, fmt.Sprintf("failed to write responses stream events: %s", err.Error()))
+		}
+		w.ResponseWriter.Flush()
+		return w.converter.Response()
+	}
+	body := w.buf.Bytes()
+	var response *dto.OpenAIResponsesResponse
+	var chatResponse dto.OpenAITextResponse
+	if err := common.Unmarshal(body, &chatResponse); err == nil {
+		response = service.ResponseOpenAI2Responses(&chatResponse, w.request, w.id, usage)
+		if converted, err := common.Marshal(response); err == nil {
+			body = converted
+		} else {
+			logger.LogError(w.c, fmt.Sprintf("failed to marshal responses response: %s", err.Error()))
+		}
+	} else {
+		logger.LogError(w.c, fmt.Sprintf("failed to parse chat completions response: %s", err.Error()))
+	}
+	header := w.ResponseWriter.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/json")
+	w.ResponseWriter.WriteHeader(w.status)
+	if _, err := w.ResponseWriter.Write(body); err != nil {
+		logger.LogError(w.c, fmt.Sprintf("failed to write responses response: %s", err.Error()))
+	}
+	w.ResponseWriter.Flush()
+	return response
+}
+
+// responsesViaChatCompletions 为仅支持对话接口的渠道转发 Responses 请求：
+// 请求转换为 Chat Completions 后交由适配器按 OpenAI 格式处理，响应再转换回 Responses 格式
+func responsesViaChatCompletions(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, request *dto.OpenAIResponsesRequest) (*dto.Usage, *types.NewAPIError) {
+	openAIRequest, err := service.ResponsesToOpenAIRequest(request)
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	// 适配器按对话请求处理，结束后恢复，以免影响 Responses 的计费与日志
+	relayMode, relayFormat, originRequest := info.RelayMode, info.RelayFormat, info.Request
+	defer func() {
+		info.RelayMode, info.RelayFormat, info.Request = relayMode, relayFormat, originRequest
+	}()
+	httpResp, newAPIError := doChatCompletionsRequest(c, info, adaptor, openAIRequest)
+	if newAPIError != nil {
+		return nil, newAPIError
+	}
+
+	// 响应中回显展开前的 previous_response_id
+	echoRequest := *request
+	if store := info.ResponsesStore; store != nil && store.Expanded {
+		echoRequest.PreviousResponseID = store.PreviousResponseId
+	}
+	writer := newResponsesChatWriter(c, &echoRequest, info.IsStream)
+	c.Writer = writer
+	usage, newAPIError := doChatResponse(c, info, adaptor, httpResp)
+	c.Writer = writer.ResponseWriter
+	if newAPIError != nil {
+		service.ResetStatusCode(newAPIError, c.GetString("status_code_mapping"))
+		return nil, newAPIError
+	}
+	openAIUsage, _ := usage.(*dto.Usage)
+	if openAIUsage == nil {
+		openAIUsage = &dto.Usage{}
+	}
+	response := writer.finish(openAIUsage)
+	if store := info.ResponsesStore; store != nil && store.Save && response != nil {
+		store.Response, _ = common.Marshal(response)
+	}
+	return openAIUsage, nil
+}
+
+// doChatCompletionsRequest 以 Chat Completions 模式向适配器发送转换后的对话请求，
+// 调用方负责在处理完响应后恢复 info 中的 RelayMode、RelayFormat 与 Request
+func doChatCompletionsRequest(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, openAIRequest *dto.GeneralOpenAIRequest) (*http.Response, *types.NewAPIError) {
+	info.RelayMode = relayconstant.RelayModeChatCompletions
+	info.RelayFormat = types.RelayFormatOpenAI
+	info.Request = openAIRequest
+	if info.ToolCallEmulation {
+		service.EmulateToolCallRequest(openAIRequest)
+	}
+	// 部分适配器按原始请求路径拼接上游地址，转发期间改为对话接口路径
+	requestURLPath := info.RequestURLPath
+	info.RequestURLPath = "/v1/chat/completions"
+	defer func() {
+		info.RequestURLPath = requestURLPath
+	}()
+
+	convertedRequest, err := adaptor.ConvertOpenAIRequest(c, info, openAIRequest)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	jsonData, err := common.Marshal(convertedRequest)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	jsonData, err = relaycommon.RemoveDisabledFields(jsonData, info.ChannelOtherSettings)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	if len(info.ParamOverride) > 0 {
+		jsonData, err = relaycommon.ApplyParamOverride(jsonData, info.ParamOverride, relaycommon.BuildParamOverrideContext(info))
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid, types.ErrOptionWithSkipRetry())
+		}
+	}
+	if common.DebugEnabled {
+		println("requestBody: ", string(jsonData))
+	}
+
+	resp, err := adaptor.DoRequest(c, info, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	var httpResp *http.Response
+	if resp != nil {
+		httpResp = resp.(*http.Response)
+		info.IsStream = info.IsStream || strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream")
+		if httpResp.StatusCode != http.StatusOK {
+			newAPIError := service.RelayErrorHandler(c.Request.Context(), httpResp, false)
+			service.ResetStatusCode(newAPIError, c.GetString("status_code_mapping"))
+			return nil, newAPIError
+		}
+	}
+	return httpResp, nil
+}
@@ -0,0 +1,191 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 渠道未配置时，校验失败后最多重新请求的次数
+const defaultStructuredOutputMaxRetries = 2
+
+// structuredOutputRecorder 缓存适配器写出的非流式响应，待校验通过后再写给客户端
+type structuredOutputRecorder struct {
+	gin.ResponseWriter
+	buf    bytes.Buffer
+	status int
+}
+
+func (w *structuredOutputRecorder) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *structuredOutputRecorder) WriteHeaderNow() {}
+
+func (w *structuredOutputRecorder) Write(data []byte) (int, error) {
+	return w.buf.Write(data)
+}
+
+func (w *structuredOutputRecorder) WriteString(s string) (int, error) {
+	return w.buf.WriteString(s)
+}
+
+func (w *structuredOutputRecorder) Status() int {
+	return w.status
+}
+
+func (w *structuredOutputRecorder) Flush() {}
+
+// structuredOutputEmulationSchema 渠道开启结构化输出模拟且请求指定了 json_schema 时返回对应的 schema
+func structuredOutputEmulationSchema(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (*service.StructuredOutputSchema, error) {
+	if !info.ChannelSetting.StructuredOutputEmulation || request.ResponseFormat == nil {
+		return nil, nil
+	}
+	return service.ParseStructuredOutputSchema(request.ResponseFormat)
+}
+
+// structuredOutputViaEmulation 为不支持 json_schema 的上游模拟结构化输出：去除 response_format 并在系统提示词中注入 schema，
+// 以非流式请求上游并校验输出，校验失败时将错误反馈给模型重新请求，每次请求的用量累加计费
+func structuredOutputViaEmulation(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, request *dto.GeneralOpenAIRequest, schema *service.StructuredOutputSchema) (*dto.Usage, *types.NewAPIError) {
+	maxRetries := info.ChannelSetting.StructuredOutputMaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultStructuredOutputMaxRetries
+	}
+
+	// 需要完整输出才能校验，统一以非流式请求上游，结束后按客户端要求的格式输出
+	clientStream := request.Stream
+	originRequest := info.Request
+	defer func() {
+		info.IsStream = clientStream
+		info.Request = originRequest
+	}()
+	request.Stream = false
+	request.StreamOptions = nil
+	request.ResponseFormat = nil
+	info.IsStream = false
+	request.AppendSystemInstruction(schema.Instruction())
+
+	info.StructuredOutput = &relaycommon.StructuredOutputInfo{}
+	totalUsage := &dto.Usage{}
+	var response *dto.OpenAITextResponse
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		attemptResponse, usage, newAPIError := structuredOutputAttempt(c, info, adaptor, request)
+		if newAPIError != nil {
+			if response == nil {
+				return nil, newAPIError
+			}
+			// 重新请求失败时返回上一次的输出，已产生的用量照常计费
+			logger.LogWarn(c, fmt.Sprintf("structured output retry failed: %s", newAPIError.Error()))
+			break
+		}
+		info.StructuredOutput.Attempts++
+		addStructuredOutputUsage(totalUsage, usage)
+		response = attemptResponse
+
+		if len(response.Choices) == 0 {
+			break
+		}
+		content := response.Choices[0].Message.StringContent()
+		validated, err := schema.Validate(content)
+		if err == nil {
+			info.StructuredOutput.Valid = true
+			info.StructuredOutput.Repaired = validated != content
+			response.Choices[0].Message.SetStringContent(validated)
+			break
+		}
+		logger.LogInfo(c, fmt.Sprintf("structured output attempt %d failed validation: %s", attempt+1, err.Error()))
+		request.Messages = append(request.Messages,
+			dto.Message{Role: "assistant", Content: content},
+			dto.Message{Role: "user", Content: schema.RetryPrompt(err)},
+		)
+	}
+
+	response.Usage = *totalUsage
+	if clientStream {
+		writeStructuredOutputStream(c, info, response)
+	} else {
+		c.JSON(http.StatusOK, response)
+	}
+	return totalUsage, nil
+}
+
+// structuredOutputAttempt 请求一次上游并解析完整的非流式响应
+func structuredOutputAttempt(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, request *dto.GeneralOpenAIRequest) (*dto.OpenAITextResponse, *dto.Usage, *types.NewAPIError) {
+	httpResp, newAPIError := doChatCompletionsRequest(c, info, adaptor, request)
+	if newAPIError != nil {
+		return nil, nil, newAPIError
+	}
+	recorder := &structuredOutputRecorder{ResponseWriter: c.Writer, status: http.StatusOK}
+	c.Writer = recorder
+	usage, newAPIError := doChatResponse(c, info, adaptor, httpResp)
+	c.Writer = recorder.ResponseWriter
+	// 适配器可能已按上游响应设置了长度，最终输出内容会变化
+	c.Writer.Header().Del("Content-Length")
+	if newAPIError != nil {
+		service.ResetStatusCode(newAPIError, c.GetString("status_code_mapping"))
+		return nil, nil, newAPIError
+	}
+	var response dto.OpenAITextResponse
+	if err := common.Unmarshal(recorder.buf.Bytes(), &response); err != nil {
+		return nil, nil, types.NewOpenAIError(fmt.Errorf("failed to parse upstream response: %w", err), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	openAIUsage, _ := usage.(*dto.Usage)
+	if openAIUsage == nil {
+		openAIUsage = &response.Usage
+	}
+	return &response, openAIUsage, nil
+}
+
+func addStructuredOutputUsage(total *dto.Usage, usage *dto.Usage) {
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+	total.PromptTokensDetails.CachedTokens += usage.PromptTokensDetails.CachedTokens
+	total.PromptTokensDetails.TextTokens += usage.PromptTokensDetails.TextTokens
+	total.CompletionTokenDetails.TextTokens += usage.CompletionTokenDetails.TextTokens
+	total.CompletionTokenDetails.ReasoningTokens += usage.CompletionTokenDetails.ReasoningTokens
+}
+
+// writeStructuredOutputStream 将校验后的完整响应按流式分片输出
+func writeStructuredOutputStream(c *gin.Context, info *relaycommon.RelayInfo, response *dto.OpenAITextResponse) {
+	helper.SetEventStreamHeaders(c)
+	id := response.Id
+	if id == "" {
+		id = helper.GetResponseID(c)
+	}
+	createdAt := info.StartTime.Unix()
+	chunks := make([]*dto.ChatCompletionsStreamResponse, 0, 3)
+	finishReason := "stop"
+	if len(response.Choices) > 0 {
+		start := helper.GenerateStartEmptyResponse(id, createdAt, response.Model, nil)
+		start.Choices[0].Delta.SetContentString(response.Choices[0].Message.StringContent())
+		chunks = append(chunks, start)
+		if response.Choices[0].FinishReason != "" {
+			finishReason = response.Choices[0].FinishReason
+		}
+	}
+	chunks = append(chunks, helper.GenerateStopResponse(id, createdAt, response.Model, finishReason))
+	if info.ShouldIncludeUsage {
+		chunks = append(chunks, helper.GenerateFinalUsageResponse(id, createdAt, response.Model, response.Usage))
+	}
+	for _, chunk := range chunks {
+		if err := helper.ObjectData(c, chunk); err != nil {
+			logger.LogError(c, fmt.Sprintf("failed to write structured output stream: %s", err.Error()))
+			return
+		}
+	}
+	helper.Done(c)
+}
@@ -0,0 +1,208 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// initToolCallEmulation 模型元数据开启了函数调用模拟且请求携带工具定义时，由网关在提示词中描述工具，
+// 并从模型输出中解析工具调用。透传请求体时无法改写请求，不做模拟
+func initToolCallEmulation(info *relaycommon.RelayInfo, hasTools bool) {
+	passThrough := model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled
+	info.ToolCallEmulation = hasTools && !passThrough && model.IsModelToolCallEmulationEnabled(info.OriginModelName)
+}
+
+// toolCallEmulationWriter 将适配器写出的 Chat Completions 响应中的工具调用块转换为 tool_calls：
+// 流式响应逐个改写 data 分片，非流式响应先缓存，结束后整体改写
+type toolCallEmulationWriter struct {
+	gin.ResponseWriter
+	c        *gin.Context
+	stream   bool
+	parser   service.ToolCallStreamParser
+	buf      bytes.Buffer
+	status   int
+	template *dto.ChatCompletionsStreamResponse
+	finished bool
+}
+
+func newToolCallEmulationWriter(c *gin.Context, stream bool) *toolCallEmulationWriter {
+	return &toolCallEmulationWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		stream:         stream,
+		status:         http.StatusOK,
+	}
+}
+
+func (w *toolCallEmulationWriter) WriteHeader(code int) {
+	if w.stream {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *toolCallEmulationWriter) WriteHeaderNow() {
+	if w.stream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *toolCallEmulationWriter) Write(data []byte) (int, error) {
+	w.buf.Write(data)
+	if w.stream {
+		if err := translateChatStream(w.c, &w.buf, w.ResponseWriter, w.convertChunk); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *toolCallEmulationWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *toolCallEmulationWriter) Status() int {
+	if w.stream {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *toolCallEmulationWriter) Flush() {
+	if w.stream {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *toolCallEmulationWriter) writeChunk(chunk *dto.ChatCompletionsStreamResponse) error {
+	data, err := common.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	_, err = w.ResponseWriter.WriteString(fmt.Sprintf("data: %s\n\n", data))
+	return err
+}
+
+// textChunk 以最近一个分片为模板构造只包含文本的分片
+func (w *toolCallEmulationWriter) textChunk(text string) *dto.ChatCompletionsStreamResponse {
+	chunk := &dto.ChatCompletionsStreamResponse{Object: "chat.completion.chunk"}
+	if w.template != nil {
+		chunk.Id, chunk.Created, chunk.Model = w.template.Id, w.template.Created, w.template.Model
+	}
+	choice := dto.ChatCompletionsStreamResponseChoice{}
+	choice.Delta.SetContentString(text)
+	chunk.Choices = []dto.ChatCompletionsStreamResponseChoice{choice}
+	return chunk
+}
+
+func (w *toolCallEmulationWriter) convertChunk(chunk *dto.ChatCompletionsStreamResponse) error {
+	w.template = chunk
+	if len(chunk.Choices) == 0 {
+		return w.writeChunk(chunk)
+	}
+	choice := &chunk.Choices[0]
+	var text string
+	if choice.Delta.Content != nil {
+		var toolCalls []dto.ToolCallResponse
+		text, toolCalls = w.parser.Feed(*choice.Delta.Content)
+		choice.Delta.ToolCalls = append(choice.Delta.ToolCalls, toolCalls...)
+	}
+	if choice.FinishReason != nil {
+		// 结束时输出保留的文本，解析出工具调用时结束原因改为 tool_calls
+		w.finished = true
+		text += w.parser.Flush()
+		if w.parser.ToolCallCount() > 0 && *choice.FinishReason == "stop" {
+			choice.FinishReason = common.GetPointer("tool_calls")
+		}
+	}
+	choice.Delta.Content = nil
+	if text != "" {
+		choice.Delta.SetContentString(text)
+	}
+	delta := choice.Delta
+	if delta.Content == nil && delta.ReasoningContent == nil && delta.Reasoning == nil && delta.Role == "" &&
+		len(delta.ToolCalls) == 0 && choice.FinishReason == nil && chunk.Usage == nil && len(chunk.Choices) == 1 {
+		return nil
+	}
+	return w.writeChunk(chunk)
+}
+
+// finish 在适配器处理完响应后输出剩余内容：流式响应补发保留的文本与结束标记，非流式响应整体改写后写出
+func (w *toolCallEmulationWriter) finish() {
+	if w.stream {
+		if !w.finished {
+			if rest := w.parser.Flush(); rest != "" {
+				if err := w.writeChunk(w.textChunk(rest)); err != nil {
+					logger.LogError(w.c, fmt.Sprintf("failed to write tool call emulation chunk: %s", err.Error()))
+				}
+			}
+		}
+		if _, err := w.ResponseWriter.WriteString("data: [DONE]\n\n"); err != nil {
+			logger.LogError(w.c, fmt.Sprintf("failed to write tool call emulation chunk: %s", err.Error()))
+		}
+		w.ResponseWriter.Flush()
+		return
+	}
+	body := w.buf.Bytes()
+	var response dto.OpenAITextResponse
+	if err := common.Unmarshal(body, &response); err == nil {
+		for i := range response.Choices {
+			message := &response.Choices[i].Message
+			text, toolCalls := service.ParseEmulatedToolCalls(message.StringContent())
+			if len(toolCalls) == 0 {
+				continue
+			}
+			message.SetToolCalls(toolCalls)
+			message.Content = nil
+			if text != "" {
+				message.SetStringContent(text)
+			}
+			if response.Choices[i].FinishReason == "" || response.Choices[i].FinishReason == "stop" {
+				response.Choices[i].FinishReason = "tool_calls"
+			}
+		}
+		if converted, err := common.Marshal(response); err == nil {
+			body = converted
+		} else {
+			logger.LogError(w.c, fmt.Sprintf("failed to marshal tool call emulation response: %s", err.Error()))
+		}
+	} else {
+		logger.LogError(w.c, fmt.Sprintf("failed to parse chat completions response: %s", err.Error()))
+	}
+	w.ResponseWriter.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	if _, err := w.ResponseWriter.Write(body); err != nil {
+		logger.LogError(w.c, fmt.Sprintf("failed to write tool call emulation response: %s", err.Error()))
+	}
+}
+
+// doChatResponse 由适配器处理 Chat Completions 响应，模拟函数调用时从模型输出中解析工具调用
+func doChatResponse(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, httpResp *http.Response) (any, *types.NewAPIError) {
+	if !info.ToolCallEmulation {
+		return adaptor.DoResponse(c, httpResp, info)
+	}
+	writer := newToolCallEmulationWriter(c, info.IsStream)
+	c.Writer = writer
+	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	c.Writer = writer.ResponseWriter
+	if newAPIError == nil {
+		writer.finish()
+	}
+	return usage, newAPIError
+}
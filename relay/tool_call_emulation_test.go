@@ -0,0 +1,116 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestToolCallEmulationWriterStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	writer := newToolCallEmulationWriter(c, true)
+
+	// 开始标签被拆分到多个分片中
+	deltas := []string{"Let me check.", " <tool", "_call>\n{\"name\": \"get_weather\", ", "\"arguments\": {\"city\": \"Paris\"}}\n</tool_call>", "\n"}
+	for _, delta := range deltas {
+		chunk := dto.ChatCompletionsStreamResponse{Id: "chatcmpl-1", Object: "chat.completion.chunk", Model: "m"}
+		choice := dto.ChatCompletionsStreamResponseChoice{}
+		choice.Delta.SetContentString(delta)
+		chunk.Choices = []dto.ChatCompletionsStreamResponseChoice{choice}
+		writeTestChunk(t, writer, chunk)
+	}
+	stop := dto.ChatCompletionsStreamResponse{Id: "chatcmpl-1", Object: "chat.completion.chunk", Model: "m",
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{FinishReason: common.GetPointer("stop")}}}
+	writeTestChunk(t, writer, stop)
+	if _, err := writer.WriteString("data: [DONE]\n\n"); err != nil {
+		t.Fatal(err)
+	}
+	writer.finish()
+
+	var text strings.Builder
+	var toolCalls []dto.ToolCallResponse
+	var finishReason string
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		payload, ok := strings.CutPrefix(line, "data: ")
+		if !ok || payload == "[DONE]" {
+			continue
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			t.Fatalf("invalid chunk %q: %v", payload, err)
+		}
+		for _, choice := range chunk.Choices {
+			text.WriteString(choice.Delta.GetContentString())
+			toolCalls = append(toolCalls, choice.Delta.ToolCalls...)
+			if choice.FinishReason != nil {
+				finishReason = *choice.FinishReason
+			}
+		}
+	}
+	if !strings.HasSuffix(recorder.Body.String(), "data: [DONE]\n\n") {
+		t.Errorf("stream should end with [DONE], got %q", recorder.Body.String())
+	}
+	if got := strings.TrimSpace(text.String()); got != "Let me check." {
+		t.Errorf("text = %q", got)
+	}
+	if len(toolCalls) != 1 || toolCalls[0].Function.Name != "get_weather" || toolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Fatalf("tool calls = %+v", toolCalls)
+	}
+	if toolCalls[0].Index == nil || *toolCalls[0].Index != 0 || toolCalls[0].ID == "" {
+		t.Errorf("stream tool call should carry index and id, got %+v", toolCalls[0])
+	}
+	if finishReason != "tool_calls" {
+		t.Errorf("finish reason = %q", finishReason)
+	}
+}
+
+func TestToolCallEmulationWriterNonStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	writer := newToolCallEmulationWriter(c, false)
+
+	body := `{"id":"chatcmpl-1","object":"chat.completion","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"<tool_call>\n{\"name\": \"a\", \"arguments\": {}}\n</tool_call>\n<tool_call>\n{\"name\": \"b\", \"arguments\": \"{\\\"x\\\":1}\"}\n</tool_call>"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}}`
+	if _, err := writer.Write([]byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	writer.finish()
+
+	var response dto.OpenAITextResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	choice := response.Choices[0]
+	if choice.FinishReason != "tool_calls" {
+		t.Errorf("finish reason = %q", choice.FinishReason)
+	}
+	if choice.Message.Content != nil {
+		t.Errorf("content = %v, want null", choice.Message.Content)
+	}
+	var toolCalls []dto.ToolCallResponse
+	if err := json.Unmarshal(choice.Message.ToolCalls, &toolCalls); err != nil {
+		t.Fatal(err)
+	}
+	if len(toolCalls) != 2 || toolCalls[0].Function.Name != "a" || toolCalls[0].Function.Arguments != "{}" ||
+		toolCalls[1].Function.Name != "b" || toolCalls[1].Function.Arguments != `{"x":1}` || toolCalls[0].Index != nil {
+		t.Errorf("tool calls = %+v", toolCalls)
+	}
+}
+
+func writeTestChunk(t *testing.T, writer *toolCallEmulationWriter, chunk dto.ChatCompletionsStreamResponse) {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.WriteString("data: " + string(data) + "\n\n"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/QuantumNous/new-api/controller"
 	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
 
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
@@ -156,6 +157,7 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.POST("/fix", controller.FixChannelsAbilities)
 			channelRoute.GET("/fetch_models/:id", controller.FetchUpstreamModels)
 			channelRoute.POST("/fetch_models", controller.FetchModels)
+			channelRoute.POST("/script/test", controller.TestChannelScript)
 			channelRoute.POST("/ollama/pull", controller.OllamaPullModel)
 			channelRoute.POST("/ollama/pull/stream", controller.OllamaPullModelStream)
 			channelRoute.DELETE("/ollama/delete", controller.OllamaDeleteModel)
@@ -198,6 +200,52 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.DELETE("/invalid", controller.DeleteInvalidRedemption)
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
+		subscriptionRoute := apiRouter.Group("/subscription")
+		{
+			subscriptionRoute.GET("/plans", middleware.UserAuth(), controller.GetSubscriptionPlans)
+			subscriptionRoute.GET("/self", middleware.UserAuth(), controller.GetSelfSubscriptions)
+			subscriptionRoute.POST("/pay", middleware.UserAuth(), middleware.CriticalRateLimit(), controller.RequestSubscriptionPay)
+			subscriptionRoute.GET("/", middleware.AdminAuth(), controller.GetAllUserSubscriptions)
+			subscriptionRoute.GET("/plan", middleware.AdminAuth(), controller.GetAllSubscriptionPlans)
+			subscriptionRoute.POST("/plan", middleware.AdminAuth(), controller.AddSubscriptionPlan)
+			subscriptionRoute.PUT("/plan", middleware.AdminAuth(), controller.UpdateSubscriptionPlan)
+			subscriptionRoute.DELETE("/plan/:id", middleware.AdminAuth(), controller.DeleteSubscriptionPlan)
+		}
+		organizationRoute := apiRouter.Group("/organization")
+		{
+			organizationRoute.GET("/self", middleware.UserAuth(), controller.GetSelfOrganizations)
+			organizationRoute.POST("/", middleware.UserAuth(), controller.CreateOrganization)
+			organizationRoute.GET("/", middleware.AdminAuth(), controller.GetAllOrganizations)
+			organizationRoute.POST("/topup", middleware.AdminAuth(), controller.TopUpOrganization)
+			organizationRoute.PUT("/status", middleware.AdminAuth(), controller.UpdateOrganizationStatus)
+
+			orgManage := []string{model.OrganizationRoleAdmin}
+			orgBilling := []string{model.OrganizationRoleAdmin, model.OrganizationRoleBilling}
+			organizationRoute.GET("/:id", middleware.UserAuth(), middleware.OrganizationAuth(), controller.GetOrganization)
+			organizationRoute.PUT("/:id", middleware.UserAuth(), middleware.OrganizationAuth(orgManage...), controller.UpdateOrganization)
+			organizationRoute.GET("/:id/member", middleware.UserAuth(), middleware.OrganizationAuth(orgManage...), controller.GetOrganizationMembers)
+			organizationRoute.POST("/:id/member", middleware.UserAuth(), middleware.OrganizationAuth(orgManage...), controller.AddOrganizationMember)
+			organizationRoute.PUT("/:id/member", middleware.UserAuth(), middleware.OrganizationAuth(orgManage...), controller.UpdateOrganizationMember)
+			organizationRoute.DELETE("/:id/member/:member_id", middleware.UserAuth(), middleware.OrganizationAuth(orgManage...), controller.DeleteOrganizationMember)
+			organizationRoute.GET("/:id/token", middleware.UserAuth(), middleware.OrganizationAuth(orgManage...), controller.GetOrganizationTokens)
+			organizationRoute.DELETE("/:id/token/:token_id", middleware.UserAuth(), middleware.OrganizationAuth(orgManage...), controller.DeleteOrganizationToken)
+			organizationRoute.GET("/:id/log", middleware.UserAuth(), middleware.OrganizationAuth(orgBilling...), controller.GetOrganizationLogs)
+			organizationRoute.POST("/:id/fund", middleware.UserAuth(), middleware.CriticalRateLimit(), middleware.OrganizationAuth(orgBilling...), controller.FundOrganization)
+			organizationRoute.GET("/:id/statement/:period", middleware.UserAuth(), middleware.OrganizationAuth(orgBilling...), controller.GetOrganizationStatement)
+			organizationRoute.GET("/:id/statement/:period/download", middleware.UserAuth(), middleware.OrganizationAuth(orgBilling...), controller.DownloadOrganizationStatement)
+		}
+		statementRoute := apiRouter.Group("/statement")
+		{
+			statementRoute.GET("/self", middleware.UserAuth(), controller.GetSelfStatements)
+			statementRoute.GET("/self/:period", middleware.UserAuth(), controller.GetSelfStatement)
+			statementRoute.GET("/self/:period/download", middleware.UserAuth(), controller.DownloadSelfStatement)
+			statementRoute.GET("/", middleware.AdminAuth(), controller.GetAllStatements)
+			statementRoute.POST("/regenerate", middleware.AdminAuth(), controller.RegenerateStatement)
+			statementRoute.GET("/:id/download", middleware.AdminAuth(), controller.DownloadStatement)
+			statementRoute.GET("/lock", middleware.AdminAuth(), controller.GetStatementPeriodLocks)
+			statementRoute.POST("/lock", middleware.AdminAuth(), controller.LockStatementPeriod)
+			statementRoute.DELETE("/lock/:period", middleware.RootAuth(), controller.UnlockStatementPeriod)
+		}
 		logRoute := apiRouter.Group("/log")
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
@@ -0,0 +1,158 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 1x1 像素的 PNG
+const countTokensTestImage = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg=="
+
+func postCountTokens(engine *gin.Engine, token *model.Token, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-"+token.Key)
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestCountTokensResponseShape(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	// 本地计数不请求上游
+	var upstreamHits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits.Add(1)
+		writeChatCompletion(w, r)
+	}))
+	defer upstream.Close()
+	token, _ := createRelayFixture(t, "counttokens", upstream.URL)
+	engine := gin.New()
+	SetRelayRouter(engine)
+
+	tests := []struct {
+		name string
+		path string
+		// 仅含文本的请求，以及在其基础上增加工具与图片的请求
+		plain    string
+		extended string
+		// 响应中的计数字段，以及响应应包含的全部字段
+		countKey string
+		keys     []string
+	}{
+		{
+			name:  "claude",
+			path:  "/v1/messages/count_tokens",
+			plain: `{"model":"gpt-4o","messages":[{"role":"user","content":"What is the weather in Paris?"}]}`,
+			extended: `{"model":"gpt-4o","messages":[{"role":"user","content":[` +
+				`{"type":"text","text":"What is the weather in Paris?"},` +
+				`{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + countTokensTestImage + `"}}]}],` +
+				`"tools":[{"name":"get_weather","description":"Get the current weather of a city",` +
+				`"input_schema":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}]}`,
+			countKey: "input_tokens",
+			keys:     []string{"input_tokens"},
+		},
+		{
+			name:  "gemini",
+			path:  "/v1beta/models/gpt-4o:countTokens",
+			plain: `{"contents":[{"role":"user","parts":[{"text":"What is the weather in Paris?"}]}]}`,
+			extended: `{"contents":[{"role":"user","parts":[{"text":"What is the weather in Paris?"},` +
+				`{"inlineData":{"mimeType":"image/png","data":"` + countTokensTestImage + `"}}]}],` +
+				`"tools":[{"functionDeclarations":[{"name":"get_weather","description":"Get the current weather of a city",` +
+				`"parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}]}]}`,
+			countKey: "totalTokens",
+			keys:     []string{"totalTokens"},
+		},
+		{
+			name:  "responses",
+			path:  "/v1/responses/input_tokens",
+			plain: `{"model":"gpt-4o","input":"What is the weather in Paris?"}`,
+			extended: `{"model":"gpt-4o","input":[{"role":"user","content":[` +
+				`{"type":"input_text","text":"What is the weather in Paris?"},` +
+				`{"type":"input_image","image_url":"data:image/png;base64,` + countTokensTestImage + `"}]}],` +
+				`"tools":[{"type":"function","name":"get_weather","description":"Get the current weather of a city",` +
+				`"parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}]}`,
+			countKey: "input_tokens",
+			keys:     []string{"input_tokens", "object"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts := make([]float64, 0, 2)
+			for _, body := range []string{tt.plain, tt.extended} {
+				recorder := postCountTokens(engine, token, tt.path, body)
+				if recorder.Code != http.StatusOK {
+					t.Fatalf("status = %d, body = %s", recorder.Code, recorder.Body.String())
+				}
+				var resp map[string]any
+				if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("invalid response %s: %v", recorder.Body.String(), err)
+				}
+				keys := make([]string, 0, len(resp))
+				for key := range resp {
+					keys = append(keys, key)
+				}
+				slices.Sort(keys)
+				if !slices.Equal(keys, tt.keys) {
+					t.Fatalf("response keys = %v, want %v", keys, tt.keys)
+				}
+				if object, ok := resp["object"]; ok && object != "response.input_tokens" {
+					t.Fatalf("object = %v", object)
+				}
+				count, ok := resp[tt.countKey].(float64)
+				if !ok || count <= 0 {
+					t.Fatalf("%s = %v", tt.countKey, resp[tt.countKey])
+				}
+				counts = append(counts, count)
+			}
+			// 工具定义与图片计入输入 Token
+			if counts[1] <= counts[0] {
+				t.Fatalf("tokens with tools and images = %v, text only = %v", counts[1], counts[0])
+			}
+		})
+	}
+	if hits := upstreamHits.Load(); hits != 0 {
+		t.Fatalf("local counting sent %d upstream requests", hits)
+	}
+}
+
+func TestRelayGeminiDispatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	var upstreamPaths []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamPaths = append(upstreamPaths, r.URL.Path)
+		writeChatCompletion(w, r)
+	}))
+	defer upstream.Close()
+	token, _ := createRelayFixture(t, "geminidispatch", upstream.URL)
+	engine := gin.New()
+	SetRelayRouter(engine)
+
+	body := `{"contents":[{"role":"user","parts":[{"text":"hello"}]}]}`
+	// :countTokens 在网关本地计数，其余动作转发到渠道
+	recorder := postCountTokens(engine, token, "/v1beta/models/gpt-4o:countTokens", body)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"totalTokens"`) {
+		t.Fatalf("countTokens status = %d, body = %s", recorder.Code, recorder.Body.String())
+	}
+	if len(upstreamPaths) != 0 {
+		t.Fatalf("countTokens was relayed upstream: %v", upstreamPaths)
+	}
+	recorder = postCountTokens(engine, token, "/v1beta/models/gpt-4o:generateContent", body)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"candidates"`) {
+		t.Fatalf("generateContent status = %d, body = %s", recorder.Code, recorder.Body.String())
+	}
+	if len(upstreamPaths) != 1 || upstreamPaths[0] != "/v1/chat/completions" {
+		t.Fatalf("generateContent upstream paths = %v", upstreamPaths)
+	}
+}
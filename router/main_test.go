@@ -0,0 +1,110 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupTestDB 为每个测试创建独立的内存 SQLite 数据库并完成迁移
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	t.Setenv("SQL_DSN", "local")
+	t.Setenv("LOG_SQL_DSN", "")
+	originalPath := common.SQLitePath
+	common.SQLitePath = "file:" + strings.ReplaceAll(t.Name(), "/", "_") + "?mode=memory&cache=shared"
+	common.UsingMySQL = false
+	common.UsingPostgreSQL = false
+	common.RedisEnabled = false
+	common.IsMasterNode = true
+	if err := model.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.InitLogDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		common.SQLitePath = originalPath
+		if sqlDB, err := model.DB.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+}
+
+// createRelayFixture 创建用户、无限额度令牌与指向 upstreamURL 的 OpenAI 渠道，并初始化转发所需的全局配置
+func createRelayFixture(t *testing.T, name string, upstreamURL string) (*model.Token, *model.Channel) {
+	t.Helper()
+	constant.StreamingTimeout = 300
+	ratio_setting.InitRatioSettings()
+	if service.GetHttpClient() == nil {
+		service.InitHttpClient()
+	}
+	accessToken := "access-" + name
+	user := &model.User{
+		Username:    name,
+		Password:    "password",
+		DisplayName: name,
+		Role:        common.RoleCommonUser,
+		Status:      common.UserStatusEnabled,
+		Quota:       1000000,
+		Group:       "default",
+		AffCode:     "aff-" + name,
+		AccessToken: &accessToken,
+	}
+	if err := model.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	token := &model.Token{
+		UserId:         user.Id,
+		Name:           name,
+		Key:            name + common.GetRandomString(32),
+		Status:         common.TokenStatusEnabled,
+		ExpiredTime:    -1,
+		UnlimitedQuota: true,
+	}
+	if err := model.DB.Create(token).Error; err != nil {
+		t.Fatal(err)
+	}
+	channel := &model.Channel{
+		Type:    constant.ChannelTypeOpenAI,
+		Key:     "sk-upstream",
+		Name:    name,
+		Status:  common.ChannelStatusEnabled,
+		Models:  "gpt-4o",
+		Group:   "default",
+		BaseURL: &upstreamURL,
+	}
+	if err := channel.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	return token, channel
+}
+
+// writeChatCompletion 模拟上游返回的非流式 chat completions 响应
+func writeChatCompletion(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.Copy(io.Discard, r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, `{"id":"chatcmpl-test","object":"chat.completion","created":1,"model":"gpt-4o",`+
+		`"choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],`+
+		`"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`)
+}
+
+func sendChatCompletion(engine *gin.Engine, token *model.Token) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hello"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-"+token.Key)
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	return recorder
+}
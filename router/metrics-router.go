@@ -0,0 +1,12 @@
+package router
+
+import (
+	"github.com/QuantumNous/new-api/controller"
+	"github.com/QuantumNous/new-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetMetricsRouter(router *gin.Engine) {
+	router.GET("/metrics", middleware.MetricsAuth(), controller.Metrics)
+}
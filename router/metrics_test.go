@@ -0,0 +1,148 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setMetricsConfig(t *testing.T, enabled bool, token string, allowedIps []string) {
+	t.Helper()
+	originalEnabled, originalToken, originalIps := constant.MetricsEnabled, constant.MetricsToken, constant.MetricsAllowedIps
+	constant.MetricsEnabled = enabled
+	constant.MetricsToken = token
+	constant.MetricsAllowedIps = allowedIps
+	t.Cleanup(func() {
+		constant.MetricsEnabled, constant.MetricsToken, constant.MetricsAllowedIps = originalEnabled, originalToken, originalIps
+	})
+}
+
+func scrapeMetrics(engine *gin.Engine, remoteAddr string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.RemoteAddr = remoteAddr
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestMetricsRecordRelayRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	setMetricsConfig(t, true, "metrics-secret", nil)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeChatCompletion(w, r)
+	}))
+	defer upstream.Close()
+	token, channel := createRelayFixture(t, "metrics", upstream.URL)
+
+	engine := gin.New()
+	SetRelayRouter(engine)
+	SetMetricsRouter(engine)
+
+	labels := fmt.Sprintf(`channel_id="%d",group="default",model="gpt-4o"`, channel.Id)
+	series := []string{
+		fmt.Sprintf(`new_api_relay_requests_total{%s,relay_format="openai",status_code="200"}`, labels),
+		fmt.Sprintf(`new_api_relay_request_duration_seconds_count{%s,relay_format="openai",status_code="200"}`, labels),
+		fmt.Sprintf(`new_api_relay_tokens_total{%s,type="prompt"}`, labels),
+		fmt.Sprintf(`new_api_billing_quota_consumed_total{%s}`, labels),
+	}
+	// 消耗额度取决于模型倍率，只要求有增加
+	expected := map[string]float64{series[0]: 1, series[1]: 1, series[2]: 12, series[3]: 1}
+	before := scrapeMetricValues(t, engine, series)
+
+	recorder := sendChatCompletion(engine, token)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("relay status = %d, body = %s", recorder.Code, recorder.Body.String())
+	}
+
+	// 计费在请求返回后完成，等待指标写入；指标为进程内全局状态，按请求前后的差值校验
+	for deadline := time.Now().Add(3 * time.Second); ; {
+		after := scrapeMetricValues(t, engine, series)
+		missing := ""
+		for _, name := range series {
+			if after[name]-before[name] < expected[name] {
+				missing = name
+				break
+			}
+		}
+		if missing == "" {
+			for _, name := range series {
+				if delta := after[name] - before[name]; name != series[3] && delta != expected[name] {
+					t.Fatalf("%s increased by %v, want %v", name, delta, expected[name])
+				}
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s increased by %v, want %v", missing, after[missing]-before[missing], expected[missing])
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// scrapeMetricValues 抓取 /metrics 并返回指定序列的取值，不存在的序列为 0
+func scrapeMetricValues(t *testing.T, engine *gin.Engine, series []string) map[string]float64 {
+	t.Helper()
+	scraped := scrapeMetrics(engine, "203.0.113.10:1234", "metrics-secret")
+	if scraped.Code != http.StatusOK {
+		t.Fatalf("scrape status = %d", scraped.Code)
+	}
+	values := make(map[string]float64, len(series))
+	for _, line := range strings.Split(scraped.Body.String(), "\n") {
+		for _, name := range series {
+			if value, found := strings.CutPrefix(line, name+" "); found {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					t.Fatalf("invalid metric line %q", line)
+				}
+				values[name] = parsed
+			}
+		}
+	}
+	return values
+}
+
+func TestMetricsAccessControl(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	engine := gin.New()
+	SetMetricsRouter(engine)
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		token      string
+		allowedIps []string
+		remoteAddr string
+		bearer     string
+		want       int
+	}{
+		{name: "disabled", enabled: false, remoteAddr: "127.0.0.1:1234", want: http.StatusNotFound},
+		{name: "loopback by default", enabled: true, remoteAddr: "127.0.0.1:1234", want: http.StatusOK},
+		{name: "remote by default", enabled: true, remoteAddr: "203.0.113.10:1234", want: http.StatusForbidden},
+		{name: "valid token", enabled: true, token: "secret", remoteAddr: "203.0.113.10:1234", bearer: "secret", want: http.StatusOK},
+		{name: "invalid token", enabled: true, token: "secret", remoteAddr: "203.0.113.10:1234", bearer: "wrong", want: http.StatusForbidden},
+		{name: "token without loopback fallback", enabled: true, token: "secret", remoteAddr: "127.0.0.1:1234", want: http.StatusForbidden},
+		{name: "allowed cidr", enabled: true, allowedIps: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:1234", want: http.StatusOK},
+		{name: "outside allowed cidr", enabled: true, allowedIps: []string{"10.0.0.0/8"}, remoteAddr: "127.0.0.1:1234", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setMetricsConfig(t, tt.enabled, tt.token, tt.allowedIps)
+			if code := scrapeMetrics(engine, tt.remoteAddr, tt.bearer).Code; code != tt.want {
+				t.Fatalf("status = %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package router
 
 import (
+	"strings"
+
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/controller"
 	"github.com/QuantumNous/new-api/middleware"
@@ -16,6 +18,7 @@ func SetRelayRouter(router *gin.Engine) {
 	router.Use(middleware.CORS())
 	router.Use(middleware.DecompressRequestMiddleware())
 	router.Use(middleware.StatsMiddleware())
+	router.Use(middleware.Tracing())
 
 	// ============================================================
 	// 防呆设计：同时注册带 /v1 和不带 /v1 的路由
@@ -96,10 +99,17 @@ func registerGeminiRelayRouter(group *gin.RouterGroup) {
 	group.Use(middleware.Distribute())
 	{
 		// Gemini API 路径格式: /v1beta/models/{model_name}:{action}
-		group.POST("/models/*path", func(c *gin.Context) {
-			controller.Relay(c, types.RelayFormatGemini)
-		})
+		group.POST("/models/*path", relayGemini)
+	}
+}
+
+// relayGemini 转发 Gemini 原生接口，:countTokens 由网关本地计数
+func relayGemini(c *gin.Context) {
+	if strings.HasSuffix(c.Param("path"), ":countTokens") {
+		controller.CountTokens(c, types.RelayFormatGemini)
+		return
 	}
+	controller.Relay(c, types.RelayFormatGemini)
 }
 
 // registerModelsRouter 注册模型相关路由
@@ -142,6 +152,21 @@ func registerOpenAICompatibleRoutes(group *gin.RouterGroup) {
 		controller.Relay(c, types.RelayFormatOpenAIRealtime)
 	})
 
+	// 文件与 Batch 路由，不绑定渠道，无需 Distribute
+	fileRouter := group.Group("")
+	fileRouter.GET("/files", controller.ListFiles)
+	fileRouter.POST("/files", controller.UploadFile)
+	fileRouter.GET("/files/:id", controller.RetrieveFile)
+	fileRouter.DELETE("/files/:id", controller.DeleteFile)
+	fileRouter.GET("/files/:id/content", controller.RetrieveFileContent)
+	fileRouter.POST("/batches", controller.CreateBatch)
+	fileRouter.GET("/batches", controller.ListBatches)
+	fileRouter.GET("/batches/:id", controller.RetrieveBatch)
+	fileRouter.POST("/batches/:id/cancel", controller.CancelBatch)
+	// 网关保存的 Responses 会话状态
+	fileRouter.GET("/responses/:id", controller.RetrieveResponse)
+	fileRouter.DELETE("/responses/:id", controller.DeleteResponse)
+
 	// HTTP 路由
 	httpRouter := group.Group("")
 	httpRouter.Use(middleware.Distribute())
@@ -150,6 +175,9 @@ func registerOpenAICompatibleRoutes(group *gin.RouterGroup) {
 	httpRouter.POST("/messages", func(c *gin.Context) {
 		controller.Relay(c, types.RelayFormatClaude)
 	})
+	httpRouter.POST("/messages/count_tokens", func(c *gin.Context) {
+		controller.CountTokens(c, types.RelayFormatClaude)
+	})
 
 	// chat related routes
 	httpRouter.POST("/completions", func(c *gin.Context) {
@@ -163,6 +191,9 @@ func registerOpenAICompatibleRoutes(group *gin.RouterGroup) {
 	httpRouter.POST("/responses", func(c *gin.Context) {
 		controller.Relay(c, types.RelayFormatOpenAIResponses)
 	})
+	httpRouter.POST("/responses/input_tokens", func(c *gin.Context) {
+		controller.CountTokens(c, types.RelayFormatOpenAIResponses)
+	})
 
 	// image related routes
 	httpRouter.POST("/edits", func(c *gin.Context) {
@@ -200,9 +231,7 @@ func registerOpenAICompatibleRoutes(group *gin.RouterGroup) {
 	httpRouter.POST("/engines/:model/embeddings", func(c *gin.Context) {
 		controller.Relay(c, types.RelayFormatGemini)
 	})
-	httpRouter.POST("/models/*path", func(c *gin.Context) {
-		controller.Relay(c, types.RelayFormatGemini)
-	})
+	httpRouter.POST("/models/*path", relayGemini)
 
 	// other relay routes
 	httpRouter.POST("/moderations", func(c *gin.Context) {
@@ -211,11 +240,6 @@ func registerOpenAICompatibleRoutes(group *gin.RouterGroup) {
 
 	// not implemented
 	httpRouter.POST("/images/variations", controller.RelayNotImplemented)
-	httpRouter.GET("/files", controller.RelayNotImplemented)
-	httpRouter.POST("/files", controller.RelayNotImplemented)
-	httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
-	httpRouter.GET("/files/:id", controller.RelayNotImplemented)
-	httpRouter.GET("/files/:id/content", controller.RelayNotImplemented)
 	httpRouter.POST("/fine-tunes", controller.RelayNotImplemented)
 	httpRouter.GET("/fine-tunes", controller.RelayNotImplemented)
 	httpRouter.GET("/fine-tunes/:id", controller.RelayNotImplemented)
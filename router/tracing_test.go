@@ -0,0 +1,104 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/tracing"
+
+	"github.com/gin-gonic/gin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var spanExporter = tracetest.NewInMemoryExporter()
+
+// installTracing 全局 Tracer 只会委托给第一次设置的 TracerProvider，所有测试共用同一个内存导出器
+var installTracing = sync.OnceFunc(func() {
+	tracing.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter)))
+})
+
+func TestTracingRelayAttemptSpans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	installTracing()
+	spanExporter.Reset()
+	originalRetryTimes := common.RetryTimes
+	common.RetryTimes = 1
+	t.Cleanup(func() { common.RetryTimes = originalRetryTimes })
+
+	// 第一次请求上游失败，重试后成功
+	var mu sync.Mutex
+	var traceparents []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		attempt := len(traceparents)
+		mu.Unlock()
+		if attempt == 1 {
+			_, _ = io.Copy(io.Discard, r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = io.WriteString(w, `{"error":{"message":"upstream failed","type":"server_error"}}`)
+			return
+		}
+		writeChatCompletion(w, r)
+	}))
+	defer upstream.Close()
+	token, _ := createRelayFixture(t, "tracing", upstream.URL)
+
+	engine := gin.New()
+	SetRelayRouter(engine)
+	if recorder := sendChatCompletion(engine, token); recorder.Code != http.StatusOK {
+		t.Fatalf("relay status = %d, body = %s", recorder.Code, recorder.Body.String())
+	}
+
+	spans := spanExporter.GetSpans()
+	byId := make(map[string]tracetest.SpanStub, len(spans))
+	var distribute []tracetest.SpanStub
+	var attempts []tracetest.SpanStub
+	for _, span := range spans {
+		byId[span.SpanContext.SpanID().String()] = span
+		switch span.Name {
+		case "middleware.distribute":
+			distribute = append(distribute, span)
+		case "relay.attempt":
+			attempts = append(attempts, span)
+		}
+	}
+	if len(distribute) != 1 {
+		t.Fatalf("distribute spans = %d", len(distribute))
+	}
+	// 每次尝试各有一个 Span 且只导出一次，均挂在选渠道的 Span 下
+	if len(attempts) != 2 || attempts[0].SpanContext.SpanID() == attempts[1].SpanContext.SpanID() {
+		t.Fatalf("expected two distinct attempt spans, got %d", len(attempts))
+	}
+	for _, attempt := range attempts {
+		if attempt.Parent.SpanID() != distribute[0].SpanContext.SpanID() {
+			t.Fatalf("attempt span parent = %s, want distribute span %s", attempt.Parent.SpanID(), distribute[0].SpanContext.SpanID())
+		}
+	}
+
+	// 发往上游的 traceparent 指向对应尝试的 Span 或其子 Span
+	if len(traceparents) != 2 {
+		t.Fatalf("upstream requests = %d", len(traceparents))
+	}
+	for i, traceparent := range traceparents {
+		parts := strings.Split(traceparent, "-")
+		if len(parts) != 4 || parts[1] != attempts[i].SpanContext.TraceID().String() {
+			t.Fatalf("upstream request %d traceparent = %q", i, traceparent)
+		}
+		span, ok := byId[parts[2]]
+		for ok && span.Name != "relay.attempt" {
+			span, ok = byId[span.Parent.SpanID().String()]
+		}
+		if !ok || span.SpanContext.SpanID() != attempts[i].SpanContext.SpanID() {
+			t.Fatalf("upstream request %d traceparent %q is not under attempt %d", i, traceparent, i)
+		}
+	}
+}
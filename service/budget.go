@@ -0,0 +1,227 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// budgetAlertLevels 预算预警的百分比，从高到低排列
+var budgetAlertLevels = []int{100, 80, 50}
+
+type budgetWindow struct {
+	scope       string
+	scopeId     int
+	windowType  string
+	windowStart int64
+	limit       int
+}
+
+func (window budgetWindow) name() string {
+	scopeName := "账户"
+	switch window.scope {
+	case model.BudgetScopeToken:
+		scopeName = fmt.Sprintf("令牌 #%d ", window.scopeId)
+	case model.BudgetScopeOrganizationMember:
+		scopeName = "组织成员"
+	}
+	if window.windowType == model.BudgetWindowMonth {
+		return scopeName + "每月"
+	}
+	return scopeName + "每日"
+}
+
+func (window budgetWindow) resetTime() string {
+	return time.Unix(model.BudgetWindowEnd(window.windowType, window.windowStart), 0).Format("2006-01-02 15:04:05")
+}
+
+// spendBudgetWindows 返回本次请求需要统计的预算窗口。窗口按请求开始时间确定，
+// 跨越零点的请求的预扣费、结算与退款都计入同一个窗口
+func spendBudgetWindows(relayInfo *relaycommon.RelayInfo) []budgetWindow {
+	at := relayInfo.StartTime
+	if at.IsZero() {
+		at = time.Now()
+	}
+	windows := make([]budgetWindow, 0, 5)
+	add := func(scope string, scopeId int, windowType string, limit int) {
+		if limit <= 0 || scopeId == 0 {
+			return
+		}
+		windows = append(windows, budgetWindow{
+			scope:       scope,
+			scopeId:     scopeId,
+			windowType:  windowType,
+			windowStart: model.BudgetWindowStart(windowType, at),
+			limit:       limit,
+		})
+	}
+	if !relayInfo.IsPlayground {
+		add(model.BudgetScopeToken, relayInfo.TokenId, model.BudgetWindowDay, relayInfo.TokenBudget.Daily)
+		add(model.BudgetScopeToken, relayInfo.TokenId, model.BudgetWindowMonth, relayInfo.TokenBudget.Monthly)
+	}
+	add(model.BudgetScopeUser, relayInfo.UserId, model.BudgetWindowDay, relayInfo.UserSetting.DailyBudget)
+	add(model.BudgetScopeUser, relayInfo.UserId, model.BudgetWindowMonth, relayInfo.UserSetting.MonthlyBudget)
+	add(model.BudgetScopeOrganizationMember, relayInfo.TokenOrganization.MemberId, model.BudgetWindowMonth, relayInfo.TokenOrganization.MonthlyLimit)
+	return windows
+}
+
+// ReserveSpendBudget 检查令牌、用户与组织成员的日/月消费预算，并在各窗口中预留预估额度，
+// 窗口内已消费与已预留的额度加上预估额度超出预算时拒绝请求，进入下一个窗口后自动恢复。
+// 预留的额度在结算时按实际消费调整，请求结束时仍未结算的部分由 ReleaseSpendBudget 释放
+func ReserveSpendBudget(relayInfo *relaycommon.RelayInfo, estimatedQuota int) *types.NewAPIError {
+	estimatedQuota = max(estimatedQuota, 0)
+	windows := spendBudgetWindows(relayInfo)
+	for i, window := range windows {
+		reserved, spent, err := model.ReserveBudgetSpend(window.scope, window.scopeId, window.windowType, window.windowStart, estimatedQuota, window.limit)
+		if err == nil && reserved {
+			continue
+		}
+		// 回滚已预留的窗口
+		for _, previous := range windows[:i] {
+			if _, rollbackErr := model.AddBudgetSpend(previous.scope, previous.scopeId, previous.windowType, previous.windowStart, -estimatedQuota); rollbackErr != nil {
+				common.SysError(fmt.Sprintf("failed to roll back %s %d budget reservation: %s", previous.scope, previous.scopeId, rollbackErr.Error()))
+			}
+		}
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+		}
+		return types.NewErrorWithStatusCode(fmt.Errorf("%s消费预算不足, 已消费: %s, 预算: %s, 将于 %s 恢复",
+			window.name(), logger.FormatQuota(spent), logger.FormatQuota(window.limit), window.resetTime()),
+			types.ErrorCodeSpendBudgetExceeded, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+	}
+	if len(windows) > 0 {
+		relayInfo.BudgetReserved += estimatedQuota
+	}
+	return nil
+}
+
+// ReleaseSpendBudget 释放本次请求尚未结算的预留额度，可重复调用
+func ReleaseSpendBudget(relayInfo *relaycommon.RelayInfo) {
+	if relayInfo.BudgetReserved == 0 {
+		return
+	}
+	recordSpendBudget(relayInfo, -relayInfo.BudgetReserved, false)
+	relayInfo.BudgetReserved = 0
+}
+
+// settleSpendBudget 按实际扣除的额度结算消费预算，quota 为负数时表示退款。
+// 首次结算时抵扣预留的额度，之后的结算直接累加
+func settleSpendBudget(relayInfo *relaycommon.RelayInfo, quota int) {
+	recordSpendBudget(relayInfo, quota-relayInfo.BudgetReserved, quota > 0)
+	relayInfo.BudgetReserved = 0
+}
+
+// recordSpendBudget 将额度变化计入预算窗口，quota 为负数时表示退款或释放预留，alert 为 true 时检查是否需要发送预警
+func recordSpendBudget(relayInfo *relaycommon.RelayInfo, quota int, alert bool) {
+	if quota == 0 && !alert {
+		return
+	}
+	for _, window := range spendBudgetWindows(relayInfo) {
+		spent, err := model.AddBudgetSpend(window.scope, window.scopeId, window.windowType, window.windowStart, quota)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to record %s %d budget spend: %s", window.scope, window.scopeId, err.Error()))
+			continue
+		}
+		if alert {
+			checkBudgetAlert(relayInfo, window, spent)
+		}
+	}
+}
+
+// adjustTaskSpendBudget 异步任务提交后补扣或退款时调整提交时所在的预算窗口，quota 为负数时表示退款。
+// 任务不保存提交时的预算设置，只调整已有记录的窗口
+func adjustTaskSpendBudget(userId int, tokenId int, memberId int, submitTime time.Time, quota int) {
+	if quota == 0 {
+		return
+	}
+	scopes := []struct {
+		scope   string
+		scopeId int
+	}{
+		{model.BudgetScopeUser, userId},
+		{model.BudgetScopeToken, tokenId},
+		{model.BudgetScopeOrganizationMember, memberId},
+	}
+	for _, s := range scopes {
+		if s.scopeId == 0 {
+			continue
+		}
+		for _, windowType := range []string{model.BudgetWindowDay, model.BudgetWindowMonth} {
+			err := model.AdjustBudgetSpend(s.scope, s.scopeId, windowType, model.BudgetWindowStart(windowType, submitTime), quota)
+			if err != nil {
+				common.SysError(fmt.Sprintf("failed to adjust %s %d budget spend: %s", s.scope, s.scopeId, err.Error()))
+			}
+		}
+	}
+}
+
+// checkBudgetAlert 消费达到预算的 50%、80%、100% 时通知用户，每个窗口的每个比例只通知一次
+func checkBudgetAlert(relayInfo *relaycommon.RelayInfo, window budgetWindow, spent int) {
+	level := 0
+	for _, l := range budgetAlertLevels {
+		if spent*100 >= window.limit*l {
+			level = l
+			break
+		}
+	}
+	if level == 0 {
+		return
+	}
+	marked, err := model.MarkBudgetAlert(window.scope, window.scopeId, window.windowType, window.windowStart, level)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to mark %s %d budget alert: %s", window.scope, window.scopeId, err.Error()))
+		return
+	}
+	if !marked {
+		return
+	}
+	gopool.Go(func() {
+		prompt := fmt.Sprintf("%s消费预算已使用 %d%%", window.name(), level)
+		content := "{{value}}，已消费 {{value}}，预算 {{value}}，将于 {{value}} 重置。"
+		if level >= 100 {
+			content = "{{value}}，已消费 {{value}}，预算 {{value}}，请求将暂停至 {{value}}。"
+		}
+		values := []interface{}{prompt, logger.FormatQuota(spent), logger.FormatQuota(window.limit), window.resetTime()}
+		err := NotifyUser(relayInfo.UserId, relayInfo.UserEmail, relayInfo.UserSetting, dto.NewNotify(dto.NotifyTypeBudgetAlert, prompt, content, values))
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to send budget alert to user %d: %s", relayInfo.UserId, err.Error()))
+		}
+	})
+}
+
+// GetTokenBudgetUsage 返回令牌当前日/月窗口的预算与消费情况，未设置预算的窗口不统计消费
+func GetTokenBudgetUsage(token *model.Token) (map[string]any, error) {
+	now := time.Now()
+	usage := make(map[string]any, 2)
+	for windowType, limit := range map[string]int{
+		model.BudgetWindowDay:   token.DailyBudget,
+		model.BudgetWindowMonth: token.MonthlyBudget,
+	} {
+		windowStart := model.BudgetWindowStart(windowType, now)
+		spent := 0
+		if limit > 0 {
+			var err error
+			spent, err = model.GetBudgetSpend(model.BudgetScopeToken, token.Id, windowType, windowStart)
+			if err != nil {
+				return nil, err
+			}
+		}
+		usage[windowType] = map[string]any{
+			"budget":       limit,
+			"used":         spent,
+			"exhausted":    limit > 0 && spent >= limit,
+			"window_start": windowStart,
+			"resets_at":    model.BudgetWindowEnd(windowType, windowStart),
+		}
+	}
+	return usage, nil
+}
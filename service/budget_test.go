@@ -0,0 +1,186 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+func newBudgetRelayInfo(userId int, daily int, monthly int) *relaycommon.RelayInfo {
+	info := &relaycommon.RelayInfo{UserId: userId, StartTime: time.Now()}
+	info.UserSetting.DailyBudget = daily
+	info.UserSetting.MonthlyBudget = monthly
+	return info
+}
+
+func getUserBudgetSpend(t *testing.T, userId int, windowType string) int {
+	t.Helper()
+	spent, err := model.GetBudgetSpend(model.BudgetScopeUser, userId, windowType, model.BudgetWindowStart(windowType, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spent
+}
+
+func TestReserveSpendBudgetPreventsOvershoot(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "budget_overshoot", 0)
+
+	// 两个请求在结算前同时通过检查时，第二个请求不能超出预算
+	first := newBudgetRelayInfo(user.Id, 1000, 0)
+	if apiErr := ReserveSpendBudget(first, 600); apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	second := newBudgetRelayInfo(user.Id, 1000, 0)
+	apiErr := ReserveSpendBudget(second, 600)
+	if apiErr == nil || apiErr.GetErrorCode() != types.ErrorCodeSpendBudgetExceeded {
+		t.Fatalf("expected the second reservation to be rejected, got %v", apiErr)
+	}
+	if second.BudgetReserved != 0 {
+		t.Fatalf("rejected request reserved %d", second.BudgetReserved)
+	}
+
+	// 结算后按实际消费计入，释放多预留的部分
+	settleSpendBudget(first, 400)
+	ReleaseSpendBudget(first)
+	if spent := getUserBudgetSpend(t, user.Id, model.BudgetWindowDay); spent != 400 {
+		t.Fatalf("spent = %d", spent)
+	}
+	if apiErr := ReserveSpendBudget(second, 600); apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	// 请求失败时释放全部预留
+	ReleaseSpendBudget(second)
+	if spent := getUserBudgetSpend(t, user.Id, model.BudgetWindowDay); spent != 400 {
+		t.Fatalf("spent after release = %d", spent)
+	}
+}
+
+func TestReserveSpendBudgetThresholds(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "budget_thresholds", 0)
+
+	info := newBudgetRelayInfo(user.Id, 1000, 0)
+	if apiErr := ReserveSpendBudget(info, 500); apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	settleSpendBudget(info, 500)
+	spend := &model.BudgetSpend{}
+	if err := model.DB.Where("scope = ? AND scope_id = ?", model.BudgetScopeUser, user.Id).First(spend).Error; err != nil {
+		t.Fatal(err)
+	}
+	if spend.AlertLevel != 50 {
+		t.Fatalf("alert level = %d", spend.AlertLevel)
+	}
+
+	if apiErr := ReserveSpendBudget(newBudgetRelayInfo(user.Id, 1000, 0), 501); apiErr == nil {
+		t.Fatal("expected a reservation over the budget to be rejected")
+	}
+	// 恰好用满预算时允许，用满后预估额度为 0 的请求也被拒绝
+	info = newBudgetRelayInfo(user.Id, 1000, 0)
+	if apiErr := ReserveSpendBudget(info, 500); apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	settleSpendBudget(info, 500)
+	if apiErr := ReserveSpendBudget(newBudgetRelayInfo(user.Id, 1000, 0), 0); apiErr == nil {
+		t.Fatal("expected an exhausted budget to reject requests")
+	}
+}
+
+func TestReserveSpendBudgetRollsBackOtherWindows(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "budget_rollback", 0)
+
+	// 每日预算充足而每月预算不足时，已预留的每日窗口需要回滚
+	info := newBudgetRelayInfo(user.Id, 1000, 500)
+	if apiErr := ReserveSpendBudget(info, 600); apiErr == nil {
+		t.Fatal("expected the monthly budget to reject the request")
+	}
+	if spent := getUserBudgetSpend(t, user.Id, model.BudgetWindowDay); spent != 0 {
+		t.Fatalf("daily spend = %d", spent)
+	}
+}
+
+func TestTaskRefundReducesBudgetSpend(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "budget_task_refund", 1000)
+
+	// 提交任务时预留预算，扣费时结算
+	info := newBudgetRelayInfo(user.Id, 0, 1000)
+	info.TokenId = 8201
+	info.TokenBudget.Daily = 1000
+	info.ChannelMeta = &relaycommon.ChannelMeta{}
+	if apiErr := ReserveSpendBudget(info, 300); apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	if err := consumeUserQuota(info, 300); err != nil {
+		t.Fatal(err)
+	}
+	settleSpendBudget(info, 300)
+	task := model.InitTask("suno", info)
+	task.TaskID = "budget_task_refund"
+	task.Quota = 300
+	if err := task.Insert(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 按实际用量补扣后任务失败，全部额度退还并从预算中扣除
+	if err := ChargeTaskQuota(task, 100); err != nil {
+		t.Fatal(err)
+	}
+	task.Quota += 100
+	if spent := getUserBudgetSpend(t, user.Id, model.BudgetWindowMonth); spent != 400 {
+		t.Fatalf("monthly spend after extra charge = %d", spent)
+	}
+	if err := RefundTaskQuota(task, task.Quota); err != nil {
+		t.Fatal(err)
+	}
+	if spent := getUserBudgetSpend(t, user.Id, model.BudgetWindowMonth); spent != 0 {
+		t.Fatalf("monthly spend after refund = %d", spent)
+	}
+	tokenSpent, err := model.GetBudgetSpend(model.BudgetScopeToken, 8201, model.BudgetWindowDay, model.BudgetWindowStart(model.BudgetWindowDay, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokenSpent != 0 {
+		t.Fatalf("token daily spend after refund = %d", tokenSpent)
+	}
+	// 未设置预算的窗口不新增记录
+	if spent := getUserBudgetSpend(t, user.Id, model.BudgetWindowDay); spent != 0 {
+		t.Fatalf("daily spend = %d", spent)
+	}
+	if quota, err := model.GetUserQuota(user.Id, true); err != nil || quota != 1000 {
+		t.Fatalf("wallet = %d, err = %v", quota, err)
+	}
+}
+
+func TestReserveSpendBudgetOrganizationMemberLimit(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "budget_org_member", 0)
+
+	info := newBudgetRelayInfo(user.Id, 0, 0)
+	info.TokenOrganization = relaycommon.OrganizationInfo{Id: 1, MemberId: 8301, MonthlyLimit: 500}
+	if apiErr := ReserveSpendBudget(info, 400); apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	settleSpendBudget(info, 400)
+	// 成员每月限额按组织成员统计，超出后拒绝使用组织额度
+	if apiErr := ReserveSpendBudget(newBudgetRelayInfo(user.Id, 0, 0), 200); apiErr != nil {
+		t.Fatalf("personal requests should not use the member limit: %v", apiErr)
+	}
+	next := newBudgetRelayInfo(user.Id, 0, 0)
+	next.TokenOrganization = info.TokenOrganization
+	if apiErr := ReserveSpendBudget(next, 200); apiErr == nil {
+		t.Fatal("expected the member monthly limit to reject the request")
+	}
+	spent, err := model.GetBudgetSpend(model.BudgetScopeOrganizationMember, 8301, model.BudgetWindowMonth, model.BudgetWindowStart(model.BudgetWindowMonth, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent != 400 {
+		t.Fatalf("member spend = %d", spent)
+	}
+}
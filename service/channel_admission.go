@@ -0,0 +1,256 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/metrics"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+const channelSlotContextKey = "channel_slot"
+
+var (
+	errAdmissionQueueFull    = errors.New("admission queue is full")
+	errAdmissionWaitTimeout  = errors.New("timed out waiting for a free channel")
+	errAdmissionWaitCanceled = errors.New("request canceled while waiting for a free channel")
+)
+
+// channelSlot 请求占用的渠道并发槽位，可重复释放
+type channelSlot struct {
+	channelId int
+	once      sync.Once
+}
+
+func (s *channelSlot) release() {
+	s.once.Do(func() {
+		model.ReleaseChannelSlot(s.channelId)
+		admission.wake()
+	})
+}
+
+func holdChannelSlot(c *gin.Context, channel *model.Channel) bool {
+	if !model.TryAcquireChannelSlot(channel) {
+		return false
+	}
+	c.Set(channelSlotContextKey, &channelSlot{channelId: channel.Id})
+	return true
+}
+
+// ReleaseChannelSlot 释放当前请求占用的渠道并发槽位
+func ReleaseChannelSlot(c *gin.Context) {
+	value, ok := c.Get(channelSlotContextKey)
+	if !ok {
+		return
+	}
+	if slot, ok := value.(*channelSlot); ok && slot != nil {
+		slot.release()
+	}
+}
+
+// ClearChannelSlot 使上下文不再持有槽位（不释放），用于从请求上下文复制出的独立尝试
+func ClearChannelSlot(c *gin.Context) {
+	c.Set(channelSlotContextKey, (*channelSlot)(nil))
+}
+
+type admissionWaiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+}
+
+// admissionQueues 按分组与模型划分的等待队列，渠道槽位释放时按优先级唤醒队首请求
+type admissionQueues struct {
+	mu         sync.Mutex
+	seq        uint64
+	generation uint64
+	queues     map[string][]*admissionWaiter
+}
+
+var admission = &admissionQueues{queues: make(map[string][]*admissionWaiter)}
+
+func init() {
+	metrics.RegisterGaugeFunc("admission", "queue_waiting", "Number of requests waiting for a free channel slot.", func() float64 {
+		return float64(admission.waiting())
+	})
+}
+
+func (q *admissionQueues) currentGeneration() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.generation
+}
+
+func (q *admissionQueues) newWaiter(priority int) *admissionWaiter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.seq++
+	return &admissionWaiter{priority: priority, seq: q.seq}
+}
+
+// enqueue 按优先级与到达顺序加入队列；若在选择渠道之后已有槽位释放，则立即唤醒
+func (q *admissionQueues) enqueue(key string, w *admissionWaiter, maxSize int, generation uint64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	w.ready = make(chan struct{})
+	if q.generation != generation {
+		close(w.ready)
+		return true
+	}
+	waiters := q.queues[key]
+	if maxSize > 0 && len(waiters) >= maxSize {
+		return false
+	}
+	idx := sort.Search(len(waiters), func(i int) bool {
+		if waiters[i].priority != w.priority {
+			return waiters[i].priority < w.priority
+		}
+		return waiters[i].seq > w.seq
+	})
+	waiters = append(waiters, nil)
+	copy(waiters[idx+1:], waiters[idx:])
+	waiters[idx] = w
+	q.queues[key] = waiters
+	return true
+}
+
+// leave 放弃等待；若已被唤醒，则把唤醒机会转交给下一个等待者
+func (q *admissionQueues) leave(key string, w *admissionWaiter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	waiters := q.queues[key]
+	for i, waiter := range waiters {
+		if waiter == w {
+			q.setQueue(key, append(waiters[:i:i], waiters[i+1:]...))
+			return
+		}
+	}
+	q.wakeHead(key)
+}
+
+func (q *admissionQueues) setQueue(key string, waiters []*admissionWaiter) {
+	if len(waiters) == 0 {
+		delete(q.queues, key)
+		return
+	}
+	q.queues[key] = waiters
+}
+
+// wakeHead 唤醒队首请求，调用方需持有锁
+func (q *admissionQueues) wakeHead(key string) {
+	waiters := q.queues[key]
+	if len(waiters) == 0 {
+		return
+	}
+	close(waiters[0].ready)
+	q.setQueue(key, waiters[1:])
+}
+
+// wake 有槽位释放时唤醒每个队列的队首请求，被唤醒的请求重新选择渠道
+func (q *admissionQueues) wake() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.generation++
+	for key := range q.queues {
+		q.wakeHead(key)
+	}
+}
+
+func (q *admissionQueues) waiting() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	total := 0
+	for _, waiters := range q.queues {
+		total += len(waiters)
+	}
+	return total
+}
+
+func (q *admissionQueues) wait(c *gin.Context, key string, w *admissionWaiter, generation uint64, deadline time.Time, maxSize int) error {
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return errAdmissionWaitTimeout
+	}
+	if !q.enqueue(key, w, maxSize, generation) {
+		return errAdmissionQueueFull
+	}
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		return nil
+	case <-timer.C:
+		q.leave(key, w)
+		return errAdmissionWaitTimeout
+	case <-c.Request.Context().Done():
+		q.leave(key, w)
+		return errAdmissionWaitCanceled
+	}
+}
+
+type retryState struct {
+	retry          int
+	resetNextTry   bool
+	autoGroupIndex int
+}
+
+func saveRetryState(param *RetryParam) retryState {
+	return retryState{
+		retry:          param.GetRetry(),
+		resetNextTry:   param.resetNextTry,
+		autoGroupIndex: common.GetContextKeyInt(param.Ctx, constant.ContextKeyAutoGroupIndex),
+	}
+}
+
+// restore 重新选择渠道前恢复重试状态，避免排队后的重新选择跳过分组或优先级
+func (s retryState) restore(param *RetryParam) {
+	param.SetRetry(s.retry)
+	param.resetNextTry = s.resetNextTry
+	common.SetContextKey(param.Ctx, constant.ContextKeyAutoGroupIndex, s.autoGroupIndex)
+}
+
+// CacheGetRandomSatisfiedChannel 选择渠道并占用其并发槽位；所有渠道均达到最大并发数且启用了准入排队时，
+// 按用户分组优先级排队等待空闲槽位，超过最长等待时间后返回 model.ErrChannelsSaturated
+func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string, error) {
+	// 重试时先释放上一次尝试占用的槽位
+	ReleaseChannelSlot(param.Ctx)
+
+	setting := operation_setting.GetChannelAdmissionSetting()
+	state := saveRetryState(param)
+	var waiter *admissionWaiter
+	var deadline time.Time
+	queueKey := fmt.Sprintf("%s:%s", param.TokenGroup, param.ModelName)
+	for {
+		generation := admission.currentGeneration()
+		channel, selectGroup, err := cacheGetRandomSatisfiedChannel(param)
+		if err == nil && channel != nil {
+			if holdChannelSlot(param.Ctx, channel) {
+				return channel, selectGroup, nil
+			}
+			// 选中后槽位被其他请求抢先占满
+			channel, err = nil, model.ErrChannelsSaturated
+		}
+		if !errors.Is(err, model.ErrChannelsSaturated) || !setting.Enabled {
+			return channel, selectGroup, err
+		}
+		if waiter == nil {
+			userGroup := common.GetContextKeyString(param.Ctx, constant.ContextKeyUserGroup)
+			waiter = admission.newWaiter(operation_setting.GetAdmissionGroupPriority(userGroup))
+			deadline = time.Now().Add(time.Duration(setting.MaxWaitMs) * time.Millisecond)
+			logger.LogInfo(param.Ctx, fmt.Sprintf("分组 %s 下模型 %s 的渠道均已达到最大并发数，开始排队", param.TokenGroup, param.ModelName))
+		}
+		if waitErr := admission.wait(param.Ctx, queueKey, waiter, generation, deadline, setting.MaxQueueSize); waitErr != nil {
+			return nil, selectGroup, fmt.Errorf("%w: %s", model.ErrChannelsSaturated, waitErr.Error())
+		}
+		state.restore(param)
+	}
+}
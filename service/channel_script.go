@@ -0,0 +1,415 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/dop251/goja"
+	"github.com/gin-gonic/gin"
+)
+
+// 渠道转换脚本的执行阶段
+const (
+	ChannelScriptStageRequest  = "request"
+	ChannelScriptStageResponse = "response"
+	ChannelScriptStageChunk    = "chunk"
+)
+
+// 各阶段调用的脚本函数，脚本只需定义用到的函数
+var channelScriptFunctions = map[string]string{
+	ChannelScriptStageRequest:  "transformRequest",
+	ChannelScriptStageResponse: "transformResponse",
+	ChannelScriptStageChunk:    "transformChunk",
+}
+
+const (
+	channelScriptMaxCallStackSize = 256
+	// 单次内置函数调用可生成的字符串或数组的最大长度，避免脚本一次性申请大块内存，
+	// 逐步分配的内存由执行超时间接限制
+	channelScriptMaxAllocLength = 1 << 24
+	// 超过该大小的内容不交给脚本处理
+	channelScriptMaxPayloadBytes = 8 << 20
+)
+
+// channelScriptInvokerSource 解析 JSON 后调用脚本函数：返回 undefined 时沿用（可能已被原地修改的）传入对象，
+// 返回字符串时原样输出，返回 null 表示丢弃该内容（仅流式分片有效）
+const channelScriptInvokerSource = `(function (fn, payload, ctx) {
+	var body = JSON.parse(payload);
+	var result = fn(body, ctx);
+	if (result === undefined) {
+		result = body;
+	}
+	if (result === null) {
+		return null;
+	}
+	return typeof result === "string" ? result : JSON.stringify(result);
+})`
+
+var (
+	channelScriptInvokerProgram = goja.MustCompile("channel_script_invoker", channelScriptInvokerSource, true)
+	// 以脚本内容的摘要为键缓存编译结果
+	channelScriptPrograms sync.Map
+
+	errChannelScriptTimeout     = errors.New("script execution timed out")
+	errChannelScriptMemory      = errors.New("script allocation exceeds the limit")
+	errChannelScriptPayloadSize = errors.New("payload is too large for the script")
+)
+
+// ValidateChannelScript 检查脚本能否编译，用于保存渠道前校验
+func ValidateChannelScript(script string) error {
+	_, err := goja.Compile("transform_script", script, true)
+	return err
+}
+
+func compileChannelScript(script string) (*goja.Program, error) {
+	sum := sha256.Sum256([]byte(script))
+	key := hex.EncodeToString(sum[:])
+	if program, ok := channelScriptPrograms.Load(key); ok {
+		return program.(*goja.Program), nil
+	}
+	program, err := goja.Compile("transform_script", script, true)
+	if err != nil {
+		return nil, err
+	}
+	channelScriptPrograms.Store(key, program)
+	return program, nil
+}
+
+// ChannelScript 已加载的转换脚本实例，不能并发使用
+type ChannelScript struct {
+	vm      *goja.Runtime
+	invoker goja.Callable
+	context map[string]any
+	ctx     goja.Value
+	funcs   map[string]goja.Value
+	timeout time.Duration
+}
+
+// NewChannelScript 在独立的运行时中加载脚本，脚本没有文件、网络等宿主能力，
+// 顶层代码与每次函数调用都受 timeout 限制
+func NewChannelScript(script string, context map[string]any, timeout time.Duration) (*ChannelScript, error) {
+	program, err := compileChannelScript(script)
+	if err != nil {
+		return nil, err
+	}
+	return newChannelScript(program, context, timeout)
+}
+
+func newChannelScript(program *goja.Program, context map[string]any, timeout time.Duration) (*ChannelScript, error) {
+	vm := goja.New()
+	vm.SetMaxCallStackSize(channelScriptMaxCallStackSize)
+	limitChannelScriptAllocations(vm)
+	if context == nil {
+		context = make(map[string]any)
+	}
+	s := &ChannelScript{
+		vm:      vm,
+		context: context,
+		ctx:     vm.ToValue(context),
+		funcs:   make(map[string]goja.Value),
+		timeout: timeout,
+	}
+	if _, err := s.run(func() (goja.Value, error) { return vm.RunProgram(program) }); err != nil {
+		return nil, err
+	}
+	invoker, err := vm.RunProgram(channelScriptInvokerProgram)
+	if err != nil {
+		return nil, err
+	}
+	s.invoker, _ = goja.AssertFunction(invoker)
+	for stage, name := range channelScriptFunctions {
+		fn := vm.Get(name)
+		if _, ok := goja.AssertFunction(fn); ok {
+			s.funcs[stage] = fn
+		}
+	}
+	return s, nil
+}
+
+// limitChannelScriptAllocations 为可一次性生成超长字符串或数组的内置函数加上长度检查，
+// 这些函数在单次调用内完成分配，执行超时无法中断
+func limitChannelScriptAllocations(vm *goja.Runtime) {
+	lengthOf := func(value goja.Value) float64 {
+		if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+			return 0
+		}
+		return value.ToObject(vm).Get("length").ToFloat()
+	}
+	stringProto := vm.Get("String").ToObject(vm).Get("prototype").ToObject(vm)
+	limitChannelScriptBuiltin(vm, stringProto, "repeat", func(call goja.FunctionCall) float64 {
+		return float64(len(call.This.String())) * call.Argument(0).ToFloat()
+	})
+	for _, name := range []string{"padStart", "padEnd"} {
+		limitChannelScriptBuiltin(vm, stringProto, name, func(call goja.FunctionCall) float64 {
+			return call.Argument(0).ToFloat()
+		})
+	}
+	arrayCtor := vm.Get("Array").ToObject(vm)
+	limitChannelScriptBuiltin(vm, arrayCtor, "from", func(call goja.FunctionCall) float64 {
+		return lengthOf(call.Argument(0))
+	})
+	arrayProto := arrayCtor.Get("prototype").ToObject(vm)
+	for _, name := range []string{"fill", "join"} {
+		limitChannelScriptBuiltin(vm, arrayProto, name, func(call goja.FunctionCall) float64 {
+			return lengthOf(call.This)
+		})
+	}
+}
+
+func limitChannelScriptBuiltin(vm *goja.Runtime, object *goja.Object, name string, size func(call goja.FunctionCall) float64) {
+	original, ok := goja.AssertFunction(object.Get(name))
+	if !ok {
+		return
+	}
+	_ = object.Set(name, func(call goja.FunctionCall) goja.Value {
+		if size(call) > channelScriptMaxAllocLength {
+			panic(vm.NewGoError(errChannelScriptMemory))
+		}
+		result, err := original(call.This, call.Arguments...)
+		if err != nil {
+			panic(err)
+		}
+		return result
+	})
+}
+
+// Has 返回脚本是否定义了对应阶段的函数
+func (s *ChannelScript) Has(stage string) bool {
+	_, ok := s.funcs[stage]
+	return ok
+}
+
+// SetContext 设置传给脚本函数的上下文字段
+func (s *ChannelScript) SetContext(key string, value any) {
+	s.context[key] = value
+}
+
+// Run 以对应阶段的函数转换 JSON 内容，脚本未定义该函数时原样返回；dropped 为 true 表示脚本要求丢弃该内容
+func (s *ChannelScript) Run(stage string, payload []byte) (output []byte, dropped bool, err error) {
+	fn, ok := s.funcs[stage]
+	if !ok {
+		return payload, false, nil
+	}
+	if len(payload) > channelScriptMaxPayloadBytes {
+		return payload, false, errChannelScriptPayloadSize
+	}
+	result, err := s.run(func() (goja.Value, error) {
+		return s.invoker(goja.Undefined(), fn, s.vm.ToValue(string(payload)), s.ctx)
+	})
+	if err != nil {
+		return payload, false, err
+	}
+	if goja.IsNull(result) || goja.IsUndefined(result) {
+		return nil, true, nil
+	}
+	output = []byte(result.String())
+	if len(output) > channelScriptMaxPayloadBytes {
+		return payload, false, errChannelScriptPayloadSize
+	}
+	return output, false, nil
+}
+
+func (s *ChannelScript) run(f func() (goja.Value, error)) (goja.Value, error) {
+	timer := time.AfterFunc(s.timeout, func() {
+		s.vm.Interrupt(errChannelScriptTimeout)
+	})
+	defer func() {
+		timer.Stop()
+		s.vm.ClearInterrupt()
+	}()
+	value, err := f()
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		return nil, errChannelScriptTimeout
+	}
+	return value, err
+}
+
+// BuildChannelScriptContext 提供给脚本函数的上下文信息
+func BuildChannelScriptContext(info *relaycommon.RelayInfo) map[string]any {
+	context := map[string]any{
+		"model":        info.OriginModelName,
+		"relay_format": string(info.RelayFormat),
+		"stream":       info.IsStream,
+		"request_path": info.RequestURLPath,
+		"user_id":      info.UserId,
+		"group":        info.UsingGroup,
+	}
+	if info.ChannelMeta != nil {
+		context["upstream_model"] = info.UpstreamModelName
+		context["channel_id"] = info.ChannelId
+		context["channel_type"] = info.ChannelType
+	}
+	return context
+}
+
+// ChannelScriptSession 单次上游请求中渠道转换脚本的执行环境，请求、响应与流式分片共享脚本的全局状态。
+// 脚本出错或超时时沿用原始内容并记录错误，不影响请求本身。
+// 脚本仅作用于经 DoApiRequest 发出的 HTTP 请求，WebSocket（Realtime）、multipart 表单请求，
+// 以及通过 SDK 客户端访问上游的渠道（如 AK/SK 模式的 AWS Bedrock）不执行转换脚本
+type ChannelScriptSession struct {
+	c           *gin.Context
+	info        *relaycommon.RelayInfo
+	script      *ChannelScript
+	chunkFailed bool
+}
+
+// NewChannelScriptSession 渠道配置了转换脚本时创建执行环境，未配置、全局关闭或脚本加载失败时返回 nil
+func NewChannelScriptSession(c *gin.Context, info *relaycommon.RelayInfo) *ChannelScriptSession {
+	setting := operation_setting.GetChannelScriptSetting()
+	if !setting.Enabled || info.ChannelMeta == nil || strings.TrimSpace(info.TransformScript) == "" {
+		return nil
+	}
+	session := &ChannelScriptSession{c: c, info: info}
+	program, err := compileChannelScript(info.TransformScript)
+	if err == nil {
+		session.script, err = newChannelScript(program, BuildChannelScriptContext(info), setting.Timeout())
+	}
+	if err != nil {
+		session.report("load", err)
+		return nil
+	}
+	return session
+}
+
+func (s *ChannelScriptSession) report(stage string, err error) {
+	logger.LogWarn(s.c, fmt.Sprintf("channel #%d transform script failed at %s stage, original payload is kept: %s", s.info.ChannelId, stage, err.Error()))
+	s.info.ChannelScriptError = fmt.Sprintf("%s: %s", stage, err.Error())
+}
+
+// TransformRequest 转换发往上游的 JSON 请求体，非 JSON 请求体原样返回
+func (s *ChannelScriptSession) TransformRequest(body io.Reader) io.Reader {
+	if body == nil || !s.script.Has(ChannelScriptStageRequest) {
+		return body
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		s.report(ChannelScriptStageRequest, err)
+		return bytes.NewReader(data)
+	}
+	if !isJSONPayload(data) {
+		return bytes.NewReader(data)
+	}
+	output, dropped, err := s.script.Run(ChannelScriptStageRequest, data)
+	if err == nil && dropped {
+		err = errors.New("transformRequest must not return null")
+	}
+	if err != nil {
+		s.report(ChannelScriptStageRequest, err)
+		return bytes.NewReader(data)
+	}
+	return bytes.NewReader(output)
+}
+
+// TransformResponse 按响应类型替换上游响应体：SSE 响应逐个转换 data 分片，JSON 响应整体转换
+func (s *ChannelScriptSession) TransformResponse(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	s.script.SetContext("status_code", resp.StatusCode)
+	contentType := resp.Header.Get("Content-Type")
+	switch {
+	case strings.Contains(contentType, "text/event-stream"):
+		if s.script.Has(ChannelScriptStageChunk) {
+			resp.Body = &channelScriptStreamReader{session: s, body: resp.Body, reader: bufio.NewReader(resp.Body)}
+		}
+	case strings.Contains(contentType, "json"):
+		if !s.script.Has(ChannelScriptStageResponse) {
+			return
+		}
+		data, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err == nil && isJSONPayload(data) {
+			output, dropped, scriptErr := s.script.Run(ChannelScriptStageResponse, data)
+			if scriptErr == nil && dropped {
+				scriptErr = errors.New("transformResponse must not return null")
+			}
+			if scriptErr != nil {
+				s.report(ChannelScriptStageResponse, scriptErr)
+			} else {
+				data = output
+			}
+		} else if err != nil {
+			s.report(ChannelScriptStageResponse, err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		resp.ContentLength = int64(len(data))
+		resp.Header.Del("Content-Length")
+	}
+}
+
+// transformStreamLine 转换一行 SSE 内容，只处理 JSON 格式的 data 行，返回 nil 表示丢弃
+func (s *ChannelScriptSession) transformStreamLine(line []byte) []byte {
+	content := bytes.TrimRight(line, "\r\n")
+	payload, ok := bytes.CutPrefix(content, []byte("data:"))
+	if !ok {
+		return line
+	}
+	payload = bytes.TrimSpace(payload)
+	if string(payload) == "[DONE]" || !isJSONPayload(payload) {
+		return line
+	}
+	output, dropped, err := s.script.Run(ChannelScriptStageChunk, payload)
+	if err != nil {
+		// 同一请求中只记录第一次分片转换失败，避免刷屏
+		if !s.chunkFailed {
+			s.chunkFailed = true
+			s.report(ChannelScriptStageChunk, err)
+		}
+		return line
+	}
+	if dropped {
+		return nil
+	}
+	result := make([]byte, 0, len(output)+len(line)-len(content)+6)
+	result = append(result, "data: "...)
+	result = append(result, output...)
+	return append(result, line[len(content):]...)
+}
+
+func isJSONPayload(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && (data[0] == '{' || data[0] == '[')
+}
+
+// channelScriptStreamReader 按行读取上游 SSE 响应并转换 data 分片
+type channelScriptStreamReader struct {
+	session *ChannelScriptSession
+	body    io.ReadCloser
+	reader  *bufio.Reader
+	out     bytes.Buffer
+	err     error
+}
+
+func (r *channelScriptStreamReader) Read(p []byte) (int, error) {
+	for r.out.Len() == 0 && r.err == nil {
+		line, err := r.reader.ReadBytes('\n')
+		if len(line) > 0 {
+			r.out.Write(r.session.transformStreamLine(line))
+		}
+		if err != nil {
+			r.err = err
+		}
+	}
+	if r.out.Len() > 0 {
+		return r.out.Read(p)
+	}
+	return 0, r.err
+}
+
+func (r *channelScriptStreamReader) Close() error {
+	return r.body.Close()
+}
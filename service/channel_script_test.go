@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChannelScriptRun(t *testing.T) {
+	script, err := NewChannelScript(`
+		var calls = 0;
+		function transformRequest(body, ctx) {
+			calls++;
+			body.model = ctx.model;
+			body.calls = calls;
+		}
+		function transformChunk(chunk) {
+			return chunk.skip ? null : chunk;
+		}`, map[string]any{"model": "gpt-4o"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, dropped, err := script.Run(ChannelScriptStageRequest, []byte(`{"model":"alias"}`))
+	if err != nil || dropped {
+		t.Fatalf("run: %v, dropped %v", err, dropped)
+	}
+	if string(output) != `{"model":"gpt-4o","calls":1}` {
+		t.Fatalf("output = %s", output)
+	}
+	if _, dropped, _ = script.Run(ChannelScriptStageChunk, []byte(`{"skip":true}`)); !dropped {
+		t.Fatal("returning null should drop the chunk")
+	}
+	if output, _, _ = script.Run(ChannelScriptStageResponse, []byte(`{"a":1}`)); string(output) != `{"a":1}` {
+		t.Fatal("undefined stage should keep the payload")
+	}
+}
+
+func TestChannelScriptTimeout(t *testing.T) {
+	script, err := NewChannelScript(`function transformRequest(body) { while (true) {} }`, nil, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, _, err := script.Run(ChannelScriptStageRequest, []byte(`{}`))
+	if !errors.Is(err, errChannelScriptTimeout) || string(output) != `{}` {
+		t.Fatalf("expected timeout with original payload, got %s, %v", output, err)
+	}
+}
+
+func TestChannelScriptLimits(t *testing.T) {
+	for name, source := range map[string]string{
+		"repeat": `function transformRequest(body) { body.x = "abcd".repeat(1e8); }`,
+		"pad":    `function transformRequest(body) { body.x = "".padEnd(1e9, "x"); }`,
+		"fill":   `function transformRequest(body) { body.x = new Array(1e9).fill(0).length; }`,
+		"from":   `function transformRequest(body) { body.x = Array.from({length: 1e9}).length; }`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			script, err := NewChannelScript(source, nil, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := script.Run(ChannelScriptStageRequest, []byte(`{}`)); err == nil || !strings.Contains(err.Error(), errChannelScriptMemory.Error()) {
+				t.Fatalf("expected allocation limit error, got %v", err)
+			}
+		})
+	}
+
+	script, err := NewChannelScript(`function transformRequest(body) { body.ok = "ab".repeat(3); }`, nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, _, err := script.Run(ChannelScriptStageRequest, []byte(`{}`)); err != nil || string(output) != `{"ok":"ababab"}` {
+		t.Fatalf("small allocations should pass, got %s, %v", output, err)
+	}
+	if _, _, err := script.Run(ChannelScriptStageRequest, []byte(`"`+strings.Repeat("x", channelScriptMaxPayloadBytes)+`"`)); !errors.Is(err, errChannelScriptPayloadSize) {
+		t.Fatalf("expected payload size error, got %v", err)
+	}
+}
+
+func TestChannelScriptStackDepth(t *testing.T) {
+	script, err := NewChannelScript(`function f(n) { return f(n + 1); } function transformRequest(body) { f(0); }`, nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := script.Run(ChannelScriptStageRequest, []byte(`{}`)); err == nil {
+		t.Fatal("unbounded recursion should fail")
+	}
+}
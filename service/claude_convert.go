@@ -0,0 +1,188 @@
+package service
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ClaudeStreamConverter 将 Chat Completions 流式分片转换为 Claude Messages 流式事件：
+// thinking、text、tool_use 依次占用递增的内容块索引，结束时补发带用量的 message_delta 与 message_stop
+type ClaudeStreamConverter struct {
+	id          string
+	inputTokens int
+	started     bool
+	finished    bool
+	index       int
+	blockType   string
+	toolBlocks  map[int]int
+	hasToolUse  bool
+	stopReason  string
+}
+
+// NewClaudeStreamConverter 创建流式转换器，inputTokens 为 message_start 中的预估输入用量
+func NewClaudeStreamConverter(id string, inputTokens int) *ClaudeStreamConverter {
+	return &ClaudeStreamConverter{
+		id:          id,
+		inputTokens: inputTokens,
+		index:       -1,
+		toolBlocks:  make(map[int]int),
+	}
+}
+
+func (s *ClaudeStreamConverter) messageStart(model string) *dto.ClaudeResponse {
+	s.started = true
+	msg := &dto.ClaudeMediaMessage{
+		Id:    s.id,
+		Model: model,
+		Type:  "message",
+		Role:  "assistant",
+		Usage: &dto.ClaudeUsage{
+			InputTokens: s.inputTokens,
+		},
+	}
+	msg.SetContent(make([]any, 0))
+	return &dto.ClaudeResponse{
+		Type:    "message_start",
+		Message: msg,
+	}
+}
+
+// closeBlock 结束当前打开的内容块
+func (s *ClaudeStreamConverter) closeBlock() []*dto.ClaudeResponse {
+	if s.blockType == "" {
+		return nil
+	}
+	s.blockType = ""
+	return []*dto.ClaudeResponse{generateStopBlock(s.index)}
+}
+
+// openBlock 结束当前内容块并以下一个索引开始新的内容块
+func (s *ClaudeStreamConverter) openBlock(block *dto.ClaudeMediaMessage) []*dto.ClaudeResponse {
+	events := s.closeBlock()
+	s.index++
+	s.blockType = block.Type
+	start := &dto.ClaudeResponse{
+		Type:         "content_block_start",
+		ContentBlock: block,
+	}
+	start.SetIndex(s.index)
+	return append(events, start)
+}
+
+func (s *ClaudeStreamConverter) delta(index int, delta *dto.ClaudeMediaMessage) *dto.ClaudeResponse {
+	event := &dto.ClaudeResponse{
+		Type:  "content_block_delta",
+		Delta: delta,
+	}
+	event.SetIndex(index)
+	return event
+}
+
+// Convert 转换一个 Chat Completions 流式分片
+func (s *ClaudeStreamConverter) Convert(chunk *dto.ChatCompletionsStreamResponse) []*dto.ClaudeResponse {
+	if s.finished {
+		return nil
+	}
+	var events []*dto.ClaudeResponse
+	if !s.started {
+		events = append(events, s.messageStart(chunk.Model))
+	}
+	for _, choice := range chunk.Choices {
+		if reasoning := choice.Delta.GetReasoningContent(); reasoning != "" {
+			if s.blockType != "thinking" {
+				events = append(events, s.openBlock(&dto.ClaudeMediaMessage{
+					Type:     "thinking",
+					Thinking: common.GetPointer[string](""),
+				})...)
+			}
+			events = append(events, s.delta(s.index, &dto.ClaudeMediaMessage{
+				Type:     "thinking_delta",
+				Thinking: &reasoning,
+			}))
+		}
+		if content := choice.Delta.GetContentString(); content != "" {
+			if s.blockType != "text" {
+				events = append(events, s.openBlock(&dto.ClaudeMediaMessage{
+					Type: "text",
+					Text: common.GetPointer[string](""),
+				})...)
+			}
+			events = append(events, s.delta(s.index, &dto.ClaudeMediaMessage{
+				Type: "text_delta",
+				Text: &content,
+			}))
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			// 带 id 的分片开始新的工具调用，其余分片按 index 追加参数；缺少 index 时归入当前工具块
+			blockIndex, seen := -1, false
+			if toolCall.Index != nil {
+				blockIndex, seen = s.toolBlocks[*toolCall.Index]
+			}
+			if toolCall.ID != "" && !seen {
+				events = append(events, s.openBlock(&dto.ClaudeMediaMessage{
+					Id:    toolCall.ID,
+					Type:  "tool_use",
+					Name:  toolCall.Function.Name,
+					Input: map[string]interface{}{},
+				})...)
+				s.hasToolUse = true
+				blockIndex = s.index
+				if toolCall.Index != nil {
+					s.toolBlocks[*toolCall.Index] = blockIndex
+				}
+			} else if !seen {
+				if s.blockType != "tool_use" {
+					continue
+				}
+				blockIndex = s.index
+			}
+			if toolCall.Function.Arguments != "" {
+				arguments := toolCall.Function.Arguments
+				events = append(events, s.delta(blockIndex, &dto.ClaudeMediaMessage{
+					Type:        "input_json_delta",
+					PartialJson: &arguments,
+				}))
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.stopReason = stopReasonOpenAI2Claude(*choice.FinishReason)
+		}
+	}
+	return events
+}
+
+// Finish 结束流式响应，补发 message_delta（含停止原因与最终用量）和 message_stop
+func (s *ClaudeStreamConverter) Finish(usage *dto.Usage) []*dto.ClaudeResponse {
+	if s.finished {
+		return nil
+	}
+	s.finished = true
+	var events []*dto.ClaudeResponse
+	if !s.started {
+		events = append(events, s.messageStart(""))
+	}
+	events = append(events, s.closeBlock()...)
+
+	stopReason := s.stopReason
+	if stopReason == "" {
+		stopReason = "end_turn"
+	}
+	// 部分上游在返回工具调用时 finish_reason 仍为 stop
+	if stopReason == "end_turn" && s.hasToolUse {
+		stopReason = "tool_use"
+	}
+	if usage == nil {
+		usage = &dto.Usage{}
+	}
+	events = append(events, &dto.ClaudeResponse{
+		Type:  "message_delta",
+		Usage: claudeUsageFromOpenAI(usage),
+		Delta: &dto.ClaudeMediaMessage{
+			StopReason: common.GetPointer[string](stopReason),
+		},
+	})
+	events = append(events, &dto.ClaudeResponse{
+		Type: "message_stop",
+	})
+	return events
+}
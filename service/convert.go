@@ -18,6 +18,7 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 		MaxTokens:   claudeRequest.MaxTokens,
 		Temperature: claudeRequest.Temperature,
 		TopP:        claudeRequest.TopP,
+		TopK:        claudeRequest.TopK,
 		Stream:      claudeRequest.Stream,
 	}
 
@@ -53,6 +54,10 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 	tools, _ := common.Any2Type[[]dto.Tool](claudeRequest.Tools)
 	openAITools := make([]dto.ToolCallRequest, 0)
 	for _, claudeTool := range tools {
+		// 服务端工具（如 web_search）没有 input_schema，无法交由其他上游执行
+		if claudeTool.InputSchema == nil {
+			continue
+		}
 		openAITool := dto.ToolCallRequest{
 			Type: "function",
 			Function: dto.FunctionRequest{
@@ -65,6 +70,36 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 	}
 	openAIRequest.Tools = openAITools
 
+	if claudeRequest.ToolChoice != nil && len(openAITools) > 0 {
+		if toolChoice, err := common.Any2Type[dto.ClaudeToolChoice](claudeRequest.ToolChoice); err == nil {
+			switch toolChoice.Type {
+			case "auto":
+				openAIRequest.ToolChoice = "auto"
+			case "any":
+				openAIRequest.ToolChoice = "required"
+			case "none":
+				openAIRequest.ToolChoice = "none"
+			case "tool":
+				openAIRequest.ToolChoice = map[string]any{
+					"type":     "function",
+					"function": map[string]any{"name": toolChoice.Name},
+				}
+			}
+			if toolChoice.DisableParallelToolUse {
+				openAIRequest.ParallelTooCalls = common.GetPointer(false)
+			}
+		}
+	}
+
+	if len(claudeRequest.Metadata) > 0 {
+		var metadata struct {
+			UserId string `json:"user_id"`
+		}
+		if err := common.Unmarshal(claudeRequest.Metadata, &metadata); err == nil {
+			openAIRequest.User = metadata.UserId
+		}
+	}
+
 	// Convert messages
 	openAIMessages := make([]dto.Message, 0)
 
@@ -112,16 +147,15 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 			Role: claudeMessage.Role,
 		}
 
-		//log.Printf("claudeMessage.Content: %v", claudeMessage.Content)
 		if claudeMessage.IsStringContent() {
 			openAIMessage.SetStringContent(claudeMessage.GetStringContent())
 		} else {
-			content, err := claudeMessage.ParseContent()
+			contents, err := claudeMessage.ParseContent()
 			if err != nil {
 				return nil, err
 			}
-			contents := content
 			var toolCalls []dto.ToolCallRequest
+			var reasoning strings.Builder
 			mediaMessages := make([]dto.MediaContent, 0, len(contents))
 
 			for _, mediaMsg := range contents {
@@ -133,15 +167,15 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 						CacheControl: mediaMsg.CacheControl,
 					}
 					mediaMessages = append(mediaMessages, message)
-				case "image":
-					// Handle image conversion (base64 to URL or keep as is)
-					imageData := fmt.Sprintf("data:%s;base64,%s", mediaMsg.Source.MediaType, mediaMsg.Source.Data)
-					//textContent += fmt.Sprintf("[Image: %s]", imageData)
-					mediaMessage := dto.MediaContent{
-						Type:     "image_url",
-						ImageUrl: &dto.MessageImageUrl{Url: imageData},
+				case "image", "document":
+					if mediaMessage := claudeSourceToMediaContent(mediaMsg); mediaMessage != nil {
+						mediaMessages = append(mediaMessages, *mediaMessage)
+					}
+				case "thinking":
+					// 历史思考内容以 reasoning_content 回传，供支持的上游延续推理
+					if mediaMsg.Thinking != nil {
+						reasoning.WriteString(*mediaMsg.Thinking)
 					}
-					mediaMessages = append(mediaMessages, mediaMessage)
 				case "tool_use":
 					toolCall := dto.ToolCallRequest{
 						ID:   mediaMsg.Id,
@@ -153,7 +187,7 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 					}
 					toolCalls = append(toolCalls, toolCall)
 				case "tool_result":
-					// Add tool result as a separate message
+					// 工具结果作为单独的 tool 消息，其中的图片放入随后的用户消息
 					toolName := mediaMsg.Name
 					if toolName == "" {
 						toolName = claudeRequest.SearchToolNameByToolCallId(mediaMsg.ToolUseId)
@@ -163,14 +197,27 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 						Name:       &toolName,
 						ToolCallId: mediaMsg.ToolUseId,
 					}
-					//oaiToolMessage.SetStringContent(*mediaMsg.GetMediaContent().Text)
+					var resultText string
 					if mediaMsg.IsStringContent() {
-						oaiToolMessage.SetStringContent(mediaMsg.GetStringContent())
+						resultText = mediaMsg.GetStringContent()
 					} else {
-						mediaContents := mediaMsg.ParseMediaContent()
-						encodeJson, _ := common.Marshal(mediaContents)
-						oaiToolMessage.SetStringContent(string(encodeJson))
+						texts := make([]string, 0)
+						for _, resultContent := range mediaMsg.ParseMediaContent() {
+							switch resultContent.Type {
+							case "text":
+								texts = append(texts, resultContent.GetText())
+							case "image", "document":
+								if mediaMessage := claudeSourceToMediaContent(resultContent); mediaMessage != nil {
+									mediaMessages = append(mediaMessages, *mediaMessage)
+								}
+							}
+						}
+						resultText = strings.Join(texts, "\n")
 					}
+					if mediaMsg.IsError != nil && *mediaMsg.IsError {
+						resultText = "Error: " + resultText
+					}
+					oaiToolMessage.SetStringContent(resultText)
 					openAIMessages = append(openAIMessages, oaiToolMessage)
 				}
 			}
@@ -178,10 +225,12 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 			if len(toolCalls) > 0 {
 				openAIMessage.SetToolCalls(toolCalls)
 			}
-
-			if len(mediaMessages) > 0 && len(toolCalls) == 0 {
+			if len(mediaMessages) > 0 {
 				openAIMessage.SetMediaContent(mediaMessages)
 			}
+			if reasoning.Len() > 0 && claudeMessage.Role == "assistant" {
+				openAIMessage.ReasoningContent = reasoning.String()
+			}
 		}
 		if len(openAIMessage.ParseContent()) > 0 || len(openAIMessage.ToolCalls) > 0 {
 			openAIMessages = append(openAIMessages, openAIMessage)
@@ -193,6 +242,43 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 	return &openAIRequest, nil
 }
 
+// claudeSourceToMediaContent 将 Claude 的图片或文档块转换为 OpenAI 内容块，不支持的来源返回 nil
+func claudeSourceToMediaContent(mediaMsg dto.ClaudeMediaMessage) *dto.MediaContent {
+	source := mediaMsg.Source
+	if source == nil {
+		return nil
+	}
+	if source.Type == "text" {
+		return &dto.MediaContent{
+			Type:         dto.ContentTypeText,
+			Text:         common.Interface2String(source.Data),
+			CacheControl: mediaMsg.CacheControl,
+		}
+	}
+	url := source.Url
+	if source.Type == "base64" {
+		url = fmt.Sprintf("data:%s;base64,%s", source.MediaType, common.Interface2String(source.Data))
+	}
+	if url == "" {
+		return nil
+	}
+	if mediaMsg.Type == "document" {
+		if source.Type != "base64" {
+			return nil
+		}
+		return &dto.MediaContent{
+			Type:         dto.ContentTypeFile,
+			File:         &dto.MessageFile{FileData: url},
+			CacheControl: mediaMsg.CacheControl,
+		}
+	}
+	return &dto.MediaContent{
+		Type:         dto.ContentTypeImageURL,
+		ImageUrl:     &dto.MessageImageUrl{Url: url},
+		CacheControl: mediaMsg.CacheControl,
+	}
+}
+
 func generateStopBlock(index int) *dto.ClaudeResponse {
 	return &dto.ClaudeResponse{
 		Type:  "content_block_stop",
@@ -508,37 +594,60 @@ func ResponseOpenAI2Claude(openAIResponse *dto.OpenAITextResponse, info *relayco
 	}
 	for _, choice := range openAIResponse.Choices {
 		stopReason = stopReasonOpenAI2Claude(choice.FinishReason)
-		if choice.FinishReason == "tool_calls" {
-			for _, toolUse := range choice.Message.ParseToolCalls() {
-				claudeContent := dto.ClaudeMediaMessage{}
-				claudeContent.Type = "tool_use"
-				claudeContent.Id = toolUse.ID
-				claudeContent.Name = toolUse.Function.Name
-				var mapParams map[string]interface{}
-				if err := common.Unmarshal([]byte(toolUse.Function.Arguments), &mapParams); err == nil {
-					claudeContent.Input = mapParams
-				} else {
-					claudeContent.Input = toolUse.Function.Arguments
-				}
-				contents = append(contents, claudeContent)
-			}
-		} else {
+		reasoning := choice.Message.ReasoningContent
+		if reasoning == "" {
+			reasoning = choice.Message.Reasoning
+		}
+		if reasoning != "" {
+			contents = append(contents, dto.ClaudeMediaMessage{
+				Type:     "thinking",
+				Thinking: common.GetPointer[string](reasoning),
+			})
+		}
+		toolCalls := choice.Message.ParseToolCalls()
+		if text := choice.Message.StringContent(); text != "" || len(toolCalls) == 0 {
 			claudeContent := dto.ClaudeMediaMessage{}
 			claudeContent.Type = "text"
-			claudeContent.SetText(choice.Message.StringContent())
+			claudeContent.SetText(text)
+			contents = append(contents, claudeContent)
+		}
+		for _, toolUse := range toolCalls {
+			claudeContent := dto.ClaudeMediaMessage{}
+			claudeContent.Type = "tool_use"
+			claudeContent.Id = toolUse.ID
+			claudeContent.Name = toolUse.Function.Name
+			var mapParams map[string]interface{}
+			if err := common.Unmarshal([]byte(toolUse.Function.Arguments), &mapParams); err == nil {
+				claudeContent.Input = mapParams
+			} else {
+				claudeContent.Input = toolUse.Function.Arguments
+			}
 			contents = append(contents, claudeContent)
 		}
+		// 部分上游在返回工具调用时 finish_reason 仍为 stop
+		if len(toolCalls) > 0 && stopReason == "end_turn" {
+			stopReason = "tool_use"
+		}
 	}
 	claudeResponse.Content = contents
 	claudeResponse.StopReason = stopReason
-	claudeResponse.Usage = &dto.ClaudeUsage{
-		InputTokens:  openAIResponse.PromptTokens,
-		OutputTokens: openAIResponse.CompletionTokens,
-	}
+	claudeResponse.Usage = claudeUsageFromOpenAI(&openAIResponse.Usage)
 
 	return claudeResponse
 }
 
+// claudeUsageFromOpenAI 转换用量，Claude 的 input_tokens 不包含缓存读取与写入的部分
+func claudeUsageFromOpenAI(usage *dto.Usage) *dto.ClaudeUsage {
+	cacheRead := usage.PromptTokensDetails.CachedTokens
+	cacheCreation := usage.PromptTokensDetails.CachedCreationTokens
+	return &dto.ClaudeUsage{
+		InputTokens:              max(usage.PromptTokens-cacheRead-cacheCreation, 0),
+		OutputTokens:             usage.CompletionTokens,
+		CacheReadInputTokens:     cacheRead,
+		CacheCreationInputTokens: cacheCreation,
+	}
+}
+
 func stopReasonOpenAI2Claude(reason string) string {
 	switch reason {
 	case "stop":
@@ -570,6 +679,10 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 		Stream: info.IsStream,
 	}
 
+	// Gemini 的 functionCall 与 functionResponse 按函数名顺序对应，为其生成全局唯一的调用 ID
+	callCount := 0
+	pendingCalls := make(map[string][]string)
+
 	// 转换 messages
 	var messages []dto.Message
 	for _, content := range geminiRequest.Contents {
@@ -580,49 +693,57 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 		// 处理 parts
 		var mediaContents []dto.MediaContent
 		var toolCalls []dto.ToolCallRequest
+		var reasoning []string
 		for _, part := range content.Parts {
-			if part.Text != "" {
+			if part.Thought {
+				// 思考内容仅在模型轮次中作为 reasoning_content 回传
+				if part.Text != "" && message.Role == "assistant" {
+					reasoning = append(reasoning, part.Text)
+				}
+			} else if part.Text != "" {
 				mediaContent := dto.MediaContent{
-					Type: "text",
+					Type: dto.ContentTypeText,
 					Text: part.Text,
 				}
 				mediaContents = append(mediaContents, mediaContent)
 			} else if part.InlineData != nil {
-				mediaContent := dto.MediaContent{
-					Type: "image_url",
-					ImageUrl: &dto.MessageImageUrl{
-						Url:      fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data),
-						Detail:   "auto",
-						MimeType: part.InlineData.MimeType,
-					},
-				}
-				mediaContents = append(mediaContents, mediaContent)
+				mediaContents = append(mediaContents, geminiInlineDataToMediaContent(part.InlineData))
 			} else if part.FileData != nil {
-				mediaContent := dto.MediaContent{
-					Type: "image_url",
-					ImageUrl: &dto.MessageImageUrl{
-						Url:      part.FileData.FileUri,
-						Detail:   "auto",
-						MimeType: part.FileData.MimeType,
-					},
+				mediaContent, err := geminiFileDataToMediaContent(part.FileData)
+				if err != nil {
+					return nil, err
 				}
 				mediaContents = append(mediaContents, mediaContent)
 			} else if part.FunctionCall != nil {
 				// 处理 Gemini 的工具调用
+				callCount++
+				callId := fmt.Sprintf("call_%d", callCount)
+				name := part.FunctionCall.FunctionName
+				pendingCalls[name] = append(pendingCalls[name], callId)
 				toolCall := dto.ToolCallRequest{
-					ID:   fmt.Sprintf("call_%d", len(toolCalls)+1), // 生成唯一ID
+					ID:   callId,
 					Type: "function",
 					Function: dto.FunctionRequest{
-						Name:      part.FunctionCall.FunctionName,
+						Name:      name,
 						Arguments: toJSONString(part.FunctionCall.Arguments),
 					},
 				}
 				toolCalls = append(toolCalls, toolCall)
 			} else if part.FunctionResponse != nil {
-				// 处理 Gemini 的工具响应，创建单独的 tool 消息
+				// 处理 Gemini 的工具响应，创建单独的 tool 消息，并对应到同名函数最早未响应的调用
+				name := part.FunctionResponse.Name
+				var callId string
+				if queue := pendingCalls[name]; len(queue) > 0 {
+					callId = queue[0]
+					pendingCalls[name] = queue[1:]
+				} else {
+					callCount++
+					callId = fmt.Sprintf("call_%d", callCount)
+				}
 				toolMessage := dto.Message{
 					Role:       "tool",
-					ToolCallId: fmt.Sprintf("call_%d", len(toolCalls)), // 使用对应的调用ID
+					Name:       &name,
+					ToolCallId: callId,
 				}
 				toolMessage.SetStringContent(toJSONString(part.FunctionResponse.Response))
 				messages = append(messages, toolMessage)
@@ -630,16 +751,19 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 		}
 
 		// 设置消息内容
-		if len(toolCalls) > 0 {
-			// 如果有工具调用，设置工具调用
-			message.SetToolCalls(toolCalls)
-		} else if len(mediaContents) == 1 && mediaContents[0].Type == "text" {
+		if len(mediaContents) == 1 && mediaContents[0].Type == dto.ContentTypeText {
 			// 如果只有一个文本内容，直接设置字符串
-			message.Content = mediaContents[0].Text
+			message.SetStringContent(mediaContents[0].Text)
 		} else if len(mediaContents) > 0 {
 			// 如果有多个内容或包含媒体，设置为数组
 			message.SetMediaContent(mediaContents)
 		}
+		if len(toolCalls) > 0 {
+			message.SetToolCalls(toolCalls)
+		}
+		if len(reasoning) > 0 {
+			message.ReasoningContent = strings.Join(reasoning, "")
+		}
 
 		// 只有当消息有内容或工具调用时才添加
 		if len(message.ParseContent()) > 0 || len(message.ToolCalls) > 0 {
@@ -649,43 +773,62 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 
 	openaiRequest.Messages = messages
 
-	if geminiRequest.GenerationConfig.Temperature != nil {
-		openaiRequest.Temperature = geminiRequest.GenerationConfig.Temperature
+	generationConfig := geminiRequest.GenerationConfig
+	if generationConfig.Temperature != nil {
+		openaiRequest.Temperature = generationConfig.Temperature
 	}
-	if geminiRequest.GenerationConfig.TopP > 0 {
-		openaiRequest.TopP = geminiRequest.GenerationConfig.TopP
+	if generationConfig.TopP > 0 {
+		openaiRequest.TopP = generationConfig.TopP
 	}
-	if geminiRequest.GenerationConfig.TopK > 0 {
-		openaiRequest.TopK = int(geminiRequest.GenerationConfig.TopK)
+	if generationConfig.TopK > 0 {
+		openaiRequest.TopK = int(generationConfig.TopK)
 	}
-	if geminiRequest.GenerationConfig.MaxOutputTokens > 0 {
-		openaiRequest.MaxTokens = geminiRequest.GenerationConfig.MaxOutputTokens
+	if generationConfig.MaxOutputTokens > 0 {
+		openaiRequest.MaxTokens = generationConfig.MaxOutputTokens
 	}
 	// gemini stop sequences 最多 5 个，openai stop 最多 4 个
-	if len(geminiRequest.GenerationConfig.StopSequences) > 0 {
-		openaiRequest.Stop = geminiRequest.GenerationConfig.StopSequences[:4]
+	if len(generationConfig.StopSequences) > 0 {
+		openaiRequest.Stop = generationConfig.StopSequences[:min(len(generationConfig.StopSequences), 4)]
+	}
+	if generationConfig.CandidateCount > 0 {
+		openaiRequest.N = generationConfig.CandidateCount
+	}
+	if generationConfig.PresencePenalty != nil {
+		openaiRequest.PresencePenalty = float64(*generationConfig.PresencePenalty)
 	}
-	if geminiRequest.GenerationConfig.CandidateCount > 0 {
-		openaiRequest.N = geminiRequest.GenerationConfig.CandidateCount
+	if generationConfig.FrequencyPenalty != nil {
+		openaiRequest.FrequencyPenalty = float64(*generationConfig.FrequencyPenalty)
 	}
+	if generationConfig.Seed != 0 {
+		openaiRequest.Seed = float64(generationConfig.Seed)
+	}
+	responseFormat, err := geminiResponseFormatToOpenAI(&generationConfig)
+	if err != nil {
+		return nil, err
+	}
+	openaiRequest.ResponseFormat = responseFormat
 
 	// 转换工具调用
 	if len(geminiRequest.GetTools()) > 0 {
 		var tools []dto.ToolCallRequest
 		for _, tool := range geminiRequest.GetTools() {
 			if tool.FunctionDeclarations != nil {
-				functionDeclarations, err := common.Any2Type[[]dto.FunctionRequest](tool.FunctionDeclarations)
+				functionDeclarations, err := common.Any2Type[[]geminiFunctionDeclaration](tool.FunctionDeclarations)
 				if err != nil {
 					common.SysError(fmt.Sprintf("failed to parse gemini function declarations: %v (type=%T)", err, tool.FunctionDeclarations))
 					continue
 				}
 				for _, function := range functionDeclarations {
+					parameters := function.ParametersJsonSchema
+					if parameters == nil {
+						parameters = geminiSchemaToJSONSchema(function.Parameters)
+					}
 					openAITool := dto.ToolCallRequest{
 						Type: "function",
 						Function: dto.FunctionRequest{
 							Name:        function.Name,
 							Description: function.Description,
-							Parameters:  function.Parameters,
+							Parameters:  parameters,
 						},
 					}
 					tools = append(tools, openAITool)
@@ -694,6 +837,7 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 		}
 		if len(tools) > 0 {
 			openaiRequest.Tools = tools
+			openaiRequest.ToolChoice = geminiToolConfigToOpenAI(geminiRequest.ToolConfig)
 		}
 	}
 
@@ -736,176 +880,40 @@ func extractTextFromGeminiParts(parts []dto.GeminiPart) string {
 // ResponseOpenAI2Gemini 将 OpenAI 响应转换为 Gemini 格式
 func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relaycommon.RelayInfo) *dto.GeminiChatResponse {
 	geminiResponse := &dto.GeminiChatResponse{
-		Candidates: make([]dto.GeminiChatCandidate, 0, len(openAIResponse.Choices)),
-		UsageMetadata: dto.GeminiUsageMetadata{
-			PromptTokenCount:     openAIResponse.PromptTokens,
-			CandidatesTokenCount: openAIResponse.CompletionTokens,
-			TotalTokenCount:      openAIResponse.PromptTokens + openAIResponse.CompletionTokens,
-		},
+		Candidates:    make([]dto.GeminiChatCandidate, 0, len(openAIResponse.Choices)),
+		UsageMetadata: geminiUsageFromOpenAI(&openAIResponse.Usage),
 	}
 
 	for _, choice := range openAIResponse.Choices {
+		finishReason := finishReasonOpenAI2Gemini(choice.FinishReason)
 		candidate := dto.GeminiChatCandidate{
 			Index:         int64(choice.Index),
+			FinishReason:  &finishReason,
 			SafetyRatings: []dto.GeminiChatSafetyRating{},
 		}
 
-		// 设置结束原因
-		var finishReason string
-		switch choice.FinishReason {
-		case "stop":
-			finishReason = "STOP"
-		case "length":
-			finishReason = "MAX_TOKENS"
-		case "content_filter":
-			finishReason = "SAFETY"
-		case "tool_calls":
-			finishReason = "STOP"
-		default:
-			finishReason = "STOP"
-		}
-		candidate.FinishReason = &finishReason
-
-		// 转换消息内容
+		// 转换消息内容：思考内容、文本与工具调用依次作为 parts
 		content := dto.GeminiChatContent{
 			Role:  "model",
 			Parts: make([]dto.GeminiPart, 0),
 		}
-
-		// 处理工具调用
-		toolCalls := choice.Message.ParseToolCalls()
-		if len(toolCalls) > 0 {
-			for _, toolCall := range toolCalls {
-				// 解析参数
-				var args map[string]interface{}
-				if toolCall.Function.Arguments != "" {
-					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-						args = map[string]interface{}{"arguments": toolCall.Function.Arguments}
-					}
-				} else {
-					args = make(map[string]interface{})
-				}
-
-				part := dto.GeminiPart{
-					FunctionCall: &dto.FunctionCall{
-						FunctionName: toolCall.Function.Name,
-						Arguments:    args,
-					},
-				}
-				content.Parts = append(content.Parts, part)
-			}
-		} else {
-			// 处理文本内容
-			textContent := choice.Message.StringContent()
-			if textContent != "" {
-				part := dto.GeminiPart{
-					Text: textContent,
-				}
-				content.Parts = append(content.Parts, part)
-			}
-		}
-
-		candidate.Content = content
-		geminiResponse.Candidates = append(geminiResponse.Candidates, candidate)
-	}
-
-	return geminiResponse
-}
-
-// StreamResponseOpenAI2Gemini 将 OpenAI 流式响应转换为 Gemini 格式
-func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamResponse, info *relaycommon.RelayInfo) *dto.GeminiChatResponse {
-	// 检查是否有实际内容或结束标志
-	hasContent := false
-	hasFinishReason := false
-	for _, choice := range openAIResponse.Choices {
-		if len(choice.Delta.GetContentString()) > 0 || (choice.Delta.ToolCalls != nil && len(choice.Delta.ToolCalls) > 0) {
-			hasContent = true
-		}
-		if choice.FinishReason != nil {
-			hasFinishReason = true
-		}
-	}
-
-	// 如果没有实际内容且没有结束标志，跳过。主要针对 openai 流响应开头的空数据
-	if !hasContent && !hasFinishReason {
-		return nil
-	}
-
-	geminiResponse := &dto.GeminiChatResponse{
-		Candidates: make([]dto.GeminiChatCandidate, 0, len(openAIResponse.Choices)),
-		UsageMetadata: dto.GeminiUsageMetadata{
-			PromptTokenCount:     info.GetEstimatePromptTokens(),
-			CandidatesTokenCount: 0, // 流式响应中可能没有完整的 usage 信息
-			TotalTokenCount:      info.GetEstimatePromptTokens(),
-		},
-	}
-
-	if openAIResponse.Usage != nil {
-		geminiResponse.UsageMetadata.PromptTokenCount = openAIResponse.Usage.PromptTokens
-		geminiResponse.UsageMetadata.CandidatesTokenCount = openAIResponse.Usage.CompletionTokens
-		geminiResponse.UsageMetadata.TotalTokenCount = openAIResponse.Usage.TotalTokens
-	}
-
-	for _, choice := range openAIResponse.Choices {
-		candidate := dto.GeminiChatCandidate{
-			Index:         int64(choice.Index),
-			SafetyRatings: []dto.GeminiChatSafetyRating{},
+		reasoning := choice.Message.ReasoningContent
+		if reasoning == "" {
+			reasoning = choice.Message.Reasoning
 		}
-
-		// 设置结束原因
-		if choice.FinishReason != nil {
-			var finishReason string
-			switch *choice.FinishReason {
-			case "stop":
-				finishReason = "STOP"
-			case "length":
-				finishReason = "MAX_TOKENS"
-			case "content_filter":
-				finishReason = "SAFETY"
-			case "tool_calls":
-				finishReason = "STOP"
-			default:
-				finishReason = "STOP"
-			}
-			candidate.FinishReason = &finishReason
+		if reasoning != "" {
+			content.Parts = append(content.Parts, dto.GeminiPart{Text: reasoning, Thought: true})
 		}
-
-		// 转换消息内容
-		content := dto.GeminiChatContent{
-			Role:  "model",
-			Parts: make([]dto.GeminiPart, 0),
+		if textContent := choice.Message.StringContent(); textContent != "" {
+			content.Parts = append(content.Parts, dto.GeminiPart{Text: textContent})
 		}
-
-		// 处理工具调用
-		if choice.Delta.ToolCalls != nil {
-			for _, toolCall := range choice.Delta.ToolCalls {
-				// 解析参数
-				var args map[string]interface{}
-				if toolCall.Function.Arguments != "" {
-					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-						args = map[string]interface{}{"arguments": toolCall.Function.Arguments}
-					}
-				} else {
-					args = make(map[string]interface{})
-				}
-
-				part := dto.GeminiPart{
-					FunctionCall: &dto.FunctionCall{
-						FunctionName: toolCall.Function.Name,
-						Arguments:    args,
-					},
-				}
-				content.Parts = append(content.Parts, part)
-			}
-		} else {
-			// 处理文本内容
-			textContent := choice.Delta.GetContentString()
-			if textContent != "" {
-				part := dto.GeminiPart{
-					Text: textContent,
-				}
-				content.Parts = append(content.Parts, part)
-			}
+		for _, toolCall := range choice.Message.ParseToolCalls() {
+			content.Parts = append(content.Parts, dto.GeminiPart{
+				FunctionCall: &dto.FunctionCall{
+					FunctionName: toolCall.Function.Name,
+					Arguments:    geminiFunctionCallArgs(toolCall.Function.Arguments),
+				},
+			})
 		}
 
 		candidate.Content = content
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// geminiFunctionDeclaration 对应 Gemini tools 中的 functionDeclarations，
+// parameters 为 OpenAPI 风格的 schema，parametersJsonSchema 为标准 JSON Schema
+type geminiFunctionDeclaration struct {
+	Name                 string `json:"name"`
+	Description          string `json:"description,omitempty"`
+	Parameters           any    `json:"parameters,omitempty"`
+	ParametersJsonSchema any    `json:"parametersJsonSchema,omitempty"`
+}
+
+// geminiInlineDataToMediaContent 按 MIME 类型将内联数据转换为对应的 OpenAI 内容块
+func geminiInlineDataToMediaContent(inlineData *dto.GeminiInlineData) dto.MediaContent {
+	dataURL := fmt.Sprintf("data:%s;base64,%s", inlineData.MimeType, inlineData.Data)
+	mimeType := strings.ToLower(inlineData.MimeType)
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return dto.MediaContent{
+			Type: dto.ContentTypeImageURL,
+			ImageUrl: &dto.MessageImageUrl{
+				Url:    dataURL,
+				Detail: "auto",
+			},
+		}
+	case strings.HasPrefix(mimeType, "audio/"):
+		format := strings.TrimPrefix(strings.TrimPrefix(mimeType, "audio/"), "x-")
+		if format == "mpeg" {
+			format = "mp3"
+		}
+		return dto.MediaContent{
+			Type: dto.ContentTypeInputAudio,
+			InputAudio: &dto.MessageInputAudio{
+				Data:   inlineData.Data,
+				Format: format,
+			},
+		}
+	case strings.HasPrefix(mimeType, "video/"):
+		return dto.MediaContent{
+			Type:     dto.ContentTypeVideoUrl,
+			VideoUrl: &dto.MessageVideoUrl{Url: dataURL},
+		}
+	default:
+		return dto.MediaContent{
+			Type: dto.ContentTypeFile,
+			File: &dto.MessageFile{FileData: dataURL},
+		}
+	}
+}
+
+// geminiFileDataToMediaContent 将文件引用转换为 OpenAI 内容块，仅图片与视频可以按 URL 传递
+func geminiFileDataToMediaContent(fileData *dto.GeminiFileData) (dto.MediaContent, error) {
+	mimeType := strings.ToLower(fileData.MimeType)
+	switch {
+	case strings.HasPrefix(mimeType, "image/") || mimeType == "":
+		return dto.MediaContent{
+			Type: dto.ContentTypeImageURL,
+			ImageUrl: &dto.MessageImageUrl{
+				Url:    fileData.FileUri,
+				Detail: "auto",
+			},
+		}, nil
+	case strings.HasPrefix(mimeType, "video/"):
+		return dto.MediaContent{
+			Type:     dto.ContentTypeVideoUrl,
+			VideoUrl: &dto.MessageVideoUrl{Url: fileData.FileUri},
+		}, nil
+	default:
+		return dto.MediaContent{}, fmt.Errorf("fileData with mime type %s is not supported by this channel, use inlineData instead", fileData.MimeType)
+	}
+}
+
+// geminiSchemaToJSONSchema 将 Gemini 的 OpenAPI 风格 schema 转换为 JSON Schema：
+// 类型名转为小写，nullable 转为包含 null 的类型数组，并去掉 propertyOrdering
+func geminiSchemaToJSONSchema(schema any) any {
+	switch v := schema.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, value := range v {
+			switch key {
+			case "propertyOrdering", "nullable":
+				continue
+			case "type":
+				if typeName, ok := value.(string); ok {
+					value = strings.ToLower(typeName)
+				}
+			default:
+				value = geminiSchemaToJSONSchema(value)
+			}
+			result[key] = value
+		}
+		if nullable, _ := v["nullable"].(bool); nullable {
+			if typeName, ok := result["type"].(string); ok {
+				result["type"] = []any{typeName, "null"}
+			}
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			result[i] = geminiSchemaToJSONSchema(item)
+		}
+		return result
+	default:
+		return schema
+	}
+}
+
+// geminiResponseFormatToOpenAI 将 responseMimeType 与 responseSchema/responseJsonSchema 转换为 response_format
+func geminiResponseFormatToOpenAI(config *dto.GeminiChatGenerationConfig) (*dto.ResponseFormat, error) {
+	if config.ResponseMimeType != "application/json" {
+		return nil, nil
+	}
+	var schema any
+	if len(config.ResponseJsonSchema) > 0 {
+		schema = config.ResponseJsonSchema
+	} else if config.ResponseSchema != nil {
+		schema = geminiSchemaToJSONSchema(config.ResponseSchema)
+	}
+	if schema == nil {
+		return &dto.ResponseFormat{Type: "json_object"}, nil
+	}
+	jsonSchema, err := common.Marshal(dto.FormatJsonSchema{
+		Name:   "response",
+		Schema: schema,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dto.ResponseFormat{
+		Type:       "json_schema",
+		JsonSchema: jsonSchema,
+	}, nil
+}
+
+// geminiToolConfigToOpenAI 将 functionCallingConfig 转换为 OpenAI 的 tool_choice，未指定时返回 nil
+func geminiToolConfigToOpenAI(toolConfig *dto.ToolConfig) any {
+	if toolConfig == nil || toolConfig.FunctionCallingConfig == nil {
+		return nil
+	}
+	config := toolConfig.FunctionCallingConfig
+	switch strings.ToUpper(string(config.Mode)) {
+	case "AUTO", "VALIDATED":
+		return "auto"
+	case "NONE":
+		return "none"
+	case "ANY":
+		// OpenAI 只能指定单个函数，多个候选函数时退化为 required
+		if len(config.AllowedFunctionNames) == 1 {
+			return map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name": config.AllowedFunctionNames[0],
+				},
+			}
+		}
+		return "required"
+	default:
+		return nil
+	}
+}
+
+func finishReasonOpenAI2Gemini(reason string) string {
+	switch reason {
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+// geminiUsageFromOpenAI 将 OpenAI 用量转换为 usageMetadata，candidatesTokenCount 不包含思考部分
+func geminiUsageFromOpenAI(usage *dto.Usage) dto.GeminiUsageMetadata {
+	reasoningTokens := usage.CompletionTokenDetails.ReasoningTokens
+	totalTokens := usage.TotalTokens
+	if totalTokens == 0 {
+		totalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return dto.GeminiUsageMetadata{
+		PromptTokenCount:        usage.PromptTokens,
+		CandidatesTokenCount:    max(usage.CompletionTokens-reasoningTokens, 0),
+		TotalTokenCount:         totalTokens,
+		ThoughtsTokenCount:      reasoningTokens,
+		CachedContentTokenCount: usage.PromptTokensDetails.CachedTokens,
+	}
+}
+
+// geminiFunctionCallArgs 解析工具调用参数，无法解析时原样放入 arguments 字段
+func geminiFunctionCallArgs(arguments string) map[string]any {
+	args := make(map[string]any)
+	if arguments == "" {
+		return args
+	}
+	if err := common.UnmarshalJsonStr(arguments, &args); err != nil {
+		return map[string]any{"arguments": arguments}
+	}
+	return args
+}
+
+type geminiStreamToolCall struct {
+	name      string
+	arguments strings.Builder
+}
+
+type geminiStreamCandidate struct {
+	toolCalls    []*geminiStreamToolCall
+	toolIndex    map[int]*geminiStreamToolCall
+	finishReason string
+}
+
+// GeminiStreamConverter 将 Chat Completions 流式分片转换为 Gemini 流式响应：
+// 文本与思考内容逐片输出，工具调用参数累积完整后在结束时与 finishReason、最终用量一并输出
+type GeminiStreamConverter struct {
+	inputTokens int
+	finished    bool
+	candidates  map[int]*geminiStreamCandidate
+	order       []int
+}
+
+// NewGeminiStreamConverter 创建流式转换器，inputTokens 为中间分片 usageMetadata 中的预估输入用量
+func NewGeminiStreamConverter(inputTokens int) *GeminiStreamConverter {
+	return &GeminiStreamConverter{
+		inputTokens: inputTokens,
+		candidates:  make(map[int]*geminiStreamCandidate),
+	}
+}
+
+func (s *GeminiStreamConverter) candidate(index int) *geminiStreamCandidate {
+	state, ok := s.candidates[index]
+	if !ok {
+		state = &geminiStreamCandidate{toolIndex: make(map[int]*geminiStreamToolCall)}
+		s.candidates[index] = state
+		s.order = append(s.order, index)
+	}
+	return state
+}
+
+// Convert 转换一个 Chat Completions 流式分片，没有可输出的内容时返回 nil
+func (s *GeminiStreamConverter) Convert(chunk *dto.ChatCompletionsStreamResponse) *dto.GeminiChatResponse {
+	if s.finished {
+		return nil
+	}
+	var candidates []dto.GeminiChatCandidate
+	for _, choice := range chunk.Choices {
+		state := s.candidate(choice.Index)
+		var parts []dto.GeminiPart
+		if reasoning := choice.Delta.GetReasoningContent(); reasoning != "" {
+			parts = append(parts, dto.GeminiPart{Text: reasoning, Thought: true})
+		}
+		if content := choice.Delta.GetContentString(); content != "" {
+			parts = append(parts, dto.GeminiPart{Text: content})
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			// 带 id 的分片开始新的工具调用，其余分片按 index 追加参数；缺少 index 时归入最后一个工具调用
+			var call *geminiStreamToolCall
+			if toolCall.Index != nil {
+				call = state.toolIndex[*toolCall.Index]
+			}
+			if call == nil && toolCall.ID == "" && len(state.toolCalls) > 0 {
+				call = state.toolCalls[len(state.toolCalls)-1]
+			}
+			if call == nil {
+				call = &geminiStreamToolCall{}
+				state.toolCalls = append(state.toolCalls, call)
+				if toolCall.Index != nil {
+					state.toolIndex[*toolCall.Index] = call
+				}
+			}
+			if toolCall.Function.Name != "" {
+				call.name = toolCall.Function.Name
+			}
+			call.arguments.WriteString(toolCall.Function.Arguments)
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			state.finishReason = *choice.FinishReason
+		}
+		if len(parts) > 0 {
+			candidates = append(candidates, dto.GeminiChatCandidate{
+				Content: dto.GeminiChatContent{
+					Role:  "model",
+					Parts: parts,
+				},
+				Index:         int64(choice.Index),
+				SafetyRatings: []dto.GeminiChatSafetyRating{},
+			})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return &dto.GeminiChatResponse{
+		Candidates: candidates,
+		UsageMetadata: dto.GeminiUsageMetadata{
+			PromptTokenCount: s.inputTokens,
+			TotalTokenCount:  s.inputTokens,
+		},
+	}
+}
+
+// Finish 结束流式响应，输出累积的工具调用、finishReason 与最终用量
+func (s *GeminiStreamConverter) Finish(usage *dto.Usage) *dto.GeminiChatResponse {
+	if s.finished {
+		return nil
+	}
+	s.finished = true
+	if len(s.order) == 0 {
+		s.candidate(0)
+	}
+	if usage == nil {
+		usage = &dto.Usage{}
+	}
+	response := &dto.GeminiChatResponse{
+		Candidates:    make([]dto.GeminiChatCandidate, 0, len(s.order)),
+		UsageMetadata: geminiUsageFromOpenAI(usage),
+	}
+	for _, index := range s.order {
+		state := s.candidates[index]
+		parts := make([]dto.GeminiPart, 0, len(state.toolCalls))
+		for _, call := range state.toolCalls {
+			parts = append(parts, dto.GeminiPart{
+				FunctionCall: &dto.FunctionCall{
+					FunctionName: call.name,
+					Arguments:    geminiFunctionCallArgs(call.arguments.String()),
+				},
+			})
+		}
+		finishReason := finishReasonOpenAI2Gemini(state.finishReason)
+		response.Candidates = append(response.Candidates, dto.GeminiChatCandidate{
+			Content: dto.GeminiChatContent{
+				Role:  "model",
+				Parts: parts,
+			},
+			FinishReason:  &finishReason,
+			Index:         int64(index),
+			SafetyRatings: []dto.GeminiChatSafetyRating{},
+		})
+	}
+	return response
+}
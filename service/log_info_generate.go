@@ -42,6 +42,12 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	other["model_price"] = modelPrice
 	other["user_group_ratio"] = userGroupRatio
 	other["frt"] = float64(relayInfo.FirstResponseTime.UnixMilli() - relayInfo.StartTime.UnixMilli())
+	if relayInfo.Subscription != nil && relayInfo.Subscription.Charged > 0 {
+		other["subscription_quota"] = relayInfo.Subscription.Charged
+	}
+	if relayInfo.PriceData.PricingRule != nil {
+		other["pricing_rule"] = relayInfo.PriceData.PricingRule
+	}
 	if relayInfo.ReasoningEffort != "" {
 		other["reasoning_effort"] = relayInfo.ReasoningEffort
 	}
@@ -50,6 +56,14 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		other["upstream_model_name"] = relayInfo.UpstreamModelName
 	}
 
+	if virtualModel := common.GetContextKeyString(ctx, constant.ContextKeyVirtualModel); virtualModel != "" {
+		other["virtual_model"] = virtualModel
+		other["virtual_model_arm"] = common.GetContextKeyString(ctx, constant.ContextKeyVirtualModelArm)
+	}
+	if len(relayInfo.ModelFallbackPath) > 1 {
+		other["model_fallback"] = relayInfo.ModelFallbackPath
+	}
+
 	isSystemPromptOverwritten := common.GetContextKeyBool(ctx, constant.ContextKeySystemPromptOverride)
 	if isSystemPromptOverwritten {
 		other["is_system_prompt_overwritten"] = true
@@ -68,6 +82,10 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		adminInfo["local_count_tokens"] = isLocalCountTokens
 	}
 
+	if relayInfo.ChannelScriptError != "" {
+		adminInfo["channel_script_error"] = relayInfo.ChannelScriptError
+	}
+
 	other["admin_info"] = adminInfo
 	appendRequestPath(ctx, relayInfo, other)
 	return other
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+)
+
+// setupTestDB 为每个测试创建独立的内存 SQLite 数据库并完成迁移
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	t.Setenv("SQL_DSN", "local")
+	t.Setenv("LOG_SQL_DSN", "")
+	originalPath := common.SQLitePath
+	common.SQLitePath = "file:" + strings.ReplaceAll(t.Name(), "/", "_") + "?mode=memory&cache=shared"
+	common.UsingMySQL = false
+	common.UsingPostgreSQL = false
+	common.RedisEnabled = false
+	common.IsMasterNode = true
+	if err := model.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.InitLogDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		common.SQLitePath = originalPath
+		if sqlDB, err := model.DB.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+}
+
+// createTestUser 创建测试用户，AffCode 与 AccessToken 需唯一
+func createTestUser(t *testing.T, username string, quota int) *model.User {
+	t.Helper()
+	accessToken := "access-" + username
+	user := &model.User{
+		Username:    username,
+		Password:    "password",
+		DisplayName: username,
+		Role:        common.RoleCommonUser,
+		Status:      common.UserStatusEnabled,
+		Quota:       quota,
+		Group:       "default",
+		AffCode:     "aff-" + username,
+		AccessToken: &accessToken,
+	}
+	if err := model.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
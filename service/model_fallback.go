@@ -0,0 +1,79 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ValidateModelFallbackChains 校验令牌配置的降级链格式，空字符串表示未配置
+func ValidateModelFallbackChains(chains string) error {
+	if strings.TrimSpace(chains) == "" {
+		return nil
+	}
+	var parsed map[string][]string
+	if err := common.UnmarshalJsonStr(chains, &parsed); err != nil {
+		return fmt.Errorf("模型降级链格式错误，应为 {\"模型\": [\"降级模型1\", \"降级模型2\"]}：%s", err.Error())
+	}
+	for modelName, chain := range parsed {
+		if strings.TrimSpace(modelName) == "" {
+			return fmt.Errorf("模型降级链的模型名称不能为空")
+		}
+		for _, fallback := range chain {
+			if strings.TrimSpace(fallback) == "" {
+				return fmt.Errorf("模型 %s 的降级链包含空的模型名称", modelName)
+			}
+		}
+	}
+	return nil
+}
+
+// GetModelFallbackChain 返回模型不可用时依次尝试的模型，不含模型本身。
+// 令牌配置优先于全局配置，全局开关只控制全局配置的降级链；启用了模型限制的令牌只会降级到允许访问的模型
+func GetModelFallbackChain(c *gin.Context, modelName string) []string {
+	if modelName == "" {
+		return nil
+	}
+	var chain []string
+	ok := false
+	if setting := operation_setting.GetModelFallbackSetting(); setting.Enabled {
+		chain, ok = setting.Chains[modelName]
+	}
+	if tokenChains, exists := common.GetContextKeyType[map[string][]string](c, constant.ContextKeyTokenModelFallback); exists {
+		if tokenChain, found := tokenChains[modelName]; found {
+			chain, ok = tokenChain, true
+		}
+	}
+	if !ok {
+		return nil
+	}
+
+	var tokenModelLimit map[string]bool
+	if common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		// 令牌启用了模型限制但列表为空时不允许访问任何模型
+		tokenModelLimit, _ = common.GetContextKeyType[map[string]bool](c, constant.ContextKeyTokenModelLimit)
+		if tokenModelLimit == nil {
+			return nil
+		}
+	}
+	seen := map[string]bool{modelName: true}
+	result := make([]string, 0, len(chain))
+	for _, fallback := range chain {
+		fallback = strings.TrimSpace(fallback)
+		if fallback == "" || seen[fallback] {
+			continue
+		}
+		seen[fallback] = true
+		if tokenModelLimit != nil && !tokenModelLimit[ratio_setting.FormatMatchingModelName(fallback)] {
+			continue
+		}
+		result = append(result, fallback)
+	}
+	return result
+}
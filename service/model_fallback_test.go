@@ -0,0 +1,91 @@
+package service
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setModelFallbackSetting(t *testing.T, enabled bool, chains map[string][]string) {
+	t.Helper()
+	setting := operation_setting.GetModelFallbackSetting()
+	original := *setting
+	setting.Enabled = enabled
+	setting.Chains = chains
+	t.Cleanup(func() {
+		*setting = original
+	})
+}
+
+func TestGetModelFallbackChain(t *testing.T) {
+	setModelFallbackSetting(t, true, map[string][]string{
+		"gpt-4o": {"claude-sonnet-4", "gpt-4o", "claude-sonnet-4", " gemini-2.5-pro "},
+	})
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	// 去掉模型本身、重复项与空白
+	chain := GetModelFallbackChain(c, "gpt-4o")
+	if !slices.Equal(chain, []string{"claude-sonnet-4", "gemini-2.5-pro"}) {
+		t.Fatalf("chain = %v", chain)
+	}
+	if chain := GetModelFallbackChain(c, "o3"); chain != nil {
+		t.Fatalf("model without chain should not fall back, got %v", chain)
+	}
+
+	// 令牌配置覆盖同名模型的全局降级链
+	token := &model.Token{ModelFallback: `{"gpt-4o": ["deepseek-chat"]}`}
+	common.SetContextKey(c, constant.ContextKeyTokenModelFallback, token.GetModelFallback())
+	if chain := GetModelFallbackChain(c, "gpt-4o"); !slices.Equal(chain, []string{"deepseek-chat"}) {
+		t.Fatalf("token chain should override the global chain, got %v", chain)
+	}
+
+	// 启用模型限制的令牌只降级到允许访问的模型
+	common.SetContextKey(c, constant.ContextKeyTokenModelFallback, map[string][]string{})
+	common.SetContextKey(c, constant.ContextKeyTokenModelLimitEnabled, true)
+	common.SetContextKey(c, constant.ContextKeyTokenModelLimit, map[string]bool{"gpt-4o": true, "gemini-2.5-pro": true})
+	if chain := GetModelFallbackChain(c, "gpt-4o"); !slices.Equal(chain, []string{"gemini-2.5-pro"}) {
+		t.Fatalf("chain should respect token model limits, got %v", chain)
+	}
+}
+
+func TestGetModelFallbackChainDisabled(t *testing.T) {
+	setModelFallbackSetting(t, false, map[string][]string{"gpt-4o": {"claude-sonnet-4"}})
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if chain := GetModelFallbackChain(c, "gpt-4o"); chain != nil {
+		t.Fatalf("disabled fallback should return nil, got %v", chain)
+	}
+
+	// 全局开关关闭时令牌单独配置的降级链仍然生效
+	token := &model.Token{ModelFallback: `{"gpt-4o": ["deepseek-chat"]}`}
+	common.SetContextKey(c, constant.ContextKeyTokenModelFallback, token.GetModelFallback())
+	if chain := GetModelFallbackChain(c, "gpt-4o"); !slices.Equal(chain, []string{"deepseek-chat"}) {
+		t.Fatalf("token chain should apply without the global setting, got %v", chain)
+	}
+	if chain := GetModelFallbackChain(c, "o3"); chain != nil {
+		t.Fatalf("model without token chain should not fall back, got %v", chain)
+	}
+}
+
+func TestValidateModelFallbackChains(t *testing.T) {
+	if err := ValidateModelFallbackChains(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateModelFallbackChains(`{"gpt-4o": ["claude-sonnet-4"]}`); err != nil {
+		t.Fatal(err)
+	}
+	for _, chains := range []string{`["gpt-4o"]`, `{"": ["a"]}`, `{"gpt-4o": [" "]}`} {
+		if ValidateModelFallbackChains(chains) == nil {
+			t.Fatalf("chains %s should be rejected", chains)
+		}
+	}
+	if (&model.Token{ModelFallback: "not json"}).GetModelFallback() != nil {
+		t.Fatal("malformed token chains should be ignored")
+	}
+}
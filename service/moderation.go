@@ -0,0 +1,392 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	moderationStagePrompt     = "prompt"
+	moderationStageCompletion = "completion"
+	// 流式审核时暂不下发的末尾文本长度，用于发现跨分片的命中
+	moderationStreamTailRunes = 64
+	moderationMatchMaxRunes   = 64
+)
+
+// ModerationResult 一次审核命中
+type ModerationResult struct {
+	Provider string
+	Category string
+	Matches  []string
+}
+
+// moderationRule 关键词与正则审核规则，关键词会被转换为不区分大小写的正则
+type moderationRule struct {
+	provider string
+	category string
+	re       *regexp.Regexp
+}
+
+var moderationRuleCache struct {
+	sync.Mutex
+	key   string
+	rules []moderationRule
+}
+
+// getModerationRules 按启用顺序返回编译后的关键词与正则规则，配置未变化时复用缓存
+func getModerationRules(setting *operation_setting.ModerationSetting) []moderationRule {
+	key := common.GetJsonString([]any{setting.Providers, setting.Keywords, setting.RegexRules})
+	moderationRuleCache.Lock()
+	defer moderationRuleCache.Unlock()
+	if moderationRuleCache.key == key {
+		return moderationRuleCache.rules
+	}
+	var rules []moderationRule
+	for _, provider := range setting.Providers {
+		switch provider {
+		case operation_setting.ModerationProviderKeyword:
+			for category, words := range setting.Keywords {
+				quoted := make([]string, 0, len(words))
+				for _, word := range words {
+					if word = strings.TrimSpace(word); word != "" {
+						quoted = append(quoted, regexp.QuoteMeta(word))
+					}
+				}
+				if len(quoted) == 0 {
+					continue
+				}
+				rules = append(rules, moderationRule{
+					provider: provider,
+					category: category,
+					re:       regexp.MustCompile("(?i)" + strings.Join(quoted, "|")),
+				})
+			}
+		case operation_setting.ModerationProviderRegex:
+			for _, rule := range setting.RegexRules {
+				re, err := regexp.Compile(rule.Pattern)
+				if err != nil {
+					common.SysError(fmt.Sprintf("invalid moderation regex %q: %v", rule.Pattern, err))
+					continue
+				}
+				rules = append(rules, moderationRule{provider: provider, category: rule.Category, re: re})
+			}
+		}
+	}
+	moderationRuleCache.key = key
+	moderationRuleCache.rules = rules
+	return rules
+}
+
+func truncateModerationMatch(match string) string {
+	runes := []rune(match)
+	if len(runes) > moderationMatchMaxRunes {
+		return string(runes[:moderationMatchMaxRunes]) + "..."
+	}
+	return match
+}
+
+// matchModerationRules 返回第一条命中的规则及其全部匹配内容
+func matchModerationRules(rules []moderationRule, text string) *ModerationResult {
+	for _, rule := range rules {
+		matches := rule.re.FindAllString(text, 10)
+		if len(matches) == 0 {
+			continue
+		}
+		for i := range matches {
+			matches[i] = truncateModerationMatch(matches[i])
+		}
+		return &ModerationResult{Provider: rule.provider, Category: rule.category, Matches: matches}
+	}
+	return nil
+}
+
+func redactModerationRules(rules []moderationRule, text string, replacement string) string {
+	for _, rule := range rules {
+		text = rule.re.ReplaceAllLiteralString(text, replacement)
+	}
+	return text
+}
+
+type moderationClassifierResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+}
+
+// ModerationRequestBuilder 通过渠道适配器构造分类器请求的地址与请求头
+type ModerationRequestBuilder func(c *gin.Context, channel *model.Channel, key string, modelName string) (string, http.Header, error)
+
+var moderationRequestBuilder ModerationRequestBuilder
+
+// RegisterModerationRequestBuilder 由 relay 包注册分类器请求构造函数，service 包不能直接依赖渠道适配器
+func RegisterModerationRequestBuilder(builder ModerationRequestBuilder) {
+	moderationRequestBuilder = builder
+}
+
+// pickModerationChannel 选择分类器渠道；选择时会经过熔断器并可能占用半开状态的探测机会，调用结果必须回报熔断器
+func pickModerationChannel(setting *operation_setting.ModerationSetting) (*model.Channel, error) {
+	channel, err := model.GetRandomSatisfiedChannel(setting.ClassifierGroup, setting.ClassifierModel, 0)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, fmt.Errorf("no channel available for moderation model %s in group %s", setting.ClassifierModel, setting.ClassifierGroup)
+	}
+	return channel, nil
+}
+
+// classifyModeration 调用网关中已配置的 OpenAI 兼容 /v1/moderations 渠道，并把结果计入渠道熔断器
+func classifyModeration(c *gin.Context, setting *operation_setting.ModerationSetting, text string) (*ModerationResult, error) {
+	if moderationRequestBuilder == nil {
+		return nil, fmt.Errorf("moderation request builder is not registered")
+	}
+	channel, err := pickModerationChannel(setting)
+	if err != nil {
+		return nil, err
+	}
+	key, keyIndex, apiErr := channel.GetNextEnabledKey()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	requestURL, header, err := moderationRequestBuilder(c, channel, key, setting.ClassifierModel)
+	if err != nil {
+		return nil, err
+	}
+	body, err := common.Marshal(map[string]any{
+		"model": setting.ClassifierModel,
+		"input": text,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(setting.ClassifierTimeoutMs)*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	client, err := GetHttpClientWithProxy(channel.GetSetting().Proxy)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		model.RecordChannelCircuitResult(channel.Id, channel.ChannelInfo.IsMultiKey, keyIndex, false)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	model.RecordChannelCircuitResult(channel.Id, channel.ChannelInfo.IsMultiKey, keyIndex, !isModerationChannelFailure(resp.StatusCode))
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("moderation channel #%d returned status %d: %s", channel.Id, resp.StatusCode, string(respBody))
+	}
+	var result moderationClassifierResponse
+	if err := common.Unmarshal(respBody, &result); err != nil {
+		return nil, err
+	}
+	for _, item := range result.Results {
+		if !item.Flagged {
+			continue
+		}
+		var categories []string
+		for category, flagged := range item.Categories {
+			if flagged {
+				categories = append(categories, category)
+			}
+		}
+		if len(categories) == 0 {
+			categories = []string{"flagged"}
+		}
+		return &ModerationResult{Provider: operation_setting.ModerationProviderClassifier, Category: strings.Join(categories, ","), Matches: categories}, nil
+	}
+	return nil, nil
+}
+
+// isModerationChannelFailure 与转发请求一致，限流、鉴权失败与 5xx 视为渠道故障
+func isModerationChannelFailure(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusUnauthorized ||
+		statusCode == http.StatusForbidden || statusCode >= http.StatusInternalServerError
+}
+
+func isModerationProviderEnabled(setting *operation_setting.ModerationSetting, provider string) bool {
+	for _, p := range setting.Providers {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// recordModerationViolation 以内容审核日志类型记录命中的类别与处理方式
+func recordModerationViolation(c *gin.Context, info *relaycommon.RelayInfo, result *ModerationResult, action string, stage string) {
+	other := map[string]interface{}{
+		"moderation_stage":    stage,
+		"moderation_provider": result.Provider,
+		"moderation_category": result.Category,
+		"moderation_action":   action,
+		"moderation_matches":  result.Matches,
+	}
+	content := fmt.Sprintf("内容审核命中（%s）：类别 %s，来源 %s，处理方式 %s", stage, result.Category, result.Provider, action)
+	model.RecordModerationLog(c, info.UserId, c.GetInt("channel_id"), info.OriginModelName, c.GetString("token_name"), content,
+		info.TokenId, info.IsStream, info.UsingGroup, other)
+}
+
+func moderationBlockedError(result *ModerationResult) *types.NewAPIError {
+	return types.NewErrorWithStatusCode(fmt.Errorf("请求内容未通过内容审核，类别：%s", result.Category), types.ErrorCodeModerationBlocked,
+		http.StatusBadRequest, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+}
+
+// redactModerationRequest 替换请求中命中的内容，目前支持 OpenAI 格式的对话与补全请求
+func redactModerationRequest(request dto.Request, redact func(string) string) bool {
+	textRequest, ok := request.(*dto.GeneralOpenAIRequest)
+	if !ok {
+		return false
+	}
+	if prompt, ok := textRequest.Prompt.(string); ok {
+		textRequest.Prompt = redact(prompt)
+	}
+	for i := range textRequest.Messages {
+		message := &textRequest.Messages[i]
+		if message.IsStringContent() {
+			message.SetStringContent(redact(message.StringContent()))
+			continue
+		}
+		contents := message.ParseContent()
+		if len(contents) == 0 {
+			continue
+		}
+		for j := range contents {
+			if contents[j].Type == dto.ContentTypeText {
+				contents[j].Text = redact(contents[j].Text)
+			}
+		}
+		message.SetMediaContent(contents)
+	}
+	return true
+}
+
+// ModeratePrompt 在预扣费前按分组策略审核提示词：拒绝时返回错误，替换时直接修改请求并返回 true；
+// 策略要求审核流式补全内容时，为 info 设置流式审核器
+func ModeratePrompt(c *gin.Context, info *relaycommon.RelayInfo, request dto.Request, meta *types.TokenCountMeta) (bool, *types.NewAPIError) {
+	policy, ok := operation_setting.GetModerationPolicy(info.UsingGroup)
+	if !ok {
+		return false, nil
+	}
+	setting := operation_setting.GetModerationSetting()
+	rules := getModerationRules(setting)
+	if policy.CheckCompletion && info.IsStream && len(rules) > 0 {
+		info.Moderator = &streamModerator{c: c, info: info, policy: policy, rules: rules, replacement: setting.RedactReplacement, logged: map[string]bool{}}
+	}
+	if meta == nil || meta.CombineText == "" {
+		return false, nil
+	}
+	text := meta.CombineText
+	redacted := false
+
+	if result := matchModerationRules(rules, text); result != nil {
+		action := policy.Action
+		redact := func(s string) string {
+			return redactModerationRules(rules, s, setting.RedactReplacement)
+		}
+		if action == operation_setting.ModerationActionRedact && !redactModerationRequest(request, redact) {
+			action = operation_setting.ModerationActionBlock
+		}
+		recordModerationViolation(c, info, result, action, moderationStagePrompt)
+		switch action {
+		case operation_setting.ModerationActionBlock:
+			return false, moderationBlockedError(result)
+		case operation_setting.ModerationActionRedact:
+			redacted = true
+			text = redact(text)
+		}
+	}
+
+	if isModerationProviderEnabled(setting, operation_setting.ModerationProviderClassifier) {
+		result, err := classifyModeration(c, setting, text)
+		if err != nil {
+			// 分类器异常时放行，避免审核本身导致不可用
+			logger.LogError(c, fmt.Sprintf("moderation classifier failed: %s", err.Error()))
+			return redacted, nil
+		}
+		if result != nil {
+			action := policy.Action
+			if action == operation_setting.ModerationActionRedact {
+				// 分类器无法定位命中内容
+				action = operation_setting.ModerationActionBlock
+			}
+			recordModerationViolation(c, info, result, action, moderationStagePrompt)
+			if action == operation_setting.ModerationActionBlock {
+				return false, moderationBlockedError(result)
+			}
+		}
+	}
+	return redacted, nil
+}
+
+// streamModerator 使用关键词与正则规则审核流式补全内容，同一类别只记录一次日志。
+// 末尾 moderationStreamTailRunes 个字符可能是尚未完整出现的命中内容，暂不下发，与后续文本合并审核后再输出
+type streamModerator struct {
+	c           *gin.Context
+	info        *relaycommon.RelayInfo
+	policy      operation_setting.ModerationPolicy
+	rules       []moderationRule
+	replacement string
+	pending     string
+	logged      map[string]bool
+}
+
+func (m *streamModerator) Moderate(delta string) (string, bool) {
+	window := m.pending + delta
+	if result := matchModerationRules(m.rules, window); result != nil {
+		if !m.logged[result.Category] {
+			m.logged[result.Category] = true
+			recordModerationViolation(m.c, m.info, result, m.policy.Action, moderationStageCompletion)
+		}
+		switch m.policy.Action {
+		case operation_setting.ModerationActionBlock:
+			m.pending = ""
+			return "", true
+		case operation_setting.ModerationActionRedact:
+			window = redactModerationRules(m.rules, window, m.replacement)
+		}
+	}
+	runes := []rune(window)
+	if len(runes) <= moderationStreamTailRunes {
+		m.pending = window
+		return "", false
+	}
+	m.pending = string(runes[len(runes)-moderationStreamTailRunes:])
+	return string(runes[:len(runes)-moderationStreamTailRunes]), false
+}
+
+func (m *streamModerator) Flush() string {
+	pending := m.pending
+	m.pending = ""
+	return pending
+}
+
+var _ relaycommon.StreamModerator = (*streamModerator)(nil)
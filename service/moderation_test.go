@@ -0,0 +1,81 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestStreamModerator(t *testing.T, action string) *streamModerator {
+	t.Helper()
+	setupTestDB(t)
+	user := createTestUser(t, "moderation", 0)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	rules := getModerationRules(&operation_setting.ModerationSetting{
+		Providers: []string{operation_setting.ModerationProviderKeyword},
+		Keywords:  map[string][]string{"secret": {"password123"}},
+	})
+	return &streamModerator{
+		c:           c,
+		info:        &relaycommon.RelayInfo{UserId: user.Id, IsStream: true},
+		policy:      operation_setting.ModerationPolicy{Action: action, CheckCompletion: true},
+		rules:       rules,
+		replacement: "***",
+		logged:      map[string]bool{},
+	}
+}
+
+func TestStreamModeratorRedactsMatchAcrossChunks(t *testing.T) {
+	moderator := newTestStreamModerator(t, operation_setting.ModerationActionRedact)
+	filler := strings.Repeat("x", moderationStreamTailRunes)
+
+	var emitted []string
+	for _, delta := range []string{filler + "my pass", "word123 is", " here" + filler} {
+		out, stop := moderator.Moderate(delta)
+		if stop {
+			t.Fatal("redact policy should not stop the stream")
+		}
+		emitted = append(emitted, out)
+	}
+	// 命中内容的前半段在第一个分片中，审核前不能下发
+	if strings.Contains(emitted[0], "pass") {
+		t.Fatalf("unchecked tail was emitted: %q", emitted[0])
+	}
+	output := strings.Join(emitted, "") + moderator.Flush()
+	if strings.Contains(output, "password123") {
+		t.Fatalf("match spanning chunks was not redacted: %q", output)
+	}
+	if output != filler+"my *** is here"+filler {
+		t.Fatalf("unexpected output %q", output)
+	}
+
+	var count int64
+	model.LOG_DB.Model(&model.Log{}).Where("type = ?", model.LogTypeModeration).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected one moderation log, got %d", count)
+	}
+}
+
+func TestStreamModeratorBlocksBeforeEmittingMatch(t *testing.T) {
+	moderator := newTestStreamModerator(t, operation_setting.ModerationActionBlock)
+
+	out, stop := moderator.Moderate("the passw")
+	if stop || out != "" {
+		t.Fatalf("short text should be held back, got %q, %v", out, stop)
+	}
+	out, stop = moderator.Moderate("ord123")
+	if !stop || out != "" {
+		t.Fatalf("expected stop without output, got %q, %v", out, stop)
+	}
+	if rest := moderator.Flush(); rest != "" {
+		t.Fatalf("held text must be dropped after a block, got %q", rest)
+	}
+}
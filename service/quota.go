@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/metrics"
+	"github.com/QuantumNous/new-api/common/tracing"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
@@ -19,6 +21,8 @@ import (
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/bytedance/gopkg/util/gopool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/gin-gonic/gin"
 	"github.com/shopspring/decimal"
@@ -37,6 +41,22 @@ type QuotaInfo struct {
 	ModelPrice    float64
 	ModelRatio    float64
 	GroupRatio    float64
+	PricingRule   *types.PricingRuleInfo
+}
+
+// SettlePricingRule 按实际输入 tokens 重新匹配计价规则，实际用量与预估落在不同区间时以实际用量为准。
+// inputTokens 需包含缓存读取与写入的部分，与预扣费时按完整提示词估算的口径一致
+func SettlePricingRule(relayInfo *relaycommon.RelayInfo, inputTokens int) {
+	rule := ratio_setting.MatchPricingRule(relayInfo.OriginModelName, relayInfo.UsingGroup, inputTokens, relayInfo.StartTime)
+	relayInfo.PriceData.ApplyPricingRule(rule)
+}
+
+// PricingInputTokens 返回匹配计价区间使用的全部输入 tokens，promptExcludesCache 表示 usage.PromptTokens 不含缓存读写的部分
+func PricingInputTokens(usage *dto.Usage, promptExcludesCache bool) int {
+	if !promptExcludesCache {
+		return usage.PromptTokens
+	}
+	return usage.PromptTokens + usage.PromptTokensDetails.CachedTokens + usage.PromptTokensDetails.CachedCreationTokens
 }
 
 func hasCustomModelRatio(modelName string, currentRatio float64) bool {
@@ -57,7 +77,7 @@ func calculateAudioQuota(info QuotaInfo) int {
 		return int(quota.IntPart())
 	}
 
-	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(info.ModelName))
+	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(info.ModelName) * info.PricingRule.CompletionRatioMultiplier())
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(info.ModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(info.ModelName))
 
@@ -90,7 +110,7 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	if relayInfo.UsePrice {
 		return nil
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := GetUserAvailableQuota(relayInfo)
 	if err != nil {
 		return err
 	}
@@ -146,8 +166,13 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 		return fmt.Errorf("token quota is not enough, token remain quota: %s, need quota: %s", logger.FormatQuota(token.RemainQuota), logger.FormatQuota(quota))
 	}
 
+	if apiErr := ReserveSpendBudget(relayInfo, quota); apiErr != nil {
+		return apiErr.Err
+	}
+
 	err = PostConsumeQuota(relayInfo, quota, 0, false)
 	if err != nil {
+		ReleaseSpendBudget(relayInfo)
 		return err
 	}
 	logger.LogInfo(ctx, "realtime streaming consume quota success, quota: "+fmt.Sprintf("%d", quota))
@@ -157,6 +182,11 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelName string,
 	usage *dto.RealtimeUsage, extraContent string) {
 
+	defer BeginQuotaSettle(ctx, relayInfo, &dto.Usage{
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.TotalTokens,
+	})()
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.InputTokenDetails.TextTokens
 	textOutTokens := usage.OutputTokenDetails.TextTokens
@@ -164,8 +194,10 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	audioInputTokens := usage.InputTokenDetails.AudioTokens
 	audioOutTokens := usage.OutputTokenDetails.AudioTokens
 
+	SettlePricingRule(relayInfo, usage.InputTokens)
+
 	tokenName := ctx.GetString("token_name")
-	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(modelName))
+	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(modelName) * relayInfo.PriceData.PricingRule.CompletionRatioMultiplier())
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(relayInfo.OriginModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(modelName))
 
@@ -183,10 +215,11 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 			TextTokens:  textOutTokens,
 			AudioTokens: audioOutTokens,
 		},
-		ModelName:  modelName,
-		UsePrice:   usePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: groupRatio,
+		ModelName:   modelName,
+		UsePrice:    usePrice,
+		ModelRatio:  modelRatio,
+		GroupRatio:  groupRatio,
+		PricingRule: relayInfo.PriceData.PricingRule,
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
@@ -237,10 +270,13 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 
 func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage) {
 
+	defer BeginQuotaSettle(ctx, relayInfo, usage)()
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
 	completionTokens := usage.CompletionTokens
 	modelName := relayInfo.OriginModelName
+	// Claude 的 input_tokens 不含缓存部分（OpenRouter 除外）
+	SettlePricingRule(relayInfo, PricingInputTokens(usage, relayInfo.ChannelType != constant.ChannelTypeOpenRouter))
 
 	tokenName := ctx.GetString("token_name")
 	completionRatio := relayInfo.PriceData.CompletionRatio
@@ -376,6 +412,7 @@ func CalcOpenRouterCacheCreateTokens(usage dto.Usage, priceData types.PriceData)
 
 func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent string) {
 
+	defer BeginQuotaSettle(ctx, relayInfo, usage)()
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.PromptTokensDetails.TextTokens
 	textOutTokens := usage.CompletionTokenDetails.TextTokens
@@ -383,8 +420,10 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	audioInputTokens := usage.PromptTokensDetails.AudioTokens
 	audioOutTokens := usage.CompletionTokenDetails.AudioTokens
 
+	SettlePricingRule(relayInfo, usage.PromptTokens)
+
 	tokenName := ctx.GetString("token_name")
-	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(relayInfo.OriginModelName))
+	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(relayInfo.OriginModelName) * relayInfo.PriceData.PricingRule.CompletionRatioMultiplier())
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(relayInfo.OriginModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(relayInfo.OriginModelName))
 
@@ -402,10 +441,11 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 			TextTokens:  textOutTokens,
 			AudioTokens: audioOutTokens,
 		},
-		ModelName:  relayInfo.OriginModelName,
-		UsePrice:   usePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: groupRatio,
+		ModelName:   relayInfo.OriginModelName,
+		UsePrice:    usePrice,
+		ModelRatio:  modelRatio,
+		GroupRatio:  groupRatio,
+		PricingRule: relayInfo.PriceData.PricingRule,
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
@@ -501,12 +541,41 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	return nil
 }
 
+// StartQuotaSettleSpan 开启额度结算 Span，记录本次计费的 Token 数
+func StartQuotaSettleSpan(c *gin.Context, usage *dto.Usage) (trace.Span, func()) {
+	return tracing.StartSpan(c, "quota.settle",
+		attribute.Int("gen_ai.usage.input_tokens", usage.PromptTokens),
+		attribute.Int("gen_ai.usage.output_tokens", usage.CompletionTokens),
+	)
+}
+
+// BeginQuotaSettle 按用量结算的统一入口：开启结算 Span、记录输出速度，并按实际用量结算预留的 TPM 配额，
+// 返回的函数用于结束 Span
+func BeginQuotaSettle(c *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage) func() {
+	_, endSpan := StartQuotaSettleSpan(c, usage)
+	ObserveOutputThroughput(relayInfo, usage.CompletionTokens)
+	SettleTokenRateLimit(c, usage)
+	return endSpan
+}
+
+// ObserveOutputThroughput 记录上游输出速度，流式请求从首字开始计时，命中响应缓存时不记录
+func ObserveOutputThroughput(relayInfo *relaycommon.RelayInfo, completionTokens int) {
+	if relayInfo.ChannelMeta == nil || relayInfo.ResponseCacheHit {
+		return
+	}
+	start := relayInfo.StartTime
+	if relayInfo.IsStream && relayInfo.HasSendResponse() {
+		start = relayInfo.FirstResponseTime
+	}
+	metrics.ObserveOutputThroughput(relayInfo.OriginModelName, relayInfo.ChannelId, completionTokens, time.Since(start))
+}
+
 func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool) (err error) {
 
 	if quota > 0 {
-		err = model.DecreaseUserQuota(relayInfo.UserId, quota)
+		err = settleUserQuota(relayInfo, quota)
 	} else {
-		err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false)
+		err = refundUserQuota(relayInfo, -quota)
 	}
 	if err != nil {
 		return err
@@ -523,7 +592,10 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		}
 	}
 
-	if sendEmail {
+	settleSpendBudget(relayInfo, quota)
+
+	// 组织额度由组织管理，不向成员发送余额提醒
+	if sendEmail && relayInfo.TokenOrganization.Id == 0 {
 		if (quota + preConsumedQuota) != 0 {
 			checkAndSendQuotaNotify(relayInfo, quota, preConsumedQuota)
 		}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+func TestSettlePricingRuleMatchesTotalInputTokens(t *testing.T) {
+	original := ratio_setting.PricingRules2JSONString()
+	t.Cleanup(func() {
+		_ = ratio_setting.UpdatePricingRulesByJSONString(original)
+	})
+	err := ratio_setting.UpdatePricingRulesByJSONString(`[
+		{"name": "long", "models": ["claude-*"], "min_prompt_tokens": 1000, "input_multiplier": 2, "output_multiplier": 2},
+		{"name": "short", "models": ["claude-*"], "max_prompt_tokens": 1000, "input_multiplier": 1}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newInfo := func() *relaycommon.RelayInfo {
+		info := &relaycommon.RelayInfo{
+			OriginModelName: "claude-sonnet-4",
+			UsingGroup:      "default",
+			StartTime:       time.Now(),
+			PriceData:       types.PriceData{ModelRatio: 1.5, CompletionRatio: 5},
+		}
+		// 预扣费时按预估的提示词命中短上下文区间
+		info.PriceData.ApplyPricingRule(ratio_setting.MatchPricingRule(info.OriginModelName, info.UsingGroup, 800, info.StartTime))
+		return info
+	}
+
+	// Claude 的 input_tokens 不含缓存，缓存读写计入后超过区间上限
+	usage := &dto.Usage{PromptTokens: 300}
+	usage.PromptTokensDetails.CachedTokens = 600
+	usage.PromptTokensDetails.CachedCreationTokens = 200
+	if tokens := PricingInputTokens(usage, true); tokens != 1100 {
+		t.Fatalf("input tokens = %d", tokens)
+	}
+	info := newInfo()
+	SettlePricingRule(info, PricingInputTokens(usage, true))
+	if info.PriceData.PricingRule == nil || info.PriceData.PricingRule.Name != "long" {
+		t.Fatalf("expected the long context tier, got %+v", info.PriceData.PricingRule)
+	}
+	if info.PriceData.ModelRatio != 3 || info.PriceData.CompletionRatio != 5 {
+		t.Fatalf("unexpected ratios after settling: %+v", info.PriceData)
+	}
+
+	// OpenAI 格式的 prompt_tokens 已包含缓存部分，不能重复计入
+	usage = &dto.Usage{PromptTokens: 900}
+	usage.PromptTokensDetails.CachedTokens = 600
+	info = newInfo()
+	SettlePricingRule(info, PricingInputTokens(usage, false))
+	if info.PriceData.PricingRule == nil || info.PriceData.PricingRule.Name != "short" || info.PriceData.ModelRatio != 1.5 {
+		t.Fatalf("expected the short context tier, got %+v", info.PriceData)
+	}
+}
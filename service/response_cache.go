@@ -0,0 +1,150 @@
+package service
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+const responseCacheKeyPrefix = "response_cache:"
+
+// ResponseCacheEntry 缓存的上游响应，流式响应保存每个 SSE data 块用于回放
+type ResponseCacheEntry struct {
+	IsStream    bool      `json:"is_stream"`
+	ContentType string    `json:"content_type,omitempty"`
+	Body        []byte    `json:"body,omitempty"`
+	Chunks      []string  `json:"chunks,omitempty"`
+	Usage       dto.Usage `json:"usage"`
+	CreatedAt   int64     `json:"created_at"`
+}
+
+// GetResponseCacheKey 由用户、分组、上游模型名与规范化后的请求体计算缓存键。
+// 缓存按用户与分组隔离，避免不同用户之间共享响应，也避免跨分组命中绕过分组计费
+func GetResponseCacheKey(userId int, group string, upstreamModel string, body []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var request map[string]any
+	if err := decoder.Decode(&request); err != nil {
+		return "", err
+	}
+	// 不影响生成结果的字段
+	delete(request, "user")
+	// encoding/json 对 map 的键排序，保证字段顺序不同的请求得到相同的键
+	normalized, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(strconv.Itoa(userId)))
+	hash.Write([]byte{0})
+	hash.Write([]byte(group))
+	hash.Write([]byte{0})
+	hash.Write([]byte(upstreamModel))
+	hash.Write([]byte{0})
+	hash.Write(normalized)
+	return responseCacheKeyPrefix + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func GetResponseCache(key string) (*ResponseCacheEntry, bool) {
+	if common.RedisEnabled {
+		data, err := common.RedisGet(key)
+		if err != nil || data == "" {
+			return nil, false
+		}
+		var entry ResponseCacheEntry
+		if err = common.UnmarshalJsonStr(data, &entry); err != nil {
+			return nil, false
+		}
+		return &entry, true
+	}
+	return responseMemoryCache.get(key)
+}
+
+func SetResponseCache(key string, entry *ResponseCacheEntry) {
+	setting := operation_setting.GetResponseCacheSetting()
+	ttl := time.Duration(setting.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		return
+	}
+	entry.CreatedAt = common.GetTimestamp()
+	if common.RedisEnabled {
+		data, err := common.Marshal(entry)
+		if err != nil {
+			return
+		}
+		if err = common.RedisSet(key, string(data), ttl); err != nil {
+			common.SysError("failed to set response cache: " + err.Error())
+		}
+		return
+	}
+	responseMemoryCache.set(key, entry, ttl, setting.MemoryMaxEntries)
+}
+
+type responseCacheItem struct {
+	key       string
+	entry     *ResponseCacheEntry
+	expiresAt time.Time
+}
+
+// responseLRUCache 未启用 Redis 时使用的内存 LRU 缓存
+type responseLRUCache struct {
+	mu    sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+}
+
+var responseMemoryCache = &responseLRUCache{
+	items: make(map[string]*list.Element),
+	order: list.New(),
+}
+
+func (l *responseLRUCache) get(key string) (*ResponseCacheEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := elem.Value.(*responseCacheItem)
+	if time.Now().After(item.expiresAt) {
+		l.order.Remove(elem)
+		delete(l.items, key)
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return item.entry, true
+}
+
+func (l *responseLRUCache) set(key string, entry *ResponseCacheEntry, ttl time.Duration, maxEntries int) {
+	if maxEntries <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.items[key]; ok {
+		item := elem.Value.(*responseCacheItem)
+		item.entry = entry
+		item.expiresAt = time.Now().Add(ttl)
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.items[key] = l.order.PushFront(&responseCacheItem{
+		key:       key,
+		entry:     entry,
+		expiresAt: time.Now().Add(ttl),
+	})
+	for l.order.Len() > maxEntries {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*responseCacheItem).key)
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+func TestResponseCacheKeyScope(t *testing.T) {
+	body := []byte(`{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"hi"}]}`)
+	reordered := []byte(`{"messages":[{"role":"user","content":"hi"}],"temperature":0,"model":"gpt-4o","user":"someone"}`)
+
+	key, err := GetResponseCacheKey(1, "default", "gpt-4o", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, _ := GetResponseCacheKey(1, "default", "gpt-4o", reordered)
+	if key != same {
+		t.Fatal("field order and user field should not change the key")
+	}
+	otherUser, _ := GetResponseCacheKey(2, "default", "gpt-4o", body)
+	if key == otherUser {
+		t.Fatal("different users must not share a cache key")
+	}
+	otherGroup, _ := GetResponseCacheKey(1, "vip", "gpt-4o", body)
+	if key == otherGroup {
+		t.Fatal("different groups must not share a cache key")
+	}
+	otherModel, _ := GetResponseCacheKey(1, "default", "gpt-4o-mini", body)
+	if key == otherModel {
+		t.Fatal("different upstream models must not share a cache key")
+	}
+}
+
+func TestResponseCacheNotSharedAcrossUsers(t *testing.T) {
+	originalRedis := common.RedisEnabled
+	common.RedisEnabled = false
+	t.Cleanup(func() { common.RedisEnabled = originalRedis })
+
+	body := []byte(`{"model":"text-embedding-3-small","input":"hello"}`)
+	keyA, _ := GetResponseCacheKey(101, "default", "text-embedding-3-small", body)
+	keyB, _ := GetResponseCacheKey(102, "default", "text-embedding-3-small", body)
+	SetResponseCache(keyA, &ResponseCacheEntry{ContentType: "application/json", Body: []byte(`{"data":[]}`)})
+
+	if _, ok := GetResponseCache(keyA); !ok {
+		t.Fatal("expected cache hit for the same user")
+	}
+	if _, ok := GetResponseCache(keyB); ok {
+		t.Fatal("another user must not hit the cached entry")
+	}
+}
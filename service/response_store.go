@@ -0,0 +1,147 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// responsesInputItems 将 input 规范化为条目数组，字符串输入视为一条用户消息
+func responsesInputItems(input json.RawMessage) ([]json.RawMessage, error) {
+	if len(input) == 0 {
+		return nil, nil
+	}
+	if common.GetJsonType(input) == "string" {
+		var text string
+		if err := common.Unmarshal(input, &text); err != nil {
+			return nil, err
+		}
+		item, err := common.Marshal(map[string]any{"type": "message", "role": "user", "content": text})
+		if err != nil {
+			return nil, err
+		}
+		return []json.RawMessage{item}, nil
+	}
+	var items []json.RawMessage
+	if err := common.Unmarshal(input, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// PrepareResponsesStore 若 previous_response_id 指向网关保存的响应，则将其历史输入与输出展开到本次请求的 input 中
+func PrepareResponsesStore(c *gin.Context, info *relaycommon.RelayInfo, request *dto.OpenAIResponsesRequest) *types.NewAPIError {
+	info.ResponsesStore = nil
+	if !operation_setting.GetResponseStoreSetting().Enabled {
+		return nil
+	}
+	store := &relaycommon.ResponsesStoreInfo{Store: strings.TrimSpace(string(request.Store)) != "false"}
+	info.ResponsesStore = store
+
+	items, err := responsesInputItems(request.Input)
+	if err != nil {
+		return types.NewErrorWithStatusCode(fmt.Errorf("invalid input: %w", err), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	if request.PreviousResponseID != "" {
+		stored, err := model.GetTokenStoredResponse(info.TokenId, request.PreviousResponseID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+		}
+		if stored != nil {
+			var history []json.RawMessage
+			if len(stored.Input) > 0 {
+				if err := common.Unmarshal(stored.Input, &history); err != nil {
+					return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+				}
+			}
+			var response struct {
+				Output []json.RawMessage `json:"output"`
+			}
+			if err := common.Unmarshal(stored.Response, &response); err != nil {
+				return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+			}
+			items = append(append(history, response.Output...), items...)
+			input, err := common.Marshal(items)
+			if err != nil {
+				return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+			}
+			store.Expanded = true
+			store.PreviousResponseId = request.PreviousResponseID
+			request.Input = input
+			request.PreviousResponseID = ""
+		}
+	}
+	if items == nil {
+		items = []json.RawMessage{}
+	}
+	store.Input, _ = common.Marshal(items)
+	return nil
+}
+
+// SaveResponsesStore 保存本次响应，失败时只记录日志
+func SaveResponsesStore(c *gin.Context, info *relaycommon.RelayInfo) {
+	store := info.ResponsesStore
+	if store == nil || !store.Save || len(store.Response) == 0 {
+		return
+	}
+	setting := operation_setting.GetResponseStoreSetting()
+	if setting.MaxEntrySizeKB > 0 && len(store.Input)+len(store.Response) > setting.MaxEntrySizeKB*1024 {
+		logger.LogWarn(c, fmt.Sprintf("response exceeds %d KB, not stored", setting.MaxEntrySizeKB))
+		return
+	}
+	var response struct {
+		ID     string `json:"id"`
+		Model  string `json:"model"`
+		Status string `json:"status"`
+	}
+	if err := common.Unmarshal(store.Response, &response); err != nil || response.ID == "" {
+		return
+	}
+	if response.Status == "failed" || response.Status == "cancelled" {
+		return
+	}
+	now := common.GetTimestamp()
+	stored := &model.StoredResponse{
+		ResponseId:         response.ID,
+		UserId:             info.UserId,
+		TokenId:            info.TokenId,
+		Model:              response.Model,
+		PreviousResponseId: store.PreviousResponseId,
+		CreatedAt:          now,
+		ExpiresAt:          now + int64(setting.TTLSeconds),
+		Input:              store.Input,
+		Response:           store.Response,
+	}
+	if err := stored.Insert(setting.MaxResponsesPerToken); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to store response %s: %s", response.ID, err.Error()))
+	}
+}
+
+// StartResponseStoreCleanupTask 定期删除过期的 Responses 会话状态
+func StartResponseStoreCleanupTask() {
+	gopool.Go(func() {
+		for {
+			time.Sleep(time.Hour)
+			if count, err := model.DeleteExpiredStoredResponses(); err != nil {
+				common.SysError("failed to delete expired stored responses: " + err.Error())
+			} else if count > 0 {
+				common.SysLog(fmt.Sprintf("deleted %d expired stored responses", count))
+			}
+		}
+	})
+}
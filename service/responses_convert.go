@@ -0,0 +1,669 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// responsesInputItem Responses API input 数组中的单个条目
+type responsesInputItem struct {
+	Type      string          `json:"type"`
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"`
+	CallId    string          `json:"call_id"`
+	Name      string          `json:"name"`
+	Arguments string          `json:"arguments"`
+	Output    json.RawMessage `json:"output"`
+	Summary   []struct {
+		Text string `json:"text"`
+	} `json:"summary"`
+}
+
+// responsesContentPart 消息条目中的内容片段
+type responsesContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Refusal  string `json:"refusal"`
+	ImageUrl any    `json:"image_url"`
+	Detail   string `json:"detail"`
+	FileId   string `json:"file_id"`
+	FileData string `json:"file_data"`
+	FileUrl  string `json:"file_url"`
+	Filename string `json:"filename"`
+}
+
+type responsesFunctionTool struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Parameters  any    `json:"parameters"`
+}
+
+type responsesTextFormat struct {
+	Format *struct {
+		Type        string          `json:"type"`
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Schema      any             `json:"schema"`
+		Strict      json.RawMessage `json:"strict"`
+	} `json:"format"`
+}
+
+// ResponsesToOpenAIRequest 将 Responses API 请求转换为 Chat Completions 请求，供仅支持对话接口的渠道使用
+func ResponsesToOpenAIRequest(request *dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
+	openAIRequest := &dto.GeneralOpenAIRequest{
+		Model:     request.Model,
+		Stream:    request.Stream,
+		MaxTokens: request.MaxOutputTokens,
+		TopP:      request.TopP,
+		User:      request.User,
+		Metadata:  request.Metadata,
+	}
+	if request.Stream {
+		openAIRequest.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+	if request.Temperature != 0 {
+		temperature := request.Temperature
+		openAIRequest.Temperature = &temperature
+	}
+	if request.Reasoning != nil {
+		openAIRequest.ReasoningEffort = request.Reasoning.Effort
+	}
+	if len(request.ParallelToolCalls) > 0 {
+		var parallel bool
+		if err := common.Unmarshal(request.ParallelToolCalls, &parallel); err == nil {
+			openAIRequest.ParallelTooCalls = &parallel
+		}
+	}
+
+	if len(request.Instructions) > 0 && common.GetJsonType(request.Instructions) == "string" {
+		var instructions string
+		if err := common.Unmarshal(request.Instructions, &instructions); err != nil {
+			return nil, fmt.Errorf("invalid instructions: %w", err)
+		}
+		if instructions != "" {
+			openAIRequest.Messages = append(openAIRequest.Messages, dto.Message{Role: "system", Content: instructions})
+		}
+	}
+
+	messages, err := responsesInputToMessages(request.Input)
+	if err != nil {
+		return nil, err
+	}
+	openAIRequest.Messages = append(openAIRequest.Messages, messages...)
+
+	if len(request.Tools) > 0 {
+		var tools []responsesFunctionTool
+		if err := common.Unmarshal(request.Tools, &tools); err != nil {
+			return nil, fmt.Errorf("invalid tools: %w", err)
+		}
+		for _, tool := range tools {
+			if tool.Type != "function" {
+				return nil, fmt.Errorf("built-in tool %s is not supported by this channel", tool.Type)
+			}
+			openAIRequest.Tools = append(openAIRequest.Tools, dto.ToolCallRequest{
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:        tool.Name,
+					Description: tool.Description,
+					Parameters:  tool.Parameters,
+				},
+			})
+		}
+	}
+
+	if len(request.ToolChoice) > 0 {
+		toolChoice, err := responsesToolChoiceToOpenAI(request.ToolChoice)
+		if err != nil {
+			return nil, err
+		}
+		openAIRequest.ToolChoice = toolChoice
+	}
+
+	if len(request.Text) > 0 {
+		var text responsesTextFormat
+		if err := common.Unmarshal(request.Text, &text); err != nil {
+			return nil, fmt.Errorf("invalid text: %w", err)
+		}
+		if text.Format != nil {
+			switch text.Format.Type {
+			case "json_object":
+				openAIRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+			case "json_schema":
+				schema, err := common.Marshal(dto.FormatJsonSchema{
+					Name:        text.Format.Name,
+					Description: text.Format.Description,
+					Schema:      text.Format.Schema,
+					Strict:      text.Format.Strict,
+				})
+				if err != nil {
+					return nil, err
+				}
+				openAIRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_schema", JsonSchema: schema}
+			}
+		}
+	}
+	return openAIRequest, nil
+}
+
+func responsesToolChoiceToOpenAI(raw json.RawMessage) (any, error) {
+	if common.GetJsonType(raw) == "string" {
+		var choice string
+		if err := common.Unmarshal(raw, &choice); err != nil {
+			return nil, err
+		}
+		return choice, nil
+	}
+	var choice struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+		Mode string `json:"mode"`
+	}
+	if err := common.Unmarshal(raw, &choice); err != nil {
+		return nil, fmt.Errorf("invalid tool_choice: %w", err)
+	}
+	switch choice.Type {
+	case "function":
+		return map[string]any{"type": "function", "function": map[string]any{"name": choice.Name}}, nil
+	case "allowed_tools":
+		if choice.Mode == "required" {
+			return "required", nil
+		}
+		return "auto", nil
+	}
+	return nil, fmt.Errorf("tool_choice %s is not supported by this channel", choice.Type)
+}
+
+// responsesInputToMessages 转换 input：连续的 function_call 合并为同一条 assistant 消息，reasoning 摘要附加到下一条 assistant 消息
+func responsesInputToMessages(input json.RawMessage) ([]dto.Message, error) {
+	if len(input) == 0 {
+		return nil, nil
+	}
+	if common.GetJsonType(input) == "string" {
+		var text string
+		if err := common.Unmarshal(input, &text); err != nil {
+			return nil, fmt.Errorf("invalid input: %w", err)
+		}
+		return []dto.Message{{Role: "user", Content: text}}, nil
+	}
+	var items []responsesInputItem
+	if err := common.Unmarshal(input, &items); err != nil {
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	var messages []dto.Message
+	var pendingReasoning string
+	var pendingToolCalls []dto.ToolCallRequest
+	flushToolCalls := func() {
+		if len(pendingToolCalls) == 0 {
+			return
+		}
+		message := dto.Message{Role: "assistant", ReasoningContent: pendingReasoning}
+		message.SetNullContent()
+		message.SetToolCalls(pendingToolCalls)
+		messages = append(messages, message)
+		pendingReasoning = ""
+		pendingToolCalls = nil
+	}
+
+	for _, item := range items {
+		itemType := item.Type
+		if itemType == "" && item.Role != "" {
+			itemType = "message"
+		}
+		switch itemType {
+		case "message":
+			flushToolCalls()
+			message, err := responsesMessageItemToMessage(item)
+			if err != nil {
+				return nil, err
+			}
+			if message.Role == "assistant" && pendingReasoning != "" {
+				message.ReasoningContent = pendingReasoning
+				pendingReasoning = ""
+			}
+			messages = append(messages, message)
+		case "function_call":
+			pendingToolCalls = append(pendingToolCalls, dto.ToolCallRequest{
+				ID:   item.CallId,
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			})
+		case "function_call_output":
+			flushToolCalls()
+			messages = append(messages, dto.Message{
+				Role:       "tool",
+				Content:    responsesFunctionOutputText(item.Output),
+				ToolCallId: item.CallId,
+			})
+		case "reasoning":
+			flushToolCalls()
+			for _, summary := range item.Summary {
+				pendingReasoning += summary.Text
+			}
+		default:
+			return nil, fmt.Errorf("input item type %s is not supported by this channel", item.Type)
+		}
+	}
+	flushToolCalls()
+	return messages, nil
+}
+
+func responsesMessageItemToMessage(item responsesInputItem) (dto.Message, error) {
+	role := item.Role
+	if role == "developer" {
+		role = "system"
+	}
+	message := dto.Message{Role: role}
+	if common.GetJsonType(item.Content) == "string" {
+		var text string
+		if err := common.Unmarshal(item.Content, &text); err != nil {
+			return message, err
+		}
+		message.SetStringContent(text)
+		return message, nil
+	}
+	var parts []responsesContentPart
+	if err := common.Unmarshal(item.Content, &parts); err != nil {
+		return message, fmt.Errorf("invalid message content: %w", err)
+	}
+	contents := make([]dto.MediaContent, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "input_text", "output_text":
+			contents = append(contents, dto.MediaContent{Type: dto.ContentTypeText, Text: part.Text})
+		case "refusal":
+			contents = append(contents, dto.MediaContent{Type: dto.ContentTypeText, Text: part.Refusal})
+		case "input_image":
+			url, _ := part.ImageUrl.(string)
+			if m, ok := part.ImageUrl.(map[string]any); ok {
+				url, _ = m["url"].(string)
+			}
+			if url == "" {
+				return message, fmt.Errorf("input_image without image_url is not supported by this channel")
+			}
+			contents = append(contents, dto.MediaContent{
+				Type:     dto.ContentTypeImageURL,
+				ImageUrl: &dto.MessageImageUrl{Url: url, Detail: part.Detail},
+			})
+		case "input_file":
+			if part.FileData == "" && part.FileId == "" {
+				return message, fmt.Errorf("input_file without file_data or file_id is not supported by this channel")
+			}
+			contents = append(contents, dto.MediaContent{
+				Type: dto.ContentTypeFile,
+				File: &dto.MessageFile{FileName: part.Filename, FileData: part.FileData, FileId: part.FileId},
+			})
+		default:
+			return message, fmt.Errorf("content type %s is not supported by this channel", part.Type)
+		}
+	}
+	// assistant 历史消息使用纯文本，兼容不支持多段内容的上游
+	if role == "assistant" {
+		var text strings.Builder
+		for _, content := range contents {
+			text.WriteString(content.Text)
+		}
+		message.SetStringContent(text.String())
+		return message, nil
+	}
+	message.SetMediaContent(contents)
+	return message, nil
+}
+
+func responsesFunctionOutputText(output json.RawMessage) string {
+	if common.GetJsonType(output) == "string" {
+		var text string
+		_ = common.Unmarshal(output, &text)
+		return text
+	}
+	var parts []responsesContentPart
+	if err := common.Unmarshal(output, &parts); err == nil {
+		var text strings.Builder
+		for _, part := range parts {
+			text.WriteString(part.Text)
+		}
+		return text.String()
+	}
+	return string(output)
+}
+
+// usageOpenAI2Responses 将 Chat Completions 用量转换为 Responses API 的 input_tokens/output_tokens 格式
+func usageOpenAI2Responses(usage *dto.Usage) *dto.Usage {
+	if usage == nil {
+		return nil
+	}
+	return &dto.Usage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.PromptTokens + usage.CompletionTokens,
+		InputTokensDetails: &dto.InputTokenDetails{
+			CachedTokens: usage.PromptTokensDetails.CachedTokens,
+		},
+	}
+}
+
+// newResponsesResponse 按原始请求回填 Responses 响应中的请求参数
+func newResponsesResponse(request *dto.OpenAIResponsesRequest, id string, createdAt int) *dto.OpenAIResponsesResponse {
+	response := &dto.OpenAIResponsesResponse{
+		ID:                 id,
+		Object:             "response",
+		CreatedAt:          createdAt,
+		Status:             "in_progress",
+		MaxOutputTokens:    int(request.MaxOutputTokens),
+		Model:              request.Model,
+		Output:             []dto.ResponsesOutput{},
+		ParallelToolCalls:  true,
+		PreviousResponseID: request.PreviousResponseID,
+		Reasoning:          request.Reasoning,
+		Temperature:        request.Temperature,
+		ToolChoice:         "auto",
+		Tools:              request.GetToolsMap(),
+		TopP:               request.TopP,
+		Truncation:         request.Truncation,
+		Metadata:           request.Metadata,
+	}
+	if response.Tools == nil {
+		response.Tools = []map[string]any{}
+	}
+	if len(request.Instructions) > 0 && common.GetJsonType(request.Instructions) == "string" {
+		_ = common.Unmarshal(request.Instructions, &response.Instructions)
+	}
+	if len(request.ParallelToolCalls) > 0 {
+		_ = common.Unmarshal(request.ParallelToolCalls, &response.ParallelToolCalls)
+	}
+	if common.GetJsonType(request.ToolChoice) == "string" {
+		_ = common.Unmarshal(request.ToolChoice, &response.ToolChoice)
+	}
+	if request.User != "" {
+		response.User, _ = common.Marshal(request.User)
+	}
+	return response
+}
+
+// setResponsesStatus 按 finish_reason 设置响应状态，截断与内容过滤视为未完成
+func setResponsesStatus(response *dto.OpenAIResponsesResponse, finishReason string) {
+	switch finishReason {
+	case constant.FinishReasonLength:
+		response.Status = "incomplete"
+		response.IncompleteDetails = &dto.IncompleteDetails{Reason: "max_output_tokens"}
+	case constant.FinishReasonContentFilter:
+		response.Status = "incomplete"
+		response.IncompleteDetails = &dto.IncompleteDetails{Reason: "content_filter"}
+	default:
+		response.Status = "completed"
+	}
+}
+
+func newResponsesReasoningItem(id string, text string) dto.ResponsesOutput {
+	return dto.ResponsesOutput{
+		Type:    "reasoning",
+		ID:      id,
+		Summary: []dto.ResponsesOutputContent{{Type: "summary_text", Text: text}},
+	}
+}
+
+func newResponsesMessageItem(id string, status string, text string) dto.ResponsesOutput {
+	return dto.ResponsesOutput{
+		Type:    "message",
+		ID:      id,
+		Status:  status,
+		Role:    "assistant",
+		Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: text, Annotations: []interface{}{}}},
+	}
+}
+
+func newResponsesFunctionCallItem(id string, status string, callId string, name string, arguments string) dto.ResponsesOutput {
+	return dto.ResponsesOutput{
+		Type:      "function_call",
+		ID:        id,
+		Status:    status,
+		CallId:    callId,
+		Name:      name,
+		Arguments: arguments,
+	}
+}
+
+// ResponseOpenAI2Responses 将非流式 Chat Completions 响应转换为 Responses API 响应
+func ResponseOpenAI2Responses(openAIResponse *dto.OpenAITextResponse, request *dto.OpenAIResponsesRequest, id string, usage *dto.Usage) *dto.OpenAIResponsesResponse {
+	response := newResponsesResponse(request, id, int(common.GetTimestamp()))
+	if openAIResponse.Model != "" {
+		response.Model = openAIResponse.Model
+	}
+	finishReason := ""
+	if len(openAIResponse.Choices) > 0 {
+		choice := openAIResponse.Choices[0]
+		finishReason = choice.FinishReason
+		reasoning := choice.Message.ReasoningContent
+		if reasoning == "" {
+			reasoning = choice.Message.Reasoning
+		}
+		if reasoning != "" {
+			response.Output = append(response.Output, newResponsesReasoningItem(fmt.Sprintf("rs_%s_%d", id, len(response.Output)), reasoning))
+		}
+		if text := choice.Message.StringContent(); text != "" {
+			response.Output = append(response.Output, newResponsesMessageItem(fmt.Sprintf("msg_%s_%d", id, len(response.Output)), "completed", text))
+		}
+		for _, toolCall := range choice.Message.ParseToolCalls() {
+			response.Output = append(response.Output, newResponsesFunctionCallItem(fmt.Sprintf("fc_%s_%d", id, len(response.Output)), "completed",
+				toolCall.ID, toolCall.Function.Name, toolCall.Function.Arguments))
+		}
+	}
+	setResponsesStatus(response, finishReason)
+	response.Usage = usageOpenAI2Responses(usage)
+	return response
+}
+
+// responsesStreamToolCall 流式转换中正在生成的函数调用
+type responsesStreamToolCall struct {
+	outputIndex int
+	itemId      string
+	callId      string
+	name        string
+	arguments   strings.Builder
+}
+
+// ResponsesStreamConverter 将 Chat Completions 流式分片转换为 Responses API 的 response.* 事件
+type ResponsesStreamConverter struct {
+	request      *dto.OpenAIResponsesRequest
+	response     *dto.OpenAIResponsesResponse
+	started      bool
+	sequence     int
+	finishReason string
+
+	// 正在输出的文本条目（reasoning 或 message）
+	textType  string
+	textIndex int
+	textId    string
+	text      strings.Builder
+
+	toolCalls     map[int]*responsesStreamToolCall
+	toolCallOrder []int
+}
+
+func NewResponsesStreamConverter(request *dto.OpenAIResponsesRequest, id string) *ResponsesStreamConverter {
+	return &ResponsesStreamConverter{
+		request:   request,
+		response:  newResponsesResponse(request, id, int(common.GetTimestamp())),
+		toolCalls: make(map[int]*responsesStreamToolCall),
+	}
+}
+
+func (s *ResponsesStreamConverter) event(events []dto.ResponsesStreamResponse, event dto.ResponsesStreamResponse) []dto.ResponsesStreamResponse {
+	event.SequenceNumber = s.sequence
+	s.sequence++
+	return append(events, event)
+}
+
+func (s *ResponsesStreamConverter) snapshot() *dto.OpenAIResponsesResponse {
+	response := *s.response
+	response.Output = append([]dto.ResponsesOutput{}, s.response.Output...)
+	return &response
+}
+
+func (s *ResponsesStreamConverter) start(events []dto.ResponsesStreamResponse) []dto.ResponsesStreamResponse {
+	if s.started {
+		return events
+	}
+	s.started = true
+	events = s.event(events, dto.ResponsesStreamResponse{Type: "response.created", Response: s.snapshot()})
+	return s.event(events, dto.ResponsesStreamResponse{Type: "response.in_progress", Response: s.snapshot()})
+}
+
+func (s *ResponsesStreamConverter) openText(events []dto.ResponsesStreamResponse, textType string) []dto.ResponsesStreamResponse {
+	if s.textType == textType {
+		return events
+	}
+	events = s.closeText(events)
+	s.textType = textType
+	s.textIndex = len(s.response.Output)
+	s.text.Reset()
+	outputIndex := s.textIndex
+	zero := 0
+	var item dto.ResponsesOutput
+	if textType == "reasoning" {
+		s.textId = fmt.Sprintf("rs_%s_%d", s.response.ID, outputIndex)
+		item = dto.ResponsesOutput{Type: "reasoning", ID: s.textId, Summary: []dto.ResponsesOutputContent{}}
+		s.response.Output = append(s.response.Output, item)
+		events = s.event(events, dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemAdded, OutputIndex: &outputIndex, Item: &item})
+		return s.event(events, dto.ResponsesStreamResponse{Type: "response.reasoning_summary_part.added", ItemID: s.textId,
+			OutputIndex: &outputIndex, SummaryIndex: &zero, Part: &dto.ResponsesOutputContent{Type: "summary_text"}})
+	}
+	s.textId = fmt.Sprintf("msg_%s_%d", s.response.ID, outputIndex)
+	item = dto.ResponsesOutput{Type: "message", ID: s.textId, Status: "in_progress", Role: "assistant", Content: []dto.ResponsesOutputContent{}}
+	s.response.Output = append(s.response.Output, item)
+	events = s.event(events, dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemAdded, OutputIndex: &outputIndex, Item: &item})
+	return s.event(events, dto.ResponsesStreamResponse{Type: "response.content_part.added", ItemID: s.textId,
+		OutputIndex: &outputIndex, ContentIndex: &zero, Part: &dto.ResponsesOutputContent{Type: "output_text", Annotations: []interface{}{}}})
+}
+
+func (s *ResponsesStreamConverter) closeText(events []dto.ResponsesStreamResponse) []dto.ResponsesStreamResponse {
+	if s.textType == "" {
+		return events
+	}
+	outputIndex := s.textIndex
+	zero := 0
+	text := s.text.String()
+	var item dto.ResponsesOutput
+	if s.textType == "reasoning" {
+		item = newResponsesReasoningItem(s.textId, text)
+		part := item.Summary[0]
+		events = s.event(events, dto.ResponsesStreamResponse{Type: "response.reasoning_summary_text.done", ItemID: s.textId,
+			OutputIndex: &outputIndex, SummaryIndex: &zero, Text: &text})
+		events = s.event(events, dto.ResponsesStreamResponse{Type: "response.reasoning_summary_part.done", ItemID: s.textId,
+			OutputIndex: &outputIndex, SummaryIndex: &zero, Part: &part})
+	} else {
+		item = newResponsesMessageItem(s.textId, "completed", text)
+		part := item.Content[0]
+		events = s.event(events, dto.ResponsesStreamResponse{Type: "response.output_text.done", ItemID: s.textId,
+			OutputIndex: &outputIndex, ContentIndex: &zero, Text: &text})
+		events = s.event(events, dto.ResponsesStreamResponse{Type: "response.content_part.done", ItemID: s.textId,
+			OutputIndex: &outputIndex, ContentIndex: &zero, Part: &part})
+	}
+	s.response.Output[outputIndex] = item
+	s.textType = ""
+	return s.event(events, dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemDone, OutputIndex: &outputIndex, Item: &item})
+}
+
+func (s *ResponsesStreamConverter) appendText(events []dto.ResponsesStreamResponse, textType string, delta string) []dto.ResponsesStreamResponse {
+	events = s.openText(events, textType)
+	s.text.WriteString(delta)
+	outputIndex := s.textIndex
+	zero := 0
+	if textType == "reasoning" {
+		return s.event(events, dto.ResponsesStreamResponse{Type: "response.reasoning_summary_text.delta", ItemID: s.textId,
+			OutputIndex: &outputIndex, SummaryIndex: &zero, Delta: delta})
+	}
+	return s.event(events, dto.ResponsesStreamResponse{Type: "response.output_text.delta", ItemID: s.textId,
+		OutputIndex: &outputIndex, ContentIndex: &zero, Delta: delta})
+}
+
+func (s *ResponsesStreamConverter) appendToolCall(events []dto.ResponsesStreamResponse, toolCall dto.ToolCallResponse) []dto.ResponsesStreamResponse {
+	index := 0
+	if toolCall.Index != nil {
+		index = *toolCall.Index
+	}
+	call, ok := s.toolCalls[index]
+	if !ok {
+		events = s.closeText(events)
+		call = &responsesStreamToolCall{
+			outputIndex: len(s.response.Output),
+			callId:      toolCall.ID,
+			name:        toolCall.Function.Name,
+		}
+		call.itemId = fmt.Sprintf("fc_%s_%d", s.response.ID, call.outputIndex)
+		s.toolCalls[index] = call
+		s.toolCallOrder = append(s.toolCallOrder, index)
+		item := newResponsesFunctionCallItem(call.itemId, "in_progress", call.callId, call.name, "")
+		s.response.Output = append(s.response.Output, item)
+		outputIndex := call.outputIndex
+		events = s.event(events, dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemAdded, OutputIndex: &outputIndex, Item: &item})
+	}
+	if toolCall.Function.Arguments == "" {
+		return events
+	}
+	call.arguments.WriteString(toolCall.Function.Arguments)
+	outputIndex := call.outputIndex
+	return s.event(events, dto.ResponsesStreamResponse{Type: "response.function_call_arguments.delta", ItemID: call.itemId,
+		OutputIndex: &outputIndex, Delta: toolCall.Function.Arguments})
+}
+
+// Convert 转换一个 Chat Completions 流式分片，返回需要发送的事件
+func (s *ResponsesStreamConverter) Convert(chunk *dto.ChatCompletionsStreamResponse) []dto.ResponsesStreamResponse {
+	events := s.start(nil)
+	if chunk.Model != "" {
+		s.response.Model = chunk.Model
+	}
+	for _, choice := range chunk.Choices {
+		if reasoning := choice.Delta.GetReasoningContent(); reasoning != "" {
+			events = s.appendText(events, "reasoning", reasoning)
+		}
+		if content := choice.Delta.GetContentString(); content != "" {
+			events = s.appendText(events, "message", content)
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			events = s.appendToolCall(events, toolCall)
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.finishReason = *choice.FinishReason
+		}
+	}
+	return events
+}
+
+// Response 返回当前的完整响应，在 Finish 之后调用时即为最终结果
+func (s *ResponsesStreamConverter) Response() *dto.OpenAIResponsesResponse {
+	return s.snapshot()
+}
+
+// Finish 结束所有未完成的条目并返回最终的 response.completed 或 response.incomplete 事件
+func (s *ResponsesStreamConverter) Finish(usage *dto.Usage) []dto.ResponsesStreamResponse {
+	events := s.start(nil)
+	events = s.closeText(events)
+	for _, index := range s.toolCallOrder {
+		call := s.toolCalls[index]
+		outputIndex := call.outputIndex
+		arguments := call.arguments.String()
+		item := newResponsesFunctionCallItem(call.itemId, "completed", call.callId, call.name, arguments)
+		s.response.Output[outputIndex] = item
+		events = s.event(events, dto.ResponsesStreamResponse{Type: "response.function_call_arguments.done", ItemID: call.itemId,
+			OutputIndex: &outputIndex, Arguments: arguments})
+		events = s.event(events, dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemDone, OutputIndex: &outputIndex, Item: &item})
+	}
+	s.toolCallOrder = nil
+	setResponsesStatus(s.response, s.finishReason)
+	s.response.Usage = usageOpenAI2Responses(usage)
+	eventType := "response.completed"
+	if s.response.Status == "incomplete" {
+		eventType = "response.incomplete"
+	}
+	return s.event(events, dto.ResponsesStreamResponse{Type: eventType, Response: s.snapshot()})
+}
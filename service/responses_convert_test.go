@@ -0,0 +1,186 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+func TestResponsesToOpenAIRequest(t *testing.T) {
+	var request dto.OpenAIResponsesRequest
+	err := common.UnmarshalJsonStr(`{
+		"model": "claude-sonnet-4",
+		"stream": true,
+		"instructions": "be brief",
+		"max_output_tokens": 256,
+		"input": [
+			{"role": "developer", "content": "answer in English"},
+			{"type": "message", "role": "user", "content": [{"type": "input_text", "text": "weather?"}, {"type": "input_image", "image_url": "https://example.com/a.png"}]},
+			{"type": "reasoning", "summary": [{"text": "need a tool"}]},
+			{"type": "function_call", "call_id": "call_1", "name": "get_weather", "arguments": "{\"city\":\"Paris\"}"},
+			{"type": "function_call", "call_id": "call_2", "name": "get_time", "arguments": "{}"},
+			{"type": "function_call_output", "call_id": "call_1", "output": "sunny"},
+			{"type": "function_call_output", "call_id": "call_2", "output": [{"type": "output_text", "text": "noon"}]}
+		],
+		"tools": [{"type": "function", "name": "get_weather", "parameters": {"type": "object"}}],
+		"tool_choice": {"type": "function", "name": "get_weather"},
+		"text": {"format": {"type": "json_schema", "name": "answer", "schema": {"type": "object"}}}
+	}`, &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	openAIRequest, err := ResponsesToOpenAIRequest(&request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if openAIRequest.StreamOptions == nil || !openAIRequest.StreamOptions.IncludeUsage {
+		t.Fatal("streaming requests should ask for usage")
+	}
+	if openAIRequest.MaxTokens != 256 {
+		t.Fatalf("max_tokens = %d", openAIRequest.MaxTokens)
+	}
+
+	messages := openAIRequest.Messages
+	roles := make([]string, 0, len(messages))
+	for _, message := range messages {
+		roles = append(roles, message.Role)
+	}
+	expected := []string{"system", "system", "user", "assistant", "tool", "tool"}
+	if common.GetJsonString(roles) != common.GetJsonString(expected) {
+		t.Fatalf("roles = %v, want %v", roles, expected)
+	}
+	if messages[0].StringContent() != "be brief" || messages[1].StringContent() != "answer in English" {
+		t.Fatal("instructions and developer message should become system messages")
+	}
+	if contents := messages[2].ParseContent(); len(contents) != 2 || contents[1].Type != dto.ContentTypeImageURL {
+		t.Fatalf("user content parts not converted: %+v", contents)
+	}
+	// 连续的 function_call 合并为一条 assistant 消息，reasoning 摘要附加到该消息
+	assistant := messages[3]
+	if toolCalls := assistant.ParseToolCalls(); len(toolCalls) != 2 || toolCalls[0].ID != "call_1" || toolCalls[1].Function.Name != "get_time" {
+		t.Fatalf("tool calls not merged: %+v", toolCalls)
+	}
+	if assistant.ReasoningContent != "need a tool" {
+		t.Fatalf("reasoning summary not attached, got %q", assistant.ReasoningContent)
+	}
+	if messages[4].ToolCallId != "call_1" || messages[4].StringContent() != "sunny" || messages[5].StringContent() != "noon" {
+		t.Fatal("function_call_output not converted to tool messages")
+	}
+
+	if len(openAIRequest.Tools) != 1 || openAIRequest.Tools[0].Function.Name != "get_weather" {
+		t.Fatalf("tools = %+v", openAIRequest.Tools)
+	}
+	if common.GetJsonString(openAIRequest.ToolChoice) != `{"function":{"name":"get_weather"},"type":"function"}` {
+		t.Fatalf("tool_choice = %s", common.GetJsonString(openAIRequest.ToolChoice))
+	}
+	if openAIRequest.ResponseFormat == nil || openAIRequest.ResponseFormat.Type != "json_schema" {
+		t.Fatal("json_schema text format not converted")
+	}
+}
+
+func TestResponsesToOpenAIRequestRejectsBuiltInTools(t *testing.T) {
+	request := &dto.OpenAIResponsesRequest{
+		Model: "gemini-2.5-pro",
+		Input: []byte(`"hi"`),
+		Tools: []byte(`[{"type": "web_search"}]`),
+	}
+	if _, err := ResponsesToOpenAIRequest(request); err == nil {
+		t.Fatal("built-in tools should be rejected")
+	}
+}
+
+func TestResponseOpenAI2Responses(t *testing.T) {
+	var openAIResponse dto.OpenAITextResponse
+	err := common.UnmarshalJsonStr(`{
+		"model": "claude-sonnet-4",
+		"choices": [{
+			"index": 0,
+			"finish_reason": "length",
+			"message": {
+				"role": "assistant",
+				"content": "partial",
+				"reasoning_content": "thinking",
+				"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{}"}}]
+			}
+		}]
+	}`, &openAIResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &dto.OpenAIResponsesRequest{Model: "claude-sonnet-4"}
+	usage := &dto.Usage{PromptTokens: 10, CompletionTokens: 5}
+	usage.PromptTokensDetails.CachedTokens = 4
+	response := ResponseOpenAI2Responses(&openAIResponse, request, "resp_1", usage)
+
+	if len(response.Output) != 3 || response.Output[0].Type != "reasoning" || response.Output[1].Type != "message" || response.Output[2].Type != "function_call" {
+		t.Fatalf("unexpected output items: %+v", response.Output)
+	}
+	if response.Output[2].CallId != "call_1" {
+		t.Fatalf("function call id = %q", response.Output[2].CallId)
+	}
+	if response.Status != "incomplete" || response.IncompleteDetails == nil || response.IncompleteDetails.Reason != "max_output_tokens" {
+		t.Fatalf("length finish should be incomplete, got %s", response.Status)
+	}
+	if response.Usage.InputTokens != 10 || response.Usage.OutputTokens != 5 || response.Usage.TotalTokens != 15 ||
+		response.Usage.InputTokensDetails.CachedTokens != 4 {
+		t.Fatalf("usage = %+v", response.Usage)
+	}
+}
+
+func TestResponsesStreamConverter(t *testing.T) {
+	converter := NewResponsesStreamConverter(&dto.OpenAIResponsesRequest{Model: "gemini-2.5-pro"}, "resp_1")
+	var events []dto.ResponsesStreamResponse
+	chunk := func(data string) {
+		var response dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(data, &response); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, converter.Convert(&response)...)
+	}
+	chunk(`{"choices":[{"index":0,"delta":{"reasoning_content":"hmm"}}]}`)
+	chunk(`{"choices":[{"index":0,"delta":{"content":"Hel"}}]}`)
+	chunk(`{"choices":[{"index":0,"delta":{"content":"lo"}}]}`)
+	chunk(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"ci"}}]}}]}`)
+	chunk(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ty\":1}"}}]},"finish_reason":"tool_calls"}]}`)
+	events = append(events, converter.Finish(&dto.Usage{PromptTokens: 3, CompletionTokens: 7})...)
+
+	for i, event := range events {
+		if event.SequenceNumber != i {
+			t.Fatalf("event %d has sequence number %d", i, event.SequenceNumber)
+		}
+	}
+	if events[0].Type != "response.created" || events[1].Type != "response.in_progress" {
+		t.Fatalf("stream should start with created and in_progress, got %s, %s", events[0].Type, events[1].Type)
+	}
+	last := events[len(events)-1]
+	if last.Type != "response.completed" || last.Response.Status != "completed" {
+		t.Fatalf("last event = %s", last.Type)
+	}
+
+	response := converter.Response()
+	if len(response.Output) != 3 {
+		t.Fatalf("expected reasoning, message and function call, got %+v", response.Output)
+	}
+	if text := response.Output[1].Content[0].Text; text != "Hello" {
+		t.Fatalf("message text = %q", text)
+	}
+	if call := response.Output[2]; call.Arguments != `{"city":1}` || call.Status != "completed" {
+		t.Fatalf("function call = %+v", call)
+	}
+	if response.Usage.TotalTokens != 10 {
+		t.Fatalf("usage = %+v", response.Usage)
+	}
+}
+
+func TestResponsesStreamConverterContentFilter(t *testing.T) {
+	converter := NewResponsesStreamConverter(&dto.OpenAIResponsesRequest{Model: "gpt-4o"}, "resp_2")
+	finishReason := constant.FinishReasonContentFilter
+	converter.Convert(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{{FinishReason: &finishReason}}})
+	events := converter.Finish(nil)
+	last := events[len(events)-1]
+	if last.Type != "response.incomplete" || last.Response.IncompleteDetails.Reason != "content_filter" {
+		t.Fatalf("content filter should end with response.incomplete, got %s", last.Type)
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+)
+
+const (
+	StatementFormatCSV = "csv"
+	StatementFormatPDF = "pdf"
+)
+
+// GetStatementOwnerName 返回账单所属用户的用户名或组织名称
+func GetStatementOwnerName(statement *model.Statement) string {
+	if statement.Scope == model.StatementScopeOrganization {
+		if org, err := model.GetOrganizationById(statement.ScopeId); err == nil {
+			return org.Name
+		}
+	} else if username, err := model.GetUsernameById(statement.ScopeId, false); err == nil {
+		return username
+	}
+	return fmt.Sprintf("#%d", statement.ScopeId)
+}
+
+// StatementFileName 返回账单下载的文件名
+func StatementFileName(statement *model.Statement, format string) string {
+	return fmt.Sprintf("statement-%s-%d-%s.%s", statement.Scope, statement.ScopeId, statement.Period, format)
+}
+
+func formatStatementTime(timestamp int64) string {
+	return time.Unix(timestamp, 0).In(time.Local).Format("2006-01-02 15:04:05")
+}
+
+// RenderStatement 按指定格式渲染账单，返回文件内容与 Content-Type
+func RenderStatement(statement *model.Statement, format string) ([]byte, string, error) {
+	switch format {
+	case StatementFormatCSV:
+		data, err := RenderStatementCSV(statement)
+		return data, "text/csv; charset=utf-8", err
+	case StatementFormatPDF:
+		return RenderStatementPDF(statement), "application/pdf", nil
+	default:
+		return nil, "", fmt.Errorf("不支持的账单格式: %s", format)
+	}
+}
+
+// RenderStatementCSV 将账单渲染为 CSV，依次为汇总、按模型、按令牌、按日期、充值明细与转入组织明细
+func RenderStatementCSV(statement *model.Statement) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	// 写入 BOM，避免 Excel 打开时中文乱码
+	buf.WriteString("\xEF\xBB\xBF")
+	writer := csv.NewWriter(buf)
+	detail := statement.GetDetail()
+
+	rows := [][]string{
+		{"账单", GetStatementOwnerName(statement), statement.Period},
+		{"账期", formatStatementTime(statement.PeriodStart), formatStatementTime(statement.PeriodEnd)},
+		{"生成时间", formatStatementTime(statement.GeneratedTime)},
+		{"请求次数", strconv.Itoa(statement.RequestCount)},
+		{"消费", logger.FormatQuota(statement.ConsumeQuota), strconv.Itoa(statement.ConsumeQuota)},
+		{"退款", logger.FormatQuota(statement.RefundQuota), strconv.Itoa(statement.RefundQuota)},
+		{"充值", logger.FormatQuota(statement.TopUpQuota), strconv.Itoa(statement.TopUpQuota)},
+		{"充值支付金额", strconv.FormatFloat(statement.TopUpMoney, 'f', 2, 64)},
+		{"转入组织", logger.FormatQuota(statement.TransferQuota), strconv.Itoa(statement.TransferQuota)},
+		{"净变化", logger.FormatQuota(statement.NetQuota()), strconv.Itoa(statement.NetQuota())},
+	}
+	sections := []struct {
+		title string
+		items []model.StatementItem
+	}{
+		{"模型", detail.ByModel},
+		{"令牌", detail.ByToken},
+		{"日期", detail.ByDay},
+	}
+	for _, section := range sections {
+		rows = append(rows, []string{}, []string{section.title, "请求次数", "输入 Tokens", "输出 Tokens", "消费", "消费额度"})
+		for _, item := range section.items {
+			rows = append(rows, []string{
+				item.Name,
+				strconv.Itoa(item.Requests),
+				strconv.Itoa(item.PromptTokens),
+				strconv.Itoa(item.CompletionTokens),
+				logger.FormatQuota(item.Quota),
+				strconv.Itoa(item.Quota),
+			})
+		}
+	}
+	rows = append(rows, []string{}, []string{"充值时间", "订单号", "支付方式", "支付金额", "入账额度", "备注"})
+	for _, topUp := range detail.TopUps {
+		rows = append(rows, []string{
+			formatStatementTime(topUp.Time),
+			topUp.TradeNo,
+			topUp.PaymentMethod,
+			strconv.FormatFloat(topUp.Money, 'f', 2, 64),
+			strconv.Itoa(topUp.Quota),
+			topUp.Content,
+		})
+	}
+	if len(detail.Transfers) > 0 {
+		rows = append(rows, []string{}, []string{"转入时间", "转入额度", "备注"})
+		for _, transfer := range detail.Transfers {
+			rows = append(rows, []string{
+				formatStatementTime(transfer.Time),
+				strconv.Itoa(transfer.Quota),
+				transfer.Content,
+			})
+		}
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderStatementPDF 将账单渲染为 PDF
+func RenderStatementPDF(statement *model.Statement) []byte {
+	doc := common.NewPDFDocument()
+	detail := statement.GetDetail()
+
+	doc.AddLine(18, nil, fmt.Sprintf("%s 账单", statement.Period))
+	doc.AddLine(10, nil, GetStatementOwnerName(statement))
+	doc.AddLine(9, nil, fmt.Sprintf("账期 %s 至 %s，生成于 %s",
+		formatStatementTime(statement.PeriodStart), formatStatementTime(statement.PeriodEnd), formatStatementTime(statement.GeneratedTime)))
+	doc.AddRule()
+
+	summaryColumns := []float64{0, 120}
+	doc.AddLine(10, summaryColumns, "请求次数", strconv.Itoa(statement.RequestCount))
+	doc.AddLine(10, summaryColumns, "消费", logger.FormatQuota(statement.ConsumeQuota))
+	doc.AddLine(10, summaryColumns, "退款", logger.FormatQuota(statement.RefundQuota))
+	doc.AddLine(10, summaryColumns, "充值", logger.FormatQuota(statement.TopUpQuota))
+	if statement.TransferQuota != 0 {
+		doc.AddLine(10, summaryColumns, "转入组织", logger.FormatQuota(statement.TransferQuota))
+	}
+	doc.AddLine(10, summaryColumns, "净变化", logger.FormatQuota(statement.NetQuota()))
+
+	itemColumns := []float64{0, 210, 270, 340, 410}
+	sections := []struct {
+		title string
+		items []model.StatementItem
+	}{
+		{"按模型", detail.ByModel},
+		{"按令牌", detail.ByToken},
+		{"按日期", detail.ByDay},
+	}
+	for _, section := range sections {
+		doc.AddSpace(10)
+		doc.AddLine(12, nil, section.title)
+		doc.AddLine(9, itemColumns, "名称", "请求次数", "输入 Tokens", "输出 Tokens", "消费")
+		doc.AddRule()
+		for _, item := range section.items {
+			doc.AddLine(9, itemColumns, item.Name, strconv.Itoa(item.Requests), strconv.Itoa(item.PromptTokens),
+				strconv.Itoa(item.CompletionTokens), logger.FormatQuota(item.Quota))
+		}
+	}
+
+	if len(detail.TopUps) > 0 {
+		topUpColumns := []float64{0, 110, 280, 360}
+		doc.AddSpace(10)
+		doc.AddLine(12, nil, "充值明细")
+		doc.AddLine(9, topUpColumns, "时间", "订单号 / 备注", "支付金额", "入账")
+		doc.AddRule()
+		for _, topUp := range detail.TopUps {
+			reference := topUp.TradeNo
+			if reference == "" {
+				// 备注为中文时较宽，截断以免与后一列重叠
+				reference = topUp.Content
+				if runes := []rune(reference); len(runes) > 18 {
+					reference = string(runes[:18]) + "..."
+				}
+			}
+			doc.AddLine(9, topUpColumns, formatStatementTime(topUp.Time), reference,
+				strconv.FormatFloat(topUp.Money, 'f', 2, 64), logger.FormatQuota(topUp.Quota))
+		}
+	}
+
+	if len(detail.Transfers) > 0 {
+		transferColumns := []float64{0, 110, 360}
+		doc.AddSpace(10)
+		doc.AddLine(12, nil, "转入组织明细")
+		doc.AddLine(9, transferColumns, "时间", "备注", "转出")
+		doc.AddRule()
+		for _, transfer := range detail.Transfers {
+			doc.AddLine(9, transferColumns, formatStatementTime(transfer.Time), transfer.Content, logger.FormatQuota(transfer.Quota))
+		}
+	}
+	return doc.Bytes()
+}
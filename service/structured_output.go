@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// StructuredOutputSchema 请求中 response_format 指定的 JSON Schema，用于网关侧模拟结构化输出
+type StructuredOutputSchema struct {
+	Name        string
+	Description string
+	Schema      map[string]any
+	schemaJSON  string
+}
+
+// ParseStructuredOutputSchema 解析 json_schema 类型的 response_format，其他类型返回 nil
+func ParseStructuredOutputSchema(format *dto.ResponseFormat) (*StructuredOutputSchema, error) {
+	if format == nil || format.Type != "json_schema" {
+		return nil, nil
+	}
+	if len(format.JsonSchema) == 0 {
+		return nil, errors.New("response_format.json_schema is required")
+	}
+	var jsonSchema dto.FormatJsonSchema
+	if err := common.Unmarshal(format.JsonSchema, &jsonSchema); err != nil {
+		return nil, fmt.Errorf("invalid response_format.json_schema: %w", err)
+	}
+	schema, ok := jsonSchema.Schema.(map[string]any)
+	if !ok {
+		return nil, errors.New("response_format.json_schema.schema must be an object")
+	}
+	schemaJSON, err := common.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	return &StructuredOutputSchema{
+		Name:        jsonSchema.Name,
+		Description: jsonSchema.Description,
+		Schema:      schema,
+		schemaJSON:  string(schemaJSON),
+	}, nil
+}
+
+// Instruction 返回注入系统提示词的格式说明
+func (s *StructuredOutputSchema) Instruction() string {
+	var b strings.Builder
+	b.WriteString("You must respond with a single JSON value that strictly conforms to the JSON Schema below.")
+	b.WriteString(" Output only the JSON itself, without markdown code fences, comments or any other text.")
+	if s.Name != "" {
+		fmt.Fprintf(&b, "\nSchema name: %s", s.Name)
+	}
+	if s.Description != "" {
+		fmt.Fprintf(&b, "\nSchema description: %s", s.Description)
+	}
+	fmt.Fprintf(&b, "\nJSON Schema:\n%s", s.schemaJSON)
+	return b.String()
+}
+
+// RetryPrompt 返回校验失败后要求模型重新输出的提示
+func (s *StructuredOutputSchema) RetryPrompt(err error) string {
+	return fmt.Sprintf("Your previous response is not valid: %s. Respond again with only a JSON value that strictly conforms to the JSON Schema.", err.Error())
+}
+
+// Validate 修复并校验模型输出，通过校验时返回规范化后的 JSON 文本
+func (s *StructuredOutputSchema) Validate(content string) (string, error) {
+	repaired := repairStructuredOutput(content)
+	var value any
+	if err := common.UnmarshalJsonStr(repaired, &value); err != nil {
+		return "", fmt.Errorf("response is not valid JSON: %w", err)
+	}
+	if err := common.ValidateJSONSchema(value, s.Schema); err != nil {
+		return "", fmt.Errorf("response does not match the JSON Schema: %w", err)
+	}
+	return repaired, nil
+}
+
+var (
+	structuredOutputThinkRegex = regexp.MustCompile(`(?s)^\s*<think>.*?</think>`)
+	structuredOutputFenceRegex = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*\\n?(.*?)\\n?\\s*```")
+)
+
+// repairStructuredOutput 去除思考标签与 markdown 代码块，并截取首个 JSON 对象或数组
+func repairStructuredOutput(content string) string {
+	content = strings.TrimSpace(structuredOutputThinkRegex.ReplaceAllString(content, ""))
+	if match := structuredOutputFenceRegex.FindStringSubmatch(content); match != nil {
+		content = strings.TrimSpace(match[1])
+	}
+	start := strings.IndexAny(content, "{[")
+	if start < 0 {
+		return content
+	}
+	closing := "}"
+	if content[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(content, closing)
+	if end < start {
+		return content
+	}
+	return content[start : end+1]
+}
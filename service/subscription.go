@@ -0,0 +1,182 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// loadSubscription 加载本次请求可使用的订阅套餐，每个请求只查询一次。
+// 套餐限定了可用分组时，其他分组的请求只使用钱包余额
+func loadSubscription(relayInfo *relaycommon.RelayInfo) *relaycommon.SubscriptionCharge {
+	if relayInfo.Subscription != nil {
+		return relayInfo.Subscription
+	}
+	charge := &relaycommon.SubscriptionCharge{}
+	relayInfo.Subscription = charge
+	if !operation_setting.GetSubscriptionSetting().Enabled {
+		return charge
+	}
+	subscription, err := model.GetActiveUserSubscription(relayInfo.UserId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to get subscription of user %d: %s", relayInfo.UserId, err.Error()))
+		return charge
+	}
+	if subscription == nil || !subscription.AllowsGroup(relayInfo.UsingGroup) {
+		return charge
+	}
+	charge.SubscriptionId = subscription.Id
+	charge.PeriodStart = subscription.PeriodStart
+	charge.Available = max(subscription.Quota, 0)
+	return charge
+}
+
+// GetUserAvailableQuota 返回用户可用于本次请求的额度，即钱包余额与订阅套餐剩余额度之和；
+// 使用组织令牌时返回组织的共享额度
+func GetUserAvailableQuota(relayInfo *relaycommon.RelayInfo) (int, error) {
+	if relayInfo.TokenOrganization.Id != 0 {
+		return model.GetOrganizationQuota(relayInfo.TokenOrganization.Id)
+	}
+	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	if err != nil {
+		return 0, err
+	}
+	return userQuota + loadSubscription(relayInfo).Available, nil
+}
+
+// consumeUserQuota 扣除用户额度，优先扣除订阅套餐额度，不足部分扣除钱包余额；
+// 使用组织令牌时只扣除组织额度
+func consumeUserQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
+	if relayInfo.TokenOrganization.Id != 0 {
+		return model.DecreaseOrganizationQuota(relayInfo.TokenOrganization.Id, quota)
+	}
+	charge := loadSubscription(relayInfo)
+	if charge.SubscriptionId != 0 {
+		consumed, err := model.ConsumeSubscriptionQuota(charge.SubscriptionId, charge.PeriodStart, quota)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to consume subscription %d quota: %s", charge.SubscriptionId, err.Error()))
+		}
+		charge.Charged += consumed
+		charge.Available = max(charge.Available-consumed, 0)
+		quota -= consumed
+	}
+	if quota <= 0 {
+		return nil
+	}
+	charge.WalletCharged += quota
+	return model.DecreaseUserQuota(relayInfo.UserId, quota)
+}
+
+// settleUserQuota 结算已产生的消费，规则同 consumeUserQuota，但组织额度与钱包一样允许变为负数
+func settleUserQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
+	if relayInfo.TokenOrganization.Id != 0 {
+		return model.ChargeOrganizationQuota(relayInfo.TokenOrganization.Id, quota)
+	}
+	return consumeUserQuota(relayInfo, quota)
+}
+
+// refundUserQuota 返还用户额度，先返还本次请求扣除的钱包余额，再返还套餐额度；
+// 套餐已进入下一个周期时改为返还到钱包。使用组织令牌时返还到组织额度
+func refundUserQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
+	if relayInfo.TokenOrganization.Id != 0 {
+		return model.IncreaseOrganizationQuota(relayInfo.TokenOrganization.Id, quota)
+	}
+	if charge := relayInfo.Subscription; charge != nil && charge.Charged > 0 {
+		walletPart := min(quota, charge.WalletCharged)
+		planPart := min(quota-walletPart, charge.Charged)
+		if planPart > 0 {
+			refunded, err := model.RefundSubscriptionQuota(charge.SubscriptionId, charge.PeriodStart, planPart)
+			if err != nil {
+				common.SysError(fmt.Sprintf("failed to refund subscription %d quota: %s", charge.SubscriptionId, err.Error()))
+			} else if refunded {
+				charge.Charged -= planPart
+				charge.Available += planPart
+				quota -= planPart
+			}
+		}
+		charge.WalletCharged -= min(quota, charge.WalletCharged)
+	}
+	if quota <= 0 {
+		return nil
+	}
+	return model.IncreaseUserQuota(relayInfo.UserId, quota, false)
+}
+
+// RefundTaskQuota 返还异步任务的额度，先返还钱包余额，再返还提交时扣除的套餐额度；
+// 使用组织令牌提交的任务返还到组织额度。退款同时从提交时所在的预算窗口中扣除
+func RefundTaskQuota(task *model.Task, quota int) error {
+	charge := relaycommon.SubscriptionCharge{
+		SubscriptionId: task.SubscriptionId,
+		PeriodStart:    task.SubscriptionPeriodStart,
+		Charged:        task.SubscriptionQuota,
+	}
+	err := refundSubmittedQuota(task.UserId, task.OrganizationId, task.Quota, &charge, quota)
+	task.SubscriptionQuota = charge.Charged
+	if err != nil {
+		return err
+	}
+	adjustTaskSpendBudget(task.UserId, task.TokenId, task.OrganizationMemberId, time.Unix(task.CreatedAt, 0), -quota)
+	return nil
+}
+
+// ChargeTaskQuota 异步任务按实际用量补扣额度，从钱包或组织额度扣除，并计入提交时所在的预算窗口
+func ChargeTaskQuota(task *model.Task, quota int) error {
+	if err := model.DecreasePayerQuota(task.UserId, task.OrganizationId, quota); err != nil {
+		return err
+	}
+	adjustTaskSpendBudget(task.UserId, task.TokenId, task.OrganizationMemberId, time.Unix(task.CreatedAt, 0), quota)
+	return nil
+}
+
+// RefundMidjourneyQuota 返还 Midjourney 任务的额度，规则同 RefundTaskQuota
+func RefundMidjourneyQuota(task *model.Midjourney, quota int) error {
+	charge := relaycommon.SubscriptionCharge{
+		SubscriptionId: task.SubscriptionId,
+		PeriodStart:    task.SubscriptionPeriodStart,
+		Charged:        task.SubscriptionQuota,
+	}
+	err := refundSubmittedQuota(task.UserId, task.OrganizationId, task.Quota, &charge, quota)
+	task.SubscriptionQuota = charge.Charged
+	if err != nil {
+		return err
+	}
+	// Midjourney 任务没有创建时间，按毫秒级的提交时间确定窗口
+	adjustTaskSpendBudget(task.UserId, task.TokenId, task.OrganizationMemberId, time.UnixMilli(task.SubmitTime), -quota)
+	return nil
+}
+
+// refundSubmittedQuota 按任务提交时的扣费来源返还额度，chargedQuota 为任务已扣除的总额度
+func refundSubmittedQuota(userId int, organizationId int, chargedQuota int, charge *relaycommon.SubscriptionCharge, quota int) error {
+	charge.WalletCharged = max(chargedQuota-charge.Charged, 0)
+	relayInfo := &relaycommon.RelayInfo{
+		UserId:            userId,
+		TokenOrganization: relaycommon.OrganizationInfo{Id: organizationId},
+		Subscription:      charge,
+	}
+	return refundUserQuota(relayInfo, quota)
+}
+
+// StartSubscriptionTask 定期为订阅发放新周期的套餐额度，并处理到期的订阅
+func StartSubscriptionTask() {
+	gopool.Go(func() {
+		for {
+			time.Sleep(time.Minute)
+			if count, err := model.ExpireSubscriptions(); err != nil {
+				common.SysError("failed to expire subscriptions: " + err.Error())
+			} else if count > 0 {
+				common.SysLog(fmt.Sprintf("expired %d subscriptions", count))
+			}
+			if count, err := model.RenewSubscriptionPeriods(); err != nil {
+				common.SysError("failed to renew subscription periods: " + err.Error())
+			} else if count > 0 {
+				common.SysLog(fmt.Sprintf("renewed %d subscription periods", count))
+			}
+		}
+	})
+}
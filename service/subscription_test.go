@@ -0,0 +1,270 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+func enableSubscription(t *testing.T) {
+	t.Helper()
+	setting := operation_setting.GetSubscriptionSetting()
+	original := setting.Enabled
+	setting.Enabled = true
+	t.Cleanup(func() {
+		setting.Enabled = original
+	})
+}
+
+func createTestSubscription(t *testing.T, userId int, quota int) *model.UserSubscription {
+	t.Helper()
+	now := common.GetTimestamp()
+	subscription := &model.UserSubscription{
+		UserId:      userId,
+		PlanName:    "test",
+		Status:      model.SubscriptionStatusActive,
+		Quota:       quota,
+		PeriodQuota: quota,
+		PeriodDays:  30,
+		StartTime:   now,
+		EndTime:     now + 30*24*60*60,
+		PeriodStart: now,
+		PeriodEnd:   now + 30*24*60*60,
+	}
+	if err := model.DB.Create(subscription).Error; err != nil {
+		t.Fatal(err)
+	}
+	return subscription
+}
+
+func assertQuotas(t *testing.T, userId int, subscriptionId int, wallet int, plan int) {
+	t.Helper()
+	userQuota, err := model.GetUserQuota(userId, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscription := &model.UserSubscription{}
+	if err := model.DB.First(subscription, subscriptionId).Error; err != nil {
+		t.Fatal(err)
+	}
+	if userQuota != wallet || subscription.Quota != plan {
+		t.Fatalf("wallet = %d, plan = %d, want wallet = %d, plan = %d", userQuota, subscription.Quota, wallet, plan)
+	}
+}
+
+func TestConsumeUserQuotaPlanThenWallet(t *testing.T) {
+	setupTestDB(t)
+	enableSubscription(t)
+	user := createTestUser(t, "plan_then_wallet", 1000)
+	subscription := createTestSubscription(t, user.Id, 300)
+
+	info := &relaycommon.RelayInfo{UserId: user.Id, UsingGroup: "default"}
+	available, err := GetUserAvailableQuota(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available != 1300 {
+		t.Fatalf("available = %d", available)
+	}
+
+	// 套餐额度不足时剩余部分扣除钱包
+	if err := consumeUserQuota(info, 500); err != nil {
+		t.Fatal(err)
+	}
+	if info.Subscription.Charged != 300 || info.Subscription.WalletCharged != 200 {
+		t.Fatalf("unexpected charge: %+v", info.Subscription)
+	}
+	assertQuotas(t, user.Id, subscription.Id, 800, 0)
+
+	// 返还时先返还钱包部分，再返还套餐部分
+	if err := refundUserQuota(info, 250); err != nil {
+		t.Fatal(err)
+	}
+	if info.Subscription.Charged != 250 || info.Subscription.WalletCharged != 0 {
+		t.Fatalf("unexpected charge after refund: %+v", info.Subscription)
+	}
+	assertQuotas(t, user.Id, subscription.Id, 1000, 50)
+}
+
+func TestConsumeUserQuotaSkipsPlanOfOtherGroup(t *testing.T) {
+	setupTestDB(t)
+	enableSubscription(t)
+	user := createTestUser(t, "plan_other_group", 1000)
+	subscription := createTestSubscription(t, user.Id, 300)
+	if err := model.DB.Model(subscription).Update("allowed_groups", "vip").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	info := &relaycommon.RelayInfo{UserId: user.Id, UsingGroup: "default"}
+	if err := consumeUserQuota(info, 100); err != nil {
+		t.Fatal(err)
+	}
+	assertQuotas(t, user.Id, subscription.Id, 900, 300)
+}
+
+func TestRefundTaskQuotaReturnsToPlan(t *testing.T) {
+	setupTestDB(t)
+	enableSubscription(t)
+	user := createTestUser(t, "task_refund_plan", 1000)
+	subscription := createTestSubscription(t, user.Id, 300)
+
+	// 提交任务时全部从套餐扣除
+	info := &relaycommon.RelayInfo{UserId: user.Id, UsingGroup: "default"}
+	if err := consumeUserQuota(info, 200); err != nil {
+		t.Fatal(err)
+	}
+	task := &model.Task{UserId: user.Id, TaskID: "task_refund_plan", Quota: 200}
+	if err := task.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	charge := info.Subscription
+	if err := task.UpdateSubscriptionCharge(charge.SubscriptionId, charge.PeriodStart, charge.Charged); err != nil {
+		t.Fatal(err)
+	}
+	assertQuotas(t, user.Id, subscription.Id, 1000, 100)
+
+	// 轮询任务时从数据库重新加载，任务失败后额度返还到套餐
+	stored, exist, err := model.GetByOnlyTaskId(task.TaskID)
+	if err != nil || !exist {
+		t.Fatalf("task not found: %v", err)
+	}
+	if stored.SubscriptionId != subscription.Id || stored.SubscriptionQuota != 200 {
+		t.Fatalf("subscription charge not stored: %+v", stored)
+	}
+	if err := RefundTaskQuota(stored, stored.Quota); err != nil {
+		t.Fatal(err)
+	}
+	if stored.SubscriptionQuota != 0 {
+		t.Fatalf("subscription quota = %d", stored.SubscriptionQuota)
+	}
+	assertQuotas(t, user.Id, subscription.Id, 1000, 300)
+}
+
+func TestRefundTaskQuotaPlanThenWallet(t *testing.T) {
+	setupTestDB(t)
+	enableSubscription(t)
+	user := createTestUser(t, "task_refund_mixed", 1000)
+	subscription := createTestSubscription(t, user.Id, 100)
+
+	info := &relaycommon.RelayInfo{UserId: user.Id, UsingGroup: "default"}
+	if err := consumeUserQuota(info, 300); err != nil {
+		t.Fatal(err)
+	}
+	assertQuotas(t, user.Id, subscription.Id, 800, 0)
+	task := &model.Task{
+		UserId:                  user.Id,
+		Quota:                   300,
+		SubscriptionId:          subscription.Id,
+		SubscriptionPeriodStart: subscription.PeriodStart,
+		SubscriptionQuota:       info.Subscription.Charged,
+	}
+
+	// 视频任务按实际用量退还多扣的部分：先退钱包，再退套餐
+	if err := RefundTaskQuota(task, 250); err != nil {
+		t.Fatal(err)
+	}
+	task.Quota = 50
+	if task.SubscriptionQuota != 50 {
+		t.Fatalf("subscription quota = %d", task.SubscriptionQuota)
+	}
+	assertQuotas(t, user.Id, subscription.Id, 1000, 50)
+
+	// 剩余部分在任务失败时全部返还到套餐
+	if err := RefundTaskQuota(task, task.Quota); err != nil {
+		t.Fatal(err)
+	}
+	assertQuotas(t, user.Id, subscription.Id, 1000, 100)
+}
+
+func TestRefundMidjourneyQuotaAfterPeriodRenewal(t *testing.T) {
+	setupTestDB(t)
+	enableSubscription(t)
+	user := createTestUser(t, "mj_refund_renewed", 1000)
+	subscription := createTestSubscription(t, user.Id, 300)
+
+	info := &relaycommon.RelayInfo{UserId: user.Id, UsingGroup: "default"}
+	if err := consumeUserQuota(info, 200); err != nil {
+		t.Fatal(err)
+	}
+	task := &model.Midjourney{UserId: user.Id, MjId: "mj_refund_renewed", Quota: 200}
+	if err := task.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.UpdateSubscriptionCharge(subscription.Id, subscription.PeriodStart, info.Subscription.Charged); err != nil {
+		t.Fatal(err)
+	}
+
+	// 套餐进入下一个周期后，上个周期扣除的额度改为返还到钱包
+	if err := model.DB.Model(subscription).Update("period_start", subscription.PeriodStart+1).Error; err != nil {
+		t.Fatal(err)
+	}
+	stored := model.GetByOnlyMJId(task.MjId)
+	if stored == nil {
+		t.Fatal("midjourney task not found")
+	}
+	if err := RefundMidjourneyQuota(stored, stored.Quota); err != nil {
+		t.Fatal(err)
+	}
+	assertQuotas(t, user.Id, subscription.Id, 1200, 100)
+}
+
+func createTestOrganization(t *testing.T, owner *model.User, quota int) *model.Organization {
+	t.Helper()
+	org := &model.Organization{Name: "org-" + owner.Username, OwnerId: owner.Id}
+	if err := org.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.DB.Model(org).Update("quota", quota).Error; err != nil {
+		t.Fatal(err)
+	}
+	return org
+}
+
+func TestConsumeUserQuotaOrganizationPool(t *testing.T) {
+	setupTestDB(t)
+	enableSubscription(t)
+	user := createTestUser(t, "org_pool", 1000)
+	subscription := createTestSubscription(t, user.Id, 300)
+	org := createTestOrganization(t, user, 500)
+
+	// 组织令牌只使用组织额度，不使用个人钱包与套餐
+	info := &relaycommon.RelayInfo{UserId: user.Id, UsingGroup: "default"}
+	info.TokenOrganization.Id = org.Id
+	available, err := GetUserAvailableQuota(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available != 500 {
+		t.Fatalf("available = %d", available)
+	}
+	if err := consumeUserQuota(info, 600); err == nil {
+		t.Fatal("expected pre-consuming more than the organization quota to fail")
+	}
+	if err := consumeUserQuota(info, 200); err != nil {
+		t.Fatal(err)
+	}
+	if err := refundUserQuota(info, 50); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := model.GetOrganizationById(org.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Quota != 350 || stored.UsedQuota != 150 {
+		t.Fatalf("organization quota = %d, used = %d", stored.Quota, stored.UsedQuota)
+	}
+	assertQuotas(t, user.Id, subscription.Id, 1000, 300)
+
+	// 组织令牌提交的任务失败时返还到组织
+	task := &model.Task{UserId: user.Id, OrganizationId: org.Id, Quota: 150}
+	if err := RefundTaskQuota(task, task.Quota); err != nil {
+		t.Fatal(err)
+	}
+	if quota, err := model.GetOrganizationQuota(org.Id); err != nil || quota != 500 {
+		t.Fatalf("organization quota = %d, err = %v", quota, err)
+	}
+	assertQuotas(t, user.Id, subscription.Id, 1000, 300)
+}
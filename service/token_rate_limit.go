@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const tokenRateLimitReservationKey = "token_rate_limit_reservation"
+
+// rateLimitScope 一个限流维度（令牌、用户分组、模型）
+type rateLimitScope struct {
+	name string
+	key  string
+	rule operation_setting.RateLimitRule
+}
+
+type rateLimitBucket struct {
+	key   string
+	limit int64
+}
+
+// tokenRateLimitReservation 本次请求预留的 TPM 配额，请求结束后按实际用量调整
+type tokenRateLimitReservation struct {
+	buckets  []rateLimitBucket
+	reserved int64
+	settled  bool
+}
+
+func getRateLimitScopes(c *gin.Context, info *relaycommon.RelayInfo) []rateLimitScope {
+	var scopes []rateLimitScope
+	tokenRule := operation_setting.RateLimitRule{
+		TPM: common.GetContextKeyInt(c, constant.ContextKeyTokenTpmLimit),
+		RPM: common.GetContextKeyInt(c, constant.ContextKeyTokenRpmLimit),
+	}
+	if info.TokenId > 0 && (tokenRule.TPM > 0 || tokenRule.RPM > 0) {
+		scopes = append(scopes, rateLimitScope{
+			name: "令牌",
+			key:  fmt.Sprintf("rate_limit:token:%d", info.TokenId),
+			rule: tokenRule,
+		})
+	}
+	if rule, ok := operation_setting.GetGroupRateLimitRule(info.UserGroup); ok {
+		scopes = append(scopes, rateLimitScope{
+			name: "分组 " + info.UserGroup,
+			key:  fmt.Sprintf("rate_limit:user:%d", info.UserId),
+			rule: rule,
+		})
+	}
+	if rule, ok := operation_setting.GetModelRateLimitRule(info.OriginModelName); ok {
+		scopes = append(scopes, rateLimitScope{
+			name: "模型 " + info.OriginModelName,
+			key:  fmt.Sprintf("rate_limit:user:%d:model:%s", info.UserId, info.OriginModelName),
+			rule: rule,
+		})
+	}
+	return scopes
+}
+
+// rateLimitHeaders 记录各维度中剩余最少的配额，用于 x-ratelimit-* 响应头
+type rateLimitHeaders struct {
+	requests *limiter.BucketResult
+	tokens   *limiter.BucketResult
+}
+
+func (h *rateLimitHeaders) observe(result limiter.BucketResult, isTokens bool) {
+	current := &h.requests
+	if isTokens {
+		current = &h.tokens
+	}
+	if *current == nil || result.Remaining < (*current).Remaining {
+		*current = &result
+	}
+}
+
+func formatRateLimitReset(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+func (h *rateLimitHeaders) write(c *gin.Context) {
+	if h.requests != nil {
+		c.Header("x-ratelimit-limit-requests", strconv.FormatInt(h.requests.Limit, 10))
+		c.Header("x-ratelimit-remaining-requests", strconv.FormatInt(max(h.requests.Remaining, 0), 10))
+		c.Header("x-ratelimit-reset-requests", formatRateLimitReset(h.requests.ResetAfter))
+	}
+	if h.tokens != nil {
+		c.Header("x-ratelimit-limit-tokens", strconv.FormatInt(h.tokens.Limit, 10))
+		c.Header("x-ratelimit-remaining-tokens", strconv.FormatInt(max(h.tokens.Remaining, 0), 10))
+		c.Header("x-ratelimit-reset-tokens", formatRateLimitReset(h.tokens.ResetAfter))
+	}
+}
+
+// ReserveTokenRateLimit 检查令牌、用户分组与模型的 RPM/TPM 限制，按预估的提示词 Token 数预留 TPM 配额
+func ReserveTokenRateLimit(c *gin.Context, info *relaycommon.RelayInfo, promptTokens int) *types.NewAPIError {
+	scopes := getRateLimitScopes(c, info)
+	if len(scopes) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	requested := int64(max(promptTokens, 1))
+	reservation := &tokenRateLimitReservation{reserved: requested}
+	var taken []rateLimitBucket
+	var takenAmounts []int64
+	headers := &rateLimitHeaders{}
+
+	for _, scope := range scopes {
+		checks := []struct {
+			suffix    string
+			limit     int
+			requested int64
+			isTokens  bool
+			unit      string
+		}{
+			{":rpm", scope.rule.RPM, 1, false, "请求"},
+			{":tpm", scope.rule.TPM, requested, true, "Token"},
+		}
+		for _, check := range checks {
+			if check.limit <= 0 {
+				continue
+			}
+			bucket := rateLimitBucket{key: scope.key + check.suffix, limit: int64(check.limit)}
+			result, err := limiter.Reserve(ctx, bucket.key, bucket.limit, check.requested)
+			if err != nil {
+				// 限流器异常时放行，避免限流本身导致不可用
+				logger.LogError(c, err.Error())
+				continue
+			}
+			headers.observe(result, check.isTokens)
+			if !result.Allowed {
+				for i, takenBucket := range taken {
+					_ = limiter.Adjust(ctx, takenBucket.key, takenBucket.limit, -takenAmounts[i])
+				}
+				headers.write(c)
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+				return types.NewErrorWithStatusCode(
+					fmt.Errorf("%s已达到每分钟%s数限制 %d，请在 %s 后重试", scope.name, check.unit, check.limit, formatRateLimitReset(result.RetryAfter)),
+					types.ErrorCodeRateLimitExceeded, http.StatusTooManyRequests,
+					types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+			}
+			taken = append(taken, bucket)
+			takenAmounts = append(takenAmounts, check.requested)
+			if check.isTokens {
+				reservation.buckets = append(reservation.buckets, bucket)
+			}
+		}
+	}
+	headers.write(c)
+	if len(reservation.buckets) > 0 {
+		c.Set(tokenRateLimitReservationKey, reservation)
+	}
+	return nil
+}
+
+func adjustTokenRateLimit(c *gin.Context, delta func(reserved int64) int64) {
+	value, ok := c.Get(tokenRateLimitReservationKey)
+	if !ok {
+		return
+	}
+	reservation := value.(*tokenRateLimitReservation)
+	if reservation.settled {
+		return
+	}
+	reservation.settled = true
+	amount := delta(reservation.reserved)
+	for _, bucket := range reservation.buckets {
+		if err := limiter.Adjust(context.Background(), bucket.key, bucket.limit, amount); err != nil {
+			logger.LogError(c, err.Error())
+		}
+	}
+}
+
+// SettleTokenRateLimit 按实际用量（提示词与补全 Token 之和）调整预留的 TPM 配额
+func SettleTokenRateLimit(c *gin.Context, usage *dto.Usage) {
+	adjustTokenRateLimit(c, func(reserved int64) int64 {
+		return int64(usage.PromptTokens+usage.CompletionTokens) - reserved
+	})
+}
+
+// ReleaseTokenRateLimit 请求失败时返还预留的 TPM 配额
+func ReleaseTokenRateLimit(c *gin.Context) {
+	adjustTokenRateLimit(c, func(reserved int64) int64 {
+		return -reserved
+	})
+}
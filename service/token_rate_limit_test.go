@@ -0,0 +1,69 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTokenRateLimitContext(tokenId int, tpm int) (*gin.Context, *relaycommon.RelayInfo) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings", nil)
+	common.SetContextKey(c, constant.ContextKeyTokenTpmLimit, tpm)
+	info := &relaycommon.RelayInfo{
+		TokenId:         tokenId,
+		OriginModelName: "text-embedding-3-small",
+		StartTime:       time.Now(),
+	}
+	return c, info
+}
+
+func TestBeginQuotaSettleAdjustsReservation(t *testing.T) {
+	originalRedis := common.RedisEnabled
+	common.RedisEnabled = false
+	t.Cleanup(func() { common.RedisEnabled = originalRedis })
+
+	c, info := newTokenRateLimitContext(8101, 100)
+	if err := ReserveTokenRateLimit(c, info, 40); err != nil {
+		t.Fatalf("reserve failed: %v", err)
+	}
+	// 实际用量 80，比预留多 40
+	BeginQuotaSettle(c, info, &dto.Usage{PromptTokens: 30, CompletionTokens: 50})()
+	// 重复结算不会再次调整
+	SettleTokenRateLimit(c, &dto.Usage{PromptTokens: 1000})
+
+	next, nextInfo := newTokenRateLimitContext(8101, 100)
+	if err := ReserveTokenRateLimit(next, nextInfo, 21); err == nil {
+		t.Fatal("reservation beyond the settled usage should be rejected")
+	}
+	if err := ReserveTokenRateLimit(next, nextInfo, 20); err != nil {
+		t.Fatalf("remaining 20 tokens should be reservable: %v", err)
+	}
+}
+
+func TestReleaseTokenRateLimitReturnsReservation(t *testing.T) {
+	originalRedis := common.RedisEnabled
+	common.RedisEnabled = false
+	t.Cleanup(func() { common.RedisEnabled = originalRedis })
+
+	c, info := newTokenRateLimitContext(8102, 100)
+	if err := ReserveTokenRateLimit(c, info, 90); err != nil {
+		t.Fatalf("reserve failed: %v", err)
+	}
+	ReleaseTokenRateLimit(c)
+	// 结算在返还之后不再生效
+	BeginQuotaSettle(c, info, &dto.Usage{PromptTokens: 90})()
+
+	next, nextInfo := newTokenRateLimitContext(8102, 100)
+	if err := ReserveTokenRateLimit(next, nextInfo, 100); err != nil {
+		t.Fatalf("released reservation should be available again: %v", err)
+	}
+}
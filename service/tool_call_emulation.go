@@ -0,0 +1,256 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// 模型以这组标签包裹工具调用，网关从输出中解析
+const (
+	toolCallOpenTag  = "<tool_call>"
+	toolCallCloseTag = "</tool_call>"
+)
+
+type emulatedToolCall struct {
+	Name      string `json:"name"`
+	Arguments any    `json:"arguments"`
+}
+
+// EmulateToolCallRequest 为不支持函数调用的模型改写请求：工具定义写入系统提示词，
+// 历史中的工具调用与工具结果改写为文本消息，并移除 tools 等上游无法识别的字段。重复调用不会再次改写
+func EmulateToolCallRequest(request *dto.GeneralOpenAIRequest) {
+	messages := make([]dto.Message, 0, len(request.Messages))
+	toolNames := make(map[string]string)
+	for _, message := range request.Messages {
+		switch {
+		case message.Role == "assistant" && len(message.ParseToolCalls()) > 0:
+			var b strings.Builder
+			b.WriteString(message.StringContent())
+			for _, toolCall := range message.ParseToolCalls() {
+				toolNames[toolCall.ID] = toolCall.Function.Name
+				if b.Len() > 0 {
+					b.WriteString("\n")
+				}
+				b.WriteString(renderEmulatedToolCall(toolCall.Function.Name, toolCall.Function.Arguments))
+			}
+			message.ToolCalls = nil
+			message.SetStringContent(b.String())
+			messages = append(messages, message)
+		case message.Role == "tool":
+			name := toolNames[message.ToolCallId]
+			if name == "" && message.Name != nil {
+				name = *message.Name
+			}
+			result := fmt.Sprintf("<tool_result name=%q tool_call_id=%q>\n%s\n</tool_result>", name, message.ToolCallId, message.StringContent())
+			// 连续的工具结果合并为一条用户消息，避免上游要求角色交替
+			if last := len(messages) - 1; last >= 0 && messages[last].Role == "user" && strings.HasPrefix(messages[last].StringContent(), "<tool_result") {
+				messages[last].SetStringContent(messages[last].StringContent() + "\n" + result)
+				continue
+			}
+			messages = append(messages, dto.Message{Role: "user", Content: result})
+		default:
+			messages = append(messages, message)
+		}
+	}
+	request.Messages = messages
+
+	if len(request.Tools) == 0 {
+		return
+	}
+	instruction := toolCallInstruction(request)
+	request.Tools = nil
+	request.ToolChoice = nil
+	request.ParallelTooCalls = nil
+	if instruction != "" {
+		request.AppendSystemInstruction(instruction)
+	}
+}
+
+// toolCallInstruction 按工具定义与 tool_choice 生成注入系统提示词的调用说明，tool_choice 为 none 时返回空字符串
+func toolCallInstruction(request *dto.GeneralOpenAIRequest) string {
+	requirement := ""
+	switch choice := request.ToolChoice.(type) {
+	case string:
+		switch choice {
+		case "none":
+			return ""
+		case "required":
+			requirement = "You must call at least one tool in this reply."
+		}
+	case map[string]any:
+		if function, ok := choice["function"].(map[string]any); ok {
+			if name, ok := function["name"].(string); ok && name != "" {
+				requirement = fmt.Sprintf("You must call the tool %q in this reply.", name)
+			}
+		}
+	}
+
+	tools := make([]map[string]any, 0, len(request.Tools))
+	for _, tool := range request.Tools {
+		if tool.Function.Name == "" {
+			continue
+		}
+		definition := map[string]any{"name": tool.Function.Name}
+		if tool.Function.Description != "" {
+			definition["description"] = tool.Function.Description
+		}
+		if tool.Function.Parameters != nil {
+			definition["parameters"] = tool.Function.Parameters
+		}
+		tools = append(tools, definition)
+	}
+	if len(tools) == 0 {
+		return ""
+	}
+	toolsJSON, _ := common.Marshal(tools)
+
+	var b strings.Builder
+	b.WriteString("You can call the following tools. To call a tool, output a block in exactly this format:\n")
+	b.WriteString(toolCallOpenTag + "\n{\"name\": \"<tool name>\", \"arguments\": {<arguments as a JSON object>}}\n" + toolCallCloseTag + "\n")
+	if request.ParallelTooCalls != nil && !*request.ParallelTooCalls {
+		b.WriteString("Call at most one tool per reply. ")
+	} else {
+		b.WriteString("Use one block per call; several blocks may be output for parallel calls. ")
+	}
+	b.WriteString("Do not output anything after the tool call blocks. Tool results will be sent back to you inside <tool_result> blocks. If no tool is needed, answer the user directly.")
+	if requirement != "" {
+		b.WriteString("\n" + requirement)
+	}
+	fmt.Fprintf(&b, "\nAvailable tools:\n%s", toolsJSON)
+	return b.String()
+}
+
+func renderEmulatedToolCall(name string, arguments string) string {
+	var args any = map[string]any{}
+	if strings.TrimSpace(arguments) != "" {
+		if err := common.UnmarshalJsonStr(arguments, &args); err != nil {
+			args = arguments
+		}
+	}
+	data, _ := common.Marshal(emulatedToolCall{Name: name, Arguments: args})
+	return toolCallOpenTag + "\n" + string(data) + "\n" + toolCallCloseTag
+}
+
+// parseEmulatedToolCall 解析标签内的调用内容，容忍代码块包裹与字符串形式的参数
+func parseEmulatedToolCall(block string) (*dto.ToolCallResponse, bool) {
+	var call emulatedToolCall
+	if err := common.UnmarshalJsonStr(repairStructuredOutput(block), &call); err != nil || call.Name == "" {
+		return nil, false
+	}
+	arguments := "{}"
+	switch args := call.Arguments.(type) {
+	case nil:
+	case string:
+		if strings.TrimSpace(args) != "" {
+			arguments = args
+		}
+	default:
+		data, err := common.Marshal(args)
+		if err != nil {
+			return nil, false
+		}
+		arguments = string(data)
+	}
+	return &dto.ToolCallResponse{
+		ID:   fmt.Sprintf("call_%s", common.GetRandomString(24)),
+		Type: "function",
+		Function: dto.FunctionResponse{
+			Name:      call.Name,
+			Arguments: arguments,
+		},
+	}, true
+}
+
+// ParseEmulatedToolCalls 从完整的模型输出中解析工具调用，返回去除调用块后的文本，无法解析的块保留为文本
+func ParseEmulatedToolCalls(content string) (string, []dto.ToolCallResponse) {
+	parser := &ToolCallStreamParser{}
+	text, toolCalls := parser.Feed(content)
+	text += parser.Flush()
+	// 非流式响应中的工具调用不带序号
+	for i := range toolCalls {
+		toolCalls[i].Index = nil
+	}
+	return strings.TrimSpace(text), toolCalls
+}
+
+// ToolCallStreamParser 从流式输出中增量解析工具调用：普通文本尽快输出，
+// 可能是调用块开头的内容暂时保留，调用块完整后解析为工具调用
+type ToolCallStreamParser struct {
+	pending string
+	inCall  bool
+	count   int
+}
+
+// Feed 输入一段增量文本，返回可以立即输出的文本与新解析出的工具调用
+func (p *ToolCallStreamParser) Feed(delta string) (string, []dto.ToolCallResponse) {
+	p.pending += delta
+	var text strings.Builder
+	var toolCalls []dto.ToolCallResponse
+	for {
+		if p.inCall {
+			end := strings.Index(p.pending, toolCallCloseTag)
+			if end < 0 {
+				break
+			}
+			block := p.pending[:end]
+			p.pending = p.pending[end+len(toolCallCloseTag):]
+			p.inCall = false
+			if toolCall, ok := parseEmulatedToolCall(block); ok {
+				index := p.count
+				toolCall.Index = &index
+				p.count++
+				toolCalls = append(toolCalls, *toolCall)
+			} else {
+				text.WriteString(toolCallOpenTag + block + toolCallCloseTag)
+			}
+			continue
+		}
+		start := strings.Index(p.pending, toolCallOpenTag)
+		if start >= 0 {
+			text.WriteString(p.pending[:start])
+			p.pending = p.pending[start+len(toolCallOpenTag):]
+			p.inCall = true
+			continue
+		}
+		// 末尾可能是被截断的开始标签，保留到下一段再判断
+		keep := partialTagSuffix(p.pending, toolCallOpenTag)
+		text.WriteString(p.pending[:len(p.pending)-keep])
+		p.pending = p.pending[len(p.pending)-keep:]
+		break
+	}
+	out := text.String()
+	// 调用块之间的空白不作为文本输出
+	if p.count > 0 && strings.TrimSpace(out) == "" {
+		out = ""
+	}
+	return out, toolCalls
+}
+
+// Flush 输出结束时返回剩余的文本，未闭合的调用块按原文返回
+func (p *ToolCallStreamParser) Flush() string {
+	rest := p.pending
+	if p.inCall {
+		rest = toolCallOpenTag + rest
+	}
+	p.pending = ""
+	p.inCall = false
+	return rest
+}
+
+// ToolCallCount 返回已解析出的工具调用数量
+func (p *ToolCallStreamParser) ToolCallCount() int {
+	return p.count
+}
+
+// partialTagSuffix 返回 s 的末尾与 tag 开头重合的最大长度
+func partialTagSuffix(s string, tag string) int {
+	for n := min(len(s), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
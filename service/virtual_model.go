@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveVirtualModel 将虚拟模型按权重解析为目标模型，同一用户（或令牌）对同一虚拟模型的分配结果保持稳定。
+// 启用了模型限制的令牌只会分到允许访问的目标模型；ok 表示 name 是虚拟模型，没有可分配的目标时 target 为空
+func ResolveVirtualModel(c *gin.Context, name string) (target string, ok bool) {
+	virtualModel, ok := operation_setting.GetVirtualModel(name)
+	if !ok {
+		return "", false
+	}
+	stickyBy := virtualModel.StickyBy
+	stickyId := common.GetContextKeyInt(c, constant.ContextKeyUserId)
+	if stickyBy == operation_setting.VirtualModelStickyToken {
+		stickyId = common.GetContextKeyInt(c, constant.ContextKeyTokenId)
+	} else {
+		stickyBy = operation_setting.VirtualModelStickyUser
+	}
+	targets := filterTokenVirtualModelTargets(c, virtualModel.Targets)
+	return pickVirtualModelTarget(targets, fmt.Sprintf("%s:%s:%d", name, stickyBy, stickyId)), true
+}
+
+// filterTokenVirtualModelTargets 去掉令牌模型限制不允许访问的目标
+func filterTokenVirtualModelTargets(c *gin.Context, targets []operation_setting.VirtualModelTarget) []operation_setting.VirtualModelTarget {
+	if !common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		return targets
+	}
+	tokenModelLimit, _ := common.GetContextKeyType[map[string]bool](c, constant.ContextKeyTokenModelLimit)
+	allowed := make([]operation_setting.VirtualModelTarget, 0, len(targets))
+	for _, target := range targets {
+		if tokenModelLimit[ratio_setting.FormatMatchingModelName(target.Model)] {
+			allowed = append(allowed, target)
+		}
+	}
+	return allowed
+}
+
+// pickVirtualModelTarget 按 key 的哈希值落入的权重区间选择目标，权重调整时只有区间变化部分的用户会改变分配
+func pickVirtualModelTarget(targets []operation_setting.VirtualModelTarget, key string) string {
+	total := 0
+	for _, target := range targets {
+		if target.Model != "" && target.Weight > 0 {
+			total += target.Weight
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	bucket := int(hash.Sum32() % uint32(total))
+	for _, target := range targets {
+		if target.Model == "" || target.Weight <= 0 {
+			continue
+		}
+		if bucket < target.Weight {
+			return target.Model
+		}
+		bucket -= target.Weight
+	}
+	return ""
+}
+
+// IsVirtualModel 判断模型名称是否为启用的虚拟模型
+func IsVirtualModel(name string) bool {
+	_, ok := operation_setting.GetVirtualModel(name)
+	return ok
+}
+
+// ListVirtualModels 返回至少有一个目标模型在 available 中的虚拟模型，用于模型列表展示
+func ListVirtualModels(available []string) []string {
+	setting := operation_setting.GetVirtualModelSetting()
+	if !setting.Enabled {
+		return nil
+	}
+	availableSet := make(map[string]bool, len(available))
+	for _, modelName := range available {
+		availableSet[modelName] = true
+	}
+	names := make([]string, 0)
+	for name, virtualModel := range setting.Models {
+		for _, target := range virtualModel.Targets {
+			if target.Weight > 0 && availableSet[target.Model] {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPickVirtualModelTarget(t *testing.T) {
+	targets := []operation_setting.VirtualModelTarget{
+		{Model: "gpt-4o", Weight: 90},
+		{Model: "", Weight: 50},
+		{Model: "claude-sonnet-4", Weight: 10},
+		{Model: "gemini-2.5-pro", Weight: 0},
+	}
+	counts := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("team:user:%d", i)
+		target := pickVirtualModelTarget(targets, key)
+		if target != pickVirtualModelTarget(targets, key) {
+			t.Fatalf("assignment for %s is not stable", key)
+		}
+		counts[target]++
+	}
+	if counts[""] != 0 || counts["gemini-2.5-pro"] != 0 {
+		t.Fatalf("empty and zero-weight targets must not be picked: %v", counts)
+	}
+	// 按 90:10 分流，允许一定偏差
+	if counts["claude-sonnet-4"] < 100 || counts["claude-sonnet-4"] > 300 {
+		t.Fatalf("unexpected distribution: %v", counts)
+	}
+	if target := pickVirtualModelTarget([]operation_setting.VirtualModelTarget{{Model: "gpt-4o"}}, "k"); target != "" {
+		t.Fatalf("targets without weight should pick nothing, got %q", target)
+	}
+}
+
+func TestResolveVirtualModelRespectsTokenModelLimit(t *testing.T) {
+	setting := operation_setting.GetVirtualModelSetting()
+	original := *setting
+	t.Cleanup(func() {
+		*setting = original
+	})
+	setting.Enabled = true
+	setting.Models = map[string]operation_setting.VirtualModel{
+		"team-default": {Targets: []operation_setting.VirtualModelTarget{
+			{Model: "gpt-4o", Weight: 50},
+			{Model: "claude-sonnet-4", Weight: 50},
+		}},
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if _, ok := ResolveVirtualModel(c, "gpt-4o"); ok {
+		t.Fatal("regular models are not virtual")
+	}
+	common.SetContextKey(c, constant.ContextKeyTokenModelLimitEnabled, true)
+	common.SetContextKey(c, constant.ContextKeyTokenModelLimit, map[string]bool{"team-default": true, "claude-sonnet-4": true})
+	for userId := 1; userId <= 20; userId++ {
+		common.SetContextKey(c, constant.ContextKeyUserId, userId)
+		if target, ok := ResolveVirtualModel(c, "team-default"); !ok || target != "claude-sonnet-4" {
+			t.Fatalf("user %d resolved to %q, want the only allowed target", userId, target)
+		}
+	}
+
+	common.SetContextKey(c, constant.ContextKeyTokenModelLimit, map[string]bool{"team-default": true})
+	if target, ok := ResolveVirtualModel(c, "team-default"); !ok || target != "" {
+		t.Fatalf("no allowed target should resolve to empty, got %q, %v", target, ok)
+	}
+}
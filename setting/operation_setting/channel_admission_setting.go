@@ -0,0 +1,37 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ChannelAdmissionSetting 准入排队：模型在分组下的所有渠道均达到最大并发数时，请求排队等待空闲槽位，而不是立即失败
+type ChannelAdmissionSetting struct {
+	Enabled bool `json:"enabled"`
+	// 单个请求的最长等待时间（毫秒）
+	MaxWaitMs int `json:"max_wait_ms"`
+	// 每个分组与模型组合的最大排队请求数，超出时直接拒绝
+	MaxQueueSize int `json:"max_queue_size"`
+	// 用户分组的排队优先级，数值越大越先获得空闲槽位，未配置的分组为 0
+	GroupPriorities map[string]int `json:"group_priorities"`
+}
+
+// 默认配置
+var channelAdmissionSetting = ChannelAdmissionSetting{
+	Enabled:         false,
+	MaxWaitMs:       10000,
+	MaxQueueSize:    100,
+	GroupPriorities: map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_admission_setting", &channelAdmissionSetting)
+}
+
+func GetChannelAdmissionSetting() *ChannelAdmissionSetting {
+	return &channelAdmissionSetting
+}
+
+func GetAdmissionGroupPriority(userGroup string) int {
+	return channelAdmissionSetting.GroupPriorities[userGroup]
+}
@@ -0,0 +1,57 @@
+package operation_setting
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ChannelScriptSetting 渠道请求/响应转换脚本的全局配置
+type ChannelScriptSetting struct {
+	// 关闭后所有渠道的转换脚本都不再执行
+	Enabled bool `json:"enabled"`
+	// 单次脚本调用允许的最长执行时间（毫秒），超时后中断执行并保留原始内容
+	TimeoutMs int `json:"timeout_ms"`
+}
+
+const (
+	defaultChannelScriptTimeoutMs = 50
+	maxChannelScriptTimeoutMs     = 5000
+)
+
+// 默认配置
+var channelScriptSetting = ChannelScriptSetting{
+	Enabled:   true,
+	TimeoutMs: defaultChannelScriptTimeoutMs,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_script_setting", &channelScriptSetting)
+}
+
+func GetChannelScriptSetting() *ChannelScriptSetting {
+	return &channelScriptSetting
+}
+
+// Timeout 返回单次脚本调用的超时时间，配置无效时使用默认值，避免脚本立即被中断
+func (s *ChannelScriptSetting) Timeout() time.Duration {
+	if s.TimeoutMs <= 0 {
+		return defaultChannelScriptTimeoutMs * time.Millisecond
+	}
+	return time.Duration(s.TimeoutMs) * time.Millisecond
+}
+
+// CheckChannelScriptTimeout 校验保存的脚本超时时间
+func CheckChannelScriptTimeout(value string) error {
+	timeoutMs, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("脚本超时时间必须为整数")
+	}
+	if timeoutMs <= 0 || timeoutMs > maxChannelScriptTimeoutMs {
+		return fmt.Errorf("脚本超时时间必须在 1 到 %d 毫秒之间", maxChannelScriptTimeoutMs)
+	}
+	return nil
+}
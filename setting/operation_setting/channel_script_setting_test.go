@@ -0,0 +1,21 @@
+package operation_setting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckChannelScriptTimeout(t *testing.T) {
+	for _, value := range []string{"0", "-1", "abc", "5001"} {
+		if CheckChannelScriptTimeout(value) == nil {
+			t.Fatalf("timeout %q should be rejected", value)
+		}
+	}
+	if err := CheckChannelScriptTimeout("100"); err != nil {
+		t.Fatal(err)
+	}
+	setting := ChannelScriptSetting{TimeoutMs: 0}
+	if setting.Timeout() != defaultChannelScriptTimeoutMs*time.Millisecond {
+		t.Fatalf("invalid timeout should fall back to the default, got %s", setting.Timeout())
+	}
+}
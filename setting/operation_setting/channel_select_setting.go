@@ -0,0 +1,52 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ChannelSelectSetting 自适应渠道选择：根据实时流量统计的成功率与首字时间动态调整同优先级内的权重
+type ChannelSelectSetting struct {
+	AdaptiveEnabled bool `json:"adaptive_enabled"`
+	// 生效的分组，为空时对所有分组生效
+	AdaptiveGroups []string `json:"adaptive_groups"`
+	// 统计窗口（秒）
+	WindowSeconds int `json:"window_seconds"`
+	// 窗口内样本数低于该值时不调整权重
+	MinSamples int `json:"min_samples"`
+	// 首字时间低于该值（毫秒）时不因延迟降权
+	TargetLatencyMs int `json:"target_latency_ms"`
+	// 有效权重的下限（百分比），避免渠道完全无流量而无法恢复
+	MinWeightPercent int `json:"min_weight_percent"`
+}
+
+// 默认配置
+var channelSelectSetting = ChannelSelectSetting{
+	AdaptiveEnabled:  false,
+	AdaptiveGroups:   []string{},
+	WindowSeconds:    300,
+	MinSamples:       10,
+	TargetLatencyMs:  3000,
+	MinWeightPercent: 5,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_select_setting", &channelSelectSetting)
+}
+
+func GetChannelSelectSetting() *ChannelSelectSetting {
+	return &channelSelectSetting
+}
+
+// IsAdaptiveChannelSelectEnabled 判断分组是否启用自适应渠道选择
+func IsAdaptiveChannelSelectEnabled(group string) bool {
+	if !channelSelectSetting.AdaptiveEnabled {
+		return false
+	}
+	if len(channelSelectSetting.AdaptiveGroups) == 0 {
+		return true
+	}
+	return slices.Contains(channelSelectSetting.AdaptiveGroups, group)
+}
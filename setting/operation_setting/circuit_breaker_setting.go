@@ -0,0 +1,40 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// CircuitBreakerSetting 渠道熔断：连续失败后暂停调度，冷却后以少量真实请求探测，成功后自动恢复
+type CircuitBreakerSetting struct {
+	Enabled bool `json:"enabled"`
+	// 统计窗口内连续失败达到该次数后打开熔断
+	FailureThreshold int `json:"failure_threshold"`
+	// 失败计数窗口（秒）
+	WindowSeconds int `json:"window_seconds"`
+	// 熔断打开后的冷却时间（秒），之后进入半开状态
+	CooldownSeconds int `json:"cooldown_seconds"`
+	// 半开状态下探测请求的间隔（秒）
+	ProbeIntervalSeconds int `json:"probe_interval_seconds"`
+	// 半开状态下探测成功达到该次数后关闭熔断
+	HalfOpenSuccesses int `json:"half_open_successes"`
+	// 是否对多 Key 渠道的单个 Key 独立熔断
+	MultiKeyEnabled bool `json:"multi_key_enabled"`
+}
+
+// 默认配置
+var circuitBreakerSetting = CircuitBreakerSetting{
+	Enabled:              false,
+	FailureThreshold:     5,
+	WindowSeconds:        60,
+	CooldownSeconds:      30,
+	ProbeIntervalSeconds: 5,
+	HalfOpenSuccesses:    3,
+	MultiKeyEnabled:      true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("circuit_breaker_setting", &circuitBreakerSetting)
+}
+
+func GetCircuitBreakerSetting() *CircuitBreakerSetting {
+	return &circuitBreakerSetting
+}
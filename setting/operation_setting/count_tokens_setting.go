@@ -0,0 +1,25 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// CountTokensSetting token 计数接口（/v1/messages/count_tokens、:countTokens、/v1/responses/input_tokens）的配置
+type CountTokensSetting struct {
+	// 所选渠道原生支持对应格式的计数接口时转发给上游，否则在本地估算
+	ForwardUpstreamEnabled bool `json:"forward_upstream_enabled"`
+}
+
+// 默认配置
+var countTokensSetting = CountTokensSetting{
+	ForwardUpstreamEnabled: false,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("count_tokens_setting", &countTokensSetting)
+}
+
+func GetCountTokensSetting() *CountTokensSetting {
+	return &countTokensSetting
+}
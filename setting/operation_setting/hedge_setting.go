@@ -0,0 +1,45 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// HedgeSetting 对冲请求：所选渠道在指定时间内未返回首字时，同时向另一个渠道发起相同请求，使用先返回的结果
+type HedgeSetting struct {
+	Enabled bool `json:"enabled"`
+	// 未单独配置延迟的模型使用的默认延迟（毫秒）
+	DefaultDelayMs int `json:"default_delay_ms"`
+	// 启用对冲的模型及其延迟（毫秒），值为 0 时使用默认延迟；未列出的模型不进行对冲
+	ModelDelays map[string]int `json:"model_delays"`
+}
+
+// 默认配置
+var hedgeSetting = HedgeSetting{
+	Enabled:        false,
+	DefaultDelayMs: 2000,
+	ModelDelays:    map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("hedge_setting", &hedgeSetting)
+}
+
+func GetHedgeSetting() *HedgeSetting {
+	return &hedgeSetting
+}
+
+// GetHedgeDelayMs 返回模型的对冲延迟，模型未启用对冲时返回 false
+func GetHedgeDelayMs(modelName string) (int, bool) {
+	if !hedgeSetting.Enabled {
+		return 0, false
+	}
+	delay, ok := hedgeSetting.ModelDelays[modelName]
+	if !ok {
+		return 0, false
+	}
+	if delay <= 0 {
+		delay = hedgeSetting.DefaultDelayMs
+	}
+	return delay, true
+}
@@ -0,0 +1,27 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ModelFallbackSetting 模型降级链：模型的渠道全部不可用或请求失败时，依次改用链中的模型
+type ModelFallbackSetting struct {
+	Enabled bool `json:"enabled"` // 是否启用全局降级链，令牌单独配置的降级链不受影响
+	// 模型及其降级链，例如 {"gpt-4o": ["claude-sonnet-4", "gemini-2.5-pro"]}；令牌可单独配置并覆盖同名模型的降级链
+	Chains map[string][]string `json:"chains"`
+}
+
+// 默认配置
+var modelFallbackSetting = ModelFallbackSetting{
+	Enabled: false,
+	Chains:  map[string][]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_fallback_setting", &modelFallbackSetting)
+}
+
+func GetModelFallbackSetting() *ModelFallbackSetting {
+	return &modelFallbackSetting
+}
@@ -0,0 +1,94 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+const (
+	ModerationActionBlock  = "block"  // 拒绝请求或中止生成
+	ModerationActionRedact = "redact" // 替换命中内容后继续，外部分类器无法定位命中内容，按拒绝处理
+	ModerationActionLog    = "log"    // 仅记录日志
+	ModerationActionOff    = "off"    // 不审核
+)
+
+const (
+	ModerationProviderKeyword    = "keyword"
+	ModerationProviderRegex      = "regex"
+	ModerationProviderClassifier = "classifier"
+)
+
+// ModerationRegexRule 正则审核规则
+type ModerationRegexRule struct {
+	Category string `json:"category"`
+	Pattern  string `json:"pattern"`
+}
+
+// ModerationPolicy 分组的审核策略
+type ModerationPolicy struct {
+	Action string `json:"action"`
+	// 是否审核流式补全内容（仅关键词与正则，支持 OpenAI 格式、Claude 与 Gemini 原生格式的流式补全）
+	CheckCompletion bool `json:"check_completion"`
+}
+
+// ModerationSetting 内容审核：在预扣费前审核提示词，并可选地审核流式补全内容
+type ModerationSetting struct {
+	Enabled bool `json:"enabled"`
+	// 启用的审核方式，按顺序执行：keyword、regex、classifier
+	Providers []string `json:"providers"`
+	// 关键词审核，类别到关键词列表的映射，匹配时不区分大小写
+	Keywords map[string][]string `json:"keywords"`
+	// 正则审核规则
+	RegexRules []ModerationRegexRule `json:"regex_rules"`
+	// 外部分类器使用的模型，由网关中已配置的 OpenAI 兼容 /v1/moderations 渠道提供
+	ClassifierModel string `json:"classifier_model"`
+	// 选择分类器渠道时使用的分组
+	ClassifierGroup string `json:"classifier_group"`
+	// 分类器请求超时（毫秒），超时或出错时放行
+	ClassifierTimeoutMs int `json:"classifier_timeout_ms"`
+	// 命中内容的替换文本
+	RedactReplacement string `json:"redact_replacement"`
+	// 未单独配置的分组使用的策略
+	DefaultPolicy ModerationPolicy `json:"default_policy"`
+	// 按使用分组配置的策略
+	GroupPolicies map[string]ModerationPolicy `json:"group_policies"`
+}
+
+// 默认配置
+var moderationSetting = ModerationSetting{
+	Enabled:             false,
+	Providers:           []string{ModerationProviderKeyword, ModerationProviderRegex},
+	Keywords:            map[string][]string{},
+	RegexRules:          []ModerationRegexRule{},
+	ClassifierModel:     "omni-moderation-latest",
+	ClassifierGroup:     "default",
+	ClassifierTimeoutMs: 3000,
+	RedactReplacement:   "**###**",
+	DefaultPolicy: ModerationPolicy{
+		Action: ModerationActionBlock,
+	},
+	GroupPolicies: map[string]ModerationPolicy{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("moderation_setting", &moderationSetting)
+}
+
+func GetModerationSetting() *ModerationSetting {
+	return &moderationSetting
+}
+
+// GetModerationPolicy 返回分组的审核策略，未启用审核或策略为 off 时返回 false
+func GetModerationPolicy(group string) (ModerationPolicy, bool) {
+	if !moderationSetting.Enabled {
+		return ModerationPolicy{}, false
+	}
+	policy, ok := moderationSetting.GroupPolicies[group]
+	if !ok {
+		policy = moderationSetting.DefaultPolicy
+	}
+	if policy.Action == "" || policy.Action == ModerationActionOff {
+		return ModerationPolicy{}, false
+	}
+	return policy, true
+}
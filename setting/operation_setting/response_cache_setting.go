@@ -0,0 +1,49 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ResponseCacheSetting 响应缓存：相同的 Embedding 请求与 temperature 为 0 的对话请求直接返回缓存结果
+type ResponseCacheSetting struct {
+	Enabled bool `json:"enabled"`
+	// 对这些分组的所有令牌启用，其余令牌需在令牌设置中单独开启
+	Groups []string `json:"groups"`
+	// 缓存有效期（秒）
+	TTLSeconds int `json:"ttl_seconds"`
+	// 命中缓存时的计费倍率，0 表示免费
+	BillingRatio float64 `json:"billing_ratio"`
+	// 单条缓存的最大大小（KB），超出则不缓存
+	MaxEntrySizeKB int `json:"max_entry_size_kb"`
+	// 未启用 Redis 时内存 LRU 缓存的最大条数
+	MemoryMaxEntries int `json:"memory_max_entries"`
+}
+
+// 默认配置
+var responseCacheSetting = ResponseCacheSetting{
+	Enabled:          false,
+	Groups:           []string{},
+	TTLSeconds:       3600,
+	BillingRatio:     0.1,
+	MaxEntrySizeKB:   512,
+	MemoryMaxEntries: 1000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_cache_setting", &responseCacheSetting)
+}
+
+func GetResponseCacheSetting() *ResponseCacheSetting {
+	return &responseCacheSetting
+}
+
+// IsResponseCacheEnabled 判断分组或令牌是否启用响应缓存
+func IsResponseCacheEnabled(group string, tokenEnabled bool) bool {
+	if !responseCacheSetting.Enabled {
+		return false
+	}
+	return tokenEnabled || slices.Contains(responseCacheSetting.Groups, group)
+}
@@ -0,0 +1,33 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ResponseStoreSetting 网关保存的 Responses 会话状态，用于为无状态上游解析 previous_response_id
+type ResponseStoreSetting struct {
+	Enabled bool `json:"enabled"`
+	// 保存时长（秒）
+	TTLSeconds int `json:"ttl_seconds"`
+	// 单条记录（完整输入与响应）的最大大小（KB），超出时不保存
+	MaxEntrySizeKB int `json:"max_entry_size_kb"`
+	// 每个令牌最多保存的响应数，超出时删除最早的记录，0 表示不限制
+	MaxResponsesPerToken int `json:"max_responses_per_token"`
+}
+
+// 默认配置
+var responseStoreSetting = ResponseStoreSetting{
+	Enabled:              true,
+	TTLSeconds:           30 * 24 * 3600,
+	MaxEntrySizeKB:       2048,
+	MaxResponsesPerToken: 1000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_store_setting", &responseStoreSetting)
+}
+
+func GetResponseStoreSetting() *ResponseStoreSetting {
+	return &responseStoreSetting
+}
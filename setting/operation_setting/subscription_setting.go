@@ -0,0 +1,20 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// SubscriptionSetting 订阅套餐配置，套餐本身在套餐管理中维护
+type SubscriptionSetting struct {
+	Enabled bool `json:"enabled"` // 是否启用订阅套餐，关闭后不再出售套餐，已有订阅的套餐额度也暂停使用
+}
+
+var subscriptionSetting = SubscriptionSetting{
+	Enabled: false,
+}
+
+func init() {
+	config.GlobalConfig.Register("subscription_setting", &subscriptionSetting)
+}
+
+func GetSubscriptionSetting() *SubscriptionSetting {
+	return &subscriptionSetting
+}
@@ -0,0 +1,54 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// RateLimitRule 每分钟 Token 数（TPM）与请求数（RPM）限制，0 表示不限制
+type RateLimitRule struct {
+	TPM int `json:"tpm"`
+	RPM int `json:"rpm"`
+}
+
+// TokenRateLimitSetting 按用户分组与模型的 TPM/RPM 限流，令牌自身的限制在令牌设置中配置
+type TokenRateLimitSetting struct {
+	Enabled bool `json:"enabled"`
+	// 用户分组 -> 限制，对该分组下的每个用户分别生效
+	GroupLimits map[string]RateLimitRule `json:"group_limits"`
+	// 模型 -> 限制，对每个用户调用该模型分别生效
+	ModelLimits map[string]RateLimitRule `json:"model_limits"`
+}
+
+// 默认配置
+var tokenRateLimitSetting = TokenRateLimitSetting{
+	Enabled:     false,
+	GroupLimits: map[string]RateLimitRule{},
+	ModelLimits: map[string]RateLimitRule{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("token_rate_limit_setting", &tokenRateLimitSetting)
+}
+
+func GetTokenRateLimitSetting() *TokenRateLimitSetting {
+	return &tokenRateLimitSetting
+}
+
+// GetGroupRateLimitRule 返回用户分组的 TPM/RPM 限制
+func GetGroupRateLimitRule(group string) (RateLimitRule, bool) {
+	if !tokenRateLimitSetting.Enabled {
+		return RateLimitRule{}, false
+	}
+	rule, ok := tokenRateLimitSetting.GroupLimits[group]
+	return rule, ok
+}
+
+// GetModelRateLimitRule 返回模型的 TPM/RPM 限制
+func GetModelRateLimitRule(modelName string) (RateLimitRule, bool) {
+	if !tokenRateLimitSetting.Enabled {
+		return RateLimitRule{}, false
+	}
+	rule, ok := tokenRateLimitSetting.ModelLimits[modelName]
+	return rule, ok
+}
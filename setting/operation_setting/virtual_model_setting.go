@@ -0,0 +1,55 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// 虚拟模型的分流依据
+const (
+	VirtualModelStickyUser  = "user"  // 同一用户始终分到同一目标模型
+	VirtualModelStickyToken = "token" // 同一令牌始终分到同一目标模型
+)
+
+// VirtualModelTarget 虚拟模型的一个分流目标
+type VirtualModelTarget struct {
+	Model  string `json:"model"`
+	Weight int    `json:"weight"`
+}
+
+// VirtualModel 网关级别的虚拟模型，请求时按权重解析为其中一个目标模型，与渠道的模型重定向无关
+type VirtualModel struct {
+	Targets []VirtualModelTarget `json:"targets"`
+	// 分流依据，user 或 token，默认按用户
+	StickyBy string `json:"sticky_by"`
+}
+
+// VirtualModelSetting 虚拟模型与 A/B 分流配置
+type VirtualModelSetting struct {
+	Enabled bool `json:"enabled"`
+	// 虚拟模型名称及其分流目标，例如 {"team-default": {"targets": [{"model": "gpt-4o", "weight": 90}, {"model": "claude-sonnet-4", "weight": 10}]}}
+	Models map[string]VirtualModel `json:"models"`
+}
+
+// 默认配置
+var virtualModelSetting = VirtualModelSetting{
+	Enabled: false,
+	Models:  map[string]VirtualModel{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("virtual_model_setting", &virtualModelSetting)
+}
+
+func GetVirtualModelSetting() *VirtualModelSetting {
+	return &virtualModelSetting
+}
+
+// GetVirtualModel 返回启用状态下的虚拟模型配置
+func GetVirtualModel(name string) (VirtualModel, bool) {
+	if !virtualModelSetting.Enabled {
+		return VirtualModel{}, false
+	}
+	virtualModel, ok := virtualModelSetting.Models[name]
+	return virtualModel, ok
+}
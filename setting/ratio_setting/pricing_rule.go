@@ -0,0 +1,203 @@
+package ratio_setting
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// PricingRule 计价规则，在模型倍率与价格的基础上按模型、分组、提示词长度与时间段调整价格，
+// 按配置顺序匹配，第一条命中的规则生效
+type PricingRule struct {
+	Name string `json:"name"`
+	// 适用的模型，支持以 * 结尾的前缀匹配，为空表示所有模型
+	Models []string `json:"models,omitempty"`
+	// 适用的分组，为空表示所有分组
+	Groups []string `json:"groups,omitempty"`
+	// 输入 tokens 区间 (MinPromptTokens, MaxPromptTokens]，包含缓存读取与写入的 tokens，0 表示不限制
+	MinPromptTokens int `json:"min_prompt_tokens,omitempty"`
+	MaxPromptTokens int `json:"max_prompt_tokens,omitempty"`
+	// 生效时间段，格式为 HH:MM，结束时间早于开始时间表示跨越零点，均为空表示全天
+	StartTime string `json:"start_time,omitempty"`
+	EndTime   string `json:"end_time,omitempty"`
+	// 时间段所用的时区，为空时使用服务器时区
+	Timezone string `json:"timezone,omitempty"`
+	// 输入与输出价格的倍数，为 0 时视为 1
+	InputMultiplier  float64 `json:"input_multiplier,omitempty"`
+	OutputMultiplier float64 `json:"output_multiplier,omitempty"`
+
+	startMinute int
+	endMinute   int
+	location    *time.Location
+}
+
+var (
+	pricingRules      = make([]PricingRule, 0)
+	pricingRulesMutex sync.RWMutex
+)
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("时间格式应为 HH:MM：%s", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func parsePricingRules(jsonStr string) ([]PricingRule, error) {
+	rules := make([]PricingRule, 0)
+	if strings.TrimSpace(jsonStr) == "" {
+		return rules, nil
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &rules); err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule_%d", i+1)
+		}
+		if rule.InputMultiplier < 0 || rule.OutputMultiplier < 0 {
+			return nil, fmt.Errorf("计价规则 %s 的倍数不能小于 0", rule.Name)
+		}
+		if rule.InputMultiplier == 0 {
+			rule.InputMultiplier = 1
+		}
+		if rule.OutputMultiplier == 0 {
+			rule.OutputMultiplier = 1
+		}
+		if rule.MinPromptTokens < 0 || rule.MaxPromptTokens < 0 ||
+			(rule.MaxPromptTokens > 0 && rule.MaxPromptTokens <= rule.MinPromptTokens) {
+			return nil, fmt.Errorf("计价规则 %s 的提示词 tokens 区间无效", rule.Name)
+		}
+		if (rule.StartTime == "") != (rule.EndTime == "") {
+			return nil, fmt.Errorf("计价规则 %s 需要同时设置开始与结束时间", rule.Name)
+		}
+		if rule.StartTime != "" {
+			var err error
+			if rule.startMinute, err = parseClock(rule.StartTime); err != nil {
+				return nil, fmt.Errorf("计价规则 %s 的%s", rule.Name, err.Error())
+			}
+			if rule.endMinute, err = parseClock(rule.EndTime); err != nil {
+				return nil, fmt.Errorf("计价规则 %s 的%s", rule.Name, err.Error())
+			}
+			if rule.startMinute == rule.endMinute {
+				return nil, fmt.Errorf("计价规则 %s 的开始与结束时间不能相同", rule.Name)
+			}
+		}
+		rule.location = time.Local
+		if rule.Timezone != "" {
+			location, err := time.LoadLocation(rule.Timezone)
+			if err != nil {
+				return nil, fmt.Errorf("计价规则 %s 的时区无效：%s", rule.Name, rule.Timezone)
+			}
+			rule.location = location
+		}
+	}
+	return rules, nil
+}
+
+func PricingRules2JSONString() string {
+	pricingRulesMutex.RLock()
+	defer pricingRulesMutex.RUnlock()
+
+	jsonBytes, err := json.Marshal(pricingRules)
+	if err != nil {
+		common.SysLog("error marshalling pricing rules: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdatePricingRulesByJSONString(jsonStr string) error {
+	rules, err := parsePricingRules(jsonStr)
+	if err != nil {
+		return err
+	}
+	pricingRulesMutex.Lock()
+	defer pricingRulesMutex.Unlock()
+	pricingRules = rules
+	return nil
+}
+
+func CheckPricingRules(jsonStr string) error {
+	_, err := parsePricingRules(jsonStr)
+	return err
+}
+
+// GetPricingRules 返回当前配置的计价规则副本
+func GetPricingRules() []PricingRule {
+	pricingRulesMutex.RLock()
+	defer pricingRulesMutex.RUnlock()
+
+	rules := make([]PricingRule, len(pricingRules))
+	copy(rules, pricingRules)
+	return rules
+}
+
+func (rule *PricingRule) matchModel(modelName string) bool {
+	if len(rule.Models) == 0 {
+		return true
+	}
+	for _, pattern := range rule.Models {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(modelName, prefix) {
+				return true
+			}
+		} else if pattern == modelName {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *PricingRule) matchTime(at time.Time) bool {
+	if rule.StartTime == "" {
+		return true
+	}
+	local := at.In(rule.location)
+	minute := local.Hour()*60 + local.Minute()
+	if rule.startMinute < rule.endMinute {
+		return minute >= rule.startMinute && minute < rule.endMinute
+	}
+	return minute >= rule.startMinute || minute < rule.endMinute
+}
+
+// Match 判断规则是否适用于本次请求
+func (rule *PricingRule) Match(modelName, group string, promptTokens int, at time.Time) bool {
+	if !rule.matchModel(modelName) {
+		return false
+	}
+	if len(rule.Groups) > 0 && !common.StringsContains(rule.Groups, group) {
+		return false
+	}
+	if rule.MinPromptTokens > 0 && promptTokens <= rule.MinPromptTokens {
+		return false
+	}
+	if rule.MaxPromptTokens > 0 && promptTokens > rule.MaxPromptTokens {
+		return false
+	}
+	return rule.matchTime(at)
+}
+
+// MatchPricingRule 返回第一条适用的计价规则，没有命中时返回 nil
+func MatchPricingRule(modelName, group string, promptTokens int, at time.Time) *types.PricingRuleInfo {
+	pricingRulesMutex.RLock()
+	defer pricingRulesMutex.RUnlock()
+
+	for i := range pricingRules {
+		rule := &pricingRules[i]
+		if rule.Match(modelName, group, promptTokens, at) {
+			return &types.PricingRuleInfo{
+				Name:             rule.Name,
+				InputMultiplier:  rule.InputMultiplier,
+				OutputMultiplier: rule.OutputMultiplier,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package ratio_setting
+
+import (
+	"testing"
+	"time"
+)
+
+func setTestPricingRules(t *testing.T, jsonStr string) {
+	t.Helper()
+	original := PricingRules2JSONString()
+	if err := UpdatePricingRulesByJSONString(jsonStr); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = UpdatePricingRulesByJSONString(original)
+	})
+}
+
+func TestMatchPricingRuleTiers(t *testing.T) {
+	setTestPricingRules(t, `[
+		{"name": "vip-night", "models": ["gemini-*"], "groups": ["vip"], "start_time": "22:00", "end_time": "06:00", "timezone": "UTC", "input_multiplier": 0.5},
+		{"name": "long", "models": ["gemini-*"], "min_prompt_tokens": 200000, "input_multiplier": 2, "output_multiplier": 1.5},
+		{"name": "short", "models": ["gemini-2.5-pro"], "max_prompt_tokens": 200000, "input_multiplier": 1}
+	]`)
+	noon := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	night := time.Date(2026, 10, 18, 23, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		model  string
+		group  string
+		tokens int
+		at     time.Time
+		want   string
+	}{
+		{"gemini-2.5-pro", "default", 200000, noon, "short"},
+		{"gemini-2.5-pro", "default", 200001, noon, "long"},
+		{"gemini-2.5-flash", "default", 1000, noon, ""},
+		{"gemini-2.5-pro", "vip", 1000, night, "vip-night"},
+		{"gemini-2.5-pro", "vip", 1000, noon, "short"},
+		{"gpt-4o", "vip", 1000, night, ""},
+	}
+	for _, tc := range cases {
+		rule := MatchPricingRule(tc.model, tc.group, tc.tokens, tc.at)
+		name := ""
+		if rule != nil {
+			name = rule.Name
+		}
+		if name != tc.want {
+			t.Fatalf("%s/%s/%d at %s matched %q, want %q", tc.model, tc.group, tc.tokens, tc.at.Format("15:04"), name, tc.want)
+		}
+	}
+	if rule := MatchPricingRule("gemini-2.5-pro", "default", 300000, noon); rule.OutputMultiplier != 1.5 || rule.CompletionRatioMultiplier() != 0.75 {
+		t.Fatalf("unexpected multipliers %+v", rule)
+	}
+}
+
+func TestCheckPricingRules(t *testing.T) {
+	for _, rules := range []string{
+		`[{"name": "bad-range", "min_prompt_tokens": 100, "max_prompt_tokens": 50}]`,
+		`[{"name": "bad-time", "start_time": "25:00", "end_time": "06:00"}]`,
+		`{"name": "not-a-list"}`,
+	} {
+		if CheckPricingRules(rules) == nil {
+			t.Fatalf("rules %s should be rejected", rules)
+		}
+	}
+}
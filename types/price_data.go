@@ -24,6 +24,40 @@ type PriceData struct {
 	UsePrice             bool
 	QuotaToPreConsume    int // 预消耗额度
 	GroupRatioInfo       GroupRatioInfo
+	PricingRule          *PricingRuleInfo // 命中的计价规则，未命中时为 nil
+}
+
+// PricingRuleInfo 计价规则命中结果，倍率均大于 0
+type PricingRuleInfo struct {
+	Name             string  `json:"name"`
+	InputMultiplier  float64 `json:"input_multiplier"`
+	OutputMultiplier float64 `json:"output_multiplier"`
+}
+
+// CompletionRatioMultiplier 补全倍率需要乘上的系数，模型倍率已乘过输入倍率
+func (r *PricingRuleInfo) CompletionRatioMultiplier() float64 {
+	if r == nil {
+		return 1
+	}
+	return r.OutputMultiplier / r.InputMultiplier
+}
+
+// ApplyPricingRule 将计价规则应用到价格上，已应用过其他规则时先撤销原规则；rule 为 nil 表示恢复原价。
+// 输入倍率作用于模型倍率与按次价格，缓存等相对模型倍率的价格随之变化，输出倍率作用于补全价格
+func (p *PriceData) ApplyPricingRule(rule *PricingRuleInfo) {
+	inputMultiplier, completionMultiplier := 1.0, 1.0
+	if p.PricingRule != nil {
+		inputMultiplier /= p.PricingRule.InputMultiplier
+		completionMultiplier /= p.PricingRule.CompletionRatioMultiplier()
+	}
+	if rule != nil {
+		inputMultiplier *= rule.InputMultiplier
+		completionMultiplier *= rule.CompletionRatioMultiplier()
+	}
+	p.ModelRatio *= inputMultiplier
+	p.ModelPrice *= inputMultiplier
+	p.CompletionRatio *= completionMultiplier
+	p.PricingRule = rule
 }
 
 func (p *PriceData) AddOtherRatio(key string, ratio float64) {
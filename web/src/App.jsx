@@ -35,6 +35,8 @@ import Channel from './pages/Channel';
 import Token from './pages/Token';
 import Redemption from './pages/Redemption';
 import TopUp from './pages/TopUp';
+import Organization from './pages/Organization';
+import Statement from './pages/Statement';
 import Log from './pages/Log';
 import Chat from './pages/Chat';
 import Chat2Link from './pages/Chat2Link';
@@ -255,6 +257,22 @@ function App() {
             </PrivateRoute>
           }
         />
+        <Route
+          path='/console/organization'
+          element={
+            <PrivateRoute>
+              <Organization />
+            </PrivateRoute>
+          }
+        />
+        <Route
+          path='/console/statement'
+          element={
+            <PrivateRoute>
+              <Statement />
+            </PrivateRoute>
+          }
+        />
         <Route
           path='/console/log'
           element={
@@ -36,6 +36,8 @@ const routerMap = {
   token: '/console/token',
   redemption: '/console/redemption',
   topup: '/console/topup',
+  organization: '/console/organization',
+  statement: '/console/statement',
   user: '/console/user',
   log: '/console/log',
   midjourney: '/console/midjourney',
@@ -128,6 +130,16 @@ const SiderBar = ({ onNavigate = () => {} }) => {
         itemKey: 'topup',
         to: '/topup',
       },
+      {
+        text: t('组织管理'),
+        itemKey: 'organization',
+        to: '/organization',
+      },
+      {
+        text: t('账单'),
+        itemKey: 'statement',
+        to: '/statement',
+      },
       {
         text: t('个人设置'),
         itemKey: 'personal',
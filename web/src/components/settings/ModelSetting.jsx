@@ -25,6 +25,8 @@ import { useTranslation } from 'react-i18next';
 import SettingGeminiModel from '../../pages/Setting/Model/SettingGeminiModel';
 import SettingClaudeModel from '../../pages/Setting/Model/SettingClaudeModel';
 import SettingGlobalModel from '../../pages/Setting/Model/SettingGlobalModel';
+import SettingVirtualModel from '../../pages/Setting/Model/SettingVirtualModel';
+import SettingModelFallback from '../../pages/Setting/Model/SettingModelFallback';
 
 const ModelSetting = () => {
   const { t } = useTranslation();
@@ -43,6 +45,10 @@ const ModelSetting = () => {
     'general_setting.ping_interval_seconds': 60,
     'gemini.thinking_adapter_enabled': false,
     'gemini.thinking_adapter_budget_tokens_percentage': 0.6,
+    'virtual_model_setting.enabled': false,
+    'virtual_model_setting.models': '{}',
+    'model_fallback_setting.enabled': false,
+    'model_fallback_setting.chains': '{}',
   });
 
   let [loading, setLoading] = useState(false);
@@ -59,7 +65,9 @@ const ModelSetting = () => {
           item.key === 'claude.model_headers_settings' ||
           item.key === 'claude.default_max_tokens' ||
           item.key === 'gemini.supported_imagine_models' ||
-          item.key === 'global.thinking_model_blacklist'
+          item.key === 'global.thinking_model_blacklist' ||
+          item.key === 'virtual_model_setting.models' ||
+          item.key === 'model_fallback_setting.chains'
         ) {
           if (item.value !== '') {
             item.value = JSON.stringify(JSON.parse(item.value), null, 2);
@@ -110,6 +118,14 @@ const ModelSetting = () => {
         <Card style={{ marginTop: '10px' }}>
           <SettingClaudeModel options={inputs} refresh={onRefresh} />
         </Card>
+        {/* 虚拟模型 */}
+        <Card style={{ marginTop: '10px' }}>
+          <SettingVirtualModel options={inputs} refresh={onRefresh} />
+        </Card>
+        {/* 模型降级 */}
+        <Card style={{ marginTop: '10px' }}>
+          <SettingModelFallback options={inputs} refresh={onRefresh} />
+        </Card>
       </Spin>
     </>
   );
@@ -23,10 +23,14 @@ import SettingsGeneral from '../../pages/Setting/Operation/SettingsGeneral';
 import SettingsHeaderNavModules from '../../pages/Setting/Operation/SettingsHeaderNavModules';
 import SettingsSidebarModulesAdmin from '../../pages/Setting/Operation/SettingsSidebarModulesAdmin';
 import SettingsSensitiveWords from '../../pages/Setting/Operation/SettingsSensitiveWords';
+import SettingsModeration from '../../pages/Setting/Operation/SettingsModeration';
 import SettingsLog from '../../pages/Setting/Operation/SettingsLog';
 import SettingsMonitoring from '../../pages/Setting/Operation/SettingsMonitoring';
+import SettingsChannelSelect from '../../pages/Setting/Operation/SettingsChannelSelect';
+import SettingsChannelAdmission from '../../pages/Setting/Operation/SettingsChannelAdmission';
 import SettingsCreditLimit from '../../pages/Setting/Operation/SettingsCreditLimit';
 import SettingsCheckin from '../../pages/Setting/Operation/SettingsCheckin';
+import SettingsSubscription from '../../pages/Setting/Operation/SettingsSubscription';
 import { API, showError, toBoolean } from '../../helpers';
 
 const OperationSetting = () => {
@@ -61,6 +65,9 @@ const OperationSetting = () => {
     CheckSensitiveOnPromptEnabled: false,
     SensitiveWords: '',
 
+    /* 内容审核设置 */
+    'moderation_setting.enabled': false,
+
     /* 日志设置 */
     LogConsumeEnabled: false,
 
@@ -75,6 +82,12 @@ const OperationSetting = () => {
     'checkin_setting.enabled': false,
     'checkin_setting.min_quota': 1000,
     'checkin_setting.max_quota': 10000,
+    /* 订阅套餐设置 */
+    'subscription_setting.enabled': false,
+    /* 自适应渠道选择设置 */
+    'channel_select_setting.adaptive_enabled': false,
+    /* 准入排队设置 */
+    'channel_admission_setting.enabled': false,
   });
 
   let [loading, setLoading] = useState(false);
@@ -132,6 +145,10 @@ const OperationSetting = () => {
         <Card style={{ marginTop: '10px' }}>
           <SettingsSensitiveWords options={inputs} refresh={onRefresh} />
         </Card>
+        {/* 内容审核设置 */}
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsModeration options={inputs} refresh={onRefresh} />
+        </Card>
         {/* 日志设置 */}
         <Card style={{ marginTop: '10px' }}>
           <SettingsLog options={inputs} refresh={onRefresh} />
@@ -140,6 +157,14 @@ const OperationSetting = () => {
         <Card style={{ marginTop: '10px' }}>
           <SettingsMonitoring options={inputs} refresh={onRefresh} />
         </Card>
+        {/* 自适应渠道选择设置 */}
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsChannelSelect options={inputs} refresh={onRefresh} />
+        </Card>
+        {/* 准入排队设置 */}
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsChannelAdmission options={inputs} refresh={onRefresh} />
+        </Card>
         {/* 额度设置 */}
         <Card style={{ marginTop: '10px' }}>
           <SettingsCreditLimit options={inputs} refresh={onRefresh} />
@@ -148,6 +173,10 @@ const OperationSetting = () => {
         <Card style={{ marginTop: '10px' }}>
           <SettingsCheckin options={inputs} refresh={onRefresh} />
         </Card>
+        {/* 订阅套餐设置 */}
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsSubscription options={inputs} refresh={onRefresh} />
+        </Card>
       </Spin>
     </>
   );
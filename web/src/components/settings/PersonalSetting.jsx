@@ -87,6 +87,8 @@ const PersonalSetting = () => {
     gotifyPriority: 5,
     acceptUnsetModelRatioModel: false,
     recordIpLog: false,
+    dailyBudget: 0,
+    monthlyBudget: 0,
   });
 
   useEffect(() => {
@@ -160,6 +162,8 @@ const PersonalSetting = () => {
         acceptUnsetModelRatioModel:
           settings.accept_unset_model_ratio_model || false,
         recordIpLog: settings.record_ip_log || false,
+        dailyBudget: settings.daily_budget || 0,
+        monthlyBudget: settings.monthly_budget || 0,
       });
     }
   }, [userState?.user?.setting]);
@@ -428,6 +432,8 @@ const PersonalSetting = () => {
         accept_unset_model_ratio_model:
           notificationSettings.acceptUnsetModelRatioModel,
         record_ip_log: notificationSettings.recordIpLog,
+        daily_budget: parseInt(notificationSettings.dailyBudget) || 0,
+        monthly_budget: parseInt(notificationSettings.monthlyBudget) || 0,
       });
 
       if (res.data.success) {
@@ -79,6 +79,8 @@ const NotificationSettings = ({
     personal: {
       enabled: true,
       topup: true,
+      organization: true,
+      statement: true,
       personal: true,
     },
     admin: {
@@ -163,7 +165,13 @@ const NotificationSettings = ({
         midjourney: true,
         task: true,
       },
-      personal: { enabled: true, topup: true, personal: true },
+      personal: {
+        enabled: true,
+        topup: true,
+        organization: true,
+        statement: true,
+        personal: true,
+      },
       admin: {
         enabled: true,
         channel: true,
@@ -276,6 +284,16 @@ const NotificationSettings = ({
       description: t('用户个人功能'),
       modules: [
         { key: 'topup', title: t('钱包管理'), description: t('余额充值管理') },
+        {
+          key: 'organization',
+          title: t('组织管理'),
+          description: t('组织成员与共享额度管理'),
+        },
+        {
+          key: 'statement',
+          title: t('账单'),
+          description: t('月度账单查询与下载'),
+        },
         {
           key: 'personal',
           title: t('个人设置'),
@@ -463,6 +481,40 @@ const NotificationSettings = ({
                   ]}
                 />
 
+                <Form.InputNumber
+                  field='dailyBudget'
+                  label={
+                    <span>
+                      {t('每日消费预算')}{' '}
+                      {notificationSettings.dailyBudget > 0 &&
+                        renderQuotaWithPrompt(notificationSettings.dailyBudget)}
+                    </span>
+                  }
+                  min={0}
+                  onChange={(val) => handleFormChange('dailyBudget', val)}
+                  extraText={t('0 表示不限制')}
+                  style={{ width: '100%', maxWidth: '300px' }}
+                />
+
+                <Form.InputNumber
+                  field='monthlyBudget'
+                  label={
+                    <span>
+                      {t('每月消费预算')}{' '}
+                      {notificationSettings.monthlyBudget > 0 &&
+                        renderQuotaWithPrompt(
+                          notificationSettings.monthlyBudget,
+                        )}
+                    </span>
+                  }
+                  min={0}
+                  onChange={(val) => handleFormChange('monthlyBudget', val)}
+                  extraText={t(
+                    '账户消费达到预算后暂停调用，下一个自然日或自然月自动恢复，消费达到 50%、80%、100% 时发送通知',
+                  )}
+                  style={{ width: '100%', maxWidth: '300px' }}
+                />
+
                 {/* 邮件通知设置 */}
                 {notificationSettings.warningType === 'email' && (
                   <Form.Input
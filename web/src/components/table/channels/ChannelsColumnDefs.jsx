@@ -246,6 +246,50 @@ const renderResponseTime = (responseTime, t) => {
   }
 };
 
+const renderLiveScore = (liveScore, t) => {
+  if (!liveScore) {
+    return null;
+  }
+  const score = Math.round(liveScore.score * 100);
+  const color = score >= 80 ? 'green' : score >= 50 ? 'yellow' : 'red';
+  return (
+    <Tooltip
+      content={
+        t('实时成功率：') +
+        (liveScore.success_rate * 100).toFixed(1) +
+        '%' +
+        t('，平均首字时间：') +
+        (liveScore.avg_latency_ms / 1000).toFixed(2) +
+        t(' 秒') +
+        t('，样本数：') +
+        liveScore.samples
+      }
+    >
+      <Tag color={color} type='ghost' shape='circle'>
+        {t('得分') + ' ' + score}
+      </Tag>
+    </Tooltip>
+  );
+};
+
+const renderCircuitState = (circuitState, t) => {
+  if (circuitState === 'open') {
+    return (
+      <Tag color='red' shape='circle'>
+        {t('已熔断')}
+      </Tag>
+    );
+  }
+  if (circuitState === 'half_open') {
+    return (
+      <Tag color='orange' shape='circle'>
+        {t('熔断探测中')}
+      </Tag>
+    );
+  }
+  return null;
+};
+
 const isRequestPassThroughEnabled = (record) => {
   if (!record || record.children !== undefined) {
     return false;
@@ -424,7 +468,13 @@ export const getChannelsColumns = ({
       key: COLUMN_KEYS.RESPONSE_TIME,
       title: t('响应时间'),
       dataIndex: 'response_time',
-      render: (text, record, index) => <div>{renderResponseTime(text, t)}</div>,
+      render: (text, record, index) => (
+        <Space spacing={1}>
+          {renderResponseTime(text, t)}
+          {renderLiveScore(record.live_score, t)}
+          {renderCircuitState(record.circuit_state, t)}
+        </Space>
+      ),
     },
     {
       key: COLUMN_KEYS.BALANCE,
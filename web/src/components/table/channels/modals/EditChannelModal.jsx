@@ -46,6 +46,8 @@ import {
   Col,
   Highlight,
   Input,
+  Select,
+  TextArea,
 } from '@douyinfe/semi-ui';
 import {
   getChannelModels,
@@ -75,6 +77,66 @@ import {
 
 const { Text, Title } = Typography;
 
+const TRANSFORM_SCRIPT_EXAMPLE = `// 发往上游前改写请求体，返回 undefined 时使用修改后的 body
+function transformRequest(body, ctx) {
+  // 合并连续的同角色消息
+  const messages = [];
+  for (const message of body.messages || []) {
+    const last = messages[messages.length - 1];
+    if (last && last.role === message.role && typeof last.content === 'string' && typeof message.content === 'string') {
+      last.content += '\\n' + message.content;
+    } else {
+      messages.push(message);
+    }
+  }
+  body.messages = messages;
+}
+
+// 改写非流式响应体
+function transformResponse(body, ctx) {
+  return body;
+}
+
+// 改写流式响应的每个 data 分片，返回 null 丢弃该分片
+function transformChunk(chunk, ctx) {
+  return chunk;
+}
+`;
+
+const TRANSFORM_SCRIPT_TEST_PAYLOADS = {
+  request: {
+    model: 'gpt-4o',
+    messages: [
+      { role: 'user', content: 'Hello' },
+      { role: 'user', content: 'Are you there?' },
+    ],
+  },
+  response: {
+    id: 'chatcmpl-1',
+    object: 'chat.completion',
+    choices: [
+      {
+        index: 0,
+        message: { role: 'assistant', content: 'Hi!' },
+        finish_reason: 'stop',
+      },
+    ],
+  },
+  chunk: {
+    id: 'chatcmpl-1',
+    object: 'chat.completion.chunk',
+    choices: [{ index: 0, delta: { content: 'Hi' }, finish_reason: null }],
+  },
+};
+
+const formatScriptTestOutput = (output) => {
+  try {
+    return JSON.stringify(JSON.parse(output), null, 2);
+  } catch (e) {
+    return output;
+  }
+};
+
 const MODEL_MAPPING_EXAMPLE = {
   'gpt-3.5-turbo': 'gpt-3.5-turbo-0125',
 };
@@ -144,6 +206,7 @@ const EditChannelModal = (props) => {
     groups: ['default'],
     priority: 0,
     weight: 0,
+    max_in_flight: 0,
     tag: '',
     multi_key_mode: 'random',
     // 渠道额外设置的默认值
@@ -153,6 +216,8 @@ const EditChannelModal = (props) => {
     pass_through_body_enabled: false,
     system_prompt: '',
     system_prompt_override: false,
+    structured_output_emulation: false,
+    structured_output_max_retries: 0,
     settings: '',
     // 仅 Vertex: 密钥格式（存入 settings.vertex_key_type）
     vertex_key_type: 'json',
@@ -192,6 +257,14 @@ const EditChannelModal = (props) => {
   const [keyMode, setKeyMode] = useState('append'); // 密钥模式：replace（覆盖）或 append（追加）
   const [isEnterpriseAccount, setIsEnterpriseAccount] = useState(false); // 是否为企业账户
   const [doubaoApiEditUnlocked, setDoubaoApiEditUnlocked] = useState(false); // 豆包渠道自定义 API 地址隐藏入口
+  // 转换脚本测试
+  const [scriptTestVisible, setScriptTestVisible] = useState(false);
+  const [scriptTestStage, setScriptTestStage] = useState('request');
+  const [scriptTestPayload, setScriptTestPayload] = useState(
+    JSON.stringify(TRANSFORM_SCRIPT_TEST_PAYLOADS.request, null, 2),
+  );
+  const [scriptTestResult, setScriptTestResult] = useState(null);
+  const [scriptTestLoading, setScriptTestLoading] = useState(false);
   const redirectModelList = useMemo(() => {
     const mapping = inputs.model_mapping;
     if (typeof mapping !== 'string') return [];
@@ -425,6 +498,46 @@ const EditChannelModal = (props) => {
 
   const isIonetLocked = isIonetChannel && isEdit;
 
+  const handleScriptTestStageChange = (stage) => {
+    setScriptTestStage(stage);
+    setScriptTestPayload(
+      JSON.stringify(TRANSFORM_SCRIPT_TEST_PAYLOADS[stage], null, 2),
+    );
+    setScriptTestResult(null);
+  };
+
+  const runScriptTest = async () => {
+    if (!verifyJSON(scriptTestPayload)) {
+      showError(t('示例内容不是合法的 JSON'));
+      return;
+    }
+    setScriptTestLoading(true);
+    try {
+      const res = await API.post('/api/channel/script/test', {
+        script: inputs.transform_script || '',
+        stage: scriptTestStage,
+        payload: JSON.parse(scriptTestPayload),
+        context: {
+          model: 'gpt-4o',
+          upstream_model: 'gpt-4o',
+          channel_id: isEdit ? parseInt(channelId) : 0,
+          channel_type: inputs.type,
+          stream: scriptTestStage === 'chunk',
+          status_code: 200,
+        },
+      });
+      const { success, message, data } = res.data;
+      if (success) {
+        setScriptTestResult(data);
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      showError(error.message);
+    }
+    setScriptTestLoading(false);
+  };
+
   const handleInputChange = (name, value) => {
     if (isIonetChannel && isEdit && ['type', 'key', 'base_url'].includes(name)) {
       return;
@@ -560,6 +673,10 @@ const EditChannelModal = (props) => {
           data.system_prompt = parsedSettings.system_prompt || '';
           data.system_prompt_override =
             parsedSettings.system_prompt_override || false;
+          data.structured_output_emulation =
+            parsedSettings.structured_output_emulation || false;
+          data.structured_output_max_retries =
+            parsedSettings.structured_output_max_retries || 0;
         } catch (error) {
           console.error('解析渠道设置失败:', error);
           data.force_format = false;
@@ -568,6 +685,8 @@ const EditChannelModal = (props) => {
           data.pass_through_body_enabled = false;
           data.system_prompt = '';
           data.system_prompt_override = false;
+          data.structured_output_emulation = false;
+          data.structured_output_max_retries = 0;
         }
       } else {
         data.force_format = false;
@@ -576,6 +695,8 @@ const EditChannelModal = (props) => {
         data.pass_through_body_enabled = false;
         data.system_prompt = '';
         data.system_prompt_override = false;
+        data.structured_output_emulation = false;
+        data.structured_output_max_retries = 0;
       }
 
       if (data.settings) {
@@ -644,6 +765,8 @@ const EditChannelModal = (props) => {
         pass_through_body_enabled: data.pass_through_body_enabled,
         system_prompt: data.system_prompt,
         system_prompt_override: data.system_prompt_override || false,
+        structured_output_emulation: data.structured_output_emulation || false,
+        structured_output_max_retries: data.structured_output_max_retries || 0,
       });
       initialModelsRef.current = (data.models || [])
         .map((model) => (model || '').trim())
@@ -924,6 +1047,8 @@ const EditChannelModal = (props) => {
       pass_through_body_enabled: false,
       system_prompt: '',
       system_prompt_override: false,
+      structured_output_emulation: false,
+      structured_output_max_retries: 0,
     });
     // 重置密钥模式状态
     setKeyMode('append');
@@ -1210,6 +1335,10 @@ const EditChannelModal = (props) => {
       pass_through_body_enabled: localInputs.pass_through_body_enabled || false,
       system_prompt: localInputs.system_prompt || '',
       system_prompt_override: localInputs.system_prompt_override || false,
+      structured_output_emulation:
+        localInputs.structured_output_emulation || false,
+      structured_output_max_retries:
+        parseInt(localInputs.structured_output_max_retries) || 0,
     };
     localInputs.setting = JSON.stringify(channelExtraSettings);
 
@@ -1261,6 +1390,8 @@ const EditChannelModal = (props) => {
     delete localInputs.pass_through_body_enabled;
     delete localInputs.system_prompt;
     delete localInputs.system_prompt_override;
+    delete localInputs.structured_output_emulation;
+    delete localInputs.structured_output_max_retries;
     delete localInputs.is_enterprise_account;
     // 顶层的 vertex_key_type 不应发送给后端
     delete localInputs.vertex_key_type;
@@ -2738,6 +2869,20 @@ const EditChannelModal = (props) => {
                       </Col>
                     </Row>
 
+                    <Form.InputNumber
+                      field='max_in_flight'
+                      label={t('最大并发请求数')}
+                      placeholder={t('最大并发请求数')}
+                      min={0}
+                      onNumberChange={(value) =>
+                        handleInputChange('max_in_flight', value)
+                      }
+                      extraText={t(
+                        '网关对该渠道同时转发的最大请求数（按节点计算），达到上限后会跳过该渠道，0 表示不限制',
+                      )}
+                      style={{ width: '100%' }}
+                    />
+
                     <Form.Switch
                       field='auto_ban'
                       label={t('是否自动禁用')}
@@ -2868,6 +3013,52 @@ const EditChannelModal = (props) => {
                       showClear
                     />
 
+                    <Form.TextArea
+                      field='transform_script'
+                      label={t('转换脚本')}
+                      placeholder={t(
+                        '此项可选，使用 JavaScript 定义 transformRequest、transformResponse、transformChunk 函数，分别改写发往上游的请求体、非流式响应体与流式响应分片',
+                      )}
+                      autosize={{ minRows: 3, maxRows: 20 }}
+                      onChange={(value) =>
+                        handleInputChange('transform_script', value)
+                      }
+                      extraText={
+                        <div className='flex flex-col gap-1'>
+                          <div className='flex gap-2 flex-wrap items-center'>
+                            <Text
+                              className='!text-semi-color-primary cursor-pointer'
+                              onClick={() =>
+                                handleInputChange(
+                                  'transform_script',
+                                  TRANSFORM_SCRIPT_EXAMPLE,
+                                )
+                              }
+                            >
+                              {t('填入模板')}
+                            </Text>
+                            <Text
+                              className='!text-semi-color-primary cursor-pointer'
+                              onClick={() => setScriptTestVisible(true)}
+                            >
+                              {t('测试脚本')}
+                            </Text>
+                          </div>
+                          <Text type='tertiary' size='small'>
+                            {t(
+                              '脚本在沙箱中运行，单次调用超时或出错时沿用原始内容并记录到日志，不会禁用渠道',
+                            )}
+                          </Text>
+                          <Text type='tertiary' size='small'>
+                            {t(
+                              'WebSocket（Realtime）与表单请求，以及通过 SDK 访问上游的渠道（如 AK/SK 模式的 AWS）不执行转换脚本',
+                            )}
+                          </Text>
+                        </div>
+                      }
+                      showClear
+                    />
+
                     <JSONEditor
                       key={`status_code_mapping-${isEdit ? channelId : 'new'}`}
                       field='status_code_mapping'
@@ -3085,6 +3276,39 @@ const EditChannelModal = (props) => {
                         '如果用户请求中包含系统提示词，则使用此设置拼接到用户的系统提示词前面',
                       )}
                     />
+
+                    <Form.Switch
+                      field='structured_output_emulation'
+                      label={t('结构化输出模拟')}
+                      checkedText={t('开')}
+                      uncheckedText={t('关')}
+                      onChange={(value) =>
+                        handleChannelSettingsChange(
+                          'structured_output_emulation',
+                          value,
+                        )
+                      }
+                      extraText={t(
+                        '上游不支持 json_schema 时，由网关注入 schema 说明并校验输出，校验失败时自动修复或重新请求，每次请求均计费',
+                      )}
+                    />
+                    {inputs.structured_output_emulation && (
+                      <Form.InputNumber
+                        field='structured_output_max_retries'
+                        label={t('结构化输出最大重试次数')}
+                        min={0}
+                        max={5}
+                        onChange={(value) =>
+                          handleChannelSettingsChange(
+                            'structured_output_max_retries',
+                            value,
+                          )
+                        }
+                        extraText={t(
+                          '校验失败后重新请求上游的最大次数，0 表示使用默认值 2',
+                        )}
+                      />
+                    )}
                   </Card>
                 </div>
               </div>
@@ -3151,6 +3375,62 @@ const EditChannelModal = (props) => {
         />
       </Modal>
 
+      {/* 转换脚本测试 */}
+      <Modal
+        title={t('测试转换脚本')}
+        visible={scriptTestVisible}
+        onCancel={() => setScriptTestVisible(false)}
+        footer={
+          <Button
+            type='primary'
+            loading={scriptTestLoading}
+            onClick={runScriptTest}
+          >
+            {t('运行')}
+          </Button>
+        }
+        width={700}
+        style={{ maxWidth: '90vw' }}
+      >
+        <div className='flex flex-col gap-3'>
+          <Select
+            value={scriptTestStage}
+            onChange={handleScriptTestStageChange}
+            optionList={[
+              { label: t('请求体（transformRequest）'), value: 'request' },
+              { label: t('响应体（transformResponse）'), value: 'response' },
+              { label: t('流式分片（transformChunk）'), value: 'chunk' },
+            ]}
+          />
+          <TextArea
+            value={scriptTestPayload}
+            onChange={setScriptTestPayload}
+            autosize={{ minRows: 6, maxRows: 16 }}
+          />
+          {scriptTestResult && (
+            <div className='flex flex-col gap-1'>
+              <Text type='tertiary' size='small'>
+                {t('耗时 {{ms}} 毫秒', { ms: scriptTestResult.duration_ms })}
+              </Text>
+              {scriptTestResult.skipped && (
+                <Text type='warning'>
+                  {t('脚本未定义该阶段的函数，内容不会被改写')}
+                </Text>
+              )}
+              {scriptTestResult.error ? (
+                <Text type='danger'>{scriptTestResult.error}</Text>
+              ) : scriptTestResult.dropped ? (
+                <Text type='warning'>{t('脚本丢弃了该内容')}</Text>
+              ) : (
+                <pre className='text-xs whitespace-pre-wrap break-all m-0'>
+                  {formatScriptTestOutput(scriptTestResult.output)}
+                </pre>
+              )}
+            </div>
+          )}
+        </div>
+      </Modal>
+
       <ModelSelectModal
         visible={modelModalVisible}
         models={fetchedModels}
@@ -26,6 +26,7 @@ import ModelHeader from './components/ModelHeader';
 import ModelBasicInfo from './components/ModelBasicInfo';
 import ModelEndpoints from './components/ModelEndpoints';
 import ModelPricingTable from './components/ModelPricingTable';
+import ModelPricingRules from './components/ModelPricingRules';
 
 const { Text } = Typography;
 
@@ -42,6 +43,7 @@ const ModelDetailSideSheet = ({
   vendorsMap,
   endpointMap,
   autoGroups,
+  pricingRules,
   t,
 }) => {
   const isMobile = useIsMobile();
@@ -99,6 +101,11 @@ const ModelDetailSideSheet = ({
               autoGroups={autoGroups}
               t={t}
             />
+            <ModelPricingRules
+              modelData={modelData}
+              pricingRules={pricingRules}
+              t={t}
+            />
           </>
         )}
       </div>
@@ -0,0 +1,102 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React from 'react';
+import { Card, Avatar, Typography, Tag } from '@douyinfe/semi-ui';
+import { IconClock } from '@douyinfe/semi-icons';
+
+const { Text } = Typography;
+
+// 与后端一致：支持以 * 结尾的前缀匹配，未配置模型时适用于所有模型
+const matchModel = (rule, modelName) => {
+  if (!rule.models || rule.models.length === 0) return true;
+  return rule.models.some((pattern) =>
+    pattern.endsWith('*')
+      ? modelName.startsWith(pattern.slice(0, -1))
+      : pattern === modelName,
+  );
+};
+
+const ModelPricingRules = ({ modelData, pricingRules = [], t }) => {
+  const modelName = modelData?.model_name || '';
+  const rules = pricingRules.filter((rule) => matchModel(rule, modelName));
+  if (rules.length === 0) return null;
+
+  const renderCondition = (rule) => {
+    const conditions = [];
+    if (rule.min_prompt_tokens || rule.max_prompt_tokens) {
+      conditions.push(
+        t('提示词 {{min}} - {{max}} tokens', {
+          min: rule.min_prompt_tokens || 0,
+          max: rule.max_prompt_tokens || '∞',
+        }),
+      );
+    }
+    if (rule.start_time) {
+      conditions.push(
+        `${rule.start_time} - ${rule.end_time}` +
+          (rule.timezone ? ` (${rule.timezone})` : ''),
+      );
+    }
+    if (rule.groups && rule.groups.length > 0) {
+      conditions.push(t('分组') + '：' + rule.groups.join(', '));
+    }
+    return conditions.length > 0 ? conditions.join('；') : t('始终生效');
+  };
+
+  return (
+    <Card className='!rounded-2xl shadow-sm border-0 mb-6'>
+      <div className='flex items-center mb-4'>
+        <Avatar size='small' color='orange' className='mr-2 shadow-md'>
+          <IconClock size={16} />
+        </Avatar>
+        <div>
+          <Text className='text-lg font-medium'>{t('计价规则')}</Text>
+          <div className='text-xs text-gray-600'>
+            {t('满足条件时按倍数调整价格，按顺序匹配第一条规则')}
+          </div>
+        </div>
+      </div>
+      {rules.map((rule) => (
+        <div
+          key={rule.name}
+          className='flex justify-between border-b border-dashed last:border-0 py-2 last:pb-0'
+          style={{ borderColor: 'var(--semi-color-border)' }}
+        >
+          <span className='pr-5'>
+            <div className='font-medium'>{rule.name}</div>
+            <div className='text-gray-500 text-xs'>
+              {renderCondition(rule)}
+            </div>
+          </span>
+          <span className='flex items-center gap-1'>
+            <Tag color='blue' size='small'>
+              {t('输入')} x{rule.input_multiplier}
+            </Tag>
+            <Tag color='green' size='small'>
+              {t('输出')} x{rule.output_multiplier}
+            </Tag>
+          </span>
+        </div>
+      ))}
+    </Card>
+  );
+};
+
+export default ModelPricingRules;
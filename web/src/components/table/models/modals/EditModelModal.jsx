@@ -123,6 +123,7 @@ const EditModelModal = (props) => {
     name_rule: props.editingModel?.model_name ? 0 : undefined, // 通过未配置模型过来的固定为精确匹配
     status: true,
     sync_official: true,
+    tool_call_emulation: false,
   });
 
   const handleCancel = () => {
@@ -534,6 +535,16 @@ const EditModelModal = (props) => {
                       size='large'
                     />
                   </Col>
+                  <Col span={24}>
+                    <Form.Switch
+                      field='tool_call_emulation'
+                      label={t('函数调用模拟')}
+                      extraText={t(
+                        '模型不支持原生函数调用时开启，网关将工具定义写入提示词，并从模型输出中解析工具调用',
+                      )}
+                      size='large'
+                    />
+                  </Col>
                   <Col span={24}>
                     <Form.Switch
                       field='status'
@@ -27,6 +27,7 @@ import {
   renderQuotaWithPrompt,
   getModelCategories,
   selectFilter,
+  verifyJSON,
 } from '../../../../helpers';
 import { useIsMobile } from '../../../../hooks/common/useIsMobile';
 import {
@@ -62,6 +63,7 @@ const EditTokenModal = (props) => {
   const formApiRef = useRef(null);
   const [models, setModels] = useState([]);
   const [groups, setGroups] = useState([]);
+  const [organizations, setOrganizations] = useState([]);
   const isEdit = props.editingToken.id !== undefined;
 
   const getInitValues = () => ({
@@ -74,6 +76,14 @@ const EditTokenModal = (props) => {
     allow_ips: '',
     group: '',
     cross_group_retry: false,
+    response_cache: false,
+    hedge_enabled: false,
+    tpm_limit: 0,
+    rpm_limit: 0,
+    daily_budget: 0,
+    monthly_budget: 0,
+    model_fallback: '',
+    organization_id: 0,
     tokenCount: 1,
   });
 
@@ -149,6 +159,19 @@ const EditTokenModal = (props) => {
     }
   };
 
+  // 加载可创建组织令牌的组织，财务角色不能使用组织额度
+  const loadOrganizations = async () => {
+    let res = await API.get(`/api/organization/self`);
+    const { success, data } = res.data;
+    if (success) {
+      setOrganizations(
+        (data || [])
+          .filter((org) => org.role !== 'billing' && org.status === 1)
+          .map((org) => ({ label: org.name, value: org.id })),
+      );
+    }
+  };
+
   const loadToken = async () => {
     setLoading(true);
     let res = await API.get(`/api/token/${props.editingToken.id}`);
@@ -179,6 +202,7 @@ const EditTokenModal = (props) => {
     }
     loadModels();
     loadGroups();
+    loadOrganizations();
   }, [props.editingToken.id]);
 
   useEffect(() => {
@@ -206,6 +230,10 @@ const EditTokenModal = (props) => {
   };
 
   const submit = async (values) => {
+    if (values.model_fallback && !verifyJSON(values.model_fallback)) {
+      showError(t('模型降级链不是合法的 JSON 字符串！'));
+      return;
+    }
     setLoading(true);
     if (isEdit) {
       let { tokenCount: _tc, ...localInputs } = values;
@@ -378,6 +406,20 @@ const EditTokenModal = (props) => {
                       />
                     )}
                   </Col>
+                  {!isEdit && organizations.length > 0 && (
+                    <Col span={24}>
+                      <Form.Select
+                        field='organization_id'
+                        label={t('所属组织')}
+                        optionList={[
+                          { label: t('个人令牌'), value: 0 },
+                          ...organizations,
+                        ]}
+                        extraText={t('组织令牌消费组织的共享额度')}
+                        style={{ width: '100%' }}
+                      />
+                    </Col>
+                  )}
                   <Col span={24} style={{ display: values.group === 'auto' ? 'block' : 'none' }}>
                     <Form.Switch
                       field='cross_group_retry'
@@ -388,6 +430,26 @@ const EditTokenModal = (props) => {
                       )}
                     />
                   </Col>
+                  <Col span={24}>
+                    <Form.Switch
+                      field='response_cache'
+                      label={t('响应缓存')}
+                      size='default'
+                      extraText={t(
+                        '开启后，相同的 Embedding 请求与 temperature 为 0 的对话请求将直接返回缓存结果',
+                      )}
+                    />
+                  </Col>
+                  <Col span={24}>
+                    <Form.Switch
+                      field='hedge_enabled'
+                      label={t('对冲请求')}
+                      size='default'
+                      extraText={t(
+                        '开启后，已配置对冲的模型在首字超时时会同时请求另一个渠道，使用先返回的结果',
+                      )}
+                    />
+                  </Col>
                   <Col xs={24} sm={24} md={24} lg={10} xl={10}>
                     <Form.DatePicker
                       field='expired_time'
@@ -516,6 +578,57 @@ const EditTokenModal = (props) => {
                       )}
                     />
                   </Col>
+                  <Col span={12}>
+                    <Form.InputNumber
+                      field='tpm_limit'
+                      label={t('每分钟 Token 数限制 (TPM)')}
+                      min={0}
+                      extraText={t('0 表示不限制')}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={12}>
+                    <Form.InputNumber
+                      field='rpm_limit'
+                      label={t('每分钟请求数限制 (RPM)')}
+                      min={0}
+                      extraText={t('0 表示不限制')}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={12}>
+                    <Form.InputNumber
+                      field='daily_budget'
+                      label={t('每日消费预算')}
+                      min={0}
+                      extraText={
+                        values.daily_budget > 0
+                          ? renderQuotaWithPrompt(values.daily_budget)
+                          : t('0 表示不限制')
+                      }
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={12}>
+                    <Form.InputNumber
+                      field='monthly_budget'
+                      label={t('每月消费预算')}
+                      min={0}
+                      extraText={
+                        values.monthly_budget > 0
+                          ? renderQuotaWithPrompt(values.monthly_budget)
+                          : t('0 表示不限制')
+                      }
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={24}>
+                    <Text type='tertiary' size='small'>
+                      {t(
+                        '达到预算后令牌暂停使用，下一个自然日或自然月自动恢复，消费达到 50%、80%、100% 时发送通知',
+                      )}
+                    </Text>
+                  </Col>
                 </Row>
               </Card>
 
@@ -554,6 +667,30 @@ const EditTokenModal = (props) => {
                       style={{ width: '100%' }}
                     />
                   </Col>
+                  <Col span={24}>
+                    <Form.TextArea
+                      field='model_fallback'
+                      label={t('模型降级链')}
+                      placeholder={
+                        t(
+                          '此项可选，请求的模型不可用时依次改用链中的模型，例如：',
+                        ) +
+                        '\n' +
+                        JSON.stringify(
+                          { 'gpt-4o': ['claude-sonnet-4', 'gemini-2.5-pro'] },
+                          null,
+                          2,
+                        )
+                      }
+                      autosize
+                      rows={1}
+                      extraText={t(
+                        '覆盖系统设置中同名模型的降级链，未开启全局模型降级时也会生效；降级后按实际使用的模型计费',
+                      )}
+                      showClear
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
                   <Col span={24}>
                     <Form.TextArea
                       field='allow_ips'
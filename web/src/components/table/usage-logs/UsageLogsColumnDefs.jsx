@@ -94,6 +94,24 @@ function renderType(type, t) {
           {t('错误')}
         </Tag>
       );
+    case 6:
+      return (
+        <Tag color='lime' shape='circle'>
+          {t('退款')}
+        </Tag>
+      );
+    case 7:
+      return (
+        <Tag color='teal' shape='circle'>
+          {t('缓存命中')}
+        </Tag>
+      );
+    case 8:
+      return (
+        <Tag color='pink' shape='circle'>
+          {t('内容审核')}
+        </Tag>
+      );
     default:
       return (
         <Tag color='grey' shape='circle'>
@@ -265,7 +283,7 @@ export const getLogsColumns = ({
         }
 
         return isAdminUser &&
-          (record.type === 0 || record.type === 2 || record.type === 5) ? (
+          (record.type === 0 || record.type === 2 || record.type === 5 || record.type === 7 || record.type === 8) ? (
           <Space>
             <Tooltip content={record.channel_name || t('未知渠道')}>
               <span>
@@ -316,7 +334,7 @@ export const getLogsColumns = ({
       title: t('令牌'),
       dataIndex: 'token_name',
       render: (text, record, index) => {
-        return record.type === 0 || record.type === 2 || record.type === 5 ? (
+        return record.type === 0 || record.type === 2 || record.type === 5 || record.type === 7 || record.type === 8 ? (
           <div>
             <Tag
               color='grey'
@@ -339,7 +357,7 @@ export const getLogsColumns = ({
       title: t('分组'),
       dataIndex: 'group',
       render: (text, record, index) => {
-        if (record.type === 0 || record.type === 2 || record.type === 5) {
+        if (record.type === 0 || record.type === 2 || record.type === 5 || record.type === 7 || record.type === 8) {
           if (record.group) {
             return <>{renderGroup(record.group)}</>;
           } else {
@@ -379,7 +397,7 @@ export const getLogsColumns = ({
       title: t('模型'),
       dataIndex: 'model_name',
       render: (text, record, index) => {
-        return record.type === 0 || record.type === 2 || record.type === 5 ? (
+        return record.type === 0 || record.type === 2 || record.type === 5 || record.type === 7 || record.type === 8 ? (
           <>{renderModelName(record, copyText, t)}</>
         ) : (
           <></>
@@ -391,7 +409,7 @@ export const getLogsColumns = ({
       title: t('用时/首字'),
       dataIndex: 'use_time',
       render: (text, record, index) => {
-        if (!(record.type === 2 || record.type === 5)) {
+        if (!(record.type === 2 || record.type === 5 || record.type === 7)) {
           return <></>;
         }
         if (record.is_stream) {
@@ -422,7 +440,7 @@ export const getLogsColumns = ({
       title: t('输入'),
       dataIndex: 'prompt_tokens',
       render: (text, record, index) => {
-        return record.type === 0 || record.type === 2 || record.type === 5 ? (
+        return record.type === 0 || record.type === 2 || record.type === 5 || record.type === 7 ? (
           <>{<span> {text} </span>}</>
         ) : (
           <></>
@@ -435,7 +453,7 @@ export const getLogsColumns = ({
       dataIndex: 'completion_tokens',
       render: (text, record, index) => {
         return parseInt(text) > 0 &&
-          (record.type === 0 || record.type === 2 || record.type === 5) ? (
+          (record.type === 0 || record.type === 2 || record.type === 5 || record.type === 7) ? (
           <>{<span> {text} </span>}</>
         ) : (
           <></>
@@ -447,7 +465,7 @@ export const getLogsColumns = ({
       title: t('花费'),
       dataIndex: 'quota',
       render: (text, record, index) => {
-        return record.type === 0 || record.type === 2 || record.type === 5 ? (
+        return record.type === 0 || record.type === 2 || record.type === 5 || record.type === 7 ? (
           <>{renderQuota(text, 6)}</>
         ) : (
           <></>
@@ -470,7 +488,7 @@ export const getLogsColumns = ({
       ),
       dataIndex: 'ip',
       render: (text, record, index) => {
-        return (record.type === 2 || record.type === 5) && text ? (
+        return (record.type === 2 || record.type === 5 || record.type === 7 || record.type === 8) && text ? (
           <Tooltip content={text}>
             <span>
               <Tag
@@ -494,7 +512,7 @@ export const getLogsColumns = ({
       title: t('重试'),
       dataIndex: 'retry',
       render: (text, record, index) => {
-        if (!(record.type === 2 || record.type === 5)) {
+        if (!(record.type === 2 || record.type === 5 || record.type === 7)) {
           return <></>;
         }
         let content = t('渠道') + `：${record.channel}`;
@@ -525,7 +543,7 @@ export const getLogsColumns = ({
       fixed: 'right',
       render: (text, record, index) => {
         let other = getLogOther(record.other);
-        if (other == null || record.type !== 2) {
+        if (other == null || (record.type !== 2 && record.type !== 7)) {
           return (
             <Typography.Paragraph
               ellipsis={{
@@ -139,6 +139,9 @@ const LogsFilters = ({
               <Form.Select.Option value='3'>{t('管理')}</Form.Select.Option>
               <Form.Select.Option value='4'>{t('系统')}</Form.Select.Option>
               <Form.Select.Option value='5'>{t('错误')}</Form.Select.Option>
+              <Form.Select.Option value='6'>{t('退款')}</Form.Select.Option>
+              <Form.Select.Option value='7'>{t('缓存命中')}</Form.Select.Option>
+              <Form.Select.Option value='8'>{t('内容审核')}</Form.Select.Option>
             </Form.Select>
           </div>
 
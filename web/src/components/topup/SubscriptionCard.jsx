@@ -0,0 +1,219 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState } from 'react';
+import {
+  Avatar,
+  Button,
+  Card,
+  Descriptions,
+  Space,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import { CalendarClock } from 'lucide-react';
+import { API, showError, timestamp2string } from '../../helpers';
+
+const { Text } = Typography;
+
+// 提交易支付表单，与充值页的在线支付保持一致
+const submitEpayForm = (url, params) => {
+  const form = document.createElement('form');
+  form.action = url;
+  form.method = 'POST';
+  const isSafari =
+    navigator.userAgent.indexOf('Safari') > -1 &&
+    navigator.userAgent.indexOf('Chrome') < 1;
+  if (!isSafari) {
+    form.target = '_blank';
+  }
+  for (const key in params) {
+    const input = document.createElement('input');
+    input.type = 'hidden';
+    input.name = key;
+    input.value = params[key];
+    form.appendChild(input);
+  }
+  document.body.appendChild(form);
+  form.submit();
+  document.body.removeChild(form);
+};
+
+const SubscriptionCard = ({ t, payMethods, renderQuota }) => {
+  const [info, setInfo] = useState(null);
+  const [paying, setPaying] = useState('');
+
+  const loadInfo = async () => {
+    const res = await API.get('/api/subscription/plans');
+    const { success, message, data } = res.data;
+    if (success) {
+      setInfo(data);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadInfo();
+  }, []);
+
+  if (!info?.enabled || !info.plans?.length) {
+    return null;
+  }
+
+  const subscribe = async (plan, paymentMethod) => {
+    setPaying(`${plan.id}-${paymentMethod}`);
+    try {
+      const res = await API.post('/api/subscription/pay', {
+        plan_id: plan.id,
+        payment_method: paymentMethod,
+      });
+      const { message, data } = res.data;
+      if (message !== 'success') {
+        showError(data);
+        return;
+      }
+      if (paymentMethod === 'stripe') {
+        window.open(data.pay_link, '_blank');
+      } else if (paymentMethod === 'creem') {
+        window.open(data.checkout_url, '_blank');
+      } else {
+        submitEpayForm(res.data.url, data);
+      }
+    } catch (err) {
+      showError(t('支付请求失败'));
+    } finally {
+      setPaying('');
+    }
+  };
+
+  const paymentOptions = (plan) => {
+    const options = [];
+    if (info.enable_epay) {
+      (payMethods || [])
+        .filter((method) => method.type !== 'stripe')
+        .forEach((method) =>
+          options.push({ type: method.type, name: method.name }),
+        );
+    }
+    if (info.enable_stripe && plan.stripe_price_id) {
+      options.push({ type: 'stripe', name: 'Stripe' });
+    }
+    if (info.enable_creem && plan.creem_product_id) {
+      options.push({ type: 'creem', name: 'Creem' });
+    }
+    return options;
+  };
+
+  const current = info.subscription;
+
+  return (
+    <Card className='!rounded-2xl shadow-sm border-0'>
+      <div className='flex items-center mb-4'>
+        <Avatar size='small' color='purple' className='mr-3 shadow-md'>
+          <CalendarClock size={16} />
+        </Avatar>
+        <div>
+          <Typography.Text className='text-lg font-medium'>
+            {t('订阅套餐')}
+          </Typography.Text>
+          <div className='text-xs'>
+            {t('套餐额度每个周期发放，消费时优先使用套餐额度')}
+          </div>
+        </div>
+      </div>
+
+      {current && (
+        <Card className='!rounded-xl w-full mb-4'>
+          <Descriptions
+            row
+            size='small'
+            data={[
+              { key: t('当前套餐'), value: current.plan_name },
+              { key: t('本周期剩余额度'), value: renderQuota(current.quota) },
+              {
+                key: t('本周期已用额度'),
+                value: renderQuota(current.used_quota),
+              },
+              {
+                key: t('下次发放时间'),
+                value: timestamp2string(current.period_end),
+              },
+              { key: t('到期时间'), value: timestamp2string(current.end_time) },
+            ]}
+          />
+        </Card>
+      )}
+
+      <Space vertical style={{ width: '100%' }} spacing='medium'>
+        {info.plans.map((plan) => {
+          const options = paymentOptions(plan);
+          const disabled = current && current.plan_id !== plan.id;
+          return (
+            <Card key={plan.id} className='!rounded-xl w-full'>
+              <div className='flex justify-between items-start flex-wrap gap-2'>
+                <div>
+                  <Space>
+                    <Text strong>{plan.name}</Text>
+                    {current?.plan_id === plan.id && (
+                      <Tag color='green'>{t('已订阅')}</Tag>
+                    )}
+                  </Space>
+                  {plan.description && (
+                    <div className='text-xs mt-1'>{plan.description}</div>
+                  )}
+                  <div className='text-xs mt-1'>
+                    {t('每 {{days}} 天发放 {{quota}}，共 {{periods}} 个周期', {
+                      days: plan.period_days,
+                      quota: renderQuota(plan.quota),
+                      periods: plan.periods,
+                    })}
+                  </div>
+                </div>
+                <Text strong className='text-lg'>
+                  {plan.price}
+                </Text>
+              </div>
+              <Space wrap className='mt-3'>
+                {options.length === 0 && (
+                  <Text type='tertiary'>{t('暂无可用的支付方式')}</Text>
+                )}
+                {options.map((option) => (
+                  <Button
+                    key={option.type}
+                    theme='outline'
+                    disabled={disabled}
+                    loading={paying === `${plan.id}-${option.type}`}
+                    onClick={() => subscribe(plan, option.type)}
+                  >
+                    {current?.plan_id === plan.id ? t('续订') : t('订阅')}
+                    {' · '}
+                    {option.name}
+                  </Button>
+                ))}
+              </Space>
+            </Card>
+          );
+        })}
+      </Space>
+    </Card>
+  );
+};
+
+export default SubscriptionCard;
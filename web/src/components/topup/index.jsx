@@ -35,6 +35,7 @@ import { StatusContext } from '../../context/Status';
 
 import RechargeCard from './RechargeCard';
 import InvitationCard from './InvitationCard';
+import SubscriptionCard from './SubscriptionCard';
 import TransferModal from './modals/TransferModal';
 import PaymentConfirmModal from './modals/PaymentConfirmModal';
 import TopupHistoryModal from './modals/TopupHistoryModal';
@@ -703,6 +704,11 @@ const TopUp = () => {
               topupInfo={topupInfo}
               onOpenHistory={handleOpenHistory}
             />
+            <SubscriptionCard
+              t={t}
+              payMethods={payMethods}
+              renderQuota={renderQuota}
+            />
           </div>
 
           {/* 右侧信息区域 */}
@@ -74,6 +74,8 @@ import {
   CircleUser,
   Package,
   Server,
+  Users,
+  ReceiptText,
 } from 'lucide-react';
 
 // 获取侧边栏Lucide图标组件
@@ -106,6 +108,10 @@ export function getLucideIcon(key, selected = false) {
       return <CheckSquare {...commonProps} color={iconColor} />;
     case 'topup':
       return <CreditCard {...commonProps} color={iconColor} />;
+    case 'organization':
+      return <Users {...commonProps} color={iconColor} />;
+    case 'statement':
+      return <ReceiptText {...commonProps} color={iconColor} />;
     case 'channel':
       return <Layers {...commonProps} color={iconColor} />;
     case 'redemption':
@@ -51,6 +51,7 @@ export const useModelPricingData = () => {
   const [usableGroup, setUsableGroup] = useState({});
   const [endpointMap, setEndpointMap] = useState({});
   const [autoGroups, setAutoGroups] = useState([]);
+  const [pricingRules, setPricingRules] = useState([]);
 
   const [statusState] = useContext(StatusContext);
   const [userState] = useContext(UserContext);
@@ -231,6 +232,7 @@ export const useModelPricingData = () => {
       usable_group,
       supported_endpoint,
       auto_groups,
+      pricing_rules,
     } = res.data;
     if (success) {
       setGroupRatio(group_ratio);
@@ -246,6 +248,7 @@ export const useModelPricingData = () => {
       setVendorsMap(vendorMap);
       setEndpointMap(supported_endpoint || {});
       setAutoGroups(auto_groups || []);
+      setPricingRules(pricing_rules || []);
       setModelsFormat(data, group_ratio, vendorMap);
     } else {
       showError(message);
@@ -365,6 +368,7 @@ export const useModelPricingData = () => {
     groupRatio,
     usableGroup,
     endpointMap,
+    pricingRules,
     autoGroups,
 
     // 计算属性
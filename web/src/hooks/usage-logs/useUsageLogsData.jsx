@@ -313,7 +313,7 @@ export const useLogsData = () => {
       let other = getLogOther(logs[i].other);
       let expandDataLocal = [];
 
-      if (isAdminUser && (logs[i].type === 0 || logs[i].type === 2)) {
+      if (isAdminUser && (logs[i].type === 0 || logs[i].type === 2 || logs[i].type === 7)) {
         expandDataLocal.push({
           key: t('渠道信息'),
           value: `${logs[i].channel} - ${logs[i].channel_name || '[未知]'}`,
@@ -349,7 +349,7 @@ export const useLogsData = () => {
           value: other.cache_creation_tokens,
         });
       }
-      if (logs[i].type === 2) {
+      if (logs[i].type === 2 || logs[i].type === 7) {
         expandDataLocal.push({
           key: t('日志详情'),
           value: other?.claude
@@ -388,7 +388,7 @@ export const useLogsData = () => {
           });
         }
       }
-      if (logs[i].type === 2) {
+      if (logs[i].type === 2 || logs[i].type === 7) {
         let modelMapped =
           other?.is_model_mapped &&
           other?.upstream_model_name &&
@@ -475,6 +475,46 @@ export const useLogsData = () => {
             value: other.reasoning_effort,
           });
         }
+        if (other?.structured_output_emulation) {
+          expandDataLocal.push({
+            key: t('结构化输出'),
+            value: other.structured_output_valid
+              ? t('请求上游 {{times}} 次，校验通过', {
+                  times: other.structured_output_attempts,
+                })
+              : t('请求上游 {{times}} 次，校验未通过', {
+                  times: other.structured_output_attempts,
+                }),
+          });
+        }
+      }
+      if (other?.virtual_model) {
+        expandDataLocal.push({
+          key: t('虚拟模型'),
+          value: `${other.virtual_model} → ${other.virtual_model_arm}`,
+        });
+      }
+      if (other?.pricing_rule) {
+        expandDataLocal.push({
+          key: t('计价规则'),
+          value: t('{{name}}（输入 x{{input}}，输出 x{{output}}）', {
+            name: other.pricing_rule.name,
+            input: other.pricing_rule.input_multiplier,
+            output: other.pricing_rule.output_multiplier,
+          }),
+        });
+      }
+      if (other?.subscription_quota) {
+        expandDataLocal.push({
+          key: t('套餐额度抵扣'),
+          value: renderQuota(other.subscription_quota),
+        });
+      }
+      if (other?.model_fallback?.length > 1) {
+        expandDataLocal.push({
+          key: t('模型降级'),
+          value: other.model_fallback.join(' → '),
+        });
       }
       if (other?.request_path) {
         expandDataLocal.push({
@@ -493,6 +533,12 @@ export const useLogsData = () => {
             key: t('计费模式'),
             value: localCountMode,
         });
+        if (other?.admin_info?.channel_script_error) {
+          expandDataLocal.push({
+            key: t('转换脚本错误'),
+            value: other.admin_info.channel_script_error,
+          });
+        }
       }
       expandDatesLocal[logs[i].key] = expandDataLocal;
     }
@@ -0,0 +1,706 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  Button,
+  Card,
+  Descriptions,
+  Empty,
+  Form,
+  Input,
+  InputNumber,
+  Modal,
+  Popconfirm,
+  Select,
+  Space,
+  Table,
+  Tabs,
+  TabPane,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import {
+  API,
+  isAdmin,
+  renderQuota,
+  renderQuotaWithPrompt,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../helpers';
+import { StatementView } from '../Statement';
+
+const { Text } = Typography;
+
+const ROLE_COLORS = {
+  owner: 'red',
+  admin: 'orange',
+  member: 'blue',
+  billing: 'green',
+};
+
+const ROLE_LABELS = {
+  owner: '所有者',
+  admin: '管理员',
+  member: '成员',
+  billing: '财务',
+};
+
+const canManage = (role) => role === 'owner' || role === 'admin';
+const canViewBilling = (role) => canManage(role) || role === 'billing';
+
+// 管理员视图：查看所有组织、充值与启用/禁用
+const AdminOrganizations = ({ t }) => {
+  const [orgs, setOrgs] = useState([]);
+  const [total, setTotal] = useState(0);
+  const [page, setPage] = useState(1);
+  const [keyword, setKeyword] = useState('');
+  const [topUpOrg, setTopUpOrg] = useState(null);
+  const [topUpQuota, setTopUpQuota] = useState(0);
+
+  const load = async (p = page) => {
+    const res = await API.get(
+      `/api/organization/?p=${p}&page_size=10&keyword=${encodeURIComponent(keyword)}`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setOrgs(data.items || []);
+      setTotal(data.total);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    load(1);
+  }, []);
+
+  const topUp = async () => {
+    const res = await API.post('/api/organization/topup', {
+      organization_id: topUpOrg.id,
+      quota: parseInt(topUpQuota),
+    });
+    if (res.data.success) {
+      showSuccess(t('充值成功'));
+      setTopUpOrg(null);
+      load();
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  const setStatus = async (org, status) => {
+    const res = await API.put('/api/organization/status', {
+      id: org.id,
+      status,
+    });
+    if (res.data.success) {
+      load();
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  const columns = [
+    { title: 'ID', dataIndex: 'id' },
+    { title: t('名称'), dataIndex: 'name' },
+    { title: t('所有者 ID'), dataIndex: 'owner_id' },
+    { title: t('剩余额度'), dataIndex: 'quota', render: (v) => renderQuota(v) },
+    {
+      title: t('已用额度'),
+      dataIndex: 'used_quota',
+      render: (v) => renderQuota(v),
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'status',
+      render: (v) =>
+        v === 1 ? (
+          <Tag color='green'>{t('已启用')}</Tag>
+        ) : (
+          <Tag color='red'>{t('已禁用')}</Tag>
+        ),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, org) => (
+        <Space>
+          <Button
+            size='small'
+            onClick={() => {
+              setTopUpOrg(org);
+              setTopUpQuota(0);
+            }}
+          >
+            {t('充值')}
+          </Button>
+          <Button
+            size='small'
+            type={org.status === 1 ? 'danger' : 'primary'}
+            onClick={() => setStatus(org, org.status === 1 ? 2 : 1)}
+          >
+            {org.status === 1 ? t('禁用') : t('启用')}
+          </Button>
+        </Space>
+      ),
+    },
+  ];
+
+  return (
+    <Card
+      className='!rounded-2xl shadow-sm border-0 mt-4'
+      title={t('所有组织')}
+      headerExtraContent={
+        <Input
+          placeholder={t('搜索组织名称')}
+          value={keyword}
+          onChange={setKeyword}
+          onEnterPress={() => load(1)}
+          showClear
+        />
+      }
+    >
+      <Table
+        columns={columns}
+        dataSource={orgs}
+        rowKey='id'
+        pagination={{
+          currentPage: page,
+          pageSize: 10,
+          total,
+          onPageChange: (p) => {
+            setPage(p);
+            load(p);
+          },
+        }}
+      />
+      <Modal
+        title={t('为组织充值')}
+        visible={!!topUpOrg}
+        onOk={topUp}
+        onCancel={() => setTopUpOrg(null)}
+      >
+        <Text>{topUpOrg?.name}</Text>
+        <InputNumber
+          className='mt-2'
+          style={{ width: '100%' }}
+          min={0}
+          value={topUpQuota}
+          onChange={setTopUpQuota}
+        />
+        <Text type='tertiary'>{renderQuotaWithPrompt(topUpQuota)}</Text>
+      </Modal>
+    </Card>
+  );
+};
+
+const OrganizationMembers = ({ t, orgId, role }) => {
+  const [members, setMembers] = useState([]);
+  const [editing, setEditing] = useState(null);
+
+  const load = async () => {
+    const res = await API.get(`/api/organization/${orgId}/member`);
+    if (res.data.success) {
+      setMembers(res.data.data || []);
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  useEffect(() => {
+    load();
+  }, [orgId]);
+
+  const save = async (values) => {
+    const payload = {
+      ...values,
+      monthly_limit: parseInt(values.monthly_limit || 0),
+    };
+    const res = editing.id
+      ? await API.put(`/api/organization/${orgId}/member`, {
+          ...payload,
+          id: editing.id,
+        })
+      : await API.post(`/api/organization/${orgId}/member`, payload);
+    if (res.data.success) {
+      showSuccess(t('保存成功'));
+      setEditing(null);
+      load();
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  const remove = async (member) => {
+    const res = await API.delete(
+      `/api/organization/${orgId}/member/${member.id}`,
+    );
+    if (res.data.success) {
+      load();
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  const roleOptions = [
+    role === 'owner' && { label: t('管理员'), value: 'admin' },
+    { label: t('成员'), value: 'member' },
+    { label: t('财务'), value: 'billing' },
+  ].filter(Boolean);
+
+  const columns = [
+    { title: t('用户名'), dataIndex: 'username' },
+    {
+      title: t('角色'),
+      dataIndex: 'role',
+      render: (v) => <Tag color={ROLE_COLORS[v]}>{t(ROLE_LABELS[v])}</Tag>,
+    },
+    {
+      title: t('每月限额'),
+      dataIndex: 'monthly_limit',
+      render: (v) => (v > 0 ? renderQuota(v) : t('不限制')),
+    },
+    {
+      title: t('加入时间'),
+      dataIndex: 'created_time',
+      render: (v) => timestamp2string(v),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, member) => (
+        <Space>
+          <Button size='small' onClick={() => setEditing(member)}>
+            {t('编辑')}
+          </Button>
+          {member.role !== 'owner' && (
+            <Popconfirm
+              title={t('确定移除该成员？')}
+              content={t('该成员创建的组织令牌将无法继续使用')}
+              onConfirm={() => remove(member)}
+            >
+              <Button size='small' type='danger'>
+                {t('移除')}
+              </Button>
+            </Popconfirm>
+          )}
+        </Space>
+      ),
+    },
+  ];
+
+  return (
+    <>
+      <Button
+        className='mb-2'
+        onClick={() =>
+          setEditing({ username: '', role: 'member', monthly_limit: 0 })
+        }
+      >
+        {t('添加成员')}
+      </Button>
+      <Table
+        columns={columns}
+        dataSource={members}
+        rowKey='id'
+        pagination={false}
+      />
+      <Modal
+        title={editing?.id ? t('编辑成员') : t('添加成员')}
+        visible={!!editing}
+        footer={null}
+        onCancel={() => setEditing(null)}
+      >
+        {editing && (
+          <Form initValues={editing} onSubmit={save}>
+            <Form.Input
+              field='username'
+              label={t('用户名')}
+              disabled={!!editing.id}
+              rules={[{ required: true, message: t('请输入用户名') }]}
+            />
+            {editing.role !== 'owner' && (
+              <Form.Select
+                field='role'
+                label={t('角色')}
+                optionList={roleOptions}
+                style={{ width: '100%' }}
+              />
+            )}
+            <Form.InputNumber
+              field='monthly_limit'
+              label={t('每月限额')}
+              min={0}
+              extraText={t('成员每月可使用的组织额度，0 表示不限制')}
+              style={{ width: '100%' }}
+            />
+            <Button htmlType='submit' type='primary' theme='solid'>
+              {t('保存')}
+            </Button>
+          </Form>
+        )}
+      </Modal>
+    </>
+  );
+};
+
+const OrganizationTokens = ({ t, orgId }) => {
+  const [tokens, setTokens] = useState([]);
+
+  const load = async () => {
+    const res = await API.get(
+      `/api/organization/${orgId}/token?p=1&page_size=100`,
+    );
+    if (res.data.success) {
+      setTokens(res.data.data.items || []);
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  useEffect(() => {
+    load();
+  }, [orgId]);
+
+  const remove = async (token) => {
+    const res = await API.delete(`/api/organization/${orgId}/token/${token.id}`);
+    if (res.data.success) {
+      load();
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  const columns = [
+    { title: t('名称'), dataIndex: 'name' },
+    { title: t('创建者 ID'), dataIndex: 'user_id' },
+    {
+      title: t('已用额度'),
+      dataIndex: 'used_quota',
+      render: (v) => renderQuota(v),
+    },
+    {
+      title: t('创建时间'),
+      dataIndex: 'created_time',
+      render: (v) => timestamp2string(v),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, token) => (
+        <Popconfirm
+          title={t('确定删除该令牌？')}
+          onConfirm={() => remove(token)}
+        >
+          <Button size='small' type='danger'>
+            {t('删除')}
+          </Button>
+        </Popconfirm>
+      ),
+    },
+  ];
+
+  return (
+    <Table
+      columns={columns}
+      dataSource={tokens}
+      rowKey='id'
+      pagination={false}
+    />
+  );
+};
+
+const OrganizationLogs = ({ t, orgId }) => {
+  const [logs, setLogs] = useState([]);
+  const [total, setTotal] = useState(0);
+  const [page, setPage] = useState(1);
+  const [username, setUsername] = useState('');
+
+  const load = async (p = page) => {
+    const res = await API.get(
+      `/api/organization/${orgId}/log?p=${p}&page_size=10&username=${encodeURIComponent(username)}`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setLogs(data.items || []);
+      setTotal(data.total);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    setPage(1);
+    load(1);
+  }, [orgId]);
+
+  const columns = [
+    {
+      title: t('时间'),
+      dataIndex: 'created_at',
+      render: (v) => timestamp2string(v),
+    },
+    { title: t('用户名'), dataIndex: 'username' },
+    { title: t('令牌'), dataIndex: 'token_name' },
+    { title: t('模型'), dataIndex: 'model_name' },
+    {
+      title: t('额度'),
+      dataIndex: 'quota',
+      render: (v) => (v ? renderQuota(v, 6) : ''),
+    },
+    { title: t('详情'), dataIndex: 'content' },
+  ];
+
+  return (
+    <>
+      <Input
+        className='mb-2'
+        style={{ maxWidth: 240 }}
+        placeholder={t('按用户名过滤')}
+        value={username}
+        onChange={setUsername}
+        onEnterPress={() => {
+          setPage(1);
+          load(1);
+        }}
+        showClear
+      />
+      <Table
+        columns={columns}
+        dataSource={logs}
+        rowKey='id'
+        pagination={{
+          currentPage: page,
+          pageSize: 10,
+          total,
+          onPageChange: (p) => {
+            setPage(p);
+            load(p);
+          },
+        }}
+      />
+    </>
+  );
+};
+
+const Organization = () => {
+  const { t } = useTranslation();
+  const [orgs, setOrgs] = useState([]);
+  const [currentId, setCurrentId] = useState(0);
+  const [detail, setDetail] = useState(null);
+  const [creating, setCreating] = useState(false);
+  const [newName, setNewName] = useState('');
+  const [funding, setFunding] = useState(false);
+  const [fundQuota, setFundQuota] = useState(0);
+
+  const loadOrgs = async (selectId) => {
+    const res = await API.get('/api/organization/self');
+    const { success, message, data } = res.data;
+    if (!success) {
+      showError(message);
+      return;
+    }
+    setOrgs(data || []);
+    if (data?.length) {
+      setCurrentId(selectId || currentId || data[0].id);
+    }
+  };
+
+  const loadDetail = async (id) => {
+    const res = await API.get(`/api/organization/${id}`);
+    if (res.data.success) {
+      setDetail(res.data.data);
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  useEffect(() => {
+    loadOrgs();
+  }, []);
+
+  useEffect(() => {
+    if (currentId) {
+      loadDetail(currentId);
+    }
+  }, [currentId]);
+
+  const createOrg = async () => {
+    const res = await API.post('/api/organization/', { name: newName });
+    if (res.data.success) {
+      showSuccess(t('创建成功'));
+      setCreating(false);
+      setNewName('');
+      loadOrgs(res.data.data.id);
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  const fund = async () => {
+    const res = await API.post(`/api/organization/${currentId}/fund`, {
+      quota: parseInt(fundQuota),
+    });
+    if (res.data.success) {
+      showSuccess(t('转入成功'));
+      setFunding(false);
+      loadDetail(currentId);
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  const org = detail?.organization;
+  const member = detail?.member;
+  const role = member?.role;
+
+  return (
+    <div className='mt-[60px] px-2'>
+      <Card
+        className='!rounded-2xl shadow-sm border-0'
+        title={t('组织管理')}
+        headerExtraContent={
+          <Space>
+            {orgs.length > 0 && (
+              <Select
+                value={currentId}
+                onChange={setCurrentId}
+                optionList={orgs.map((o) => ({ label: o.name, value: o.id }))}
+                style={{ width: 200 }}
+              />
+            )}
+            <Button theme='solid' onClick={() => setCreating(true)}>
+              {t('创建组织')}
+            </Button>
+          </Space>
+        }
+      >
+        {!org ? (
+          <Empty description={t('您还没有加入任何组织')} />
+        ) : (
+          <>
+            <Descriptions
+              row
+              data={[
+                { key: t('组织名称'), value: org.name },
+                {
+                  key: t('我的角色'),
+                  value: (
+                    <Tag color={ROLE_COLORS[role]}>{t(ROLE_LABELS[role])}</Tag>
+                  ),
+                },
+                { key: t('共享额度'), value: renderQuota(org.quota) },
+                { key: t('已用额度'), value: renderQuota(org.used_quota) },
+                {
+                  key: t('本月已用 / 每月限额'),
+                  value:
+                    member.monthly_limit > 0
+                      ? `${renderQuota(detail.monthly_used)} / ${renderQuota(member.monthly_limit)}`
+                      : t('不限制'),
+                },
+                {
+                  key: t('状态'),
+                  value:
+                    org.status === 1 ? (
+                      <Tag color='green'>{t('已启用')}</Tag>
+                    ) : (
+                      <Tag color='red'>{t('已禁用')}</Tag>
+                    ),
+                },
+              ]}
+            />
+            <Space className='mt-2'>
+              {canViewBilling(role) && (
+                <Button
+                  onClick={() => {
+                    setFundQuota(0);
+                    setFunding(true);
+                  }}
+                >
+                  {t('从个人余额转入')}
+                </Button>
+              )}
+              {role !== 'billing' && (
+                <Text type='tertiary'>
+                  {t('在令牌管理中创建令牌时选择该组织，即可使用组织的共享额度')}
+                </Text>
+              )}
+            </Space>
+            {canViewBilling(role) && (
+              <Tabs className='mt-4' key={org.id}>
+                {canManage(role) && (
+                  <TabPane tab={t('成员')} itemKey='members'>
+                    <OrganizationMembers t={t} orgId={org.id} role={role} />
+                  </TabPane>
+                )}
+                {canManage(role) && (
+                  <TabPane tab={t('组织令牌')} itemKey='tokens'>
+                    <OrganizationTokens t={t} orgId={org.id} />
+                  </TabPane>
+                )}
+                <TabPane tab={t('使用日志')} itemKey='logs'>
+                  <OrganizationLogs t={t} orgId={org.id} />
+                </TabPane>
+                <TabPane tab={t('账单')} itemKey='statement'>
+                  <StatementView
+                    t={t}
+                    basePath={`/api/organization/${org.id}/statement`}
+                    filePrefix={`statement-organization-${org.id}`}
+                  />
+                </TabPane>
+              </Tabs>
+            )}
+          </>
+        )}
+      </Card>
+      {isAdmin() && <AdminOrganizations t={t} />}
+      <Modal
+        title={t('创建组织')}
+        visible={creating}
+        onOk={createOrg}
+        onCancel={() => setCreating(false)}
+      >
+        <Input
+          placeholder={t('请输入组织名称')}
+          value={newName}
+          onChange={setNewName}
+        />
+      </Modal>
+      <Modal
+        title={t('从个人余额转入')}
+        visible={funding}
+        onOk={fund}
+        onCancel={() => setFunding(false)}
+      >
+        <InputNumber
+          style={{ width: '100%' }}
+          min={0}
+          value={fundQuota}
+          onChange={setFundQuota}
+        />
+        <Text type='tertiary'>{renderQuotaWithPrompt(fundQuota)}</Text>
+      </Modal>
+    </div>
+  );
+};
+
+export default Organization;
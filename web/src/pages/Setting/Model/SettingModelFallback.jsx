@@ -0,0 +1,177 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState, useRef } from 'react';
+import { Button, Col, Form, Row, Spin } from '@douyinfe/semi-ui';
+import {
+  compareObjects,
+  API,
+  showError,
+  showSuccess,
+  showWarning,
+  verifyJSON,
+} from '../../../helpers';
+import { useTranslation } from 'react-i18next';
+
+const modelFallbackExample = JSON.stringify(
+  { 'gpt-4o': ['claude-sonnet-4', 'gemini-2.5-pro'] },
+  null,
+  2,
+);
+
+const defaultModelFallbackInputs = {
+  'model_fallback_setting.enabled': false,
+  'model_fallback_setting.chains': '{}',
+};
+
+export default function SettingModelFallback(props) {
+  const { t } = useTranslation();
+
+  const [loading, setLoading] = useState(false);
+  const [inputs, setInputs] = useState(defaultModelFallbackInputs);
+  const refForm = useRef();
+  const [inputsRow, setInputsRow] = useState(defaultModelFallbackInputs);
+
+  async function onSubmit() {
+    try {
+      await refForm.current.validate();
+    } catch (error) {
+      showError(t('请检查输入'));
+      return;
+    }
+    const updateArray = compareObjects(inputs, inputsRow);
+    if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    const requestQueue = updateArray.map((item) => {
+      let value = String(inputs[item.key]);
+      if (
+        item.key === 'model_fallback_setting.chains' &&
+        value.trim() === ''
+      ) {
+        value = '{}';
+      }
+      return API.put('/api/option/', {
+        key: item.key,
+        value,
+      });
+    });
+    setLoading(true);
+    Promise.all(requestQueue)
+      .then((res) => {
+        if (requestQueue.length === 1) {
+          if (res.includes(undefined)) return;
+        } else if (requestQueue.length > 1) {
+          if (res.includes(undefined))
+            return showError(t('部分保存失败，请重试'));
+        }
+        for (let i = 0; i < res.length; i++) {
+          if (!res[i].data.success) {
+            return showError(res[i].data.message);
+          }
+        }
+        showSuccess(t('保存成功'));
+        props.refresh();
+      })
+      .catch(() => {
+        showError(t('保存失败，请重试'));
+      })
+      .finally(() => {
+        setLoading(false);
+      });
+  }
+
+  useEffect(() => {
+    const currentInputs = {};
+    for (const key of Object.keys(defaultModelFallbackInputs)) {
+      currentInputs[key] =
+        props.options[key] !== undefined
+          ? props.options[key]
+          : defaultModelFallbackInputs[key];
+    }
+    setInputs(currentInputs);
+    setInputsRow(structuredClone(currentInputs));
+    if (refForm.current) {
+      refForm.current.setValues(currentInputs);
+    }
+  }, [props.options]);
+
+  return (
+    <>
+      <Spin spinning={loading}>
+        <Form
+          values={inputs}
+          getFormApi={(formAPI) => (refForm.current = formAPI)}
+          style={{ marginBottom: 15 }}
+        >
+          <Form.Section text={t('模型降级')}>
+            <Row>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  label={t('启用全局模型降级')}
+                  field={'model_fallback_setting.enabled'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'model_fallback_setting.enabled': value,
+                    })
+                  }
+                  extraText={t(
+                    '模型的渠道全部不可用或请求失败时，依次改用降级链中的模型；令牌单独配置的降级链不受此开关影响',
+                  )}
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Col span={24}>
+                <Form.TextArea
+                  label={t('模型降级链')}
+                  field={'model_fallback_setting.chains'}
+                  placeholder={t('例如：') + '\n' + modelFallbackExample}
+                  autosize={{ minRows: 6, maxRows: 12 }}
+                  rules={[
+                    {
+                      validator: (rule, value) => {
+                        if (!value || value.trim() === '') return true;
+                        return verifyJSON(value);
+                      },
+                      message: t('不是合法的 JSON 字符串'),
+                    },
+                  ]}
+                  extraText={t(
+                    '为一个 JSON 文本，键为模型名称，值为按顺序尝试的降级模型列表；降级后按实际使用的模型计费',
+                  )}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'model_fallback_setting.chains': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Button size='default' onClick={onSubmit}>
+                {t('保存模型降级设置')}
+              </Button>
+            </Row>
+          </Form.Section>
+        </Form>
+      </Spin>
+    </>
+  );
+}
@@ -0,0 +1,185 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState, useRef } from 'react';
+import { Button, Col, Form, Row, Spin } from '@douyinfe/semi-ui';
+import {
+  compareObjects,
+  API,
+  showError,
+  showSuccess,
+  showWarning,
+  verifyJSON,
+} from '../../../helpers';
+import { useTranslation } from 'react-i18next';
+
+const virtualModelExample = JSON.stringify(
+  {
+    'team-default': {
+      targets: [
+        { model: 'gpt-4o', weight: 90 },
+        { model: 'claude-sonnet-4', weight: 10 },
+      ],
+      sticky_by: 'user',
+    },
+  },
+  null,
+  2,
+);
+
+const defaultVirtualModelInputs = {
+  'virtual_model_setting.enabled': false,
+  'virtual_model_setting.models': '{}',
+};
+
+export default function SettingVirtualModel(props) {
+  const { t } = useTranslation();
+
+  const [loading, setLoading] = useState(false);
+  const [inputs, setInputs] = useState(defaultVirtualModelInputs);
+  const refForm = useRef();
+  const [inputsRow, setInputsRow] = useState(defaultVirtualModelInputs);
+
+  async function onSubmit() {
+    try {
+      await refForm.current.validate();
+    } catch (error) {
+      showError(t('请检查输入'));
+      return;
+    }
+    const updateArray = compareObjects(inputs, inputsRow);
+    if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    const requestQueue = updateArray.map((item) => {
+      let value = String(inputs[item.key]);
+      if (
+        item.key === 'virtual_model_setting.models' &&
+        value.trim() === ''
+      ) {
+        value = '{}';
+      }
+      return API.put('/api/option/', {
+        key: item.key,
+        value,
+      });
+    });
+    setLoading(true);
+    Promise.all(requestQueue)
+      .then((res) => {
+        if (requestQueue.length === 1) {
+          if (res.includes(undefined)) return;
+        } else if (requestQueue.length > 1) {
+          if (res.includes(undefined))
+            return showError(t('部分保存失败，请重试'));
+        }
+        for (let i = 0; i < res.length; i++) {
+          if (!res[i].data.success) {
+            return showError(res[i].data.message);
+          }
+        }
+        showSuccess(t('保存成功'));
+        props.refresh();
+      })
+      .catch(() => {
+        showError(t('保存失败，请重试'));
+      })
+      .finally(() => {
+        setLoading(false);
+      });
+  }
+
+  useEffect(() => {
+    const currentInputs = {};
+    for (const key of Object.keys(defaultVirtualModelInputs)) {
+      currentInputs[key] =
+        props.options[key] !== undefined
+          ? props.options[key]
+          : defaultVirtualModelInputs[key];
+    }
+    setInputs(currentInputs);
+    setInputsRow(structuredClone(currentInputs));
+    if (refForm.current) {
+      refForm.current.setValues(currentInputs);
+    }
+  }, [props.options]);
+
+  return (
+    <>
+      <Spin spinning={loading}>
+        <Form
+          values={inputs}
+          getFormApi={(formAPI) => (refForm.current = formAPI)}
+          style={{ marginBottom: 15 }}
+        >
+          <Form.Section text={t('虚拟模型')}>
+            <Row>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  label={t('启用虚拟模型')}
+                  field={'virtual_model_setting.enabled'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'virtual_model_setting.enabled': value,
+                    })
+                  }
+                  extraText={t(
+                    '请求虚拟模型时按权重解析为其中一个目标模型，并按实际使用的模型选择渠道与计费',
+                  )}
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Col span={24}>
+                <Form.TextArea
+                  label={t('虚拟模型配置')}
+                  field={'virtual_model_setting.models'}
+                  placeholder={t('例如：') + '\n' + virtualModelExample}
+                  autosize={{ minRows: 6, maxRows: 12 }}
+                  rules={[
+                    {
+                      validator: (rule, value) => {
+                        if (!value || value.trim() === '') return true;
+                        return verifyJSON(value);
+                      },
+                      message: t('不是合法的 JSON 字符串'),
+                    },
+                  ]}
+                  extraText={t(
+                    '为一个 JSON 文本，键为虚拟模型名称，targets 为目标模型及权重，sticky_by 为分流依据（user 或 token，默认 user），同一用户或令牌始终分到同一目标模型',
+                  )}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'virtual_model_setting.models': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Button size='default' onClick={onSubmit}>
+                {t('保存虚拟模型设置')}
+              </Button>
+            </Row>
+          </Form.Section>
+        </Form>
+      </Spin>
+    </>
+  );
+}
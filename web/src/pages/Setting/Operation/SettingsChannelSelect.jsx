@@ -0,0 +1,228 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState, useRef } from 'react';
+import { Button, Col, Form, Row, Spin, Typography } from '@douyinfe/semi-ui';
+import {
+  compareObjects,
+  API,
+  showError,
+  showSuccess,
+  showWarning,
+  verifyJSON,
+} from '../../../helpers';
+import { useTranslation } from 'react-i18next';
+
+export default function SettingsChannelSelect(props) {
+  const { t } = useTranslation();
+  const [loading, setLoading] = useState(false);
+  const [inputs, setInputs] = useState({
+    'channel_select_setting.adaptive_enabled': false,
+    'channel_select_setting.adaptive_groups': '[]',
+    'channel_select_setting.window_seconds': 300,
+    'channel_select_setting.min_samples': 10,
+    'channel_select_setting.target_latency_ms': 3000,
+    'channel_select_setting.min_weight_percent': 5,
+  });
+  const refForm = useRef();
+  const [inputsRow, setInputsRow] = useState(inputs);
+
+  function handleFieldChange(fieldName) {
+    return (value) => {
+      setInputs((inputs) => ({ ...inputs, [fieldName]: value }));
+    };
+  }
+
+  async function onSubmit() {
+    try {
+      await refForm.current.validate();
+    } catch (error) {
+      showError(t('请检查输入'));
+      return;
+    }
+    const updateArray = compareObjects(inputs, inputsRow);
+    if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    const requestQueue = updateArray.map((item) => {
+      let value = String(inputs[item.key]);
+      if (
+        item.key === 'channel_select_setting.adaptive_groups' &&
+        value.trim() === ''
+      ) {
+        value = '[]';
+      }
+      return API.put('/api/option/', {
+        key: item.key,
+        value,
+      });
+    });
+    setLoading(true);
+    Promise.all(requestQueue)
+      .then((res) => {
+        if (requestQueue.length === 1) {
+          if (res.includes(undefined)) return;
+        } else if (requestQueue.length > 1) {
+          if (res.includes(undefined))
+            return showError(t('部分保存失败，请重试'));
+        }
+        showSuccess(t('保存成功'));
+        props.refresh();
+      })
+      .catch(() => {
+        showError(t('保存失败，请重试'));
+      })
+      .finally(() => {
+        setLoading(false);
+      });
+  }
+
+  useEffect(() => {
+    const currentInputs = {};
+    for (let key in props.options) {
+      if (Object.keys(inputs).includes(key)) {
+        currentInputs[key] = props.options[key];
+      }
+    }
+    setInputs(currentInputs);
+    setInputsRow(structuredClone(currentInputs));
+    refForm.current.setValues(currentInputs);
+  }, [props.options]);
+
+  return (
+    <>
+      <Spin spinning={loading}>
+        <Form
+          values={inputs}
+          getFormApi={(formAPI) => (refForm.current = formAPI)}
+          style={{ marginBottom: 15 }}
+        >
+          <Form.Section text={t('自适应渠道选择')}>
+            <Typography.Text
+              type='tertiary'
+              style={{ marginBottom: 16, display: 'block' }}
+            >
+              {t(
+                '根据统计窗口内的成功率与首字时间动态调整同优先级渠道的权重，表现较差的渠道分到更少的流量',
+              )}
+            </Typography.Text>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'channel_select_setting.adaptive_enabled'}
+                  label={t('启用自适应渠道选择')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={handleFieldChange(
+                    'channel_select_setting.adaptive_enabled',
+                  )}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  field={'channel_select_setting.window_seconds'}
+                  label={t('统计窗口')}
+                  suffix={t('秒')}
+                  step={1}
+                  min={1}
+                  onChange={handleFieldChange(
+                    'channel_select_setting.window_seconds',
+                  )}
+                  disabled={!inputs['channel_select_setting.adaptive_enabled']}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  field={'channel_select_setting.min_samples'}
+                  label={t('最少样本数')}
+                  extraText={t('窗口内样本数低于该值时不调整渠道权重')}
+                  step={1}
+                  min={1}
+                  onChange={handleFieldChange(
+                    'channel_select_setting.min_samples',
+                  )}
+                  disabled={!inputs['channel_select_setting.adaptive_enabled']}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  field={'channel_select_setting.target_latency_ms'}
+                  label={t('目标首字时间')}
+                  suffix={'ms'}
+                  extraText={t('首字时间低于该值时不因延迟降低权重')}
+                  step={100}
+                  min={0}
+                  onChange={handleFieldChange(
+                    'channel_select_setting.target_latency_ms',
+                  )}
+                  disabled={!inputs['channel_select_setting.adaptive_enabled']}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  field={'channel_select_setting.min_weight_percent'}
+                  label={t('最低权重比例')}
+                  suffix={'%'}
+                  extraText={t('有效权重的下限，避免渠道完全没有流量而无法恢复')}
+                  step={1}
+                  min={0}
+                  max={100}
+                  onChange={handleFieldChange(
+                    'channel_select_setting.min_weight_percent',
+                  )}
+                  disabled={!inputs['channel_select_setting.adaptive_enabled']}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={16}>
+                <Form.TextArea
+                  field={'channel_select_setting.adaptive_groups'}
+                  label={t('生效分组')}
+                  placeholder={t('例如：') + '["default", "vip"]'}
+                  extraText={t('为一个 JSON 数组，为空时对所有分组生效')}
+                  autosize={{ minRows: 2, maxRows: 6 }}
+                  rules={[
+                    {
+                      validator: (rule, value) => {
+                        if (!value || value.trim() === '') return true;
+                        return verifyJSON(value);
+                      },
+                      message: t('不是合法的 JSON 字符串'),
+                    },
+                  ]}
+                  onChange={handleFieldChange(
+                    'channel_select_setting.adaptive_groups',
+                  )}
+                  disabled={!inputs['channel_select_setting.adaptive_enabled']}
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Button size='default' onClick={onSubmit}>
+                {t('保存自适应渠道选择设置')}
+              </Button>
+            </Row>
+          </Form.Section>
+        </Form>
+      </Spin>
+    </>
+  );
+}
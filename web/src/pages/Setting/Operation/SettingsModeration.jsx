@@ -0,0 +1,314 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState, useRef } from 'react';
+import { Button, Col, Form, Row, Spin, Typography } from '@douyinfe/semi-ui';
+import {
+  compareObjects,
+  API,
+  showError,
+  showSuccess,
+  showWarning,
+  verifyJSON,
+} from '../../../helpers';
+import { useTranslation } from 'react-i18next';
+
+// JSON 字段及其为空时保存的值
+const jsonFields = {
+  'moderation_setting.providers': '[]',
+  'moderation_setting.keywords': '{}',
+  'moderation_setting.regex_rules': '[]',
+  'moderation_setting.default_policy': '{}',
+  'moderation_setting.group_policies': '{}',
+};
+
+const regexRulesExample = JSON.stringify(
+  [{ category: 'phone', pattern: '1[3-9]\\d{9}' }],
+  null,
+  2,
+);
+
+const groupPoliciesExample = JSON.stringify(
+  {
+    vip: { action: 'log' },
+    default: { action: 'redact', check_completion: true },
+  },
+  null,
+  2,
+);
+
+export default function SettingsModeration(props) {
+  const { t } = useTranslation();
+  const [loading, setLoading] = useState(false);
+  const [inputs, setInputs] = useState({
+    'moderation_setting.enabled': false,
+    'moderation_setting.providers': '["keyword", "regex"]',
+    'moderation_setting.keywords': '{}',
+    'moderation_setting.regex_rules': '[]',
+    'moderation_setting.classifier_model': 'omni-moderation-latest',
+    'moderation_setting.classifier_group': 'default',
+    'moderation_setting.classifier_timeout_ms': 3000,
+    'moderation_setting.redact_replacement': '**###**',
+    'moderation_setting.default_policy': '{"action": "block"}',
+    'moderation_setting.group_policies': '{}',
+  });
+  const refForm = useRef();
+  const [inputsRow, setInputsRow] = useState(inputs);
+
+  function handleFieldChange(fieldName) {
+    return (value) => {
+      setInputs((inputs) => ({ ...inputs, [fieldName]: value }));
+    };
+  }
+
+  async function onSubmit() {
+    try {
+      await refForm.current.validate();
+    } catch (error) {
+      showError(t('请检查输入'));
+      return;
+    }
+    const updateArray = compareObjects(inputs, inputsRow);
+    if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    const requestQueue = updateArray.map((item) => {
+      let value = String(inputs[item.key]);
+      if (jsonFields[item.key] !== undefined && value.trim() === '') {
+        value = jsonFields[item.key];
+      }
+      return API.put('/api/option/', {
+        key: item.key,
+        value,
+      });
+    });
+    setLoading(true);
+    Promise.all(requestQueue)
+      .then((res) => {
+        if (requestQueue.length === 1) {
+          if (res.includes(undefined)) return;
+        } else if (requestQueue.length > 1) {
+          if (res.includes(undefined))
+            return showError(t('部分保存失败，请重试'));
+        }
+        showSuccess(t('保存成功'));
+        props.refresh();
+      })
+      .catch(() => {
+        showError(t('保存失败，请重试'));
+      })
+      .finally(() => {
+        setLoading(false);
+      });
+  }
+
+  useEffect(() => {
+    const currentInputs = {};
+    for (let key in props.options) {
+      if (Object.keys(inputs).includes(key)) {
+        let value = props.options[key];
+        if (jsonFields[key] !== undefined && value !== '') {
+          try {
+            value = JSON.stringify(JSON.parse(value), null, 2);
+          } catch (error) {
+            // 保留原始内容，由表单校验提示
+          }
+        }
+        currentInputs[key] = value;
+      }
+    }
+    setInputs(currentInputs);
+    setInputsRow(structuredClone(currentInputs));
+    refForm.current.setValues(currentInputs);
+  }, [props.options]);
+
+  const jsonRules = [
+    {
+      validator: (rule, value) => {
+        if (!value || value.trim() === '') return true;
+        return verifyJSON(value);
+      },
+      message: t('不是合法的 JSON 字符串'),
+    },
+  ];
+
+  return (
+    <>
+      <Spin spinning={loading}>
+        <Form
+          values={inputs}
+          getFormApi={(formAPI) => (refForm.current = formAPI)}
+          style={{ marginBottom: 15 }}
+        >
+          <Form.Section text={t('内容审核')}>
+            <Typography.Text
+              type='tertiary'
+              style={{ marginBottom: 16, display: 'block' }}
+            >
+              {t(
+                '在预扣费前审核提示词，可按分组配置命中后的处理方式，并可选地审核流式补全内容',
+              )}
+            </Typography.Text>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'moderation_setting.enabled'}
+                  label={t('启用内容审核')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={handleFieldChange('moderation_setting.enabled')}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Input
+                  field={'moderation_setting.redact_replacement'}
+                  label={t('替换文本')}
+                  extraText={t('处理方式为 redact 时，命中内容替换为该文本')}
+                  onChange={handleFieldChange(
+                    'moderation_setting.redact_replacement',
+                  )}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={16}>
+                <Form.TextArea
+                  field={'moderation_setting.providers'}
+                  label={t('审核方式')}
+                  extraText={t(
+                    '为一个 JSON 数组，按顺序执行，可选 keyword（关键词）、regex（正则）、classifier（外部分类器）',
+                  )}
+                  autosize={{ minRows: 2, maxRows: 4 }}
+                  rules={jsonRules}
+                  onChange={handleFieldChange('moderation_setting.providers')}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={16}>
+                <Form.TextArea
+                  field={'moderation_setting.keywords'}
+                  label={t('审核关键词')}
+                  placeholder={
+                    t('例如：') + '\n' + '{"violence": ["keyword1", "keyword2"]}'
+                  }
+                  extraText={t(
+                    '为一个 JSON 文本，键为类别，值为关键词列表，匹配时不区分大小写',
+                  )}
+                  autosize={{ minRows: 4, maxRows: 12 }}
+                  rules={jsonRules}
+                  onChange={handleFieldChange('moderation_setting.keywords')}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={16}>
+                <Form.TextArea
+                  field={'moderation_setting.regex_rules'}
+                  label={t('正则审核规则')}
+                  placeholder={t('例如：') + '\n' + regexRulesExample}
+                  extraText={t(
+                    '为一个 JSON 数组，每条规则包含类别 category 与正则表达式 pattern',
+                  )}
+                  autosize={{ minRows: 4, maxRows: 12 }}
+                  rules={jsonRules}
+                  onChange={handleFieldChange('moderation_setting.regex_rules')}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Input
+                  field={'moderation_setting.classifier_model'}
+                  label={t('分类器模型')}
+                  extraText={t(
+                    '由已配置的 OpenAI 兼容 /v1/moderations 渠道提供',
+                  )}
+                  onChange={handleFieldChange(
+                    'moderation_setting.classifier_model',
+                  )}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Input
+                  field={'moderation_setting.classifier_group'}
+                  label={t('分类器分组')}
+                  extraText={t('选择分类器渠道时使用的分组')}
+                  onChange={handleFieldChange(
+                    'moderation_setting.classifier_group',
+                  )}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  field={'moderation_setting.classifier_timeout_ms'}
+                  label={t('分类器超时时间')}
+                  suffix={'ms'}
+                  extraText={t('分类器超时或出错时放行请求')}
+                  step={100}
+                  min={1}
+                  onChange={handleFieldChange(
+                    'moderation_setting.classifier_timeout_ms',
+                  )}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={16}>
+                <Form.TextArea
+                  field={'moderation_setting.default_policy'}
+                  label={t('默认审核策略')}
+                  extraText={t(
+                    '未单独配置的分组使用该策略。action 可选 block（拒绝）、redact（替换后继续，外部分类器命中时按拒绝处理）、log（仅记录）、off（不审核）；check_completion 表示是否审核流式补全内容（仅关键词与正则）',
+                  )}
+                  autosize={{ minRows: 2, maxRows: 6 }}
+                  rules={jsonRules}
+                  onChange={handleFieldChange(
+                    'moderation_setting.default_policy',
+                  )}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={16}>
+                <Form.TextArea
+                  field={'moderation_setting.group_policies'}
+                  label={t('分组审核策略')}
+                  placeholder={t('例如：') + '\n' + groupPoliciesExample}
+                  extraText={t(
+                    '为一个 JSON 文本，键为使用分组，值的格式与默认审核策略相同',
+                  )}
+                  autosize={{ minRows: 4, maxRows: 12 }}
+                  rules={jsonRules}
+                  onChange={handleFieldChange(
+                    'moderation_setting.group_policies',
+                  )}
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Button size='default' onClick={onSubmit}>
+                {t('保存内容审核设置')}
+              </Button>
+            </Row>
+          </Form.Section>
+        </Form>
+      </Spin>
+    </>
+  );
+}
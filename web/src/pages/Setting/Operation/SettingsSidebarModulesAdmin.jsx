@@ -56,6 +56,8 @@ export default function SettingsSidebarModulesAdmin(props) {
     personal: {
       enabled: true,
       topup: true,
+      organization: true,
+      statement: true,
       personal: true,
     },
     admin: {
@@ -116,6 +118,8 @@ export default function SettingsSidebarModulesAdmin(props) {
       personal: {
         enabled: true,
         topup: true,
+        organization: true,
+        statement: true,
         personal: true,
       },
       admin: {
@@ -185,7 +189,13 @@ export default function SettingsSidebarModulesAdmin(props) {
             midjourney: true,
             task: true,
           },
-          personal: { enabled: true, topup: true, personal: true },
+          personal: {
+            enabled: true,
+            topup: true,
+            organization: true,
+            statement: true,
+            personal: true,
+          },
           admin: {
             enabled: true,
             channel: true,
@@ -238,6 +248,16 @@ export default function SettingsSidebarModulesAdmin(props) {
       description: t('用户个人功能'),
       modules: [
         { key: 'topup', title: t('钱包管理'), description: t('余额充值管理') },
+        {
+          key: 'organization',
+          title: t('组织管理'),
+          description: t('组织成员与共享额度管理'),
+        },
+        {
+          key: 'statement',
+          title: t('账单'),
+          description: t('月度账单查询与下载'),
+        },
         {
           key: 'personal',
           title: t('个人设置'),
@@ -0,0 +1,371 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState, useRef } from 'react';
+import {
+  Button,
+  Col,
+  Form,
+  Modal,
+  Popconfirm,
+  Row,
+  Space,
+  Spin,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import {
+  compareObjects,
+  API,
+  renderQuota,
+  showError,
+  showSuccess,
+  showWarning,
+} from '../../../helpers';
+import { useTranslation } from 'react-i18next';
+
+const emptyPlan = {
+  id: 0,
+  name: '',
+  description: '',
+  price: 0,
+  period_days: 30,
+  periods: 1,
+  quota: 0,
+  upgrade_group: '',
+  allowed_groups: '',
+  rollover_policy: 'none',
+  rollover_cap: 0,
+  stripe_price_id: '',
+  creem_product_id: '',
+  enabled: true,
+};
+
+export default function SettingsSubscription(props) {
+  const { t } = useTranslation();
+  const [loading, setLoading] = useState(false);
+  const [inputs, setInputs] = useState({
+    'subscription_setting.enabled': false,
+  });
+  const refForm = useRef();
+  const [inputsRow, setInputsRow] = useState(inputs);
+  const [plans, setPlans] = useState([]);
+  const [editingPlan, setEditingPlan] = useState(null);
+  const planFormApi = useRef();
+
+  function handleFieldChange(fieldName) {
+    return (value) => {
+      setInputs((inputs) => ({ ...inputs, [fieldName]: value }));
+    };
+  }
+
+  function onSubmit() {
+    const updateArray = compareObjects(inputs, inputsRow);
+    if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    const requestQueue = updateArray.map((item) =>
+      API.put('/api/option/', {
+        key: item.key,
+        value: String(inputs[item.key]),
+      }),
+    );
+    setLoading(true);
+    Promise.all(requestQueue)
+      .then((res) => {
+        if (requestQueue.length === 1) {
+          if (res.includes(undefined)) return;
+        } else if (requestQueue.length > 1) {
+          if (res.includes(undefined))
+            return showError(t('部分保存失败，请重试'));
+        }
+        showSuccess(t('保存成功'));
+        props.refresh();
+      })
+      .catch(() => {
+        showError(t('保存失败，请重试'));
+      })
+      .finally(() => {
+        setLoading(false);
+      });
+  }
+
+  const loadPlans = async () => {
+    const res = await API.get('/api/subscription/plan');
+    const { success, message, data } = res.data;
+    if (success) {
+      setPlans(data || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const savePlan = async () => {
+    const values = await planFormApi.current.validate();
+    const plan = { ...editingPlan, ...values };
+    setLoading(true);
+    try {
+      const res = plan.id
+        ? await API.put('/api/subscription/plan', plan)
+        : await API.post('/api/subscription/plan', plan);
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t('保存成功'));
+        setEditingPlan(null);
+        await loadPlans();
+      } else {
+        showError(message);
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const deletePlan = async (id) => {
+    const res = await API.delete(`/api/subscription/plan/${id}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('删除成功'));
+      await loadPlans();
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    const currentInputs = {};
+    for (let key in props.options) {
+      if (Object.keys(inputs).includes(key)) {
+        currentInputs[key] = props.options[key];
+      }
+    }
+    setInputs(currentInputs);
+    setInputsRow(structuredClone(currentInputs));
+    refForm.current.setValues(currentInputs);
+  }, [props.options]);
+
+  useEffect(() => {
+    loadPlans();
+  }, []);
+
+  const columns = [
+    { title: t('名称'), dataIndex: 'name' },
+    { title: t('价格'), dataIndex: 'price' },
+    {
+      title: t('周期'),
+      dataIndex: 'period_days',
+      render: (days, record) =>
+        `${days} ${t('天')} × ${record.periods}`,
+    },
+    {
+      title: t('每周期额度'),
+      dataIndex: 'quota',
+      render: (quota) => renderQuota(quota),
+    },
+    {
+      title: t('订阅分组'),
+      dataIndex: 'upgrade_group',
+      render: (group) => group || '-',
+    },
+    {
+      title: t('额度结转'),
+      dataIndex: 'rollover_policy',
+      render: (policy) =>
+        policy === 'carry' ? t('结转剩余额度') : t('不结转'),
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'enabled',
+      render: (enabled) =>
+        enabled ? (
+          <Tag color='green'>{t('已启用')}</Tag>
+        ) : (
+          <Tag color='grey'>{t('已禁用')}</Tag>
+        ),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, record) => (
+        <Space>
+          <Button size='small' onClick={() => setEditingPlan(record)}>
+            {t('编辑')}
+          </Button>
+          <Popconfirm
+            title={t('确定删除该套餐？')}
+            content={t('已有的订阅不受影响')}
+            onConfirm={() => deletePlan(record.id)}
+          >
+            <Button size='small' type='danger'>
+              {t('删除')}
+            </Button>
+          </Popconfirm>
+        </Space>
+      ),
+    },
+  ];
+
+  return (
+    <>
+      <Spin spinning={loading}>
+        <Form
+          values={inputs}
+          getFormApi={(formAPI) => (refForm.current = formAPI)}
+          style={{ marginBottom: 15 }}
+        >
+          <Form.Section text={t('订阅套餐设置')}>
+            <Typography.Text
+              type='tertiary'
+              style={{ marginBottom: 16, display: 'block' }}
+            >
+              {t(
+                '订阅套餐每个周期发放一次套餐额度，消费时优先使用套餐额度，到期后恢复用户原分组',
+              )}
+            </Typography.Text>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'subscription_setting.enabled'}
+                  label={t('启用订阅套餐')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={handleFieldChange('subscription_setting.enabled')}
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Space>
+                <Button size='default' onClick={onSubmit}>
+                  {t('保存订阅设置')}
+                </Button>
+                <Button size='default' onClick={() => setEditingPlan(emptyPlan)}>
+                  {t('添加套餐')}
+                </Button>
+              </Space>
+            </Row>
+          </Form.Section>
+        </Form>
+        <Table
+          columns={columns}
+          dataSource={plans}
+          rowKey='id'
+          pagination={false}
+          size='small'
+        />
+      </Spin>
+      <Modal
+        title={editingPlan?.id ? t('编辑套餐') : t('添加套餐')}
+        visible={editingPlan !== null}
+        onOk={savePlan}
+        onCancel={() => setEditingPlan(null)}
+        confirmLoading={loading}
+        width={640}
+      >
+        {editingPlan && (
+          <Form
+            initValues={editingPlan}
+            getFormApi={(formAPI) => (planFormApi.current = formAPI)}
+          >
+            <Form.Input
+              field='name'
+              label={t('名称')}
+              rules={[{ required: true, message: t('请输入套餐名称') }]}
+            />
+            <Form.TextArea field='description' label={t('描述')} autosize />
+            <Row gutter={16}>
+              <Col span={8}>
+                <Form.InputNumber
+                  field='price'
+                  label={t('价格')}
+                  min={0}
+                  precision={2}
+                />
+              </Col>
+              <Col span={8}>
+                <Form.InputNumber
+                  field='period_days'
+                  label={t('周期天数')}
+                  min={1}
+                />
+              </Col>
+              <Col span={8}>
+                <Form.InputNumber
+                  field='periods'
+                  label={t('包含周期数')}
+                  min={1}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col span={12}>
+                <Form.InputNumber
+                  field='quota'
+                  label={t('每周期额度')}
+                  min={0}
+                />
+              </Col>
+              <Col span={12}>
+                <Form.Input
+                  field='upgrade_group'
+                  label={t('订阅分组')}
+                  placeholder={t('订阅期间用户所在的分组，留空不调整')}
+                />
+              </Col>
+            </Row>
+            <Form.Input
+              field='allowed_groups'
+              label={t('可用分组')}
+              placeholder={t('可使用套餐额度的分组，逗号分隔，留空表示所有分组')}
+            />
+            <Row gutter={16}>
+              <Col span={12}>
+                <Form.Select
+                  field='rollover_policy'
+                  label={t('额度结转')}
+                  style={{ width: '100%' }}
+                  optionList={[
+                    { label: t('不结转'), value: 'none' },
+                    { label: t('结转剩余额度'), value: 'carry' },
+                  ]}
+                />
+              </Col>
+              <Col span={12}>
+                <Form.InputNumber
+                  field='rollover_cap'
+                  label={t('结转后额度上限')}
+                  extraText={t('0 表示不限制')}
+                  min={0}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col span={12}>
+                <Form.Input field='stripe_price_id' label='Stripe Price ID' />
+              </Col>
+              <Col span={12}>
+                <Form.Input field='creem_product_id' label='Creem Product ID' />
+              </Col>
+            </Row>
+            <Form.Switch field='enabled' label={t('启用')} />
+          </Form>
+        )}
+      </Modal>
+    </>
+  );
+}
@@ -94,6 +94,8 @@ export default function SettingsSidebarModulesUser() {
       defaultConfig.personal = {
         enabled: true,
         topup: isSidebarModuleAllowed('personal', 'topup'),
+        organization: isSidebarModuleAllowed('personal', 'organization'),
+        statement: isSidebarModuleAllowed('personal', 'statement'),
         personal: isSidebarModuleAllowed('personal', 'personal'),
       };
     }
@@ -325,6 +327,16 @@ export default function SettingsSidebarModulesUser() {
       description: t('用户个人功能'),
       modules: [
         { key: 'topup', title: t('钱包管理'), description: t('余额充值管理') },
+        {
+          key: 'organization',
+          title: t('组织管理'),
+          description: t('组织成员与共享额度管理'),
+        },
+        {
+          key: 'statement',
+          title: t('账单'),
+          description: t('月度账单查询与下载'),
+        },
         {
           key: 'personal',
           title: t('个人设置'),
@@ -47,6 +47,7 @@ export default function ModelRatioSettings(props) {
     ImageRatio: '',
     AudioRatio: '',
     AudioCompletionRatio: '',
+    PricingRules: '',
     ExposeRatioEnabled: false,
   });
   const refForm = useRef();
@@ -294,6 +295,32 @@ export default function ModelRatioSettings(props) {
             />
           </Col>
         </Row>
+        <Row gutter={16}>
+          <Col xs={24} sm={16}>
+            <Form.TextArea
+              label={t('计价规则')}
+              extraText={t(
+                '按顺序匹配第一条满足条件的规则，在模型倍率与价格的基础上乘以输入、输出倍数。models 支持以 * 结尾的前缀匹配，输入 tokens 区间为 (min_prompt_tokens, max_prompt_tokens]，包含缓存读取与写入的 tokens，start_time 与 end_time 格式为 HH:MM，可跨越零点，未填写的条件不限制',
+              )}
+              placeholder={t(
+                '为一个 JSON 数组，例如：[{"name": "long-context", "models": ["gemini-2.5-pro*"], "min_prompt_tokens": 200000, "input_multiplier": 2, "output_multiplier": 1.5}, {"name": "night", "models": ["deepseek-*"], "start_time": "00:30", "end_time": "08:30", "timezone": "Asia/Shanghai", "input_multiplier": 0.5, "output_multiplier": 0.5}]',
+              )}
+              field={'PricingRules'}
+              autosize={{ minRows: 6, maxRows: 12 }}
+              trigger='blur'
+              stopValidateWithError
+              rules={[
+                {
+                  validator: (rule, value) => verifyJSON(value),
+                  message: '不是合法的 JSON 字符串',
+                },
+              ]}
+              onChange={(value) =>
+                setInputs({ ...inputs, PricingRules: value })
+              }
+            />
+          </Col>
+        </Row>
         <Row gutter={16}>
           <Col span={16}>
             <Form.Switch
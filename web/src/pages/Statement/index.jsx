@@ -0,0 +1,576 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import dayjs from 'dayjs';
+import {
+  Button,
+  Card,
+  DatePicker,
+  Descriptions,
+  Input,
+  InputNumber,
+  Modal,
+  Popconfirm,
+  Select,
+  Space,
+  Table,
+  Tabs,
+  TabPane,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import {
+  API,
+  isAdmin,
+  isRoot,
+  renderQuota,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../helpers';
+
+const { Text } = Typography;
+
+const SCOPE_LABELS = {
+  user: '用户',
+  organization: '组织',
+};
+
+const lastMonth = () => dayjs().subtract(1, 'month').format('YYYY-MM');
+
+// 以 blob 方式下载账单，接口出错时返回的是 JSON
+export const downloadStatement = async (url, filename) => {
+  const res = await API.get(url, { responseType: 'blob' });
+  if (res.data.type === 'application/json') {
+    const { message } = JSON.parse(await res.data.text());
+    showError(message);
+    return;
+  }
+  const link = document.createElement('a');
+  link.href = URL.createObjectURL(res.data);
+  link.download = filename;
+  link.click();
+  URL.revokeObjectURL(link.href);
+};
+
+const StatementItems = ({ t, items, nameTitle }) => (
+  <Table
+    columns={[
+      { title: nameTitle, dataIndex: 'name' },
+      { title: t('请求次数'), dataIndex: 'requests' },
+      { title: t('输入 Tokens'), dataIndex: 'prompt_tokens' },
+      { title: t('输出 Tokens'), dataIndex: 'completion_tokens' },
+      {
+        title: t('消费'),
+        dataIndex: 'quota',
+        render: (v) => renderQuota(v, 6),
+      },
+    ]}
+    dataSource={items || []}
+    rowKey='name'
+    pagination={false}
+  />
+);
+
+// 账单详情：按月查看汇总、按模型/令牌/日期的消费与充值明细，可下载 CSV 或 PDF
+// basePath 为账单接口前缀，个人账单为 /api/statement/self，组织账单为 /api/organization/:id/statement
+export const StatementView = ({ t, basePath, filePrefix }) => {
+  const [period, setPeriod] = useState(lastMonth());
+  const [data, setData] = useState(null);
+
+  const load = async () => {
+    const res = await API.get(`${basePath}/${period}`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setData(data);
+    } else {
+      setData(null);
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    load();
+  }, [basePath, period]);
+
+  const download = (format) =>
+    downloadStatement(
+      `${basePath}/${period}/download?format=${format}`,
+      `${filePrefix}-${period}.${format}`,
+    );
+
+  const statement = data?.statement;
+  const detail = data?.detail;
+
+  return (
+    <>
+      <Space className='mb-2'>
+        <DatePicker
+          type='month'
+          value={period}
+          onChange={(v) => v && setPeriod(dayjs(v).format('YYYY-MM'))}
+          disabledDate={(date) => dayjs(date).isAfter(dayjs(), 'month')}
+        />
+        <Button onClick={() => download('csv')} disabled={!statement}>
+          {t('下载 CSV')}
+        </Button>
+        <Button onClick={() => download('pdf')} disabled={!statement}>
+          {t('下载 PDF')}
+        </Button>
+        {statement?.locked && <Tag color='grey'>{t('账期已锁定')}</Tag>}
+      </Space>
+      {statement && (
+        <>
+          <Descriptions
+            row
+            data={[
+              { key: t('请求次数'), value: statement.request_count },
+              {
+                key: t('消费'),
+                value: renderQuota(statement.consume_quota, 6),
+              },
+              {
+                key: t('退款'),
+                value: renderQuota(statement.refund_quota, 6),
+              },
+              {
+                key: t('充值'),
+                value: renderQuota(statement.top_up_quota, 6),
+              },
+              {
+                key: t('转入组织'),
+                value: renderQuota(statement.transfer_quota || 0, 6),
+              },
+              {
+                key: t('净变化'),
+                value: renderQuota(
+                  statement.top_up_quota +
+                    statement.refund_quota -
+                    statement.consume_quota -
+                    (statement.transfer_quota || 0),
+                  6,
+                ),
+              },
+              {
+                key: t('生成时间'),
+                value: timestamp2string(statement.generated_time),
+              },
+            ]}
+          />
+          <Tabs className='mt-2'>
+            <TabPane tab={t('按模型')} itemKey='model'>
+              <StatementItems
+                t={t}
+                items={detail?.by_model}
+                nameTitle={t('模型')}
+              />
+            </TabPane>
+            <TabPane tab={t('按令牌')} itemKey='token'>
+              <StatementItems
+                t={t}
+                items={detail?.by_token}
+                nameTitle={t('令牌')}
+              />
+            </TabPane>
+            <TabPane tab={t('按日期')} itemKey='day'>
+              <StatementItems
+                t={t}
+                items={detail?.by_day}
+                nameTitle={t('日期')}
+              />
+            </TabPane>
+            <TabPane tab={t('充值明细')} itemKey='topup'>
+              <Table
+                columns={[
+                  {
+                    title: t('时间'),
+                    dataIndex: 'time',
+                    render: (v) => timestamp2string(v),
+                  },
+                  {
+                    title: t('订单号 / 备注'),
+                    dataIndex: 'trade_no',
+                    render: (v, record) => v || record.content,
+                  },
+                  { title: t('支付方式'), dataIndex: 'payment_method' },
+                  { title: t('支付金额'), dataIndex: 'money' },
+                  {
+                    title: t('入账额度'),
+                    dataIndex: 'quota',
+                    render: (v) => renderQuota(v, 6),
+                  },
+                ]}
+                dataSource={detail?.top_ups || []}
+                rowKey={(record) =>
+                  `${record.time}-${record.trade_no}-${record.content}`
+                }
+                pagination={false}
+              />
+            </TabPane>
+            {detail?.transfers?.length > 0 && (
+              <TabPane tab={t('转入组织')} itemKey='transfer'>
+                <Table
+                  columns={[
+                    {
+                      title: t('时间'),
+                      dataIndex: 'time',
+                      render: (v) => timestamp2string(v),
+                    },
+                    { title: t('备注'), dataIndex: 'content' },
+                    {
+                      title: t('转出额度'),
+                      dataIndex: 'quota',
+                      render: (v) => renderQuota(v, 6),
+                    },
+                  ]}
+                  dataSource={detail.transfers}
+                  rowKey={(record) => `${record.time}-${record.content}`}
+                  pagination={false}
+                />
+              </TabPane>
+            )}
+          </Tabs>
+        </>
+      )}
+    </>
+  );
+};
+
+// 管理员视图：查询已生成的账单、重新生成账单与锁定账期
+const AdminStatements = ({ t }) => {
+  const [statements, setStatements] = useState([]);
+  const [total, setTotal] = useState(0);
+  const [page, setPage] = useState(1);
+  const [scope, setScope] = useState('');
+  const [scopeId, setScopeId] = useState('');
+  const [period, setPeriod] = useState('');
+  const [locks, setLocks] = useState([]);
+  const [lockPeriod, setLockPeriod] = useState(lastMonth());
+  const [regenerating, setRegenerating] = useState(null);
+
+  const load = async (p = page) => {
+    const res = await API.get(
+      `/api/statement/?p=${p}&page_size=10&scope=${scope}&scope_id=${scopeId || 0}&period=${period}`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setStatements(data.items || []);
+      setTotal(data.total);
+    } else {
+      showError(message);
+    }
+  };
+
+  const loadLocks = async () => {
+    const res = await API.get('/api/statement/lock');
+    if (res.data.success) {
+      setLocks(res.data.data || []);
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  useEffect(() => {
+    load(1);
+    loadLocks();
+  }, []);
+
+  const regenerate = async () => {
+    const res = await API.post('/api/statement/regenerate', {
+      scope: regenerating.scope,
+      scope_id: parseInt(regenerating.scope_id),
+      period: regenerating.period,
+    });
+    if (res.data.success) {
+      showSuccess(t('账单已重新生成'));
+      setRegenerating(null);
+      load();
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  const lock = async () => {
+    const res = await API.post('/api/statement/lock', { period: lockPeriod });
+    if (res.data.success) {
+      showSuccess(t('账期已锁定'));
+      loadLocks();
+      load();
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  const unlock = async (lockItem) => {
+    const res = await API.delete(`/api/statement/lock/${lockItem.period}`);
+    if (res.data.success) {
+      loadLocks();
+      load();
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  const download = (record, format) =>
+    downloadStatement(
+      `/api/statement/${record.id}/download?format=${format}`,
+      `statement-${record.scope}-${record.scope_id}-${record.period}.${format}`,
+    );
+
+  const columns = [
+    { title: 'ID', dataIndex: 'id' },
+    {
+      title: t('对象'),
+      dataIndex: 'scope',
+      render: (v, record) => `${t(SCOPE_LABELS[v])} #${record.scope_id}`,
+    },
+    { title: t('账期'), dataIndex: 'period' },
+    {
+      title: t('消费'),
+      dataIndex: 'consume_quota',
+      render: (v) => renderQuota(v, 6),
+    },
+    {
+      title: t('退款'),
+      dataIndex: 'refund_quota',
+      render: (v) => renderQuota(v, 6),
+    },
+    {
+      title: t('充值'),
+      dataIndex: 'top_up_quota',
+      render: (v) => renderQuota(v, 6),
+    },
+    {
+      title: t('生成时间'),
+      dataIndex: 'generated_time',
+      render: (v) => timestamp2string(v),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, record) => (
+        <Space>
+          <Button
+            size='small'
+            disabled={record.locked}
+            onClick={() =>
+              setRegenerating({
+                scope: record.scope,
+                scope_id: record.scope_id,
+                period: record.period,
+              })
+            }
+          >
+            {t('重新生成')}
+          </Button>
+          <Button size='small' onClick={() => download(record, 'csv')}>
+            CSV
+          </Button>
+          <Button size='small' onClick={() => download(record, 'pdf')}>
+            PDF
+          </Button>
+        </Space>
+      ),
+    },
+  ];
+
+  return (
+    <>
+      <Card
+        className='!rounded-2xl shadow-sm border-0 mt-4'
+        title={t('账单管理')}
+        headerExtraContent={
+          <Space>
+            <Select
+              value={scope}
+              onChange={setScope}
+              style={{ width: 120 }}
+              optionList={[
+                { label: t('全部'), value: '' },
+                { label: t('用户'), value: 'user' },
+                { label: t('组织'), value: 'organization' },
+              ]}
+            />
+            <Input
+              style={{ width: 120 }}
+              placeholder={t('对象 ID')}
+              value={scopeId}
+              onChange={setScopeId}
+            />
+            <Input
+              style={{ width: 120 }}
+              placeholder='YYYY-MM'
+              value={period}
+              onChange={setPeriod}
+            />
+            <Button
+              onClick={() => {
+                setPage(1);
+                load(1);
+              }}
+            >
+              {t('查询')}
+            </Button>
+            <Button
+              theme='solid'
+              onClick={() =>
+                setRegenerating({
+                  scope: 'user',
+                  scope_id: '',
+                  period: lastMonth(),
+                })
+              }
+            >
+              {t('生成账单')}
+            </Button>
+          </Space>
+        }
+      >
+        <Table
+          columns={columns}
+          dataSource={statements}
+          rowKey='id'
+          pagination={{
+            currentPage: page,
+            pageSize: 10,
+            total,
+            onPageChange: (p) => {
+              setPage(p);
+              load(p);
+            },
+          }}
+        />
+      </Card>
+      <Card
+        className='!rounded-2xl shadow-sm border-0 mt-4'
+        title={t('账期锁定')}
+        headerExtraContent={
+          <Space>
+            <Input
+              style={{ width: 120 }}
+              placeholder='YYYY-MM'
+              value={lockPeriod}
+              onChange={setLockPeriod}
+            />
+            <Popconfirm
+              title={t('锁定时将为该账期内有记录的用户与组织生成最终账单，锁定后不能再重新生成，确定锁定？')}
+              onConfirm={lock}
+            >
+              <Button theme='solid'>{t('锁定账期')}</Button>
+            </Popconfirm>
+          </Space>
+        }
+      >
+        <Table
+          columns={[
+            { title: t('账期'), dataIndex: 'period' },
+            { title: t('操作人 ID'), dataIndex: 'locked_by' },
+            {
+              title: t('锁定时间'),
+              dataIndex: 'locked_time',
+              render: (v) => timestamp2string(v),
+            },
+            {
+              title: '',
+              dataIndex: 'operate',
+              render: (_, record) =>
+                isRoot() && (
+                  <Popconfirm
+                    title={t('确定解除该账期的锁定？')}
+                    onConfirm={() => unlock(record)}
+                  >
+                    <Button size='small' type='danger'>
+                      {t('解除锁定')}
+                    </Button>
+                  </Popconfirm>
+                ),
+            },
+          ]}
+          dataSource={locks}
+          rowKey='id'
+          pagination={false}
+        />
+      </Card>
+      <Modal
+        title={t('生成账单')}
+        visible={!!regenerating}
+        onOk={regenerate}
+        onCancel={() => setRegenerating(null)}
+      >
+        {regenerating && (
+          <Space vertical align='start' style={{ width: '100%' }}>
+            <Select
+              style={{ width: '100%' }}
+              value={regenerating.scope}
+              onChange={(v) => setRegenerating({ ...regenerating, scope: v })}
+              optionList={[
+                { label: t('用户'), value: 'user' },
+                { label: t('组织'), value: 'organization' },
+              ]}
+            />
+            <InputNumber
+              style={{ width: '100%' }}
+              placeholder={t('对象 ID')}
+              min={1}
+              value={regenerating.scope_id}
+              onChange={(v) =>
+                setRegenerating({ ...regenerating, scope_id: v })
+              }
+            />
+            <Input
+              placeholder='YYYY-MM'
+              value={regenerating.period}
+              onChange={(v) => setRegenerating({ ...regenerating, period: v })}
+            />
+            <Text type='tertiary'>
+              {t('已锁定账期的账单不能重新生成')}
+            </Text>
+          </Space>
+        )}
+      </Modal>
+    </>
+  );
+};
+
+const Statement = () => {
+  const { t } = useTranslation();
+
+  return (
+    <div className='mt-[60px] px-2'>
+      <Card
+        className='!rounded-2xl shadow-sm border-0'
+        title={t('我的账单')}
+      >
+        <StatementView
+          t={t}
+          basePath='/api/statement/self'
+          filePrefix='statement'
+        />
+        <Text type='tertiary'>
+          {t('使用组织令牌产生的消费计入组织账单，可在组织管理中查看')}
+        </Text>
+      </Card>
+      {isAdmin() && <AdminStatements t={t} />}
+    </div>
+  );
+};
+
+export default Statement;